@@ -5,9 +5,18 @@ import (
 	"os"
 
 	"github.com/ifs21014-itdel/log-analyzer/config"
+	fwdin "github.com/ifs21014-itdel/log-analyzer/internal/delivery/forward"
+	gelfin "github.com/ifs21014-itdel/log-analyzer/internal/delivery/gelf"
 	"github.com/ifs21014-itdel/log-analyzer/internal/delivery/http"
+	syslogin "github.com/ifs21014-itdel/log-analyzer/internal/delivery/syslog"
+	"github.com/ifs21014-itdel/log-analyzer/internal/delivery/tail"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/notify"
 	repo "github.com/ifs21014-itdel/log-analyzer/internal/repository"
 	usecase "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/metrics"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/redis"
 	"github.com/joho/godotenv"
 )
 
@@ -29,15 +38,165 @@ func main() {
 		log.Fatal("db:", err)
 	}
 
+	// account emails and email notifications
+	mail, mailKind, err := config.Mailer()
+	if err != nil {
+		log.Fatal("mailer:", err)
+	}
+	log.Println("mailer:", mailKind)
+
 	// repo -> usecase -> handler
 	userRepo := repo.NewUserRepository(db)
-	authUC := usecase.NewAuthUsecase(userRepo)
+	defaultRole, err := config.DefaultRole()
+	if err != nil {
+		log.Fatal("roles:", err)
+	}
+	authCfg := usecase.AuthConfig{
+		AccessTTL:            config.AccessTokenTTL(),
+		RefreshTTL:           config.RefreshTokenTTL(),
+		AdminEmails:          config.AdminEmails(),
+		DefaultRole:          defaultRole,
+		TOTPSkew:             config.TOTPSkew(),
+		Login:                config.LoginPolicy(),
+		AppURL:               config.AppURL(),
+		PasswordResetURL:     config.PasswordResetURL(),
+		RequireVerifiedEmail: config.RequireVerifiedEmail(),
+	}
+	// failed login counters, in Postgres unless LOGIN_ATTEMPT_STORE=redis
+	loginAttempts := repo.NewLoginAttemptRepository(db)
+	redisCfg, redisEnabled, err := config.LoginAttemptRedis()
+	if err != nil {
+		log.Fatal("login attempts:", err)
+	}
+	if redisEnabled {
+		loginAttempts = repo.NewRedisLoginAttemptStore(redis.New(redisCfg))
+	}
+	orgRepo := repo.NewOrgRepository(db)
+	authUC := usecase.NewAuthUsecase(usecase.AuthStores{
+		Users:         userRepo,
+		Tokens:        repo.NewTokenRepository(db),
+		RecoveryCodes: repo.NewRecoveryCodeRepository(db),
+		Audit:         repo.NewAuditRepository(db),
+		LoginAttempts: loginAttempts,
+		UserTokens:    repo.NewUserTokenRepository(db),
+		Orgs:          orgRepo,
+		APIKeys:       repo.NewAPIKeyRepository(db),
+	}, mail, authCfg)
+	if err := authUC.PromoteAdmins(); err != nil {
+		log.Fatal("admins:", err)
+	}
+	authUC.Start()
+	jwt.SetDenylist(authUC)
+	jwt.SetAPIKeys(authUC)
 
 	logRepo := repo.NewLogAnalysisRepo(db)
-	logUC := usecase.NewLogAnalysisUsecase(logRepo)
+
+	// notifiers for alerts and scheduled reports; email unless the mailer only logs
+	notifyAllow, err := notify.NewAllowlist(config.NotifyAllowHosts())
+	if err != nil {
+		log.Fatal("NOTIFY_ALLOW_HOSTS:", err)
+	}
+	notifier := notify.NewDispatcher()
+	notifier.Register("webhook", notify.NewWebhook(config.NotifyWebhookSecret(), notifyAllow))
+	notifier.Register("slack", notify.NewSlack(notifyAllow))
+	if mailKind != "log" {
+		notifier.Register("email", notify.NewEmail(mail))
+	}
+
+	// alert rules, evaluated on uploads and live stream windows
+	alertUC := usecase.NewAlertUsecase(repo.NewAlertRepository(db), notifier)
+	alertUC.Start()
+
+	// parsed records for drill-down, kept RECORDS_RETENTION_DAYS days
+	recordUC := usecase.NewRecordUsecase(repo.NewRecordRepository(db, config.RecordsRetentionDays()), logRepo, config.RecordsRetain(), config.RecordsRetentionDays())
+	recordUC.StartRetention()
+
+	// per-service gauges for /metrics, from the analyses of the last METRICS_WINDOW
+	analysisMetrics := usecase.NewAnalysisMetrics(config.MetricsServiceLabel(), config.MetricsWindow(), config.MetricsMaxServices())
+	observers := usecase.Observers{alertUC, analysisMetrics}
+
+	logUC := usecase.NewLogAnalysisUsecase(logRepo, userRepo, recordUC, observers)
+
+	streamRepo := repo.NewStreamRepository(db)
+	ingestUC := usecase.NewIngestUsecase(streamRepo, logRepo, recordUC, observers)
+	ingestUC.Start()
+
+	metrics.Register(
+		metrics.DBStats(db),
+		metrics.GaugeFunc("log_analyzer_ingest_queue_depth", "Batches waiting for the ingest worker.",
+			func() float64 { return float64(ingestUC.QueueDepth()) }),
+		metrics.GaugeFunc("log_analyzer_alert_pending_evaluations", "Analyses waiting for alert rule evaluation.",
+			func() float64 { return float64(alertUC.PendingEvaluations()) }),
+		analysisMetrics,
+	)
+
+	// optional syslog receiver (UDP / TCP / TLS)
+	syslogCfg, syslogEnabled, err := syslogin.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("syslog:", err)
+	}
+	if syslogEnabled {
+		orgID, owner, err := ingestOwner(authUC)
+		if err != nil {
+			log.Fatal("syslog:", err)
+		}
+		if err := syslogin.NewServer(syslogCfg, ingestUC.NewCollector(orgID, owner, "Syslog", "raw")).Start(); err != nil {
+			log.Fatal("syslog:", err)
+		}
+	}
+
+	// optional Fluent Forward receiver (Fluentd / Fluent Bit)
+	fwdCfg, fwdEnabled, err := fwdin.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("forward:", err)
+	}
+	if fwdEnabled {
+		orgID, owner, err := ingestOwner(authUC)
+		if err != nil {
+			log.Fatal("forward:", err)
+		}
+		if err := fwdin.NewServer(fwdCfg, ingestUC.NewCollector(orgID, owner, "Forward", "raw")).Start(); err != nil {
+			log.Fatal("forward:", err)
+		}
+	}
+
+	// optional GELF listener (UDP / TCP)
+	gelfCfg, gelfEnabled, err := gelfin.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("gelf:", err)
+	}
+	if gelfEnabled {
+		orgID, owner, err := ingestOwner(authUC)
+		if err != nil {
+			log.Fatal("gelf:", err)
+		}
+		if err := gelfin.NewServer(gelfCfg, ingestUC.NewCollector(orgID, owner, "GELF", "raw")).Start(); err != nil {
+			log.Fatal("gelf:", err)
+		}
+	}
+
+	// optional tail mode: follow local log files
+	tailCfg, tailEnabled, err := tail.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("tail:", err)
+	}
+	if tailEnabled {
+		orgID, owner, err := ingestOwner(authUC)
+		if err != nil {
+			log.Fatal("tail:", err)
+		}
+		collector := ingestUC.NewCollector(orgID, owner, "Tail", tailCfg.Format)
+		if err := tail.NewWatcher(tailCfg, collector, repo.NewCheckpointRepository(db)).Start(); err != nil {
+			log.Fatal("tail:", err)
+		}
+	}
+
+	// saved queries and their cron schedules
+	reportUC := usecase.NewReportUsecase(repo.NewSavedQueryRepository(db), orgRepo, recordUC, logUC, notifier)
+	reportUC.StartScheduler()
 
 	// router
-	r := http.NewRouter(authUC, logUC)
+	r := http.NewRouter(authUC, logUC, ingestUC, recordUC, reportUC, alertUC)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -46,3 +205,22 @@ func main() {
 	log.Println("listen on :", port)
 	r.Run(":" + port)
 }
+
+// ingestOwner is INGEST_USER_ID in their active org at startup; collected
+// logs go there. The user and their role in that org are re-read whenever a
+// listener creates a stream.
+func ingestOwner(authUC *usecase.AuthUsecase) (uint, usecase.OwnerFunc, error) {
+	ownerID, err := config.IngestUserID()
+	if err != nil {
+		return 0, nil, err
+	}
+	actor, err := authUC.OwnerActor(ownerID)
+	if err != nil {
+		return 0, nil, err
+	}
+	owner := func() (domain.Actor, error) { return authUC.IngestOwner(actor.UserID, actor.OrgID) }
+	if _, err := owner(); err != nil {
+		return 0, nil, err
+	}
+	return actor.OrgID, owner, nil
+}
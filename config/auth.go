@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// AccessTokenTTL is how long a JWT access token is valid, default 15m.
+func AccessTokenTTL() time.Duration {
+	return durationEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL is how long a refresh token can be used, default 720h
+// (30 days). Every refresh starts the period again.
+func RefreshTokenTTL() time.Duration {
+	return durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// AdminEmails lists the users (ADMIN_EMAILS, comma separated) given the admin
+// role at startup and when they register, so there is always a first admin.
+func AdminEmails() []string {
+	var emails []string
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// DefaultRole is the role of newly registered users (DEFAULT_ROLE: admin,
+// analyst or viewer), default analyst.
+func DefaultRole() (string, error) {
+	role := os.Getenv("DEFAULT_ROLE")
+	if role == "" {
+		return domain.RoleAnalyst, nil
+	}
+	if !domain.ValidRole(role) {
+		return "", errors.New("DEFAULT_ROLE must be admin, analyst or viewer")
+	}
+	return role, nil
+}
+
+// TOTPSkew is how many 30s steps before and after now a TOTP code may come
+// from, for clock drift (TOTP_SKEW, default 1, max 10).
+func TOTPSkew() uint {
+	n, err := strconv.Atoi(os.Getenv("TOTP_SKEW"))
+	if err != nil || n < 0 || n > 10 {
+		return 1
+	}
+	return uint(n)
+}
+
+// AppURL is the public base URL of the server, for links in emails
+// (APP_URL, default http://localhost:$PORT).
+func AppURL() string {
+	if v := os.Getenv("APP_URL"); v != "" {
+		return v
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// PasswordResetURL is an optional frontend page for reset links.
+func PasswordResetURL() string {
+	return os.Getenv("PASSWORD_RESET_URL")
+}
+
+// RequireVerifiedEmail blocks login until the email is verified
+// (REQUIRE_EMAIL_VERIFIED=true).
+func RequireVerifiedEmail() bool {
+	v, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFIED"))
+	return v
+}
+
+func durationEnv(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// IngestUserID is the owner of streams fed by network listeners (syslog,
+// file tail, ...), which have no JWT to identify a user.
+func IngestUserID() (uint, error) {
+	raw := os.Getenv("INGEST_USER_ID")
+	if raw == "" {
+		return 0, errors.New("INGEST_USER_ID must be set to enable log listeners")
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		return 0, errors.New("INGEST_USER_ID must be a user id")
+	}
+	return uint(id), nil
+}
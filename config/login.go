@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/redis"
+)
+
+// LoginPolicy reads the LOGIN_* brute-force settings. A lockout threshold
+// of 0 turns that lockout off.
+func LoginPolicy() usecase.LoginPolicy {
+	return usecase.LoginPolicy{
+		FreeAttempts:    intEnv("LOGIN_FREE_ATTEMPTS", 3),
+		DelayBase:       durationEnv("LOGIN_DELAY_BASE", time.Second),
+		DelayMax:        durationEnv("LOGIN_DELAY_MAX", time.Minute),
+		AccountLockout:  intEnv("LOGIN_LOCKOUT_THRESHOLD", 10),
+		IPLockout:       intEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LockoutDuration: durationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		Window:          durationEnv("LOGIN_ATTEMPT_WINDOW", time.Hour),
+	}
+}
+
+// LoginAttemptRedis reads the Redis settings when LOGIN_ATTEMPT_STORE=redis;
+// enabled is false for the default Postgres store.
+func LoginAttemptRedis() (cfg redis.Config, enabled bool, err error) {
+	switch os.Getenv("LOGIN_ATTEMPT_STORE") {
+	case "", "postgres":
+		return cfg, false, nil
+	case "redis":
+	default:
+		return cfg, false, errors.New("LOGIN_ATTEMPT_STORE must be postgres or redis")
+	}
+	cfg.Addr = os.Getenv("REDIS_ADDR")
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:6379"
+	}
+	cfg.Password = os.Getenv("REDIS_PASSWORD")
+	if raw := os.Getenv("REDIS_DB"); raw != "" {
+		if cfg.DB, err = strconv.Atoi(raw); err != nil || cfg.DB < 0 {
+			return cfg, false, errors.New("REDIS_DB must be a database number")
+		}
+	}
+	return cfg, true, nil
+}
+
+// intEnv reads a non-negative integer, def when unset or invalid.
+func intEnv(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/pkg/mailer"
+)
+
+// SMTP reads SMTP_* variables; enabled is false without SMTP_HOST.
+func SMTP() (cfg mailer.SMTPConfig, enabled bool, err error) {
+	cfg = mailer.SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		TLS:      os.Getenv("SMTP_TLS"),
+	}
+	if cfg.Host == "" {
+		return cfg, false, nil
+	}
+	if raw := os.Getenv("SMTP_PORT"); raw != "" {
+		if cfg.Port, err = strconv.Atoi(raw); err != nil || cfg.Port <= 0 {
+			return cfg, false, errors.New("SMTP_PORT must be a port number")
+		}
+	}
+	if cfg.From == "" {
+		return cfg, false, errors.New("SMTP_FROM must be set when SMTP_HOST is")
+	}
+	return cfg, true, nil
+}
+
+// Mailer picks how account emails (verification, password reset) and email
+// notifications are sent: MAILER=smtp, file (MAILER_DIR, default "mail")
+// or log. Without MAILER it is smtp when SMTP_HOST is set, else log.
+func Mailer() (m mailer.Mailer, kind string, err error) {
+	kind = os.Getenv("MAILER")
+	if kind == "" {
+		kind = "log"
+		if os.Getenv("SMTP_HOST") != "" {
+			kind = "smtp"
+		}
+	}
+	switch kind {
+	case "smtp":
+		cfg, enabled, err := SMTP()
+		if err != nil {
+			return nil, kind, err
+		}
+		if !enabled {
+			return nil, kind, errors.New("SMTP_HOST must be set with MAILER=smtp")
+		}
+		smtp, err := mailer.NewSMTP(cfg)
+		return smtp, kind, err
+	case "file":
+		dir := os.Getenv("MAILER_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "log-analyzer@localhost"
+		}
+		f, err := mailer.NewFile(dir, from)
+		return f, kind, err
+	case "log":
+		return mailer.Log{}, kind, nil
+	}
+	return nil, kind, errors.New("MAILER must be smtp, file or log")
+}
+
+// NotifyWebhookSecret signs webhook notifications when set.
+func NotifyWebhookSecret() string {
+	return os.Getenv("NOTIFY_WEBHOOK_SECRET")
+}
+
+// NotifyAllowHosts lists the non-public IPs, CIDRs or host names webhook and
+// Slack targets may reach (NOTIFY_ALLOW_HOSTS, comma separated).
+func NotifyAllowHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("NOTIFY_ALLOW_HOSTS"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMetricsWindow      = 15 * time.Minute
+	defaultMetricsMaxServices = 50
+)
+
+// MetricsToken protects /metrics with "Authorization: Bearer <token>" when set.
+func MetricsToken() string {
+	return os.Getenv("METRICS_TOKEN")
+}
+
+// MetricsServiceLabel is the analysis label the per-service gauges group
+// by, default "service".
+func MetricsServiceLabel() string {
+	if v := os.Getenv("METRICS_SERVICE_LABEL"); v != "" {
+		return v
+	}
+	return "service"
+}
+
+// MetricsWindow is how far back analyses count towards the per-service
+// gauges, default 15m.
+func MetricsWindow() time.Duration {
+	return durationEnv("METRICS_WINDOW", defaultMetricsWindow)
+}
+
+// MetricsMaxServices caps the service label values exported; the rest are
+// folded into service="_other". Default 50.
+func MetricsMaxServices() int {
+	n, err := strconv.Atoi(os.Getenv("METRICS_MAX_SERVICES"))
+	if err != nil || n <= 0 {
+		return defaultMetricsMaxServices
+	}
+	return n
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const defaultRecordsRetentionDays = 7
+
+// RecordsRetain reports whether parsed records are kept in log_records for
+// drill-down queries (RECORDS_RETAIN=true). Uploads can still opt in or
+// out per file.
+func RecordsRetain() bool {
+	v, _ := strconv.ParseBool(os.Getenv("RECORDS_RETAIN"))
+	return v
+}
+
+// RecordsRetentionDays is how long retained records are kept, default 7.
+func RecordsRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("RECORDS_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultRecordsRetentionDays
+	}
+	return days
+}
@@ -0,0 +1,268 @@
+// Package forward receives logs from Fluentd and Fluent Bit over the
+// Forward protocol (msgpack over TCP, optionally TLS) and feeds them into
+// the ingest pipeline, one stream per tag.
+package forward
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/forward"
+)
+
+const (
+	defaultStream    = "fluent"
+	idleTimeout      = 5 * time.Minute
+	handshakeTimeout = 10 * time.Second
+)
+
+// record keys that hold the log line, in order of preference
+var lineKeys = []string{"log", "message", "msg"}
+
+// Config of the listener. Without SharedKey any peer that can reach Addr
+// may write into the ingest owner's streams, so only leave it empty on a
+// trusted network.
+type Config struct {
+	Addr      string
+	TLSCert   string
+	TLSKey    string
+	SharedKey string // shared_key of the sender's <security> section
+	Hostname  string // self_hostname sent back in PONG
+}
+
+// ConfigFromEnv reads FORWARD_* variables; enabled is false without
+// FORWARD_ADDR.
+func ConfigFromEnv() (cfg Config, enabled bool, err error) {
+	cfg = Config{
+		Addr:      os.Getenv("FORWARD_ADDR"),
+		TLSCert:   os.Getenv("FORWARD_TLS_CERT"),
+		TLSKey:    os.Getenv("FORWARD_TLS_KEY"),
+		SharedKey: os.Getenv("FORWARD_SHARED_KEY"),
+		Hostname:  os.Getenv("FORWARD_SELF_HOSTNAME"),
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return cfg, false, errors.New("FORWARD_TLS_CERT and FORWARD_TLS_KEY must be set together")
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	return cfg, cfg.Addr != "", nil
+}
+
+type Server struct {
+	cfg       Config
+	collector *uc.Collector
+}
+
+func NewServer(cfg Config, collector *uc.Collector) *Server {
+	return &Server{cfg: cfg, collector: collector}
+}
+
+// Start opens the listener and serves it in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	proto := "tcp"
+	if s.cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.TLSCert, s.cfg.TLSKey)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+		proto = "tcp+tls"
+	}
+	if s.cfg.SharedKey == "" {
+		log.Println("[Forward] ⚠️ no FORWARD_SHARED_KEY: any peer can send logs")
+	}
+	log.Println("[Forward] listening on", proto, s.cfg.Addr)
+	go s.serve(ln)
+	return nil
+}
+
+func (s *Server) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Println("[Forward] accept:", err)
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	dec := forward.NewDecoder(conn)
+	if s.cfg.SharedKey != "" {
+		if err := s.handshake(conn, dec); err != nil {
+			log.Printf("[Forward] %s: handshake: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		m, err := forward.Read(dec)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("[Forward] %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if m.Chunk == "" {
+			s.handle(m)
+			continue
+		}
+		// ack only once the records are queued; without an ack the sender
+		// retries the chunk
+		if err := s.handleNow(m); err != nil {
+			log.Printf("[Forward] %s: chunk not acked: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		if _, err := conn.Write(forward.Ack(m.Chunk)); err != nil {
+			log.Printf("[Forward] %s: ack: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handshake runs HELO/PING/PONG and checks the peer knows the shared key.
+func (s *Server) handshake(conn net.Conn, dec *forward.Decoder) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if _, err := conn.Write(forward.Helo(nonce)); err != nil {
+		return err
+	}
+	ping, err := forward.ReadPing(dec)
+	if err != nil {
+		return err
+	}
+	if !ping.Verify(nonce, s.cfg.SharedKey) {
+		conn.Write(forward.Pong(false, "shared_key mismatch", "", ""))
+		return errors.New("shared_key mismatch from " + ping.Hostname)
+	}
+	digest := forward.Digest(ping.SharedKeySalt, s.cfg.Hostname, nonce, s.cfg.SharedKey)
+	_, err = conn.Write(forward.Pong(true, "", s.cfg.Hostname, digest))
+	return err
+}
+
+// streamName: one stream per tag
+func streamName(tag string) string {
+	if name := uc.SanitizeStreamName(tag); name != "" {
+		return name
+	}
+	return defaultStream
+}
+
+func (s *Server) handle(m *forward.Message) {
+	stream, parse, err := s.collector.Stream(streamName(m.Tag))
+	if err != nil {
+		log.Println("[Forward] ❌ stream:", err)
+		return
+	}
+	for _, e := range m.Entries {
+		rec, labels := toRecord(e, stream.Format, parse)
+		s.collector.Add(stream, labels, rec)
+	}
+}
+
+// handleNow queues the message's records at once, for messages that ask for
+// an ack.
+func (s *Server) handleNow(m *forward.Message) error {
+	stream, parse, err := s.collector.Stream(streamName(m.Tag))
+	if err != nil {
+		return err
+	}
+	records := make([]domain.LogRecord, len(m.Entries))
+	labels := make([]map[string]string, len(m.Entries))
+	for i, e := range m.Entries {
+		records[i], labels[i] = toRecord(e, stream.Format, parse)
+	}
+	return s.collector.PushNow(stream, records, labels)
+}
+
+// toRecord parses the log line with the stream's format (unless raw) and
+// otherwise maps the record fields like a JSON log. Top-level fields are
+// kept as attributes, and host/hostname becomes the host label.
+func toRecord(e forward.Entry, format string, parse parser.Func) (domain.LogRecord, map[string]string) {
+	fields := make(map[string]interface{}, len(e.Record))
+	for k, v := range e.Record {
+		if b, ok := v.([]byte); ok {
+			v = string(b) // older Fluentd sends strings as bin
+		}
+		fields[k] = v
+	}
+
+	line, lineKey := "", ""
+	for _, k := range lineKeys {
+		if s, ok := fields[k].(string); ok && s != "" {
+			line, lineKey = s, k
+			break
+		}
+	}
+
+	rec := parser.FromFields(fields)
+	if line != "" && format != "raw" {
+		if parsed, ok := parse(line); ok {
+			rec = parsed
+		}
+	}
+	if rec.Time.IsZero() {
+		rec.Time = e.Time
+	}
+	if level, ok := fields["level"].(string); ok {
+		if l := parser.Level(level); l != "" {
+			rec.Level = l
+		}
+	}
+	rec.Message = line
+	if rec.Raw == "" {
+		rec.Raw = line
+	}
+
+	rec.Attributes = make(map[string]string, len(fields))
+	for k, v := range fields {
+		if k == lineKey {
+			continue
+		}
+		rec.Attributes[k] = attrString(v)
+	}
+
+	labels := map[string]string{}
+	for _, k := range []string{"host", "hostname"} {
+		if host, ok := fields[k].(string); ok && host != "" {
+			labels = uc.FilterLabels(map[string]string{"host": host})
+			break
+		}
+	}
+	return rec, labels
+}
+
+func attrString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
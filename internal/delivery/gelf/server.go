@@ -0,0 +1,210 @@
+// Package gelf receives GELF messages (Docker's gelf logging driver,
+// Graylog senders) over UDP and TCP and feeds them into the ingest pipeline.
+package gelf
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/gelf"
+)
+
+const (
+	defaultStream  = "gelf"
+	maxDatagram    = 65536
+	tcpIdleTimeout = 5 * time.Minute
+)
+
+type Config struct {
+	UDPAddr       string
+	TCPAddr       string
+	DefaultStream string
+}
+
+// ConfigFromEnv reads GELF_* variables; enabled is false when no listen
+// address is set.
+func ConfigFromEnv() (cfg Config, enabled bool, err error) {
+	cfg = Config{
+		UDPAddr:       os.Getenv("GELF_UDP_ADDR"),
+		TCPAddr:       os.Getenv("GELF_TCP_ADDR"),
+		DefaultStream: os.Getenv("GELF_DEFAULT_STREAM"),
+	}
+	if cfg.DefaultStream == "" {
+		cfg.DefaultStream = defaultStream
+	}
+	return cfg, cfg.UDPAddr != "" || cfg.TCPAddr != "", nil
+}
+
+type Server struct {
+	cfg       Config
+	collector *uc.Collector
+	chunks    *gelf.Assembler
+}
+
+func NewServer(cfg Config, collector *uc.Collector) *Server {
+	return &Server{cfg: cfg, collector: collector, chunks: gelf.NewAssembler()}
+}
+
+// Start opens the configured listeners and serves them in the background.
+func (s *Server) Start() error {
+	if s.cfg.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", s.cfg.UDPAddr)
+		if err != nil {
+			return err
+		}
+		log.Println("[GELF] listening on udp", s.cfg.UDPAddr)
+		go s.serveUDP(conn)
+	}
+	if s.cfg.TCPAddr != "" {
+		ln, err := net.Listen("tcp", s.cfg.TCPAddr)
+		if err != nil {
+			return err
+		}
+		log.Println("[GELF] listening on tcp", s.cfg.TCPAddr)
+		go s.serveTCP(ln)
+	}
+	return nil
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Println("[GELF] udp read:", err)
+			return
+		}
+		payload := buf[:n]
+		if gelf.IsChunked(payload) {
+			full, err := s.chunks.Add(payload, time.Now())
+			if err != nil {
+				log.Println("[GELF] SKIP:", err)
+				continue
+			}
+			if full == nil {
+				continue // waiting for more chunks
+			}
+			payload = full
+		}
+		s.handle(payload)
+	}
+}
+
+func (s *Server) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Println("[GELF] accept:", err)
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+	sc := gelf.NewScanner(conn)
+	for sc.Scan() {
+		if len(sc.Bytes()) > 0 {
+			s.handle(sc.Bytes())
+		}
+		conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("[GELF] %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func (s *Server) handle(payload []byte) {
+	m, err := gelf.Parse(payload)
+	if err != nil {
+		log.Println("[GELF] SKIP:", err)
+		return
+	}
+
+	// Docker's gelf driver sets _tag (log-opt tag, default the container id)
+	name := s.cfg.DefaultStream
+	if tag, ok := m.Fields["tag"].(string); ok {
+		if sanitized := uc.SanitizeStreamName(tag); sanitized != "" {
+			name = sanitized
+		}
+	}
+	stream, parse, err := s.collector.Stream(name)
+	if err != nil {
+		log.Println("[GELF] ❌ stream:", err)
+		return
+	}
+	rec := toRecord(m, stream.Format, parse)
+	s.collector.Add(stream, messageLabels(m), rec)
+}
+
+// messageLabels splits windows per host and, for Docker, per container
+func messageLabels(m *gelf.Message) map[string]string {
+	labels := make(map[string]string, 2)
+	if m.Host != "" {
+		labels["host"] = m.Host
+	}
+	if name, ok := m.Fields["container_name"].(string); ok && name != "" {
+		labels["container"] = name
+	}
+	return uc.FilterLabels(labels)
+}
+
+// toRecord parses short_message with the stream's format (unless raw) and
+// otherwise maps the additional fields like a JSON log. Additional fields
+// are kept as attributes without their "_" prefix.
+func toRecord(m *gelf.Message, format string, parse parser.Func) domain.LogRecord {
+	rec := parser.FromFields(m.Fields)
+	if format != "raw" {
+		if parsed, ok := parse(m.ShortMessage); ok {
+			rec = parsed
+		}
+	}
+	if rec.Time.IsZero() {
+		rec.Time = m.Timestamp
+	}
+	if m.Level >= 0 {
+		rec.Level = parser.SyslogLevel(m.Level)
+	}
+
+	rec.Message = m.ShortMessage
+	if rec.Message == "" {
+		rec.Message = m.FullMessage
+	}
+	if rec.Raw == "" {
+		rec.Raw = rec.Message
+	}
+
+	rec.Attributes = make(map[string]string, len(m.Fields)+2)
+	for k, v := range m.Fields {
+		rec.Attributes[k] = attrString(v)
+	}
+	if m.Host != "" {
+		rec.Attributes["host"] = m.Host
+	}
+	if m.FullMessage != "" && m.FullMessage != m.ShortMessage {
+		rec.Attributes["full_message"] = m.FullMessage
+	}
+	return rec
+}
+
+func attrString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
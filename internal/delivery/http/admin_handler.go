@@ -0,0 +1,143 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type AdminHandler struct {
+	uc *uc.AuthUsecase
+}
+
+func NewAdminHandler(rg *gin.RouterGroup, uc *uc.AuthUsecase) {
+	h := &AdminHandler{uc: uc}
+	protected := rg.Group("/admin")
+	protected.Use(jwt.AuthMiddleware(), jwt.RequireRole(domain.RoleAdmin))
+	protected.GET("/users", h.ListUsers)
+	protected.PUT("/users/:id/role", h.SetRole)
+	protected.POST("/users/:id/disable", h.Disable)
+	protected.POST("/users/:id/enable", h.Enable)
+	protected.POST("/users/:id/reset-2fa", h.ResetTOTP)
+	protected.POST("/unlock", h.Unlock)
+	protected.GET("/audit", h.AuditLog)
+}
+
+// GET /admin/users
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	users, err := h.uc.ListUsers(adminID.(uint))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+type roleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// PUT /admin/users/:id/role {"role": "viewer"}
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req roleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := h.uc.SetRole(adminID.(uint), uint(id), req.Role, c.ClientIP())
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// POST /admin/users/:id/disable blocks login and logs the user out everywhere
+func (h *AdminHandler) Disable(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// POST /admin/users/:id/enable
+func (h *AdminHandler) Enable(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c *gin.Context, disabled bool) {
+	adminID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+	u, err := h.uc.SetDisabled(adminID.(uint), uint(id), disabled, c.ClientIP())
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+type resetTOTPReq struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// POST /admin/users/:id/reset-2fa {"reason": "lost phone, ticket #123"}
+func (h *AdminHandler) ResetTOTP(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req resetTOTPReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.uc.ResetTOTP(adminID.(uint), uint(id), req.Reason, c.ClientIP()); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "2FA reset, user logged out everywhere"})
+}
+
+type unlockReq struct {
+	UserID uint   `json:"user_id"`
+	IP     string `json:"ip"`
+}
+
+// POST /admin/unlock {"user_id": 5} or {"ip": "203.0.113.7"} clears failed logins
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	var req unlockReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.uc.Unlock(adminID.(uint), req.UserID, req.IP, c.ClientIP()); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unlocked"})
+}
+
+// GET /admin/audit?user_id=&limit=
+func (h *AdminHandler) AuditLog(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	userID, _ := strconv.Atoi(c.Query("user_id"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	entries, err := h.uc.AuditLog(adminID.(uint), uint(userID), limit)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func respondAdminError(c *gin.Context, err error) {
+	if errors.Is(err, uc.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	respondError(c, http.StatusBadRequest, err)
+}
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type AlertHandler struct {
+	uc *uc.AlertUsecase
+}
+
+func NewAlertHandler(rg *gin.RouterGroup, uc *uc.AlertUsecase) {
+	h := &AlertHandler{uc: uc}
+	protected := rg.Group("/alerts")
+	protected.Use(jwt.AuthMiddleware())
+	protected.GET("/", h.GetAll)
+
+	protected.GET("/rules/", h.GetRules)
+	protected.POST("/rules/", canWrite(), h.CreateRule)
+	protected.GET("/rules/:id", h.GetRule)
+	protected.PUT("/rules/:id", canWrite(), h.UpdateRule)
+	protected.DELETE("/rules/:id", canWrite(), h.DeleteRule)
+	protected.POST("/rules/:id/test", canWrite(), h.TestRule)
+
+	protected.GET("/silences/", h.GetSilences)
+	protected.POST("/silences/", canWrite(), h.CreateSilence)
+	protected.DELETE("/silences/:id", canWrite(), h.DeleteSilence)
+}
+
+// GET /alerts?state=firing,pending
+func (h *AlertHandler) GetAll(c *gin.Context) {
+	user := actor(c)
+	var states []string
+	for _, item := range c.QueryArray("state") {
+		for _, s := range strings.Split(item, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				states = append(states, s)
+			}
+		}
+	}
+	alerts, err := h.uc.Alerts(user.OrgID, states)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// ===================== RULES =====================
+
+func (h *AlertHandler) GetRules(c *gin.Context) {
+	user := actor(c)
+	rules, err := h.uc.ListRules(user.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *AlertHandler) GetRule(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	rule, err := h.uc.GetRule(user.OrgID, uint(id))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+type alertRuleReq struct {
+	Name        string                `json:"name" binding:"required"`
+	Labels      map[string]string     `json:"labels"`
+	Expr        string                `json:"expr" binding:"required"`
+	MinRequests int                   `json:"min_requests"`
+	Notify      []domain.NotifyTarget `json:"notify"`
+	Enabled     *bool                 `json:"enabled"` // default true
+}
+
+func (req *alertRuleReq) rule(owner domain.Actor) *domain.AlertRule {
+	return &domain.AlertRule{
+		UserID:      owner.UserID,
+		OrgID:       owner.OrgID,
+		Name:        req.Name,
+		Labels:      req.Labels,
+		Expr:        req.Expr,
+		MinRequests: req.MinRequests,
+		Notify:      req.Notify,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+}
+
+// POST /alerts/rules {"name", "labels": {"service": "checkout"}, "expr": "error_rate > 5% for 10m", "notify": [...]}
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	user := actor(c)
+	var req alertRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := req.rule(user)
+	if err := h.uc.CreateRule(rule); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req alertRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := req.rule(user)
+	rule.ID = uint(id)
+	if err := h.uc.UpdateRule(rule); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.uc.DeleteRule(user.OrgID, uint(id)); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// POST /alerts/rules/:id/test sends a test message to the rule's notifiers
+func (h *AlertHandler) TestRule(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	failed, err := h.uc.TestRule(user.OrgID, uint(id))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(failed) > 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "some notifiers failed", "failed": failed})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "sent"})
+}
+
+// ===================== SILENCES =====================
+
+func (h *AlertHandler) GetSilences(c *gin.Context) {
+	user := actor(c)
+	silences, err := h.uc.ListSilences(user.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, silences)
+}
+
+type silenceReq struct {
+	RuleID   uint              `json:"rule_id"`
+	Labels   map[string]string `json:"labels"`
+	Comment  string            `json:"comment"`
+	StartsAt *time.Time        `json:"starts_at"` // default now
+	EndsAt   *time.Time        `json:"ends_at"`
+	Duration string            `json:"duration"` // e.g. "2h", instead of ends_at
+}
+
+// POST /alerts/silences {"labels": {"service": "checkout"}, "duration": "2h", "comment": "deploy"}
+func (h *AlertHandler) CreateSilence(c *gin.Context) {
+	user := actor(c)
+	var req silenceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := &domain.AlertSilence{UserID: user.UserID, OrgID: user.OrgID, RuleID: req.RuleID, Labels: req.Labels, Comment: req.Comment}
+	s.StartsAt = time.Now()
+	if req.StartsAt != nil {
+		s.StartsAt = *req.StartsAt
+	}
+	switch {
+	case req.EndsAt != nil:
+		s.EndsAt = *req.EndsAt
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duration " + strconv.Quote(req.Duration)})
+			return
+		}
+		s.EndsAt = s.StartsAt.Add(d)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at or duration is required"})
+		return
+	}
+
+	if err := h.uc.CreateSilence(s); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+func (h *AlertHandler) DeleteSilence(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.uc.DeleteSilence(user.OrgID, uint(id)); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type APIKeyHandler struct {
+	uc *uc.AuthUsecase
+}
+
+// NewAPIKeyHandler: keys are managed with a login session only, so a key
+// cannot mint a stronger one.
+func NewAPIKeyHandler(rg *gin.RouterGroup, uc *uc.AuthUsecase) {
+	h := &APIKeyHandler{uc: uc}
+	protected := rg.Group("/api-keys")
+	protected.Use(jwt.AuthMiddleware(), jwt.SessionOnly())
+	protected.GET("/", h.List)
+	protected.POST("/", h.Create)
+	protected.DELETE("/:id", h.Revoke)
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.uc.ListAPIKeys(actor(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+type apiKeyReq struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// POST /api-keys {"name", "scopes": ["upload"]} — the key works in the
+// active org and is shown only in this response
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req apiKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := actor(c)
+	k, key, err := h.uc.CreateAPIKey(user.UserID, user.OrgID, req.Name, req.Scopes, c.ClientIP())
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": key})
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.uc.RevokeAPIKey(actor(c).UserID, uint(id), c.ClientIP()); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
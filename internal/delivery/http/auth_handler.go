@@ -1,12 +1,15 @@
 package http
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
 )
 
 type AuthHandler struct {
@@ -17,8 +20,24 @@ func NewAuthHandler(rg *gin.RouterGroup, uc *uc.AuthUsecase) {
 	h := &AuthHandler{uc: uc}
 	rg.POST("/register", h.Register)
 	rg.POST("/login", h.Login)
-	rg.POST("/totp/setup/:id", h.SetupTOTP)   // id: user id (for demo)
-	rg.POST("/totp/verify/:id", h.VerifyTOTP) // verify and enable
+	rg.POST("/token/refresh", h.Refresh)
+	rg.POST("/password/forgot", h.ForgotPassword)
+	rg.POST("/password/reset", h.ResetPassword)
+	rg.GET("/email/verify", h.VerifyEmail) // link in the email
+	rg.POST("/email/verify", h.VerifyEmail)
+	rg.POST("/email/resend", h.ResendVerification)
+
+	protected := rg.Group("")
+	protected.Use(jwt.AuthMiddleware(), jwt.SessionOnly())
+	protected.POST("/logout", h.Logout)
+	protected.POST("/logout-all", h.LogoutAll)
+
+	// 2FA of the logged-in user
+	protected.POST("/totp/setup", h.SetupTOTP)
+	protected.POST("/totp/verify", h.VerifyTOTP) // verify and enable
+	protected.POST("/totp/disable", h.DisableTOTP)
+	protected.GET("/totp/recovery-codes", h.RecoveryCodesLeft)
+	protected.POST("/totp/recovery-codes", h.RegenerateRecoveryCodes)
 }
 
 type registerReq struct {
@@ -38,13 +57,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"user": gin.H{"id": user.ID, "email": user.Email}})
+	c.JSON(http.StatusCreated, gin.H{"user": gin.H{"id": user.ID, "email": user.Email, "role": user.Role, "email_verified": user.EmailVerified, "active_org_id": user.ActiveOrgID}})
 }
 
 type loginReq struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
-	TOTP     string `json:"totp"` // optional: required if user enabled
+	TOTP     string `json:"totp"` // optional: required if user enabled; a recovery code also works
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -53,19 +72,78 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	token, user, err := h.uc.Login(req.Email, req.Password, req.TOTP)
+	tokens, user, err := h.uc.Login(req.Email, req.Password, req.TOTP, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondAuthError(c, http.StatusUnauthorized, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": gin.H{"id": user.ID, "email": user.Email, "totp_enabled": user.TOTPEnabled}})
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          gin.H{"id": user.ID, "email": user.Email, "role": user.Role, "totp_enabled": user.TOTPEnabled, "active_org_id": user.ActiveOrgID},
+	})
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+// POST /token/refresh {"refresh_token"} — the old refresh token is used up
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, err := h.uc.Refresh(req.RefreshToken)
+	if err != nil {
+		respondAuthError(c, http.StatusUnauthorized, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// POST /logout revokes this token and its refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	if err := h.uc.Logout(userID.(uint), c.GetString("tokenID"), c.GetString("sessionID"), c.GetTime("tokenExpiresAt")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// POST /logout-all revokes every session of the user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	if err := h.uc.LogoutAll(userID.(uint), c.GetString("tokenID"), c.GetTime("tokenExpiresAt")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
+
+// passwordReq confirms a sensitive change with the current password
+type passwordReq struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// POST /totp/setup {"password"} — also to re-key; the old secret stays
+// valid until /totp/verify accepts a code from the new one
 func (h *AuthHandler) SetupTOTP(c *gin.Context) {
-	idStr := c.Param("id")
-	id64, _ := strconv.ParseUint(idStr, 10, 32)
+	userID, _ := c.Get("userID")
+	var req passwordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	issuer := os.Getenv("APP_NAME")
-	secret, uri, err := h.uc.GenerateTOTPForUser(uint(id64), issuer)
+	secret, uri, err := h.uc.GenerateTOTPForUser(userID.(uint), req.Password, issuer)
+	if errors.Is(err, uc.ErrInvalidPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -79,21 +157,160 @@ type verifyReq struct {
 }
 
 func (h *AuthHandler) VerifyTOTP(c *gin.Context) {
-	idStr := c.Param("id")
-	id64, _ := strconv.ParseUint(idStr, 10, 32)
+	userID, _ := c.Get("userID")
 	var req verifyReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ok, err := h.uc.VerifyAndEnableTOTP(uint(id64), req.Code)
+	codes, ok, err := h.uc.VerifyAndEnableTOTP(userID.(uint), req.Code, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAuthError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+	// recovery codes are shown only here; store them somewhere safe
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "recovery_codes": codes})
+}
+
+// POST /totp/disable {"code"}
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req verifyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ok, err := h.uc.DisableTOTP(userID.(uint), req.Code, c.ClientIP())
+	if err != nil {
+		respondAuthError(c, http.StatusBadRequest, err)
 		return
 	}
 	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"enabled": true})
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// GET /totp/recovery-codes tells how many recovery codes are left
+func (h *AuthHandler) RecoveryCodesLeft(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	n, err := h.uc.RecoveryCodesLeft(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"remaining": n})
+}
+
+// POST /totp/recovery-codes {"password"} replaces all recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req passwordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := h.uc.RegenerateRecoveryCodes(userID.(uint), req.Password, c.ClientIP())
+	if errors.Is(err, uc.ErrInvalidPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// respondAuthError answers 429 with Retry-After while attempts are backing
+// off, 403 for an unverified email or a disabled account, otherwise status
+func respondAuthError(c *gin.Context, status int, err error) {
+	if errors.Is(err, uc.ErrEmailNotVerified) || errors.Is(err, uc.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	var tooMany *uc.TooManyAttemptsError
+	if errors.As(err, &tooMany) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+type emailReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// POST /password/forgot {"email"} — same answer whether the account exists or not
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req emailReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.uc.ForgotPassword(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a reset email is on its way"})
+}
+
+type resetPasswordReq struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// POST /password/reset {"token", "password"}
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.uc.ResetPassword(req.Token, req.Password, c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, please login again"})
+}
+
+// GET /email/verify?token= or POST /email/verify {"token"}
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req struct {
+			Token string `json:"token"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		token = req.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	if err := h.uc.VerifyEmail(token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"email_verified": true})
+}
+
+// POST /email/resend {"email"} sends a new verification link
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req emailReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.uc.ResendVerification(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "if the account needs it, a verification email is on its way"})
 }
@@ -0,0 +1,235 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/elastic"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+const (
+	defaultElasticStream = "elastic"
+	defaultElasticFormat = "raw"
+	elasticClusterName   = "log-analyzer"
+)
+
+// date (and rollover) suffixes of time-based indices: filebeat-8.11.0-2024.01.31
+var indexDateSuffix = regexp.MustCompile(`[-_.]\d{4}([.\-]\d{2}){0,2}(-\d{6})?$`)
+
+// ECS fields, tried after the ones the json parser already knows
+var (
+	ecsMethodKeys = []string{"http.request.method", "http.method"}
+	ecsPathKeys   = []string{"url.path", "url.original"}
+	ecsStatusKeys = []string{"http.response.status_code", "http.status_code"}
+	ecsIPKeys     = []string{"source.ip", "client.ip", "source.address", "client.address"}
+	ecsHostKeys   = []string{"host.name", "host.hostname", "agent.hostname"}
+)
+
+// ElasticHandler speaks enough of the Elasticsearch API for Filebeat,
+// Logstash and Vector to use the analyzer as their output.
+type ElasticHandler struct {
+	uc           *uc.IngestUsecase
+	maxBodyBytes int64
+}
+
+func NewElasticHandler(rg *gin.RouterGroup, uc *uc.IngestUsecase) {
+	h := &ElasticHandler{uc: uc, maxBodyBytes: ingestMaxBodyBytes()}
+	protected := rg.Group("")
+	protected.Use(elasticProductHeader, jwt.AuthMiddleware(jwt.ScopeUpload))
+	protected.GET("/", h.Info)
+	protected.HEAD("/", h.Info)
+	protected.GET("/_license", h.License)
+	protected.GET("/_cluster/health", h.Health)
+	protected.POST("/_bulk", canWrite(), h.Bulk)
+	protected.PUT("/_bulk", canWrite(), h.Bulk)
+	protected.POST("/:index/_bulk", canWrite(), h.Bulk)
+	protected.PUT("/:index/_bulk", canWrite(), h.Bulk)
+}
+
+// newer clients refuse servers that do not send this header
+func elasticProductHeader(c *gin.Context) {
+	c.Header("X-Elastic-Product", "Elasticsearch")
+	c.Next()
+}
+
+// GET / — version check done by clients on startup
+func (h *ElasticHandler) Info(c *gin.Context) {
+	c.JSON(http.StatusOK, elastic.Info(elasticClusterName))
+}
+
+func (h *ElasticHandler) License(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"license": gin.H{"uid": elasticClusterName, "type": "basic", "mode": "basic", "status": "active"}})
+}
+
+func (h *ElasticHandler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"cluster_name": elasticClusterName, "status": "green", "number_of_nodes": 1, "timed_out": false})
+}
+
+// POST /_bulk, /{index}/_bulk — NDJSON action/document pairs. Documents
+// go to the stream named after their index; ?format= sets the format of
+// new streams and is applied to the document's "message".
+func (h *ElasticHandler) Bulk(c *gin.Context) {
+	start := time.Now()
+	user := actor(c)
+
+	body, err := requestBody(c, h.maxBodyBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, elastic.Error(http.StatusBadRequest, "parse_exception", err.Error()))
+		return
+	}
+	defer body.Close()
+	actions, err := elastic.ParseBulk(body, c.Param("index"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, elastic.Error(http.StatusRequestEntityTooLarge, "content_too_long_exception", "request body too large"))
+			return
+		}
+		c.JSON(http.StatusBadRequest, elastic.Error(http.StatusBadRequest, "illegal_argument_exception", err.Error()))
+		return
+	}
+
+	format := c.DefaultQuery("format", defaultElasticFormat)
+	batches := make(map[string]*uc.IngestBatch)
+	var order []string
+	for i := range actions {
+		a := &actions[i]
+		if a.Err != nil {
+			continue
+		}
+		stream, err := h.uc.GetOrCreateStream(user, elasticStreamName(a.Index), format)
+		if err != nil {
+			a.Err = err
+			continue
+		}
+		parse, err := uc.StreamParser("elastic", stream.Format)
+		if err != nil {
+			a.Err = err
+			continue
+		}
+		rec, labels := elasticRecord(a.Doc, stream.Format, parse)
+		key := stream.Name + "|" + labels["host"]
+		batch, ok := batches[key]
+		if !ok {
+			batch = &uc.IngestBatch{Stream: stream, Labels: labels}
+			batches[key] = batch
+			order = append(order, key)
+		}
+		batch.Records = append(batch.Records, rec)
+	}
+
+	queued := make([]uc.IngestBatch, len(order))
+	for i, key := range order {
+		queued[i] = *batches[key]
+	}
+	// queued whole or not at all, so a retried request adds nothing twice
+	if err := h.uc.Push(queued...); err != nil {
+		if errors.Is(err, uc.ErrIngestBusy) {
+			// 429 makes Beats and Logstash back off and retry the request
+			c.JSON(http.StatusTooManyRequests, elastic.Error(http.StatusTooManyRequests, "es_rejected_execution_exception", err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, elastic.Error(http.StatusInternalServerError, "exception", err.Error()))
+		return
+	}
+
+	items := make([]map[string]interface{}, len(actions))
+	failed := false
+	for i, a := range actions {
+		status := http.StatusCreated
+		if a.Err != nil {
+			status = http.StatusBadRequest
+			failed = true
+		}
+		items[i] = elastic.Item(a, status, a.Err)
+	}
+	c.JSON(http.StatusOK, gin.H{"took": time.Since(start).Milliseconds(), "errors": failed, "items": items})
+}
+
+// elasticStreamName drops hidden-index dots and date suffixes, so daily
+// indices of one source share a stream.
+func elasticStreamName(index string) string {
+	name := uc.SanitizeStreamName(indexDateSuffix.ReplaceAllString(strings.TrimLeft(index, "."), ""))
+	if name == "" {
+		return defaultElasticStream
+	}
+	return name
+}
+
+// elasticRecord maps a document (Filebeat/ECS or plain JSON) onto a record.
+// The "message" is parsed with the stream's format when it is not raw,
+// otherwise the document fields are used; ECS fields win when present.
+func elasticRecord(doc map[string]interface{}, format string, parse parser.Func) (domain.LogRecord, map[string]string) {
+	flat := elastic.Flatten(doc)
+	msg, _ := flat["message"].(string)
+
+	rec := parser.FromFields(flat)
+	if msg != "" && format != defaultElasticFormat {
+		if parsed, ok := parse(msg); ok {
+			rec = parsed
+		}
+	}
+	if v := firstFlat(flat, ecsMethodKeys); v != "" {
+		rec.Method = strings.ToUpper(v)
+	}
+	if v := firstFlat(flat, ecsPathKeys); v != "" {
+		rec.Path, _, _ = strings.Cut(v, "?")
+	}
+	if v := firstFlat(flat, ecsStatusKeys); v != "" {
+		if status, err := strconv.Atoi(v); err == nil {
+			rec.Status = status
+		}
+	}
+	if v := firstFlat(flat, ecsIPKeys); v != "" {
+		rec.IP = v
+	}
+	if ns, err := strconv.ParseFloat(elastic.String(flat["event.duration"]), 64); err == nil {
+		rec.Latency = ns / 1e6 // ECS durations are nanoseconds
+	}
+	if level := parser.Level(firstFlat(flat, []string{"log.level", "level"})); level != "" {
+		rec.Level = level
+	}
+	if rec.Time.IsZero() {
+		rec.Time = parser.ParseTime(flat["@timestamp"])
+	}
+
+	rec.Message = msg
+	if rec.Raw == "" {
+		rec.Raw = msg
+	}
+	if rec.Raw == "" {
+		raw, _ := json.Marshal(doc)
+		rec.Raw = string(raw)
+	}
+	rec.Attributes = make(map[string]string, len(flat))
+	for k, v := range flat {
+		if k != "message" {
+			rec.Attributes[k] = elastic.String(v)
+		}
+	}
+
+	labels := map[string]string{}
+	if host := firstFlat(flat, ecsHostKeys); host != "" {
+		labels = uc.FilterLabels(map[string]string{"host": host})
+	}
+	return rec, labels
+}
+
+func firstFlat(flat map[string]interface{}, keys []string) string {
+	for _, k := range keys {
+		if v := elastic.String(flat[k]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
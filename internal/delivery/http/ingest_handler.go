@@ -0,0 +1,131 @@
+package http
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+const defaultIngestMaxBodyMB = 32
+
+type IngestHandler struct {
+	uc           *uc.IngestUsecase
+	maxBodyBytes int64
+}
+
+func NewIngestHandler(rg *gin.RouterGroup, uc *uc.IngestUsecase) {
+	h := &IngestHandler{uc: uc, maxBodyBytes: ingestMaxBodyBytes()}
+	protected := rg.Group("/ingest")
+	protected.Use(jwt.AuthMiddleware(jwt.ScopeUpload))
+	protected.POST("/:stream", canWrite(), h.Ingest)
+}
+
+// POST /ingest/:stream — body: newline-delimited log lines or JSON events,
+// optionally with Content-Encoding: gzip. ?format= is used when the stream is
+// created by this request; ?label=host=web-3 adds labels to these lines.
+func (h *IngestHandler) Ingest(c *gin.Context) {
+	user := actor(c)
+
+	labels, err := uc.ParseLabels(c.QueryArray("label")...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream, err := h.uc.GetOrCreateStream(user, c.Param("stream"), c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := requestBody(c, h.maxBodyBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	result, err := h.uc.IngestLines(stream, labels, body)
+	if err != nil {
+		writeIngestError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, result)
+}
+
+// ingestMaxBodyBytes is shared by all push endpoints (INGEST_MAX_BODY_MB)
+func ingestMaxBodyBytes() int64 {
+	maxMB, err := strconv.Atoi(os.Getenv("INGEST_MAX_BODY_MB"))
+	if err != nil || maxMB <= 0 {
+		maxMB = defaultIngestMaxBodyMB
+	}
+	return int64(maxMB) << 20
+}
+
+// maxInflateRatio bounds what a gzip or snappy body may expand to: the body limit
+// times this.
+const maxInflateRatio = 10
+
+// requestBody limits the body size and undoes gzip Content-Encoding; the
+// decompressed stream is limited too, so a small gzip bomb cannot expand
+// without bound.
+func requestBody(c *gin.Context, maxBytes int64) (io.ReadCloser, error) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	if !strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		return body, nil
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, errors.New("invalid gzip body")
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&inflateLimit{r: zr, left: maxBytes * maxInflateRatio, limit: maxBytes * maxInflateRatio}, body}, nil
+}
+
+// inflateLimit fails with *http.MaxBytesError once more than left bytes
+// come out of r, so handlers answer 413 like for an oversized body.
+type inflateLimit struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+func (l *inflateLimit) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, &http.MaxBytesError{Limit: l.limit}
+	}
+	// read one byte past the limit to tell "exactly at" from "over"
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n + int(l.left), &http.MaxBytesError{Limit: l.limit}
+	}
+	return n, err
+}
+
+func writeIngestError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, uc.ErrIngestBusy):
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
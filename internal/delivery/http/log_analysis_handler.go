@@ -1,11 +1,16 @@
 package http
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/export"
 	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
 	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
 )
@@ -19,11 +24,70 @@ func NewLogAnalysisHandler(rg *gin.RouterGroup, uc *uc.LogAnalysisUsecase) {
 	h := &LogAnalysisHandler{uc: uc}
 	protected := rg.Group("/analyses")
 	protected.Use(jwt.AuthMiddleware())
-	protected.POST("/", h.Create)
 	protected.GET("/", h.GetAll)
+	protected.GET("/report", h.Report)
+	protected.GET("/export", h.ExportAll)
 	protected.GET("/:id", h.GetByID)
-	protected.PUT("/:id", h.Update)
-	protected.DELETE("/:id", h.Delete)
+	protected.GET("/:id/export", h.Export)
+
+	// viewers only read
+	write := protected.Group("", canWrite())
+	write.POST("/", h.Create)
+	write.PUT("/:id", h.Update)
+	write.DELETE("/:id", h.Delete)
+	write.PUT("/:id/labels", h.SetLabels)
+	write.PATCH("/:id/labels", h.MergeLabels)
+	write.GET("/:id/shares", h.Shares)
+	write.POST("/:id/shares", h.Share)
+	write.DELETE("/:id/shares/:user_id", h.Unshare)
+}
+
+// canWrite lets admins and analysts (by user and by org role) through;
+// viewers get 403.
+func canWrite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !actor(c).CanWrite() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "your role does not allow this"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// actor is the user of the request in their active org, as set by
+// jwt.AuthMiddleware.
+func actor(c *gin.Context) domain.Actor {
+	userID, _ := c.Get("userID")
+	id, _ := userID.(uint)
+	orgID, _ := c.Get("orgID")
+	org, _ := orgID.(uint)
+	return domain.Actor{UserID: id, Role: c.GetString("role"), OrgID: org, OrgRole: c.GetString("orgRole")}
+}
+
+// respondAnalysisError answers 403 when a reader tries to change an
+// analysis and 404 when it is not visible, otherwise status.
+func respondAnalysisError(c *gin.Context, status int, err error) {
+	if errors.Is(err, uc.ErrNotOwner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	respondError(c, status, err)
+}
+
+// analysisFilter reads ?label=k=v, ?mine=true (only my uploads) and, for
+// admins, ?all=true (every org).
+func analysisFilter(c *gin.Context) (domain.AnalysisFilter, error) {
+	labels, err := uc.ParseLabels(c.QueryArray("label")...)
+	if err != nil {
+		return domain.AnalysisFilter{}, err
+	}
+	filter := domain.AnalysisFilter{Labels: labels}
+	filter.AllOrgs, _ = strconv.ParseBool(c.Query("all"))
+	if mine, _ := strconv.ParseBool(c.Query("mine")); mine {
+		filter.UserID = actor(c).UserID
+	}
+	return filter, nil
 }
 
 // Create new log analysis
@@ -34,9 +98,9 @@ func (h *LogAnalysisHandler) Create(c *gin.Context) {
 		return
 	}
 
-	// ambil userID dari context JWT
-	userID, _ := c.Get("userID")
-	input.UserID = userID.(uint)
+	// ambil userID dan org aktif dari context JWT
+	user := actor(c)
+	input.UserID, input.OrgID = user.UserID, user.OrgID
 
 	if err := h.uc.Create(&input); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -47,7 +111,13 @@ func (h *LogAnalysisHandler) Create(c *gin.Context) {
 
 // Get all log analyses
 func (h *LogAnalysisHandler) GetAll(c *gin.Context) {
-	logs, err := h.uc.GetAll()
+	filter, err := analysisFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logs, err := h.uc.GetAll(actor(c), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -60,7 +130,7 @@ func (h *LogAnalysisHandler) GetByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.Atoi(idStr)
 
-	log, err := h.uc.GetByID(uint(id))
+	log, err := h.uc.GetByID(actor(c), uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -80,8 +150,8 @@ func (h *LogAnalysisHandler) Update(c *gin.Context) {
 	}
 	input.ID = uint(id)
 
-	if err := h.uc.Update(&input); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.uc.Update(actor(c), &input); err != nil {
+		respondAnalysisError(c, http.StatusInternalServerError, err)
 		return
 	}
 	c.JSON(http.StatusOK, input)
@@ -92,9 +162,178 @@ func (h *LogAnalysisHandler) Delete(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.Atoi(idStr)
 
-	if err := h.uc.Delete(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.uc.Delete(actor(c), uint(id)); err != nil {
+		respondAnalysisError(c, http.StatusInternalServerError, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 }
+
+// Report groups analyses by label, e.g. GET /analyses/report?group_by=service,env
+func (h *LogAnalysisHandler) Report(c *gin.Context) {
+	filter, err := analysisFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var groupBy []string
+	for _, item := range c.QueryArray("group_by") {
+		for _, key := range strings.Split(item, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				groupBy = append(groupBy, key)
+			}
+		}
+	}
+
+	report, err := h.uc.Report(actor(c), groupBy, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group_by": groupBy, "rows": report})
+}
+
+type labelsReq struct {
+	Labels map[string]string `json:"labels" binding:"required"`
+}
+
+// Replace all labels of an analysis
+func (h *LogAnalysisHandler) SetLabels(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.Atoi(idStr)
+
+	var req labelsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	a, err := h.uc.SetLabels(actor(c), uint(id), req.Labels)
+	if err != nil {
+		respondAnalysisError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// Merge labels; a key with an empty value is removed
+func (h *LogAnalysisHandler) MergeLabels(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.Atoi(idStr)
+
+	var req labelsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	a, err := h.uc.MergeLabels(actor(c), uint(id), req.Labels)
+	if err != nil {
+		respondAnalysisError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// Export one analysis: GET /analyses/:id/export?format=csv|json|xlsx|html
+func (h *LogAnalysisHandler) Export(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.Atoi(idStr)
+
+	format := c.DefaultQuery("format", "json")
+	if !export.Supported(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json, xlsx or html"})
+		return
+	}
+
+	a, err := h.uc.GetByID(actor(c), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeExport(c, format, fmt.Sprintf("analysis-%d", a.ID), export.Report{
+		Title:    fmt.Sprintf("Log analysis #%d — %s", a.ID, a.Filename),
+		Analyses: []domain.LogAnalysis{*a},
+		Single:   true,
+	})
+}
+
+// Bulk export of the (label filtered) list: GET /analyses/export?format=csv&label=env=prod
+func (h *LogAnalysisHandler) ExportAll(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if !export.Supported(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json, xlsx or html"})
+		return
+	}
+
+	filter, err := analysisFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logs, err := h.uc.GetAll(actor(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeExport(c, format, "analyses", export.Report{
+		Title:    "Log analysis report",
+		Analyses: logs,
+	})
+}
+
+type shareReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// GET /analyses/:id/shares lists who can read the analysis
+func (h *LogAnalysisHandler) Shares(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	shares, err := h.uc.Shares(actor(c), uint(id))
+	if err != nil {
+		respondAnalysisError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, shares)
+}
+
+// POST /analyses/:id/shares {"email": "viewer@example.com"} gives read access
+func (h *LogAnalysisHandler) Share(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req shareReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	share, err := h.uc.Share(actor(c), uint(id), req.Email)
+	if err != nil {
+		respondAnalysisError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, share)
+}
+
+// DELETE /analyses/:id/shares/:user_id
+func (h *LogAnalysisHandler) Unshare(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID, _ := strconv.Atoi(c.Param("user_id"))
+	if err := h.uc.Unshare(actor(c), uint(id), uint(userID)); err != nil {
+		respondAnalysisError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unshared"})
+}
+
+func writeExport(c *gin.Context, format, basename string, report export.Report) {
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename(basename, format)))
+	c.Data(http.StatusOK, export.ContentType(format), buf.Bytes())
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/loki"
+)
+
+const (
+	defaultLokiStream = "loki"
+	defaultLokiFormat = "raw"
+	defaultQueryRange = time.Hour
+)
+
+// Loki labels that pick the analyzer stream, in order of preference
+var lokiStreamLabels = []string{"stream", "service_name", "job"}
+
+// LokiHandler serves the Loki push API for Promtail / Alloy / Fluent Bit and
+// the part of the query API Grafana needs to use the analyzer as a datasource.
+type LokiHandler struct {
+	uc           *uc.IngestUsecase
+	maxBodyBytes int64
+}
+
+func NewLokiHandler(rg *gin.RouterGroup, uc *uc.IngestUsecase) {
+	h := &LokiHandler{uc: uc, maxBodyBytes: ingestMaxBodyBytes()}
+	protected := rg.Group("")
+	protected.Use(jwt.AuthMiddleware(jwt.ScopeUpload))
+	protected.POST("/push", canWrite(), h.Push)
+	protected.GET("/query_range", h.QueryRange)
+	protected.GET("/labels", h.Labels)
+	protected.GET("/label/:name/values", h.LabelValues)
+}
+
+// POST /loki/api/v1/push — application/x-protobuf (snappy) or application/json.
+// ?format= sets the format of streams created by this request (default raw).
+func (h *LokiHandler) Push(c *gin.Context) {
+	user := actor(c)
+
+	body, err := requestBody(c, h.maxBodyBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	var streams []loki.Stream
+	if c.ContentType() == "application/x-protobuf" {
+		streams, err = loki.DecodeProto(data, h.maxBodyBytes*maxInflateRatio)
+	} else {
+		streams, err = loki.DecodeJSON(data)
+	}
+	if errors.Is(err, loki.ErrTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", defaultLokiFormat)
+	batches := make([]uc.IngestBatch, 0, len(streams))
+	for _, s := range streams {
+		name, labels := lokiStream(s.Labels)
+		if err := uc.ValidateLabels(labels); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		stream, err := h.uc.GetOrCreateStream(user, name, format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parse, err := uc.StreamParser("loki", stream.Format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		batch := uc.IngestBatch{Stream: stream, Labels: labels}
+		for _, e := range s.Entries {
+			if rec, ok := lokiRecord(e, parse); ok {
+				batch.Records = append(batch.Records, rec)
+			}
+		}
+		batches = append(batches, batch)
+	}
+
+	// every stream is validated above, so the push is queued whole or not at all
+	if err := h.uc.Push(batches...); err != nil {
+		writeIngestError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// lokiStream picks the analyzer stream from the stream/service_name/job
+// label; the other labels split the stream's window analyses.
+func lokiStream(labels map[string]string) (string, map[string]string) {
+	name := ""
+	for _, key := range lokiStreamLabels {
+		if name = uc.SanitizeStreamName(labels[key]); name != "" {
+			break
+		}
+	}
+	if name == "" {
+		name = defaultLokiStream
+	}
+	rest := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != "stream" {
+			rest[k] = v
+		}
+	}
+	return name, rest
+}
+
+// lokiRecord parses the line with the stream's format, falling back to a
+// plain message. The time in the line wins over the shipper's timestamp.
+func lokiRecord(e loki.Entry, parse parser.Func) (domain.LogRecord, bool) {
+	rec, ok := parse(e.Line)
+	if !ok {
+		if rec, ok = parser.Raw(e.Line); !ok {
+			return rec, false
+		}
+	}
+	if rec.Time.IsZero() {
+		rec.Time = e.Time
+	}
+	if len(e.Metadata) > 0 {
+		if rec.Attributes == nil {
+			rec.Attributes = make(map[string]string, len(e.Metadata))
+		}
+		for k, v := range e.Metadata {
+			rec.Attributes[k] = v
+		}
+	}
+	return rec, true
+}
+
+// GET /loki/api/v1/query_range?query={job="nginx"} |= "POST"&start=&end=&limit=&direction=
+// Only log queries are supported, over lines ingested since the server started.
+func (h *LokiHandler) QueryRange(c *gin.Context) {
+	user := actor(c)
+
+	q, err := loki.ParseQuery(c.Query("query"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, end, err := lokiRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	streams := h.uc.QueryRecent(user.OrgID, uc.LogQuery{
+		Query:   q,
+		Start:   start,
+		End:     end,
+		Limit:   limit,
+		Forward: c.Query("direction") == "forward",
+	})
+
+	result := make([]gin.H, 0, len(streams))
+	for _, s := range streams {
+		values := make([][2]string, len(s.Records))
+		for i, rec := range s.Records {
+			values[i] = [2]string{strconv.FormatInt(rec.Time.UnixNano(), 10), uc.RecordLine(rec)}
+		}
+		result = append(result, gin.H{"stream": s.Labels, "values": values})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"resultType": "streams",
+			"result":     result,
+			"stats":      gin.H{},
+		},
+	})
+}
+
+// GET /loki/api/v1/labels
+func (h *LokiHandler) Labels(c *gin.Context) {
+	user := actor(c)
+	start, _, err := lokiRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": h.uc.LabelNames(user.OrgID, start)})
+}
+
+// GET /loki/api/v1/label/:name/values
+func (h *LokiHandler) LabelValues(c *gin.Context) {
+	user := actor(c)
+	start, _, err := lokiRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": h.uc.LabelValues(user.OrgID, c.Param("name"), start)})
+}
+
+// lokiRange reads start/end (unix ns, unix seconds or RFC3339); the default
+// is the last hour.
+func lokiRange(c *gin.Context) (time.Time, time.Time, error) {
+	end := time.Now()
+	if raw := c.Query("end"); raw != "" {
+		t, err := lokiTime(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = t
+	}
+	start := end.Add(-defaultQueryRange)
+	if raw := c.Query("start"); raw != "" {
+		t, err := lokiTime(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start = t
+	}
+	return start, end, nil
+}
+
+func lokiTime(raw string) (time.Time, error) {
+	if ns, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if ns < 1e12 { // plain seconds
+			return time.Unix(ns, 0), nil
+		}
+		return time.Unix(0, ns), nil
+	}
+	if sec, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Unix(0, int64(sec*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, raw)
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/metrics"
+)
+
+var httpDuration = metrics.NewHistogramVec("log_analyzer_http_request_duration_seconds",
+	"HTTP request latency by method, Gin route and status.", metrics.DefBuckets, "method", "route", "status")
+
+func init() {
+	metrics.Register(httpDuration)
+}
+
+// metricsMiddleware times every request under its route pattern (e.g.
+// /api/logs/:id), never the raw path, so IDs do not become label values.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpDuration.With(methodLabel(c.Request.Method), route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// methodLabel keeps the method label to the standard methods; any other
+// method a client sends is counted as "other".
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// NewMetricsHandler serves the default registry at /metrics in the
+// Prometheus text format; token is optional.
+func NewMetricsHandler(r *gin.Engine, token string) {
+	r.GET("/metrics", gin.WrapH(metrics.Handler(metrics.Default, token)))
+}
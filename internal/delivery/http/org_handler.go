@@ -0,0 +1,190 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type OrgHandler struct {
+	uc *uc.AuthUsecase
+}
+
+func NewOrgHandler(rg *gin.RouterGroup, uc *uc.AuthUsecase) {
+	h := &OrgHandler{uc: uc}
+	protected := rg.Group("")
+	protected.Use(jwt.AuthMiddleware())
+	protected.GET("/orgs", h.List)
+	protected.POST("/orgs", h.Create)
+	protected.POST("/orgs/:id/switch", jwt.SessionOnly(), h.Switch)
+	protected.GET("/orgs/:id/members", h.Members)
+	protected.PUT("/orgs/:id/members/:user_id", h.SetMemberRole)
+	protected.DELETE("/orgs/:id/members/:user_id", h.RemoveMember)
+	protected.GET("/orgs/:id/invitations", h.Invitations)
+	protected.POST("/orgs/:id/invitations", h.Invite)
+	protected.DELETE("/orgs/:id/invitations/:inv_id", h.RevokeInvitation)
+	protected.POST("/invitations/accept", jwt.SessionOnly(), h.AcceptInvitation)
+}
+
+// GET /orgs — the user's orgs with their role in each
+func (h *OrgHandler) List(c *gin.Context) {
+	user := actor(c)
+	orgs, err := h.uc.ListOrgs(user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"active_org_id": user.OrgID, "orgs": orgs})
+}
+
+type orgReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// POST /orgs {"name"}
+func (h *OrgHandler) Create(c *gin.Context) {
+	var req orgReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	org, err := h.uc.CreateOrg(actor(c).UserID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, org)
+}
+
+// POST /orgs/:id/switch — ends this session and returns tokens for the org
+func (h *OrgHandler) Switch(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	tokens, org, err := h.uc.SwitchOrg(actor(c).UserID, uint(orgID), c.GetString("tokenID"), c.GetString("sessionID"), c.GetTime("tokenExpiresAt"))
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"org":           org,
+	})
+}
+
+func (h *OrgHandler) Members(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	members, err := h.uc.Members(actor(c).UserID, uint(orgID))
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// PUT /orgs/:id/members/:user_id {"role": "admin"|"analyst"|"viewer"}
+func (h *OrgHandler) SetMemberRole(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+	var req roleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.uc.SetMemberRole(actor(c).UserID, uint(orgID), uint(memberID), req.Role, c.ClientIP())
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// DELETE /orgs/:id/members/:user_id — also to leave the org yourself
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	memberID, _ := strconv.Atoi(c.Param("user_id"))
+	if err := h.uc.RemoveMember(actor(c).UserID, uint(orgID), uint(memberID), c.ClientIP()); err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "removed"})
+}
+
+// GET /orgs/:id/invitations — pending ones
+func (h *OrgHandler) Invitations(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	list, err := h.uc.ListInvitations(actor(c).UserID, uint(orgID))
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+type inviteReq struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"` // default analyst
+}
+
+// POST /orgs/:id/invitations {"email", "role"} — the token is shown only here
+// and in the email
+func (h *OrgHandler) Invite(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	var req inviteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = domain.RoleAnalyst
+	}
+	inv, token, err := h.uc.Invite(actor(c).UserID, uint(orgID), req.Email, req.Role)
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invitation": inv, "token": token})
+}
+
+func (h *OrgHandler) RevokeInvitation(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Param("id"))
+	invID, _ := strconv.Atoi(c.Param("inv_id"))
+	if err := h.uc.RevokeInvitation(actor(c).UserID, uint(orgID), uint(invID)); err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "invitation revoked"})
+}
+
+type acceptReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// POST /invitations/accept {"token"} — then switch to the org to use it
+func (h *OrgHandler) AcceptInvitation(c *gin.Context) {
+	var req acceptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	org, err := h.uc.AcceptInvitation(actor(c).UserID, req.Token, c.ClientIP())
+	if err != nil {
+		respondOrgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, org)
+}
+
+// respondOrgError answers 403 to non-members and non-admins of the org.
+func respondOrgError(c *gin.Context, err error) {
+	if errors.Is(err, uc.ErrNotOrgMember) || errors.Is(err, uc.ErrNotOrgAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	respondError(c, http.StatusBadRequest, err)
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/otlp"
+)
+
+const (
+	defaultOTLPStream   = "otel"
+	defaultOTLPFormat   = "raw"
+	protobufContentType = "application/x-protobuf"
+)
+
+// HTTP semantic-convention attributes, current names first
+var (
+	otlpMethodKeys = []string{"http.request.method", "http.method"}
+	otlpPathKeys   = []string{"url.path", "http.target", "http.route"}
+	otlpStatusKeys = []string{"http.response.status_code", "http.status_code"}
+	otlpIPKeys     = []string{"client.address", "http.client_ip", "source.address", "net.peer.ip"}
+)
+
+// OTLPHandler accepts OTLP/HTTP log exports, so an OpenTelemetry Collector
+// (otlphttp exporter) or an SDK can send logs straight to the analyzer.
+type OTLPHandler struct {
+	uc             *uc.IngestUsecase
+	maxBodyBytes   int64
+	resourceLabels map[string]bool // OTLP_RESOURCE_LABELS, empty = all
+}
+
+func NewOTLPHandler(rg *gin.RouterGroup, uc *uc.IngestUsecase) {
+	h := &OTLPHandler{uc: uc, maxBodyBytes: ingestMaxBodyBytes(), resourceLabels: map[string]bool{}}
+	for _, key := range strings.Split(os.Getenv("OTLP_RESOURCE_LABELS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			h.resourceLabels[key] = true
+		}
+	}
+	protected := rg.Group("")
+	protected.Use(jwt.AuthMiddleware(jwt.ScopeUpload))
+	protected.POST("/logs", canWrite(), h.Logs)
+}
+
+// POST /v1/logs — ExportLogsServiceRequest as application/x-protobuf or
+// application/json. Records are grouped into streams by service.name.
+func (h *OTLPHandler) Logs(c *gin.Context) {
+	user := actor(c)
+
+	body, err := requestBody(c, h.maxBodyBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	isProto := c.ContentType() == protobufContentType
+	var records []otlp.Record
+	if isProto {
+		records, err = otlp.DecodeProto(data)
+	} else {
+		records, err = otlp.DecodeJSON(data)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", defaultOTLPFormat)
+	batches := make(map[string]*uc.IngestBatch)
+	var order []string
+	for _, r := range records {
+		name := uc.SanitizeStreamName(r.Resource["service.name"])
+		if name == "" {
+			name = defaultOTLPStream
+		}
+		labels := h.labels(r.Resource)
+		key := name + fmt.Sprint(labels) // fmt sorts map keys
+		batch, ok := batches[key]
+		if !ok {
+			stream, err := h.uc.GetOrCreateStream(user, name, format)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			batch = &uc.IngestBatch{Stream: stream, Labels: labels}
+			batches[key] = batch
+			order = append(order, key)
+		}
+		parse, err := uc.StreamParser("otlp", batch.Stream.Format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		batch.Records = append(batch.Records, otlpRecord(r, parse))
+	}
+
+	queued := make([]uc.IngestBatch, len(order))
+	for i, key := range order {
+		queued[i] = *batches[key]
+	}
+	// queued whole or not at all, so the exporter's retry sends nothing twice
+	if err := h.uc.Push(queued...); err != nil {
+		writeIngestError(c, err)
+		return
+	}
+
+	// an empty ExportLogsServiceResponse means full success
+	if isProto {
+		c.Data(http.StatusOK, protobufContentType, []byte{})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// labels: resource attributes that are valid labels, optionally limited to
+// the keys in OTLP_RESOURCE_LABELS
+func (h *OTLPHandler) labels(resource map[string]string) map[string]string {
+	if len(h.resourceLabels) == 0 {
+		return uc.FilterLabels(resource)
+	}
+	picked := make(map[string]string, len(h.resourceLabels))
+	for key := range h.resourceLabels {
+		if v, ok := resource[key]; ok {
+			picked[key] = v
+		}
+	}
+	return uc.FilterLabels(picked)
+}
+
+// otlpRecord parses the body with the stream's format, then lets the HTTP
+// semantic-convention attributes fill in the request fields.
+func otlpRecord(r otlp.Record, parse parser.Func) domain.LogRecord {
+	rec, ok := parse(r.Body)
+	if !ok {
+		rec = domain.LogRecord{Message: r.Body, Raw: r.Body}
+	}
+	if rec.Time.IsZero() {
+		rec.Time = r.Time
+	}
+	if rec.Message == "" {
+		rec.Message = r.Body
+	}
+
+	attrs := r.Attributes
+	if v := firstAttr(attrs, otlpMethodKeys); v != "" {
+		rec.Method = strings.ToUpper(v)
+	}
+	if v := firstAttr(attrs, otlpPathKeys); v != "" {
+		rec.Path, _, _ = strings.Cut(v, "?")
+	}
+	if status, err := strconv.Atoi(firstAttr(attrs, otlpStatusKeys)); err == nil {
+		rec.Status = status
+	}
+	if v := firstAttr(attrs, otlpIPKeys); v != "" {
+		rec.IP = v
+	}
+	if ms, ok := otlpDurationMs(attrs); ok {
+		rec.Latency = ms
+	}
+	if level := severityLevel(r.SeverityNumber, r.SeverityText); level != "" {
+		rec.Level = level
+	}
+
+	rec.Attributes = make(map[string]string, len(attrs)+3)
+	for k, v := range attrs {
+		rec.Attributes[k] = v
+	}
+	if r.Scope != "" {
+		rec.Attributes["otel.scope"] = r.Scope
+	}
+	if r.TraceID != "" {
+		rec.Attributes["trace_id"] = r.TraceID
+	}
+	if r.SpanID != "" {
+		rec.Attributes["span_id"] = r.SpanID
+	}
+	return rec
+}
+
+func firstAttr(attrs map[string]string, keys []string) string {
+	for _, k := range keys {
+		if v := attrs[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// otlpDurationMs reads http.server.request.duration (seconds, as in the
+// metric of the same name), duration_ms, or duration ("150ms" or ms).
+func otlpDurationMs(attrs map[string]string) (float64, bool) {
+	if v, err := strconv.ParseFloat(attrs["http.server.request.duration"], 64); err == nil {
+		return v * 1000, true
+	}
+	if v, err := strconv.ParseFloat(attrs["duration_ms"], 64); err == nil {
+		return v, true
+	}
+	raw := attrs["duration"]
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		return v, true
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return float64(d) / float64(time.Millisecond), true
+	}
+	return 0, false
+}
+
+// severityLevel maps OTel severity numbers (1-24) or, without one, the
+// severity text onto the analyzer's levels.
+func severityLevel(number int, text string) string {
+	switch {
+	case number >= 21:
+		return domain.LevelCritical
+	case number >= 17:
+		return domain.LevelError
+	case number >= 13:
+		return domain.LevelWarning
+	case number >= 9:
+		return domain.LevelInfo
+	case number >= 1:
+		return domain.LevelDebug
+	}
+	return parser.Level(text)
+}
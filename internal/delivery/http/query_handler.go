@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type QueryHandler struct {
+	uc *uc.RecordUsecase
+}
+
+func NewQueryHandler(rg *gin.RouterGroup, uc *uc.RecordUsecase) {
+	h := &QueryHandler{uc: uc}
+	protected := rg.Group("/query")
+	protected.Use(jwt.AuthMiddleware(jwt.ScopeRead)) // POST, but only reads
+	protected.POST("", h.Run)
+	protected.POST("/", h.Run)
+}
+
+type queryRequest struct {
+	Query      string     `json:"query" binding:"required"`
+	AnalysisID uint       `json:"analysis_id"`
+	From       *time.Time `json:"from"`
+	To         *time.Time `json:"to"`
+}
+
+// POST /query
+//
+// JSON body: runs over retained records of one analysis or all of the
+// user's analyses. Multipart (file, query, format): runs over the file
+// without storing anything.
+func (h *QueryHandler) Run(c *gin.Context) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		h.runFile(c)
+		return
+	}
+
+	user := actor(c)
+	var req queryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// parse first so syntax errors are a 400, not a 500
+	if _, err := uc.ParseQuery(req.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Query(user, domain.QueryScope{
+		AnalysisID: req.AnalysisID,
+		From:       req.From,
+		To:         req.To,
+	}, req.Query)
+	if err != nil {
+		if err.Error() == "record not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *QueryHandler) runFile(c *gin.Context) {
+	q, err := uc.ParseQuery(c.PostForm("query"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	res, err := uc.QueryReader(file, c.PostForm("format"), q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type RecordHandler struct {
+	uc *uc.RecordUsecase
+}
+
+func NewRecordHandler(rg *gin.RouterGroup, uc *uc.RecordUsecase) {
+	h := &RecordHandler{uc: uc}
+	protected := rg.Group("/analyses")
+	protected.Use(jwt.AuthMiddleware())
+	protected.GET("/:id/records", h.List)
+
+	search := rg.Group("/search")
+	search.Use(jwt.AuthMiddleware())
+	search.GET("/", h.Search)
+}
+
+// GET /analyses/:id/records?status=5xx&path=/api/*&latency_min=500&from=...&limit=100
+func (h *RecordHandler) List(c *gin.Context) {
+	user := actor(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid analysis id"})
+		return
+	}
+	filter, err := recordFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.AnalysisID = uint(id)
+
+	records, err := h.uc.Find(user, filter)
+	if err != nil {
+		if err.Error() == "record not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"records": records, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// GET /search?q="GET /api/orders" req-7f3a*&analysis_id=12&from=...&to=...
+func (h *RecordHandler) Search(c *gin.Context) {
+	user := actor(c)
+	if strings.TrimSpace(c.Query("q")) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	filter, err := searchFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hits, err := h.uc.Search(user, filter)
+	if err != nil {
+		if err.Error() == "record not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hits": hits, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+func searchFilter(c *gin.Context) (domain.SearchFilter, error) {
+	var f domain.SearchFilter
+	terms, err := uc.ParseSearchQuery(c.Query("q"))
+	if err != nil {
+		return f, err
+	}
+	f.Terms = terms
+	if v := c.Query("analysis_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return f, fmt.Errorf("invalid analysis_id %q", v)
+		}
+		f.AnalysisID = uint(id)
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				return f, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	if f.From, err = queryTime(c, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = queryTime(c, "to"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// queryTime reads an optional RFC3339 query parameter
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be RFC3339", name)
+	}
+	return &t, nil
+}
+
+func recordFilter(c *gin.Context) (domain.RecordFilter, error) {
+	f := domain.RecordFilter{
+		Method: c.Query("method"),
+		Path:   c.Query("path"),
+		IP:     c.Query("ip"),
+	}
+	var err error
+
+	// status=404 atau status=5xx
+	if s := strings.ToLower(c.Query("status")); s != "" {
+		if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+			class := int(s[0]-'0') * 100
+			f.StatusMin, f.StatusMax = class, class+99
+		} else if f.Status, err = strconv.Atoi(s); err != nil {
+			return f, fmt.Errorf("invalid status %q", s)
+		}
+	}
+	for name, dst := range map[string]*int{
+		"status_min": &f.StatusMin, "status_max": &f.StatusMax,
+		"limit": &f.Limit, "offset": &f.Offset,
+	} {
+		if v := c.Query(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				return f, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	for name, dst := range map[string]**float64{"latency_min": &f.LatencyMin, "latency_max": &f.LatencyMax} {
+		if v := c.Query(name); v != "" {
+			ms, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = &ms
+		}
+	}
+	if f.From, err = queryTime(c, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = queryTime(c, "to"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
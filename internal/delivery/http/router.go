@@ -2,20 +2,47 @@ package http
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/config"
 	usecaseAuth "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	usecaseIngest "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
 	usecaseLog "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
 )
 
-func NewRouter(authUC *usecaseAuth.AuthUsecase, logUC *usecaseLog.LogAnalysisUsecase) *gin.Engine {
+func NewRouter(authUC *usecaseAuth.AuthUsecase, logUC *usecaseLog.LogAnalysisUsecase, ingestUC *usecaseIngest.IngestUsecase, recordUC *usecaseLog.RecordUsecase, reportUC *usecaseLog.ReportUsecase, alertUC *usecaseLog.AlertUsecase) *gin.Engine {
 	r := gin.Default()
+	r.Use(metricsMiddleware())
 	api := r.Group("/api")
 
 	// Auth endpoints
 	NewAuthHandler(api, authUC)
+	NewAdminHandler(api, authUC)
+	NewOrgHandler(api, authUC)
+	NewAPIKeyHandler(api, authUC)
 
 	// Log analysis endpoints (protected)
 	NewLogAnalysisHandler(api, logUC)
 	NewUploadHandler(api, logUC)
+	NewRecordHandler(api, recordUC)
+	NewQueryHandler(api, recordUC)
+	NewSavedQueryHandler(api, reportUC)
+	NewAlertHandler(api, alertUC)
+
+	// Push ingestion into rolling per-stream analyses
+	NewStreamHandler(api, ingestUC)
+	NewIngestHandler(api, ingestUC)
+
+	// Loki-compatible push and query API, outside /api so agents and
+	// Grafana can use their default paths
+	NewLokiHandler(r.Group("/loki/api/v1"), ingestUC)
+
+	// OTLP/HTTP logs at the path exporters use by default
+	NewOTLPHandler(r.Group("/v1"), ingestUC)
+
+	// Elasticsearch bulk API at the root, where Beats and Logstash expect it
+	NewElasticHandler(r.Group(""), ingestUC)
+
+	// Prometheus scrape endpoint
+	NewMetricsHandler(r, config.MetricsToken())
 
 	return r
 }
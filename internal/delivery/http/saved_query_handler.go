@@ -0,0 +1,176 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type SavedQueryHandler struct {
+	uc *uc.ReportUsecase
+}
+
+func NewSavedQueryHandler(rg *gin.RouterGroup, uc *uc.ReportUsecase) {
+	h := &SavedQueryHandler{uc: uc}
+	protected := rg.Group("/saved-queries")
+	protected.Use(jwt.AuthMiddleware())
+	protected.GET("/", h.GetAll)
+	protected.POST("/", canWrite(), h.Create)
+	protected.GET("/:id", h.Get)
+	protected.PUT("/:id", canWrite(), h.Update)
+	protected.DELETE("/:id", canWrite(), h.Delete)
+	protected.POST("/:id/run", canWrite(), h.Run)
+	protected.GET("/:id/runs", h.Runs)
+	protected.GET("/:id/runs/:run_id/output", h.Output)
+}
+
+// respondError: "record not found" jadi 404, selain itu status yang diberikan
+func respondError(c *gin.Context, status int, err error) {
+	if err.Error() == "record not found" {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func (h *SavedQueryHandler) GetAll(c *gin.Context) {
+	user := actor(c)
+	list, err := h.uc.List(user.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *SavedQueryHandler) Get(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	q, err := h.uc.Get(user.OrgID, uint(id))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+type savedQueryReq struct {
+	Name         string                `json:"name" binding:"required"`
+	Kind         string                `json:"kind" binding:"required"`
+	Query        string                `json:"query"`
+	AnalysisID   uint                  `json:"analysis_id"`
+	RangeSeconds int                   `json:"range_seconds"`
+	Labels       map[string]string     `json:"labels"`
+	GroupBy      []string              `json:"group_by"`
+	Format       string                `json:"format"`
+	Schedule     string                `json:"schedule"`
+	Notify       []domain.NotifyTarget `json:"notify"`
+	Enabled      *bool                 `json:"enabled"` // default true
+}
+
+func (req *savedQueryReq) savedQuery(owner domain.Actor) *domain.SavedQuery {
+	enabled := req.Enabled == nil || *req.Enabled
+	return &domain.SavedQuery{
+		UserID:       owner.UserID,
+		OrgID:        owner.OrgID,
+		Name:         req.Name,
+		Kind:         req.Kind,
+		Query:        req.Query,
+		AnalysisID:   req.AnalysisID,
+		RangeSeconds: req.RangeSeconds,
+		Labels:       req.Labels,
+		GroupBy:      req.GroupBy,
+		Format:       req.Format,
+		Schedule:     req.Schedule,
+		Notify:       req.Notify,
+		Enabled:      enabled,
+	}
+}
+
+// POST /saved-queries {"name", "kind": "query"|"report", "query", "schedule": "0 7 * * 1-5", "notify": [...]}
+func (h *SavedQueryHandler) Create(c *gin.Context) {
+	user := actor(c)
+	var req savedQueryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q := req.savedQuery(user)
+	if err := h.uc.Create(q); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, q)
+}
+
+func (h *SavedQueryHandler) Update(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req savedQueryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q := req.savedQuery(user)
+	q.ID = uint(id)
+	if err := h.uc.Update(q); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+func (h *SavedQueryHandler) Delete(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.uc.Delete(user.OrgID, uint(id)); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// POST /saved-queries/:id/run runs it now and delivers to its notifiers
+func (h *SavedQueryHandler) Run(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	run, err := h.uc.RunNow(user.OrgID, uint(id))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+func (h *SavedQueryHandler) Runs(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.uc.Runs(user.OrgID, uint(id), limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GET /saved-queries/:id/runs/:run_id/output downloads the stored output
+func (h *SavedQueryHandler) Output(c *gin.Context) {
+	user := actor(c)
+	id, _ := strconv.Atoi(c.Param("id"))
+	runID, _ := strconv.Atoi(c.Param("run_id"))
+	run, err := h.uc.Run(user.OrgID, uint(id), uint(runID))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if run.Status != domain.RunStatusOK {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run failed, no output: " + run.Error})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+run.Filename+`"`)
+	c.Data(http.StatusOK, run.ContentType, run.Output)
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+type StreamHandler struct {
+	uc *uc.IngestUsecase
+}
+
+func NewStreamHandler(rg *gin.RouterGroup, uc *uc.IngestUsecase) {
+	h := &StreamHandler{uc: uc}
+	protected := rg.Group("/streams")
+	protected.Use(jwt.AuthMiddleware())
+	protected.GET("/", h.GetAll)
+	protected.GET("/:name", h.Get)
+	protected.PUT("/:name", canWrite(), h.Save)
+	protected.DELETE("/:name", canWrite(), h.Delete)
+}
+
+func (h *StreamHandler) GetAll(c *gin.Context) {
+	user := actor(c)
+	streams, err := h.uc.ListStreams(user.OrgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, streams)
+}
+
+func (h *StreamHandler) Get(c *gin.Context) {
+	user := actor(c)
+	s, err := h.uc.GetStream(user.OrgID, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+type streamReq struct {
+	Format        string            `json:"format"`
+	WindowSeconds int               `json:"window_seconds"`
+	Labels        map[string]string `json:"labels"`
+}
+
+// PUT /streams/:name — create or update format, window and labels
+func (h *StreamHandler) Save(c *gin.Context) {
+	user := actor(c)
+	var req streamReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := &domain.Stream{
+		UserID:        user.UserID,
+		OrgID:         user.OrgID,
+		Name:          c.Param("name"),
+		Format:        req.Format,
+		WindowSeconds: req.WindowSeconds,
+		Labels:        req.Labels,
+	}
+	if err := h.uc.SaveStream(s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *StreamHandler) Delete(c *gin.Context) {
+	user := actor(c)
+	if err := h.uc.DeleteStream(user.OrgID, c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
@@ -16,13 +17,13 @@ type UploadHandler struct {
 func NewUploadHandler(rg *gin.RouterGroup, uc *uc.LogAnalysisUsecase) {
 	h := &UploadHandler{uc: uc}
 	protected := rg.Group("/upload")
-	protected.Use(jwt.AuthMiddleware())
-	protected.POST("/", h.Upload)
+	protected.Use(jwt.AuthMiddleware(jwt.ScopeUpload))
+	protected.POST("/", canWrite(), h.Upload)
 }
 
 // POST /upload/
 func (h *UploadHandler) Upload(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	user := actor(c)
 
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -30,6 +31,24 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 		return
 	}
 
+	// labels opsional: labels=service=checkout,env=prod dan/atau label=host=web-3
+	labels, err := uc.ParseLabels(append(c.PostFormArray("label"), c.PostForm("labels"))...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// retain_records=true/false menimpa RECORDS_RETAIN untuk file ini
+	var retain *bool
+	if v := c.PostForm("retain_records"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retain_records must be true or false"})
+			return
+		}
+		retain = &b
+	}
+
 	// simpan file sementara
 	dst := fmt.Sprintf("./tmp/%s", file.Filename)
 	if err := c.SaveUploadedFile(file, dst); err != nil {
@@ -38,7 +57,11 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 	}
 
 	// panggil usecase untuk parse log concurrent
-	err = h.uc.ParseAndSaveLog(dst, userID.(uint))
+	err = h.uc.ParseAndSaveLog(dst, user, uc.UploadOptions{
+		Labels:        labels,
+		Format:        c.PostForm("format"),
+		RetainRecords: retain,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,50 @@
+package syslog
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/pkg/syslog"
+)
+
+// Route sends messages whose hostname or app-name matches a glob to a stream.
+type Route struct {
+	Field   string // "host" or "app"
+	Pattern string // path.Match glob, e.g. "web-*"
+	Stream  string
+}
+
+// ParseRoutes parses "host:web-*=web,app:nginx=nginx". Rules are tried in order.
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		match, stream, ok := strings.Cut(item, "=")
+		field, pattern, ok2 := strings.Cut(match, ":")
+		if !ok || !ok2 || stream == "" || (field != "host" && field != "app") {
+			return nil, fmt.Errorf("invalid syslog route %q, use host:<glob>=<stream> or app:<glob>=<stream>", item)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid syslog route pattern %q: %v", pattern, err)
+		}
+		routes = append(routes, Route{Field: field, Pattern: pattern, Stream: stream})
+	}
+	return routes, nil
+}
+
+func route(routes []Route, m *syslog.Message, fallback string) string {
+	for _, r := range routes {
+		value := m.Hostname
+		if r.Field == "app" {
+			value = m.AppName
+		}
+		if ok, _ := path.Match(r.Pattern, value); ok {
+			return r.Stream
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,194 @@
+// Package syslog receives syslog messages over UDP, TCP and TCP+TLS and
+// feeds them into the ingest pipeline.
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/syslog"
+)
+
+const defaultStream = "syslog"
+
+type Config struct {
+	UDPAddr       string
+	TCPAddr       string
+	TLSAddr       string
+	TLSCert       string
+	TLSKey        string
+	Routes        []Route
+	DefaultStream string
+}
+
+// ConfigFromEnv reads SYSLOG_* variables. enabled is false when no listen
+// address is set, which keeps the receiver off by default.
+func ConfigFromEnv() (cfg Config, enabled bool, err error) {
+	cfg = Config{
+		UDPAddr:       os.Getenv("SYSLOG_UDP_ADDR"),
+		TCPAddr:       os.Getenv("SYSLOG_TCP_ADDR"),
+		TLSAddr:       os.Getenv("SYSLOG_TLS_ADDR"),
+		TLSCert:       os.Getenv("SYSLOG_TLS_CERT"),
+		TLSKey:        os.Getenv("SYSLOG_TLS_KEY"),
+		DefaultStream: os.Getenv("SYSLOG_DEFAULT_STREAM"),
+	}
+	if cfg.DefaultStream == "" {
+		cfg.DefaultStream = defaultStream
+	}
+	if cfg.TLSAddr != "" && (cfg.TLSCert == "" || cfg.TLSKey == "") {
+		return cfg, false, errors.New("SYSLOG_TLS_CERT and SYSLOG_TLS_KEY are required with SYSLOG_TLS_ADDR")
+	}
+	cfg.Routes, err = ParseRoutes(os.Getenv("SYSLOG_ROUTES"))
+	if err != nil {
+		return cfg, false, err
+	}
+	return cfg, cfg.UDPAddr != "" || cfg.TCPAddr != "" || cfg.TLSAddr != "", nil
+}
+
+type Server struct {
+	cfg       Config
+	collector *uc.Collector
+}
+
+func NewServer(cfg Config, collector *uc.Collector) *Server {
+	return &Server{cfg: cfg, collector: collector}
+}
+
+// Start opens the configured listeners and serves them in the background.
+func (s *Server) Start() error {
+	if s.cfg.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", s.cfg.UDPAddr)
+		if err != nil {
+			return err
+		}
+		log.Println("[Syslog] listening on udp", s.cfg.UDPAddr)
+		go s.serveUDP(conn)
+	}
+	if s.cfg.TCPAddr != "" {
+		ln, err := net.Listen("tcp", s.cfg.TCPAddr)
+		if err != nil {
+			return err
+		}
+		log.Println("[Syslog] listening on tcp", s.cfg.TCPAddr)
+		go s.serveTCP(ln)
+	}
+	if s.cfg.TLSAddr != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.TLSCert, s.cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+		ln, err := tls.Listen("tcp", s.cfg.TLSAddr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("[Syslog] listening on tcp+tls", s.cfg.TLSAddr)
+		go s.serveTCP(ln)
+	}
+	return nil
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, syslog.MaxMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Println("[Syslog] udp read:", err)
+			return
+		}
+		s.handle(buf[:n])
+	}
+}
+
+func (s *Server) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Println("[Syslog] accept:", err)
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	sc := syslog.NewScanner(conn)
+	for sc.Scan() {
+		s.handle(sc.Bytes())
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("[Syslog] %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func (s *Server) handle(b []byte) {
+	m, err := syslog.Parse(b)
+	if err != nil {
+		log.Println("[Syslog] SKIP:", err)
+		return
+	}
+
+	stream, parse, err := s.collector.Stream(route(s.cfg.Routes, m, s.cfg.DefaultStream))
+	if err != nil {
+		log.Println("[Syslog] ❌ stream:", err)
+		return
+	}
+
+	rec, ok := parse(m.Message)
+	s.collector.Add(stream, messageLabels(m), toRecord(m, rec, ok))
+}
+
+// messageLabels splits windows per host and app. Both come from the
+// network, so they go through FilterLabels; the collector caps how many
+// distinct values it takes.
+func messageLabels(m *syslog.Message) map[string]string {
+	labels := make(map[string]string, 2)
+	if m.Hostname != "" {
+		labels["host"] = m.Hostname
+	}
+	if m.AppName != "" {
+		labels["app"] = m.AppName
+	}
+	return uc.FilterLabels(labels)
+}
+
+// toRecord keeps the parsed access-log fields when the message body matches
+// the stream's format, and always adds the syslog header fields.
+func toRecord(m *syslog.Message, rec domain.LogRecord, ok bool) domain.LogRecord {
+	if !ok {
+		rec = domain.LogRecord{}
+	}
+	if rec.Time.IsZero() {
+		rec.Time = m.Timestamp.UTC()
+	}
+	rec.Message = m.Message
+	rec.Raw = m.Message
+	rec.Level = parser.SyslogLevel(m.Severity)
+	rec.Attributes = map[string]string{
+		"facility": m.FacilityName(),
+		"severity": m.SeverityName(),
+		"host":     m.Hostname,
+		"app":      m.AppName,
+	}
+	if m.ProcID != "" {
+		rec.Attributes["procid"] = m.ProcID
+	}
+	if m.MsgID != "" {
+		rec.Attributes["msgid"] = m.MsgID
+	}
+	if m.StructuredData != "" {
+		rec.Attributes["structured_data"] = m.StructuredData
+	}
+	rec.Attributes["rfc"] = strconv.Itoa(m.RFC)
+	return rec
+}
@@ -0,0 +1,161 @@
+package tail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const (
+	readChunk     = 64 * 1024
+	maxLineLength = 1 << 20
+)
+
+// tailer follows one path. It keeps the file open, so after a
+// rename+create rotation the rest of the old file can still be drained
+// before switching to the new one.
+type tailer struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	missing time.Time // since when the path is gone
+
+	saved   *domain.TailCheckpoint // last checkpoint written
+	savedAt time.Time
+}
+
+// open starts at the checkpoint when it still belongs to the same file,
+// otherwise at the start (fromStart) or the end of the file.
+func (t *tailer) open(cp *domain.TailCheckpoint, fromStart bool) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	offset := int64(0)
+	switch {
+	case cp != nil && cp.Inode == inode(fi) && cp.Offset <= fi.Size():
+		offset = cp.Offset
+	case cp != nil:
+		offset = 0 // rotated or truncated while we were not running
+	case !fromStart:
+		offset = fi.Size()
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	t.file, t.info, t.offset, t.partial = f, fi, offset, nil
+	return nil
+}
+
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// checkpoint points after the last complete line, so a partial line read so
+// far is read again in full after a restart.
+func (t *tailer) checkpoint() *domain.TailCheckpoint {
+	return &domain.TailCheckpoint{Path: t.path, Inode: inode(t.info), Offset: t.offset - int64(len(t.partial))}
+}
+
+// unsaved reports whether cp differs from the last checkpoint written.
+func (t *tailer) unsaved(cp *domain.TailCheckpoint) bool {
+	return t.saved == nil || t.saved.Inode != cp.Inode || t.saved.Offset != cp.Offset
+}
+
+// poll emits every complete new line. It returns rotated=true when the file
+// was replaced or truncated, so the caller can save a checkpoint right away.
+func (t *tailer) poll(emit func(line string)) (rotated bool, err error) {
+	if t.file == nil {
+		if err := t.open(nil, true); err != nil {
+			return false, err
+		}
+	}
+	if err := t.drain(emit); err != nil {
+		return false, err
+	}
+
+	current, err := os.Stat(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil // moved away, new file not created yet
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case !os.SameFile(current, t.info):
+		// rename+create: the old file is fully read, continue with the new one
+		t.flushPartial(emit)
+		t.close()
+		if err := t.open(nil, true); err != nil {
+			return true, err
+		}
+	case current.Size() < t.offset:
+		// copytruncate: same file, cut back to zero
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return true, err
+		}
+		t.offset, t.partial = 0, nil
+	default:
+		return false, nil
+	}
+	return true, t.drain(emit)
+}
+
+func (t *tailer) drain(emit func(line string)) error {
+	buf := make([]byte, readChunk)
+	for {
+		n, err := t.file.Read(buf)
+		if n > 0 {
+			t.offset += int64(n)
+			t.split(buf[:n], emit)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *tailer) split(data []byte, emit func(line string)) {
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			t.partial = append(t.partial, data...)
+			if len(t.partial) > maxLineLength {
+				t.flushPartial(emit) // very long line: emit what we have
+			}
+			return
+		}
+		line := append(t.partial, data[:i]...)
+		t.partial = nil
+		emit(string(bytes.TrimRight(line, "\r")))
+		data = data[i+1:]
+	}
+}
+
+func (t *tailer) flushPartial(emit func(line string)) {
+	if len(t.partial) > 0 {
+		emit(string(t.partial))
+		t.partial = nil
+	}
+}
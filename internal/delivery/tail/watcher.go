@@ -0,0 +1,254 @@
+// Package tail follows local log files (paths or globs) and feeds new lines
+// into the ingest pipeline, surviving rename+create and copytruncate rotation.
+package tail
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+	uc "github.com/ifs21014-itdel/log-analyzer/internal/usecase"
+)
+
+const (
+	defaultPollInterval   = time.Second
+	notifiedPollInterval  = 5 * time.Second // safety net when fsnotify works
+	checkpointInterval    = 5 * time.Second
+	rescanEveryPolls      = 10
+	dropMissingAfter      = 30 * time.Second // a renamed file may still be written until then
+	defaultTailFormatName = "raw"
+)
+
+type Config struct {
+	Paths        []string // files or globs
+	Format       string   // format of streams created for the files
+	PollInterval time.Duration
+	FromStart    bool // read new files from the beginning instead of the end
+}
+
+// ConfigFromEnv reads TAIL_* variables; enabled is false without TAIL_PATHS.
+func ConfigFromEnv() (cfg Config, enabled bool, err error) {
+	for _, p := range strings.Split(os.Getenv("TAIL_PATHS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Paths = append(cfg.Paths, p)
+		}
+	}
+	cfg.Format = os.Getenv("TAIL_FORMAT")
+	if cfg.Format == "" {
+		cfg.Format = defaultTailFormatName
+	}
+	if raw := os.Getenv("TAIL_POLL_INTERVAL"); raw != "" {
+		if cfg.PollInterval, err = time.ParseDuration(raw); err != nil {
+			return cfg, false, err
+		}
+	}
+	cfg.FromStart = os.Getenv("TAIL_FROM_START") == "true"
+	return cfg, len(cfg.Paths) > 0, nil
+}
+
+type Watcher struct {
+	cfg         Config
+	collector   *uc.Collector
+	checkpoints repository.CheckpointRepository
+	tailers     map[string]*tailer
+	hostname    string
+}
+
+func NewWatcher(cfg Config, collector *uc.Collector, checkpoints repository.CheckpointRepository) *Watcher {
+	host, _ := os.Hostname()
+	return &Watcher{
+		cfg:         cfg,
+		collector:   collector,
+		checkpoints: checkpoints,
+		tailers:     make(map[string]*tailer),
+		hostname:    host,
+	}
+}
+
+// Start watches the parent directories with fsnotify and falls back to
+// polling alone when notifications are not available.
+func (w *Watcher) Start() error {
+	for _, p := range w.cfg.Paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	var events chan fsnotify.Event
+	interval := w.cfg.PollInterval
+	fsw, err := fsnotify.NewWatcher()
+	if err == nil {
+		events = fsw.Events
+		for _, dir := range w.watchDirs() {
+			if err = fsw.Add(dir); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Println("[Tail] file notifications unavailable, polling only:", err)
+		if fsw != nil {
+			fsw.Close()
+		}
+		events = nil
+		if interval == 0 {
+			interval = defaultPollInterval
+		}
+	} else if interval == 0 {
+		interval = notifiedPollInterval
+	}
+
+	w.rescan()
+	go w.run(events, interval)
+	return nil
+}
+
+// watchDirs returns the directories of the configured paths that have no
+// glob characters in the directory part.
+func (w *Watcher) watchDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, p := range w.cfg.Paths {
+		dir := filepath.Dir(p)
+		if strings.ContainsAny(dir, "*?[") || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+func (w *Watcher) run(events chan fsnotify.Event, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	polls := 0
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Rename) {
+				w.rescan()
+			}
+			if t, ok := w.tailers[ev.Name]; ok {
+				w.poll(t)
+			}
+		case <-ticker.C:
+			polls++
+			if polls%rescanEveryPolls == 0 {
+				w.rescan()
+			}
+			for _, t := range w.tailers {
+				w.poll(t)
+			}
+		}
+	}
+}
+
+// rescan expands the globs, starts tailers for new files and drops the
+// ones whose path has been gone for dropMissingAfter.
+func (w *Watcher) rescan() {
+	for path, t := range w.tailers {
+		_, err := os.Stat(path)
+		switch {
+		case !errors.Is(err, os.ErrNotExist):
+			t.missing = time.Time{}
+		case t.missing.IsZero():
+			t.missing = time.Now()
+		case time.Since(t.missing) >= dropMissingAfter:
+			w.drop(t)
+		}
+	}
+	for _, pattern := range w.cfg.Paths {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if _, ok := w.tailers[path]; ok {
+				continue
+			}
+			if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+				continue
+			}
+			w.add(path)
+		}
+	}
+}
+
+func (w *Watcher) add(path string) {
+	cp, err := w.checkpoints.Get(path)
+	if err != nil {
+		log.Printf("[Tail] ❌ checkpoint %s: %v", path, err)
+		return
+	}
+	t := &tailer{path: path, saved: cp}
+	if err := t.open(cp, w.cfg.FromStart); err != nil {
+		log.Printf("[Tail] ❌ open %s: %v", path, err)
+		return
+	}
+	w.tailers[path] = t
+	log.Printf("[Tail] following %s from offset %d", path, t.offset)
+	w.poll(t)
+}
+
+// drop reads what is left of a deleted file and stops following it. The
+// checkpoint stays, so a file created again at the path is read from its start.
+func (w *Watcher) drop(t *tailer) {
+	w.poll(t)
+	t.close()
+	delete(w.tailers, t.path)
+	log.Printf("[Tail] %s removed, stopped following", t.path)
+}
+
+func (w *Watcher) poll(t *tailer) {
+	stream, parse, err := w.collector.Stream(streamName(t.path))
+	if err != nil {
+		log.Printf("[Tail] ❌ stream for %s: %v", t.path, err)
+		return
+	}
+	labels := map[string]string{"path": t.path}
+	if w.hostname != "" {
+		labels["host"] = w.hostname
+	}
+
+	rotated, err := t.poll(func(line string) {
+		if rec, ok := parse(line); ok {
+			w.collector.Add(stream, labels, rec)
+		}
+	})
+	if err != nil {
+		log.Printf("[Tail] ❌ read %s: %v", t.path, err)
+	}
+	if rotated {
+		log.Printf("[Tail] %s rotated, continuing at offset %d", t.path, t.offset)
+	}
+
+	// checkpoints are saved when lines reach the collector, which pushes
+	// them up to a second later, and at most every checkpointInterval: a
+	// crash can drop the lines still buffered and replay the ones read
+	// since the last checkpoint. Every poll checks, so the last lines of a
+	// burst are saved once the interval passes even if nothing follows.
+	cp := t.checkpoint()
+	if rotated || (t.unsaved(cp) && time.Since(t.savedAt) >= checkpointInterval) {
+		if err := w.checkpoints.Save(cp); err != nil {
+			log.Printf("[Tail] ❌ save checkpoint %s: %v", t.path, err)
+			return
+		}
+		t.saved, t.savedAt = cp, time.Now()
+	}
+}
+
+// streamName: one stream per file, named after the file ("access.log")
+func streamName(path string) string {
+	name := uc.SanitizeStreamName(filepath.Base(path))
+	if name == "" {
+		name = "tail"
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package domain
+
+import "time"
+
+// AlertRule watches analyses whose labels include Labels, e.g.
+// {"service": "checkout"}, with a condition such as
+// "error_rate > 5% for 10m" or "change(requests) < -50%".
+type AlertRule struct {
+	ID          uint              `json:"id"`
+	UserID      uint              `json:"user_id"`
+	OrgID       uint              `json:"org_id"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels"`
+	Expr        string            `json:"expr"`
+	MinRequests int               `json:"min_requests"` // ignore analyses with fewer requests
+	Notify      []NotifyTarget    `json:"notify"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+const (
+	AlertStateOK       = "ok" // not breaching; kept for the change() baseline
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// Alert is the state of one rule for one label set (series). There is one
+// row per rule and series, so repeated breaches update it instead of
+// piling up duplicates.
+type Alert struct {
+	ID             uint              `json:"id"`
+	RuleID         uint              `json:"rule_id"`
+	RuleName       string            `json:"rule_name"`
+	SeriesKey      string            `json:"-"`
+	Labels         map[string]string `json:"labels"`
+	State          string            `json:"state"`
+	Value          float64           `json:"value"`
+	PrevValue      *float64          `json:"prev_value,omitempty"`
+	AnalysisID     uint              `json:"analysis_id"`
+	ActiveSince    *time.Time        `json:"active_since"`
+	FiredAt        *time.Time        `json:"fired_at"`
+	ResolvedAt     *time.Time        `json:"resolved_at"`
+	LastEvalAt     time.Time         `json:"last_eval_at"`
+	NotifiedState  string            `json:"notified_state,omitempty"` // last state sent to the notifiers
+	LastNotifiedAt *time.Time        `json:"last_notified_at"`
+	Silenced       bool              `json:"silenced"`
+}
+
+// AlertSilence mutes notifications of alerts matching RuleID (0 = any
+// rule) and Labels between StartsAt and EndsAt. Alert states still change.
+type AlertSilence struct {
+	ID        uint              `json:"id"`
+	UserID    uint              `json:"user_id"`
+	OrgID     uint              `json:"org_id"`
+	RuleID    uint              `json:"rule_id,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Comment   string            `json:"comment"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Matches reports whether the silence covers an alert of ruleID with labels.
+func (s *AlertSilence) Matches(ruleID uint, labels map[string]string, at time.Time) bool {
+	if at.Before(s.StartsAt) || !at.Before(s.EndsAt) {
+		return false
+	}
+	if s.RuleID != 0 && s.RuleID != ruleID {
+		return false
+	}
+	for k, v := range s.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// APIKey lets a machine client act as its user in one org, limited to
+// Scopes (read, upload, admin). Only the hash of the key is stored; Prefix
+// is its first part, shown in lists and used to look it up.
+type APIKey struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	OrgID      uint       `json:"org_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+const (
+	AuditTOTPReset          = "totp.reset"
+	AuditRecoveryCodeUsed   = "totp.recovery_code_used"
+	AuditRecoveryCodesRegen = "totp.recovery_codes_regenerated"
+	AuditLoginLocked        = "login.locked"
+	AuditLoginUnlocked      = "login.unlocked"
+	AuditPasswordReset      = "password.reset"
+	AuditUserRoleChanged    = "user.role_changed"
+	AuditUserDisabled       = "user.disabled"
+	AuditUserEnabled        = "user.enabled"
+	AuditOrgMemberAdded     = "org.member_added"
+	AuditOrgMemberRole      = "org.member_role_changed"
+	AuditOrgMemberRemoved   = "org.member_removed"
+	AuditAPIKeyCreated      = "api_key.created"
+	AuditAPIKeyRevoked      = "api_key.revoked"
+)
+
+// AuditEntry records who did what to which user.
+type AuditEntry struct {
+	ID           uint      `json:"id"`
+	ActorID      uint      `json:"actor_id"`
+	Action       string    `json:"action"`
+	TargetUserID uint      `json:"target_user_id,omitempty"`
+	Detail       string    `json:"detail,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RecoveryCode is a hashed one-time code that stands in for a TOTP code.
+type RecoveryCode struct {
+	ID       uint
+	UserID   uint
+	CodeHash string
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// AnalysisFilter dipakai untuk mempersempit list analysis
+type AnalysisFilter struct {
+	Labels     map[string]string
+	UserID     uint       // 0 = semua user
+	OrgID      uint       // 0 = semua org
+	SharedWith uint       // with OrgID: also analyses shared with this user
+	AllOrgs    bool       // admins: every org instead of the active one
+	From       *time.Time // window start, or created_at for uploads
+	To         *time.Time
+}
+
+// LabelReportRow is one group of a label-grouped report.
+type LabelReportRow struct {
+	Group           map[string]string `json:"group"`
+	Analyses        int               `json:"analyses"`
+	TotalRequests   int               `json:"total_requests"`
+	ErrorCount      int               `json:"error_count"`
+	AverageResponse float64           `json:"average_response"`
+}
@@ -3,13 +3,73 @@ package domain
 import "time"
 
 type LogAnalysis struct {
-	ID              uint      `gorm:"primaryKey" json:"id"`
-	UserID          uint      `json:"user_id"`
-	Filename        string    `json:"filename"`
-	TotalRequests   int       `json:"total_requests"`
-	UniqueIPs       int       `json:"unique_ips"`
-	ErrorCount      int       `json:"error_count"`
+	ID              uint              `gorm:"primaryKey" json:"id"`
+	UserID          uint              `json:"user_id"` // who uploaded it
+	OrgID           uint              `json:"org_id"`
+	Filename        string            `json:"filename"`
+	TotalRequests   int               `json:"total_requests"`
+	UniqueIPs       int               `json:"unique_ips"`
+	ErrorCount      int               `json:"error_count"`
+	AverageResponse float64           `json:"average_response"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Details         *AnalysisDetails  `json:"details,omitempty"`
+	StreamID        uint              `json:"stream_id,omitempty"`
+	WindowStart     *time.Time        `json:"window_start,omitempty"`
+	WindowEnd       *time.Time        `json:"window_end,omitempty"`
+	WindowKey       string            `json:"-"` // identifies the label set of a stream window
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// AnalysisShare gives a user read access to someone else's analysis.
+type AnalysisShare struct {
+	AnalysisID uint      `json:"analysis_id"`
+	UserID     uint      `json:"user_id"`
+	Email      string    `json:"email"`
+	SharedBy   uint      `json:"shared_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AnalysisDetails holds the breakdowns behind the summary numbers: the status
+// mix, the busiest endpoints and requests over time.
+type AnalysisDetails struct {
+	StatusCounts map[string]int `json:"status_counts"`
+	TopEndpoints []EndpointStat `json:"top_endpoints"`
+	TimeSeries   []TimeBucket   `json:"time_series"`
+	Latency      *LatencyStats  `json:"latency,omitempty"`
+}
+
+// LatencyStats are response time percentiles in ms over the records that
+// have a latency. Histogram counts records per log-scale bucket (5% wide)
+// so a stream window can keep accumulating after a restart.
+type LatencyStats struct {
+	P50       float64 `json:"p50"`
+	P90       float64 `json:"p90"`
+	P95       float64 `json:"p95"`
+	P99       float64 `json:"p99"`
+	Max       float64 `json:"max"`
+	Histogram []int   `json:"histogram"`
+}
+
+type EndpointStat struct {
+	Method          string  `json:"method"`
+	Path            string  `json:"path"`
+	Requests        int     `json:"requests"`
+	Errors          int     `json:"errors"`
+	AverageResponse float64 `json:"average_response"`
+}
+
+type TimeBucket struct {
+	Start           time.Time `json:"start"`
+	Requests        int       `json:"requests"`
+	Errors          int       `json:"errors"`
 	AverageResponse float64   `json:"average_response"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ErrorRate returns the share of requests that failed, between 0 and 1.
+func (a LogAnalysis) ErrorRate() float64 {
+	if a.TotalRequests == 0 {
+		return 0
+	}
+	return float64(a.ErrorCount) / float64(a.TotalRequests)
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// LogRecord adalah satu baris log yang sudah di-parse
+type LogRecord struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method,omitempty"`
+	Path       string            `json:"path,omitempty"`
+	Status     int               `json:"status,omitempty"`
+	Latency    float64           `json:"latency_ms"` // response time in milliseconds
+	IP         string            `json:"ip,omitempty"`
+	Level      string            `json:"level,omitempty"` // normalized: debug, info, warning, error, critical
+	Message    string            `json:"message,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Raw        string            `json:"raw,omitempty"`
+}
+
+// IsError: status 4xx / 5xx atau level error ke atas dihitung sebagai error
+func (r LogRecord) IsError() bool {
+	return r.Status >= 400 || r.Level == LevelError || r.Level == LevelCritical
+}
+
+const (
+	LevelDebug    = "debug"
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelError    = "error"
+	LevelCritical = "critical"
+)
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts recent failed logins of an account or IP.
+type LoginAttempts struct {
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
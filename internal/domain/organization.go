@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// Organization is a team whose members share analyses, streams, alert rules
+// and saved queries. Members have a per-org role (RoleAdmin, RoleAnalyst,
+// RoleViewer).
+type Organization struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy uint      `json:"created_by,omitempty"`
+	Role      string    `json:"role,omitempty"` // role of the user listing it
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrgMember struct {
+	OrgID     uint      `json:"org_id"`
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrgInvitation lets the owner of Email join the org with Role. Only the
+// hash of the token is stored.
+type OrgInvitation struct {
+	ID         uint       `json:"id"`
+	OrgID      uint       `json:"org_id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  uint       `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// QueryScope: records a query runs over, one analysis or all analyses of
+// the org
+type QueryScope struct {
+	OrgID      uint
+	AnalysisID uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// QueryResult is a table; values are string, float64, int64, time.Time or
+// nil.
+type QueryResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
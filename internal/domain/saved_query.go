@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+const (
+	SavedQueryKindQuery  = "query"  // query language over retained records
+	SavedQueryKindReport = "report" // analyses filtered by labels, optionally grouped
+)
+
+// SavedQuery is a named query or report that can run on a cron schedule
+// and deliver its output to notifiers.
+type SavedQuery struct {
+	ID           uint              `json:"id"`
+	UserID       uint              `json:"user_id"`
+	OrgID        uint              `json:"org_id"`
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	Query        string            `json:"query,omitempty"`
+	AnalysisID   uint              `json:"analysis_id,omitempty"`
+	RangeSeconds int               `json:"range_seconds"` // look back from the run time, 0 = everything
+	Labels       map[string]string `json:"labels"`
+	GroupBy      []string          `json:"group_by"`
+	Format       string            `json:"format"`
+	Schedule     string            `json:"schedule"` // cron expression, "" = manual only
+	Notify       []NotifyTarget    `json:"notify"`
+	Enabled      bool              `json:"enabled"`
+	NextRunAt    *time.Time        `json:"next_run_at"`
+	LastRunAt    *time.Time        `json:"last_run_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// NotifyTarget: Type adalah nama notifier (webhook, email), Target URL/alamatnya
+type NotifyTarget struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+const (
+	RunTriggerSchedule = "schedule"
+	RunTriggerManual   = "manual"
+	RunStatusOK        = "ok"
+	RunStatusFailed    = "failed"
+)
+
+// ReportRun is one execution of a saved query with its stored output.
+type ReportRun struct {
+	ID            uint      `json:"id"`
+	SavedQueryID  uint      `json:"saved_query_id"`
+	Trigger       string    `json:"trigger"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	DeliveryError string    `json:"delivery_error,omitempty"`
+	Filename      string    `json:"filename,omitempty"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Size          int       `json:"size"`
+	Output        []byte    `json:"-"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// StoredRecord is a parsed line kept in log_records for drill-down
+type StoredRecord struct {
+	AnalysisID uint  `json:"analysis_id"`
+	Line       int64 `json:"line,omitempty"` // line number in the uploaded file
+	LogRecord
+}
+
+// RecordFilter: semua field opsional, zero value = tidak difilter
+type RecordFilter struct {
+	AnalysisID uint
+	Stream     bool // analysis is a stream window: search the day partitions
+	Status     int
+	StatusMin  int
+	StatusMax  int
+	Method     string
+	Path       string // exact match, or prefix when it ends with *
+	IP         string
+	LatencyMin *float64
+	LatencyMax *float64
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// SearchTerm adalah satu bagian query full-text. Text may contain * and ?
+// wildcards unless it is a phrase.
+type SearchTerm struct {
+	Text   string
+	Phrase bool
+	Negate bool
+}
+
+// SearchFilter: search within one analysis, or across all analyses of the
+// org when AnalysisID is 0
+type SearchFilter struct {
+	OrgID      uint
+	AnalysisID uint
+	Terms      []SearchTerm
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// SearchHit is a matching line with the matches marked in Snippet
+type SearchHit struct {
+	StoredRecord
+	Snippet string `json:"snippet"`
+}
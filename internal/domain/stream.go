@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Stream is a named source that pushes log lines continuously. Lines are
+// parsed with Format and rolled up into one analysis per time window.
+type Stream struct {
+	ID            uint              `json:"id"`
+	UserID        uint              `json:"user_id"`
+	OrgID         uint              `json:"org_id"`
+	Name          string            `json:"name"`
+	Format        string            `json:"format"`
+	WindowSeconds int               `json:"window_seconds"`
+	Labels        map[string]string `json:"labels"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+func (s *Stream) Window() time.Duration {
+	return time.Duration(s.WindowSeconds) * time.Second
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// TailCheckpoint is how far a watched file has been read. Inode tells a
+// rotated file apart from the one the offset belongs to.
+type TailCheckpoint struct {
+	Path      string    `json:"path"`
+	Inode     uint64    `json:"inode"`
+	Offset    int64     `json:"offset"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
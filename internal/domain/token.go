@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// RefreshToken is one link of a refresh token family. A family is one
+// login session; only its newest unused token can be refreshed.
+type RefreshToken struct {
+	ID              uint       `json:"id"`
+	UserID          uint       `json:"user_id"`
+	FamilyID        string     `json:"family_id"`
+	TokenHash       string     `json:"-"`
+	AccessJTI       string     `json:"-"`
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	UsedAt          *time.Time `json:"used_at,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RevokedToken is an access token on the denylist until it expires.
+type RevokedToken struct {
+	JTI       string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// TokenPair is what login and refresh hand out.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
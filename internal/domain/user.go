@@ -2,13 +2,59 @@ package domain
 
 import "time"
 
+const (
+	RoleAdmin   = "admin"   // manages users, sees all analyses
+	RoleAnalyst = "analyst" // uploads and edits their own analyses
+	RoleViewer  = "viewer"  // reads their orgs' data and analyses shared with them
+)
+
+// ValidRole reports whether role is one of the roles above.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleAnalyst, RoleViewer:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Email        string    `gorm:"uniqueIndex;not null" json:"email"`
-	PasswordHash string    `json:"-"`
-	Name         string    `json:"name"`
-	TOTPSecret   string    `json:"-"`
-	TOTPEnabled  bool      `json:"totp_enabled"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Email         string     `gorm:"uniqueIndex;not null" json:"email"`
+	PasswordHash  string     `json:"-"`
+	Name          string     `json:"name"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	ActiveOrgID   uint       `json:"active_org_id,omitempty"`
+	TOTPSecret    string     `json:"-"`
+	TOTPPending   string     `json:"-"` // secret from setup, until verified
+	TOTPEnabled   bool       `json:"totp_enabled"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Actor is the authenticated user behind a request, in their active org.
+type Actor struct {
+	UserID  uint
+	Role    string
+	OrgID   uint
+	OrgRole string
+}
+
+func (a Actor) IsAdmin() bool {
+	return a.Role == RoleAdmin
+}
+
+// IsOrgAdmin: manages the members of the active org and everything in it.
+func (a Actor) IsOrgAdmin() bool {
+	return a.IsAdmin() || a.OrgRole == RoleAdmin
+}
+
+// CanWrite: may create things in the active org. Viewers, by user or by
+// org role, only read.
+func (a Actor) CanWrite() bool {
+	if a.IsAdmin() {
+		return true
+	}
+	return a.Role != RoleViewer && (a.OrgRole == RoleAdmin || a.OrgRole == RoleAnalyst)
 }
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeEmailVerify   = "email_verify"
+)
+
+// UserToken is a single-use token sent by email; only its hash is stored.
+type UserToken struct {
+	ID        uint
+	UserID    uint
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
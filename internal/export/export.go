@@ -0,0 +1,189 @@
+// Package export renders analyses as CSV, JSON, Excel or a standalone HTML report.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/xlsx"
+)
+
+// Report adalah data yang di-export: satu analysis atau hasil filter list
+type Report struct {
+	Title       string
+	Analyses    []domain.LogAnalysis
+	Single      bool // export satu analysis (JSON jadi object, bukan array)
+	GeneratedAt time.Time
+}
+
+type format struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, r Report) error
+}
+
+var formats = map[string]format{
+	"csv":  {"text/csv; charset=utf-8", "csv", writeCSV},
+	"json": {"application/json; charset=utf-8", "json", writeJSON},
+	"xlsx": {xlsx.ContentType, "xlsx", writeXLSX},
+	"html": {"text/html; charset=utf-8", "html", writeHTML},
+}
+
+// Supported reports whether the format name is known.
+func Supported(name string) bool {
+	_, ok := formats[name]
+	return ok
+}
+
+func ContentType(name string) string {
+	return formats[name].contentType
+}
+
+// Filename returns a download name such as "analysis-12.csv".
+func Filename(base, name string) string {
+	return base + "." + formats[name].extension
+}
+
+func Write(w io.Writer, name string, r Report) error {
+	f, ok := formats[name]
+	if !ok {
+		return fmt.Errorf("unsupported export format %q (use csv, json, xlsx or html)", name)
+	}
+	if r.GeneratedAt.IsZero() {
+		r.GeneratedAt = time.Now().UTC()
+	}
+	return f.write(w, r)
+}
+
+var summaryHeader = []string{
+	"id", "user_id", "filename", "total_requests", "unique_ips", "error_count",
+	"error_rate", "average_response", "labels", "created_at", "updated_at",
+}
+
+func summaryRow(a *domain.LogAnalysis) []interface{} {
+	return []interface{}{
+		a.ID, a.UserID, a.Filename, a.TotalRequests, a.UniqueIPs, a.ErrorCount,
+		a.ErrorRate(), a.AverageResponse, formatLabels(a.Labels), a.CreatedAt, a.UpdatedAt,
+	}
+}
+
+// formatLabels -> "env=prod,service=checkout" (urut berdasarkan key)
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + labels[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func writeCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(summaryHeader); err != nil {
+		return err
+	}
+	for i := range r.Analyses {
+		row := summaryRow(&r.Analyses[i])
+		record := make([]string, len(row))
+		for j, v := range row {
+			record[j] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case time.Time:
+		return t.Format(time.RFC3339)
+	case string:
+		return csvText(t)
+	}
+	return fmt.Sprint(v)
+}
+
+// csvText keeps spreadsheets from running text (file names, labels) that
+// looks like a formula: it gets a leading quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if r.Single && len(r.Analyses) == 1 {
+		return enc.Encode(r.Analyses[0])
+	}
+	analyses := r.Analyses
+	if analyses == nil {
+		analyses = []domain.LogAnalysis{}
+	}
+	return enc.Encode(analyses)
+}
+
+func writeXLSX(w io.Writer, r Report) error {
+	wb := xlsx.New()
+
+	summary := wb.AddSheet("Analyses")
+	summary.AddHeader(summaryHeader...)
+	for i := range r.Analyses {
+		summary.AddRow(summaryRow(&r.Analyses[i])...)
+	}
+
+	status := wb.AddSheet("Status")
+	status.AddHeader("analysis_id", "status", "count")
+	endpoints := wb.AddSheet("Endpoints")
+	endpoints.AddHeader("analysis_id", "method", "path", "requests", "errors", "average_response")
+	series := wb.AddSheet("Time Series")
+	series.AddHeader("analysis_id", "start", "requests", "errors", "average_response")
+
+	for _, a := range r.Analyses {
+		if a.Details == nil {
+			continue
+		}
+		for _, s := range sortedStatuses(a.Details.StatusCounts) {
+			status.AddRow(a.ID, s.code, s.count)
+		}
+		for _, e := range a.Details.TopEndpoints {
+			endpoints.AddRow(a.ID, e.Method, e.Path, e.Requests, e.Errors, e.AverageResponse)
+		}
+		for _, b := range a.Details.TimeSeries {
+			series.AddRow(a.ID, b.Start, b.Requests, b.Errors, b.AverageResponse)
+		}
+	}
+	return wb.Write(w)
+}
+
+type statusCount struct {
+	code  string
+	count int
+}
+
+func sortedStatuses(counts map[string]int) []statusCount {
+	list := make([]statusCount, 0, len(counts))
+	for code, n := range counts {
+		list = append(list, statusCount{code, n})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].code < list[j].code })
+	return list
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+var exportT0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestCSVFormulaEscaping(t *testing.T) {
+	names := []string{
+		`=HYPERLINK("http://evil.example","x")`,
+		"+1+2",
+		"-2+3",
+		"@SUM(A1)",
+		"\tindented",
+		"\rreturn",
+		"plain.log",
+		"a=b.log",
+		"",
+	}
+	var r Report
+	for i, n := range names {
+		r.Analyses = append(r.Analyses, domain.LogAnalysis{
+			ID: uint(i + 1), Filename: n, TotalRequests: 4, ErrorCount: 1, AverageResponse: -1.5,
+			Labels: map[string]string{"env": "=cmd", "app": "web"}, CreatedAt: exportT0, UpdatedAt: exportT0,
+		})
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "csv", r); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], summaryHeader) {
+		t.Errorf("header %q", records[0])
+	}
+	want := []string{
+		`'=HYPERLINK("http://evil.example","x")`, "'+1+2", "'-2+3", "'@SUM(A1)", "'\tindented", "'\rreturn", "plain.log", "a=b.log", "",
+	}
+	for i, rec := range records[1:] {
+		if rec[2] != want[i] {
+			t.Errorf("filename %q written as %q, want %q", names[i], rec[2], want[i])
+		}
+		// labels are text too; numbers stay numbers, even negative ones
+		if rec[8] != "app=web,env==cmd" {
+			t.Errorf("labels %q", rec[8])
+		}
+		if rec[6] != "0.25" || rec[7] != "-1.5" || rec[9] != "2026-03-01T12:00:00Z" {
+			t.Errorf("row %q", rec)
+		}
+	}
+
+	// the first label key starts the field
+	buf.Reset()
+	r = Report{Analyses: []domain.LogAnalysis{{Labels: map[string]string{"=x": "1"}}}}
+	if err := Write(&buf, "csv", r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ",'=x=1,") {
+		t.Errorf("label key starting with = not escaped:\n%s", buf.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	a := domain.LogAnalysis{ID: 3, Filename: "x.log"}
+	cases := []struct {
+		r    Report
+		want string
+	}{
+		{Report{}, "[]"},
+		{Report{Analyses: []domain.LogAnalysis{a}}, "["},
+		{Report{Analyses: []domain.LogAnalysis{a}, Single: true}, "{"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := Write(&buf, "json", c.r); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), c.want) || !json.Valid(buf.Bytes()) {
+			t.Errorf("JSON %s, want it to start with %s", buf.String(), c.want)
+		}
+	}
+}
+
+func TestXLSXAndHTML(t *testing.T) {
+	r := Report{Title: "<script>", Analyses: []domain.LogAnalysis{{
+		ID: 1, Filename: "=x", AverageResponse: math.NaN(), CreatedAt: exportT0,
+		Details: &domain.AnalysisDetails{StatusCounts: map[string]int{"200": 3, "500": 1}},
+	}}}
+	var buf bytes.Buffer
+	if err := Write(&buf, "xlsx", r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("PK")) {
+		t.Error("xlsx is not a zip file")
+	}
+
+	buf.Reset()
+	if err := Write(&buf, "html", r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Error("title not escaped in the HTML report")
+	}
+}
+
+func TestFormats(t *testing.T) {
+	if !Supported("xlsx") || Supported("xml") {
+		t.Error("Supported")
+	}
+	if got := Filename("analysis-12", "csv"); got != "analysis-12.csv" {
+		t.Errorf("Filename = %s", got)
+	}
+	if err := Write(&bytes.Buffer{}, "xml", Report{}); err == nil {
+		t.Error("xml accepted")
+	}
+}
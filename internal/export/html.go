@@ -0,0 +1,221 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// The HTML report is a single file: styles and charts (inline SVG) are
+// embedded so it can be attached to a ticket and opened offline.
+
+const (
+	chartWidth  = 720
+	chartHeight = 220
+	chartPad    = 36
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent":       func(f float64) string { return fmt.Sprintf("%.2f%%", f*100) },
+	"ms":            func(f float64) string { return fmt.Sprintf("%.1f ms", f) },
+	"labels":        formatLabels,
+	"timeChart":     timeChart,
+	"statusChart":   statusChart,
+	"endpointChart": endpointChart,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;margin:24px;color:#222;background:#fafafa}
+h1{font-size:22px;margin-bottom:4px}h2{font-size:18px;margin-top:32px;border-bottom:1px solid #ddd;padding-bottom:4px}
+h3{font-size:14px;margin:20px 0 6px;color:#555}
+.meta{color:#777;font-size:12px}
+.cards{display:flex;flex-wrap:wrap;gap:12px;margin:12px 0}
+.card{background:#fff;border:1px solid #e3e3e3;border-radius:6px;padding:10px 14px;min-width:120px}
+.card .v{font-size:20px;font-weight:600}.card .k{font-size:11px;color:#777;text-transform:uppercase}
+table{border-collapse:collapse;background:#fff;font-size:13px;margin:8px 0}
+th,td{border:1px solid #e3e3e3;padding:4px 8px;text-align:left}th{background:#f2f2f2}
+td.n{text-align:right;font-variant-numeric:tabular-nums}
+svg{background:#fff;border:1px solid #e3e3e3;border-radius:6px}
+svg text{font-size:11px;fill:#555}
+.label{display:inline-block;background:#eef3fb;border-radius:3px;padding:1px 6px;margin-right:4px;font-size:12px}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}} &middot; {{len .Analyses}} analysis(es)</div>
+{{if gt (len .Analyses) 1}}
+<h2>Summary</h2>
+<table>
+<tr><th>ID</th><th>File</th><th>Labels</th><th>Requests</th><th>Errors</th><th>Error rate</th><th>Unique IPs</th><th>Avg response</th><th>Created</th></tr>
+{{range .Analyses}}<tr><td>{{.ID}}</td><td>{{.Filename}}</td><td>{{labels .Labels}}</td><td class="n">{{.TotalRequests}}</td><td class="n">{{.ErrorCount}}</td><td class="n">{{percent .ErrorRate}}</td><td class="n">{{.UniqueIPs}}</td><td class="n">{{ms .AverageResponse}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Analyses}}
+<h2>#{{.ID}} {{.Filename}}</h2>
+{{range $k, $v := .Labels}}<span class="label">{{$k}}={{$v}}</span>{{end}}
+<div class="cards">
+<div class="card"><div class="k">Requests</div><div class="v">{{.TotalRequests}}</div></div>
+<div class="card"><div class="k">Errors</div><div class="v">{{.ErrorCount}}</div></div>
+<div class="card"><div class="k">Error rate</div><div class="v">{{percent .ErrorRate}}</div></div>
+<div class="card"><div class="k">Unique IPs</div><div class="v">{{.UniqueIPs}}</div></div>
+<div class="card"><div class="k">Avg response</div><div class="v">{{ms .AverageResponse}}</div></div>
+</div>
+{{with .Details}}
+<h3>Requests over time</h3>
+{{timeChart .TimeSeries}}
+<h3>Status mix</h3>
+{{statusChart .StatusCounts}}
+<h3>Top endpoints</h3>
+{{endpointChart .TopEndpoints}}
+<table>
+<tr><th>Method</th><th>Path</th><th>Requests</th><th>Errors</th><th>Avg response</th></tr>
+{{range .TopEndpoints}}<tr><td>{{.Method}}</td><td>{{.Path}}</td><td class="n">{{.Requests}}</td><td class="n">{{.Errors}}</td><td class="n">{{ms .AverageResponse}}</td></tr>
+{{end}}</table>
+{{else}}
+<p class="meta">No breakdown stored for this analysis.</p>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, r Report) error {
+	if r.Title == "" {
+		r.Title = "Log analysis report"
+	}
+	return reportTemplate.Execute(w, r)
+}
+
+func emptyChart(msg string) template.HTML {
+	return template.HTML(fmt.Sprintf(
+		`<svg width="%d" height="40" xmlns="http://www.w3.org/2000/svg"><text x="12" y="24">%s</text></svg>`,
+		chartWidth, template.HTMLEscapeString(msg)))
+}
+
+// timeChart draws requests (blue) and errors (red) per bucket as lines.
+func timeChart(series []domain.TimeBucket) template.HTML {
+	if len(series) == 0 {
+		return emptyChart("No timestamps in this log.")
+	}
+	maxY := 1
+	for _, b := range series {
+		if b.Requests > maxY {
+			maxY = b.Requests
+		}
+	}
+
+	plotW := float64(chartWidth - 2*chartPad)
+	plotH := float64(chartHeight - 2*chartPad)
+	x := func(i int) float64 {
+		if len(series) == 1 {
+			return chartPad + plotW/2
+		}
+		return chartPad + plotW*float64(i)/float64(len(series)-1)
+	}
+	y := func(v int) float64 { return chartPad + plotH - plotH*float64(v)/float64(maxY) }
+
+	var req, errs []string
+	for i, b := range series {
+		req = append(req, fmt.Sprintf("%.1f,%.1f", x(i), y(b.Requests)))
+		errs = append(errs, fmt.Sprintf("%.1f,%.1f", x(i), y(b.Errors)))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ccc"/>`, chartPad, chartHeight-chartPad, chartWidth-chartPad, chartHeight-chartPad)
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ccc"/>`, chartPad, chartPad, chartPad, chartHeight-chartPad)
+	fmt.Fprintf(&sb, `<text x="4" y="%d">%d</text><text x="4" y="%d">0</text>`, chartPad+4, maxY, chartHeight-chartPad+4)
+	fmt.Fprintf(&sb, `<polyline fill="none" stroke="#3b78c4" stroke-width="2" points="%s"/>`, strings.Join(req, " "))
+	fmt.Fprintf(&sb, `<polyline fill="none" stroke="#d64541" stroke-width="2" points="%s"/>`, strings.Join(errs, " "))
+	if len(series) == 1 {
+		fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="3" fill="#3b78c4"/>`, x(0), y(series[0].Requests))
+	}
+	first, last := series[0].Start, series[len(series)-1].Start
+	fmt.Fprintf(&sb, `<text x="%d" y="%d">%s</text>`, chartPad, chartHeight-12, first.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-chartPad, chartHeight-12, last.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&sb, `<text x="%d" y="16" text-anchor="end"><tspan fill="#3b78c4">&#9632; requests</tspan> <tspan fill="#d64541">&#9632; errors</tspan></text>`, chartWidth-chartPad)
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+func statusColor(code string) string {
+	switch {
+	case strings.HasPrefix(code, "2"):
+		return "#4caf50"
+	case strings.HasPrefix(code, "3"):
+		return "#2196f3"
+	case strings.HasPrefix(code, "4"):
+		return "#ff9800"
+	case strings.HasPrefix(code, "5"):
+		return "#d64541"
+	}
+	return "#9e9e9e"
+}
+
+// statusChart draws one horizontal bar per status code.
+func statusChart(counts map[string]int) template.HTML {
+	list := sortedStatuses(counts)
+	if len(list) == 0 {
+		return emptyChart("No status codes recorded.")
+	}
+	values := make([]float64, len(list))
+	names := make([]string, len(list))
+	colors := make([]string, len(list))
+	for i, s := range list {
+		values[i] = float64(s.count)
+		names[i] = s.code
+		colors[i] = statusColor(s.code)
+	}
+	return barChart(names, values, colors, func(v float64) string { return fmt.Sprintf("%.0f", v) })
+}
+
+func endpointChart(list []domain.EndpointStat) template.HTML {
+	if len(list) == 0 {
+		return emptyChart("No endpoints recorded.")
+	}
+	values := make([]float64, len(list))
+	names := make([]string, len(list))
+	colors := make([]string, len(list))
+	for i, e := range list {
+		values[i] = float64(e.Requests)
+		names[i] = strings.TrimSpace(e.Method + " " + e.Path)
+		colors[i] = "#3b78c4"
+	}
+	return barChart(names, values, colors, func(v float64) string { return fmt.Sprintf("%.0f", v) })
+}
+
+func barChart(names []string, values []float64, colors []string, format func(float64) string) template.HTML {
+	const barH, gap, labelW = 18, 6, 220
+	maxV := 0.0
+	for _, v := range values {
+		maxV = math.Max(maxV, v)
+	}
+	if maxV == 0 {
+		maxV = 1
+	}
+	height := len(values)*(barH+gap) + gap
+	plotW := float64(chartWidth - labelW - 60)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, height, chartWidth, height)
+	for i, v := range values {
+		top := gap + i*(barH+gap)
+		w := plotW * v / maxV
+		name := names[i]
+		if r := []rune(name); len(r) > 34 {
+			name = string(r[:33]) + "…"
+		}
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelW-8, top+13, template.HTMLEscapeString(name))
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, labelW, top, w, barH, colors[i])
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%d">%s</text>`, float64(labelW)+w+6, top+13, format(v))
+	}
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ranges that are not public but not covered by net.IP's helpers
+var reservedNets = mustCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+// Allowlist guards the URLs webhook and Slack targets may reach. Loopback,
+// link-local, private and reserved addresses are refused, so a saved rule
+// cannot make the server call its own network, unless an entry allows them.
+// Entries are IPs, CIDRs or host names (NOTIFY_ALLOW_HOSTS).
+type Allowlist struct {
+	nets  []*net.IPNet
+	hosts map[string]bool
+}
+
+func NewAllowlist(entries []string) (*Allowlist, error) {
+	a := &Allowlist{hosts: make(map[string]bool)}
+	for _, e := range entries {
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+		case strings.Contains(e, "/"):
+			_, n, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, fmt.Errorf("notify: invalid allowed range %q", e)
+			}
+			a.nets = append(a.nets, n)
+		case net.ParseIP(e) != nil:
+			ip := net.ParseIP(e)
+			a.nets = append(a.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+		default:
+			a.hosts[e] = true
+		}
+	}
+	return a, nil
+}
+
+// validate checks a target URL when it is saved. The check is repeated on
+// every connection, since DNS may answer differently later.
+func (a *Allowlist) validate(kind, target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%s target must be an http(s) URL, got %q", kind, target)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := a.resolve(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("%s target %q: %w", kind, target, err)
+	}
+	return nil
+}
+
+// resolve returns the addresses of host, failing if any of them is not
+// allowed.
+func (a *Allowlist) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.ToLower(host)
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+		if !a.allowed(host, addr.IP) {
+			return nil, fmt.Errorf("address %s of %s is not public (allow it with NOTIFY_ALLOW_HOSTS)", addr.IP, host)
+		}
+	}
+	return ips, nil
+}
+
+func (a *Allowlist) allowed(host string, ip net.IP) bool {
+	if public(ip) || a.hosts[host] {
+		return true
+	}
+	for _, n := range a.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialContext connects to the checked addresses themselves, so the name is
+// not looked up a second time between the check and the connection.
+func (a *Allowlist) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := a.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	errs := make([]error, 0, len(ips))
+	for _, ip := range ips {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// client dials through the allowlist, redirects included. No proxy is used:
+// it would connect to the target without the check.
+func (a *Allowlist) client() *http.Client {
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         a.dialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+func mustCIDRs(list ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(list))
+	for i, s := range list {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
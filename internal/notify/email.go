@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/pkg/mailer"
+)
+
+// Email sends the message through the mailer; the target is one address or
+// a comma-separated list.
+type Email struct {
+	mail mailer.Mailer
+}
+
+func NewEmail(mail mailer.Mailer) *Email {
+	return &Email{mail: mail}
+}
+
+func (e *Email) Validate(target string) error {
+	_, err := recipients(target)
+	return err
+}
+
+func (e *Email) Notify(ctx context.Context, target string, m Message) error {
+	to, err := recipients(target)
+	if err != nil {
+		return err
+	}
+	msg := mailer.Message{To: to, Subject: m.Subject, Text: m.Text}
+	for _, a := range m.Attachments {
+		msg.Attachments = append(msg.Attachments, mailer.Attachment(a))
+	}
+	return e.mail.Send(msg)
+}
+
+func recipients(target string) ([]string, error) {
+	list, err := mail.ParseAddressList(target)
+	if err != nil || len(list) == 0 || strings.ContainsAny(target, "\r\n") {
+		return nil, fmt.Errorf("email target must be an address list, got %q", target)
+	}
+	to := make([]string, len(list))
+	for i, a := range list {
+		to[i] = a.String()
+	}
+	return to, nil
+}
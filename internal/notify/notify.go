@@ -0,0 +1,76 @@
+// Package notify delivers report output and alerts to external channels.
+// Each channel is a Notifier registered under a type name ("webhook",
+// "email", ...); targets are a URL or address for that channel.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"` // base64 in JSON
+}
+
+type Message struct {
+	Subject     string
+	Text        string
+	Attachments []Attachment
+	// Fields are extra structured data for channels that can carry it
+	// (webhook JSON); mail only sends Text.
+	Fields map[string]interface{}
+}
+
+type Notifier interface {
+	// Validate checks a target when it is saved, before anything is sent.
+	Validate(target string) error
+	Notify(ctx context.Context, target string, m Message) error
+}
+
+// Target is where one saved report or rule delivers to.
+type Target struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+// Dispatcher routes messages to the registered notifiers.
+type Dispatcher struct {
+	notifiers map[string]Notifier
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{notifiers: make(map[string]Notifier)}
+}
+
+func (d *Dispatcher) Register(kind string, n Notifier) {
+	d.notifiers[kind] = n
+}
+
+// Types lists the registered notifier types.
+func (d *Dispatcher) Types() []string {
+	types := make([]string, 0, len(d.notifiers))
+	for k := range d.notifiers {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (d *Dispatcher) Validate(t Target) error {
+	n, ok := d.notifiers[t.Type]
+	if !ok {
+		return fmt.Errorf("notifier %q is not available (configured: %v)", t.Type, d.Types())
+	}
+	return n.Validate(t.Target)
+}
+
+func (d *Dispatcher) Send(ctx context.Context, t Target, m Message) error {
+	n, ok := d.notifiers[t.Type]
+	if !ok {
+		return fmt.Errorf("notifier %q is not available", t.Type)
+	}
+	return n.Notify(ctx, t.Target, m)
+}
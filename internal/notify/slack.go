@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Slack posts {"text": ...} to an incoming webhook URL. Mattermost,
+// Rocket.Chat and Discord (with /slack appended) accept the same payload.
+// Attachments cannot be uploaded this way, so only their names are listed.
+type Slack struct {
+	client *http.Client
+	allow  *Allowlist
+}
+
+// NewSlack: like webhooks, targets must be public or in allow.
+func NewSlack(allow *Allowlist) *Slack {
+	if allow == nil {
+		allow, _ = NewAllowlist(nil)
+	}
+	return &Slack{client: allow.client(), allow: allow}
+}
+
+func (s *Slack) Validate(target string) error {
+	return s.allow.validate("slack", target)
+}
+
+func (s *Slack) Notify(ctx context.Context, target string, m Message) error {
+	text := "*" + m.Subject + "*\n" + m.Text
+	for _, a := range m.Attachments {
+		text += fmt.Sprintf("\n_%s (%d bytes) is not included here; it is stored with the run_", a.Filename, len(a.Data))
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack: %s returned %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSlackNotify(t *testing.T) {
+	srv, got := endpoint(t, http.StatusOK)
+	s := NewSlack(localAllow(t))
+	m := Message{
+		Subject:     "Daily report",
+		Text:        "120 requests",
+		Attachments: []Attachment{{Filename: "report.csv", Data: []byte("abc")}},
+	}
+	if err := s.Notify(context.Background(), srv.URL, m); err != nil {
+		t.Fatal(err)
+	}
+	r := <-got
+
+	if ct := r.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 1 {
+		t.Errorf("payload has more than text: %v", payload)
+	}
+	text := payload["text"]
+	if !strings.HasPrefix(text, "*Daily report*\n120 requests") || !strings.Contains(text, "report.csv (3 bytes)") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestSlackErrorStatus(t *testing.T) {
+	srv, _ := endpoint(t, http.StatusForbidden)
+	err := NewSlack(localAllow(t)).Notify(context.Background(), srv.URL, Message{Subject: "x"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("err = %v, want the 403 status", err)
+	}
+}
+
+func TestSlackValidate(t *testing.T) {
+	s := NewSlack(nil)
+	if err := s.Validate("http://127.0.0.1:9000/hook"); err == nil {
+		t.Error("loopback target accepted")
+	}
+	if err := s.Validate("not a url"); err == nil {
+		t.Error("invalid target accepted")
+	}
+	if err := NewSlack(localAllow(t)).Validate("http://127.0.0.1:9000/hook"); err != nil {
+		t.Errorf("allowed target refused: %v", err)
+	}
+}
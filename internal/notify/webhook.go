@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const webhookTimeout = 15 * time.Second
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body when a secret
+// is configured, so receivers can check the sender.
+const SignatureHeader = "X-Log-Analyzer-Signature"
+
+// Webhook POSTs the message as JSON:
+// {"subject", "text", "attachments": [{filename, content_type, data}], ...fields}
+type Webhook struct {
+	client *http.Client
+	allow  *Allowlist
+	secret string
+}
+
+// NewWebhook: targets may only reach public addresses and those in allow
+// (nil allows none).
+func NewWebhook(secret string, allow *Allowlist) *Webhook {
+	if allow == nil {
+		allow, _ = NewAllowlist(nil)
+	}
+	return &Webhook{client: allow.client(), allow: allow, secret: secret}
+}
+
+func (w *Webhook) Validate(target string) error {
+	return w.allow.validate("webhook", target)
+}
+
+func (w *Webhook) Notify(ctx context.Context, target string, m Message) error {
+	payload := make(map[string]interface{}, len(m.Fields)+3)
+	for k, v := range m.Fields {
+		payload[k] = v
+	}
+	payload["subject"] = m.Subject
+	payload["text"] = m.Text
+	if len(m.Attachments) > 0 {
+		payload["attachments"] = m.Attachments
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s returned %s", target, resp.Status)
+	}
+	return nil
+}
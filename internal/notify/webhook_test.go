@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// request is what a test endpoint received.
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// endpoint serves httptest requests with status and records them.
+func endpoint(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	got := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- request{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+// localAllow lets notifiers reach the httptest servers on 127.0.0.1.
+func localAllow(t *testing.T) *Allowlist {
+	t.Helper()
+	a, err := NewAllowlist([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func parseIP(t *testing.T, s string) net.IP {
+	t.Helper()
+	ip := net.ParseIP(s)
+	if ip == nil {
+		t.Fatalf("bad IP %q", s)
+	}
+	return ip
+}
+
+func TestWebhookNotify(t *testing.T) {
+	srv, got := endpoint(t, http.StatusAccepted)
+	w := NewWebhook("s3cret", localAllow(t))
+	m := Message{
+		Subject:     "Alert firing",
+		Text:        "error rate 12%",
+		Attachments: []Attachment{{Filename: "run.csv", ContentType: "text/csv", Data: []byte("a,b\n")}},
+		Fields:      map[string]interface{}{"rule_id": 7, "subject": "overridden"},
+	}
+	if err := w.Notify(context.Background(), srv.URL+"/hook", m); err != nil {
+		t.Fatal(err)
+	}
+	r := <-got
+
+	if ct := r.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(r.body)
+	if sig := r.header.Get(SignatureHeader); sig != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("%s = %q does not sign the body", SignatureHeader, sig)
+	}
+
+	var payload struct {
+		Subject     string       `json:"subject"`
+		Text        string       `json:"text"`
+		RuleID      int          `json:"rule_id"`
+		Attachments []Attachment `json:"attachments"`
+	}
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Subject != "Alert firing" || payload.Text != "error rate 12%" || payload.RuleID != 7 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if len(payload.Attachments) != 1 || string(payload.Attachments[0].Data) != "a,b\n" {
+		t.Errorf("attachments = %+v", payload.Attachments)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	srv, got := endpoint(t, http.StatusOK)
+	if err := NewWebhook("", localAllow(t)).Notify(context.Background(), srv.URL, Message{Subject: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if sig := (<-got).header.Get(SignatureHeader); sig != "" {
+		t.Errorf("signature without a secret: %q", sig)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv, _ := endpoint(t, http.StatusInternalServerError)
+	err := NewWebhook("", localAllow(t)).Notify(context.Background(), srv.URL, Message{Subject: "x"})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("err = %v, want the 500 status", err)
+	}
+}
+
+func TestWebhookRefusesPrivateTargets(t *testing.T) {
+	srv, got := endpoint(t, http.StatusOK)
+	w := NewWebhook("", nil)
+
+	for _, target := range []string{
+		srv.URL,
+		"http://localhost:8080/hook",
+		"http://10.1.2.3/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"ftp://example.com/hook",
+	} {
+		if err := w.Validate(target); err == nil {
+			t.Errorf("Validate(%q) accepted a non-public target", target)
+		}
+	}
+
+	// a target saved earlier is checked again when dialing
+	if err := w.Notify(context.Background(), srv.URL, Message{Subject: "x"}); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("Notify to loopback: err = %v", err)
+	}
+	select {
+	case <-got:
+		t.Error("request reached the loopback endpoint")
+	default:
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	a, err := NewAllowlist([]string{"10.20.0.0/16", "192.168.1.5", "hooks.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		host, ip string
+		want     bool
+	}{
+		{"example.com", "93.184.216.34", true},
+		{"x", "10.20.3.4", true},
+		{"x", "10.21.0.1", false},
+		{"x", "192.168.1.5", true},
+		{"x", "192.168.1.6", false},
+		{"hooks.internal", "10.99.0.1", true},
+		{"x", "127.0.0.1", false},
+		{"x", "100.64.0.1", false},
+		{"x", "fd00::1", false},
+		{"x", "::ffff:127.0.0.1", false},
+	}
+	for _, c := range cases {
+		if got := a.allowed(c.host, parseIP(t, c.ip)); got != c.want {
+			t.Errorf("allowed(%s, %s) = %v, want %v", c.host, c.ip, got, c.want)
+		}
+	}
+
+	if _, err := NewAllowlist([]string{"10.0.0.0/99"}); err == nil {
+		t.Error("invalid CIDR accepted")
+	}
+}
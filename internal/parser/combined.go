@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// Apache/Nginx common and combined log format. An optional trailing number is
+// read as $request_time in seconds, which is how most nginx configs append it:
+// 10.0.0.1 - - [17/Oct/2025:10:00:00 +0000] "GET /api/users HTTP/1.1" 200 512 "-" "curl/8.0" 0.120
+var combinedPattern = regexp.MustCompile(
+	`^(\S+) \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3}) \S+(?: "[^"]*" "[^"]*")?(?: ([0-9.]+))?`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+func Combined(line string) (domain.LogRecord, bool) {
+	line = strings.TrimSpace(line)
+	m := combinedPattern.FindStringSubmatch(line)
+	if m == nil {
+		return domain.LogRecord{}, false
+	}
+	status, _ := strconv.Atoi(m[5])
+	rec := domain.LogRecord{
+		IP:     m[1],
+		Method: m[3],
+		Path:   m[4],
+		Status: status,
+		Raw:    line,
+	}
+	if t, err := time.Parse(combinedTimeLayout, m[2]); err == nil {
+		rec.Time = t.UTC()
+	}
+	if m[6] != "" {
+		if secs, err := strconv.ParseFloat(m[6], 64); err == nil {
+			rec.Latency = secs * 1000
+		}
+	}
+	return rec, true
+}
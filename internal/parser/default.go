@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const defaultTimeLayout = "2006-01-02 15:04:05"
+
+// Default parses the analyzer's own format:
+// [2025-10-17 10:00:00] GET /api/users 200 120ms 192.168.1.1
+func Default(line string) (domain.LogRecord, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return domain.LogRecord{}, false
+	}
+	rec := domain.LogRecord{Raw: line}
+
+	// buang timestamp di awal, contoh: [2025-10-17 10:00:00]
+	if strings.HasPrefix(line, "[") {
+		if idx := strings.Index(line, "]"); idx != -1 {
+			if t, err := time.ParseInLocation(defaultTimeLayout, line[1:idx], time.UTC); err == nil {
+				rec.Time = t
+			}
+			line = strings.TrimSpace(line[idx+1:])
+		}
+	}
+
+	// setelah buang timestamp: "GET /api/users 200 120ms 192.168.1.1"
+	// baris yang tidak valid dihitung di log_analyzer_parse_failures_total
+	parts := strings.Fields(line)
+	if len(parts) < 5 {
+		return domain.LogRecord{}, false
+	}
+
+	status, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return domain.LogRecord{}, false
+	}
+
+	// hapus akhiran 'ms' jika ada
+	respTimeStr := strings.TrimSuffix(parts[3], "ms")
+
+	// parse angka response time
+	respTime, err := strconv.ParseFloat(respTimeStr, 64)
+	if err != nil {
+		respTime = 0
+	}
+
+	rec.Method = parts[0]
+	rec.Path = parts[1]
+	rec.Status = status
+	rec.Latency = respTime
+	rec.IP = parts[4]
+	return rec, true
+}
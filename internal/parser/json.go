@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// field names tried in order for each record attribute
+var (
+	jsonTimeKeys    = []string{"time", "timestamp", "@timestamp", "ts"}
+	jsonMethodKeys  = []string{"method", "http_method", "request_method"}
+	jsonPathKeys    = []string{"path", "url", "uri", "request_uri"}
+	jsonStatusKeys  = []string{"status", "status_code", "code"}
+	jsonLatencyKeys = []string{"latency_ms", "duration_ms", "response_time", "latency"}
+	jsonIPKeys      = []string{"ip", "remote_addr", "client_ip", "clientip"}
+)
+
+// JSON parses one JSON object per line, e.g.
+// {"time":"2025-10-17T10:00:00Z","method":"GET","path":"/api/users","status":200,"latency_ms":120,"ip":"10.0.0.1"}
+// Latency keys are milliseconds, except nginx's "request_time" which is seconds.
+func JSON(line string) (domain.LogRecord, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return domain.LogRecord{}, false
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return domain.LogRecord{}, false
+	}
+	rec := FromFields(event)
+	rec.Raw = line
+	return rec, true
+}
+
+// FromFields maps a decoded event (JSON object, GELF message, ...) onto a record.
+func FromFields(event map[string]interface{}) domain.LogRecord {
+	rec := domain.LogRecord{
+		Method: firstString(event, jsonMethodKeys),
+		Path:   firstString(event, jsonPathKeys),
+		IP:     firstString(event, jsonIPKeys),
+	}
+	if v, ok := first(event, jsonTimeKeys); ok {
+		rec.Time = ParseTime(v)
+	}
+	if v, ok := first(event, jsonStatusKeys); ok {
+		if n, ok := toFloat(v); ok {
+			rec.Status = int(n)
+		}
+	}
+	if v, ok := first(event, jsonLatencyKeys); ok {
+		rec.Latency, _ = toFloat(v)
+	} else if v, ok := event["request_time"]; ok {
+		secs, _ := toFloat(v)
+		rec.Latency = secs * 1000
+	}
+	return rec
+}
+
+// ParseTime accepts RFC 3339 strings and unix timestamps in seconds or
+// milliseconds (number or numeric string). Unknown values give a zero time.
+func ParseTime(v interface{}) time.Time {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t.UTC()
+		}
+	}
+	n, ok := toFloat(v)
+	if !ok || n <= 0 {
+		return time.Time{}
+	}
+	if n > 1e12 { // milliseconds
+		return time.UnixMilli(int64(n)).UTC()
+	}
+	secs := int64(n)
+	return time.Unix(secs, int64((n-float64(secs))*1e9)).UTC()
+}
+
+func first(event map[string]interface{}, keys []string) (interface{}, bool) {
+	for _, k := range keys {
+		if v, ok := event[k]; ok && v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func firstString(event map[string]interface{}, keys []string) string {
+	v, ok := first(event, keys)
+	if !ok {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(n, "ms"), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
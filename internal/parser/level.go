@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// Level normalizes a level/severity name ("WARN", "err", "fatal", ...) to
+// one of the domain levels, or "" when it is not recognized.
+func Level(text string) string {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "fatal", "critical", "crit", "emerg", "emergency", "alert", "panic":
+		return domain.LevelCritical
+	case "error", "err":
+		return domain.LevelError
+	case "warn", "warning":
+		return domain.LevelWarning
+	case "info", "information", "notice":
+		return domain.LevelInfo
+	case "debug", "trace":
+		return domain.LevelDebug
+	}
+	return ""
+}
+
+// SyslogLevel maps a syslog severity (0 emerg .. 7 debug), also used by
+// GELF, onto the domain levels.
+func SyslogLevel(severity int) string {
+	switch {
+	case severity <= 2:
+		return domain.LevelCritical
+	case severity == 3:
+		return domain.LevelError
+	case severity == 4:
+		return domain.LevelWarning
+	case severity <= 6:
+		return domain.LevelInfo
+	}
+	return domain.LevelDebug
+}
@@ -0,0 +1,44 @@
+// Package parser turns raw log lines into domain.LogRecord values. Each
+// format is a Func registered under a name; streams and uploads pick one by name.
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// Func parses one line. ok is false when the line does not match the format.
+type Func func(line string) (rec domain.LogRecord, ok bool)
+
+const DefaultFormat = "default"
+
+var formats = map[string]Func{
+	DefaultFormat: Default,
+	"combined":    Combined,
+	"json":        JSON,
+	"raw":         Raw,
+}
+
+// Get returns the parser for a format name; "" means the default format.
+func Get(format string) (Func, error) {
+	if format == "" {
+		format = DefaultFormat
+	}
+	fn, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q (available: %v)", format, Formats())
+	}
+	return fn, nil
+}
+
+// Formats lists the registered format names.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
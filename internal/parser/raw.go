@@ -0,0 +1,17 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// Raw accepts any non-empty line as a plain message, for sources that are
+// not access logs (syslog from network gear, application logs, ...).
+func Raw(line string) (domain.LogRecord, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return domain.LogRecord{}, false
+	}
+	return domain.LogRecord{Message: line, Raw: line}, true
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/lib/pq"
+)
+
+type AlertRepository interface {
+	CreateRule(rule *domain.AlertRule) error
+	UpdateRule(rule *domain.AlertRule) error
+	GetRule(id uint) (*domain.AlertRule, error)
+	ListRules(orgID uint, enabledOnly bool) ([]domain.AlertRule, error)
+	DeleteRule(id uint) error
+
+	GetAlert(ruleID uint, seriesKey string) (*domain.Alert, error)
+	// SaveAlert inserts or updates the row of (rule, series).
+	SaveAlert(a *domain.Alert) error
+	// ListAlerts returns the org's alerts in the given states, all but
+	// ok when states is empty.
+	ListAlerts(orgID uint, states []string) ([]domain.Alert, error)
+	// Stale returns pending and firing alerts not evaluated since before.
+	Stale(before time.Time) ([]domain.Alert, error)
+
+	CreateSilence(s *domain.AlertSilence) error
+	GetSilence(id uint) (*domain.AlertSilence, error)
+	// ListSilences returns silences that have not ended yet.
+	ListSilences(orgID uint, now time.Time) ([]domain.AlertSilence, error)
+	DeleteSilence(id uint) error
+}
+
+type alertRepo struct {
+	db *sql.DB
+}
+
+func NewAlertRepository(db *sql.DB) AlertRepository {
+	return &alertRepo{db: db}
+}
+
+// ===================== RULES =====================
+
+const alertRuleColumns = `id, user_id, org_id, name, labels, expr, min_requests, notify, enabled, created_at, updated_at`
+
+func scanAlertRule(row rowScanner) (domain.AlertRule, error) {
+	var r domain.AlertRule
+	var labels, notify []byte
+	var orgID sql.NullInt64
+	err := row.Scan(&r.ID, &r.UserID, &orgID, &r.Name, &labels, &r.Expr, &r.MinRequests, &notify, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return r, err
+	}
+	r.OrgID = uint(orgID.Int64)
+	if err := json.Unmarshal(labels, &r.Labels); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(notify, &r.Notify); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func (r *alertRepo) CreateRule(rule *domain.AlertRule) error {
+	labels, err := labelsJSON(rule.Labels)
+	if err != nil {
+		return err
+	}
+	notify, err := notifyJSON(rule.Notify)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO alert_rules (user_id, org_id, name, labels, expr, min_requests, notify, enabled)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at`
+	return r.db.QueryRow(query, rule.UserID, rule.OrgID, rule.Name, labels, rule.Expr, rule.MinRequests, notify, rule.Enabled).
+		Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+}
+
+func (r *alertRepo) UpdateRule(rule *domain.AlertRule) error {
+	labels, err := labelsJSON(rule.Labels)
+	if err != nil {
+		return err
+	}
+	notify, err := notifyJSON(rule.Notify)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE alert_rules SET name=$1, labels=$2, expr=$3, min_requests=$4, notify=$5, enabled=$6
+			  WHERE id=$7 RETURNING updated_at`
+	err = tx.QueryRow(query, rule.Name, labels, rule.Expr, rule.MinRequests, notify, rule.Enabled, rule.ID).
+		Scan(&rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("record not found")
+	}
+	if err != nil {
+		return err
+	}
+	// a changed condition starts over; old alerts would not match it
+	if _, err := tx.Exec(`DELETE FROM alerts WHERE rule_id=$1`, rule.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *alertRepo) GetRule(id uint) (*domain.AlertRule, error) {
+	rule, err := scanAlertRule(r.db.QueryRow(`SELECT `+alertRuleColumns+` FROM alert_rules WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *alertRepo) ListRules(orgID uint, enabledOnly bool) ([]domain.AlertRule, error) {
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE org_id=$1`
+	if enabledOnly {
+		query += ` AND enabled`
+	}
+	rows, err := r.db.Query(query+` ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rule)
+	}
+	return list, rows.Err()
+}
+
+func (r *alertRepo) DeleteRule(id uint) error {
+	_, err := r.db.Exec(`DELETE FROM alert_rules WHERE id=$1`, id)
+	return err
+}
+
+// ===================== ALERTS =====================
+
+const alertColumns = `al.id, al.rule_id, ar.name, al.series_key, al.labels, al.state, al.value, al.prev_value,
+	al.analysis_id, al.active_since, al.fired_at, al.resolved_at, al.last_eval_at, al.notified_state, al.last_notified_at`
+
+func scanAlert(row rowScanner) (domain.Alert, error) {
+	var a domain.Alert
+	var labels []byte
+	var prev sql.NullFloat64
+	err := row.Scan(&a.ID, &a.RuleID, &a.RuleName, &a.SeriesKey, &labels, &a.State, &a.Value, &prev,
+		&a.AnalysisID, &a.ActiveSince, &a.FiredAt, &a.ResolvedAt, &a.LastEvalAt, &a.NotifiedState, &a.LastNotifiedAt)
+	if err != nil {
+		return a, err
+	}
+	if prev.Valid {
+		a.PrevValue = &prev.Float64
+	}
+	if err := json.Unmarshal(labels, &a.Labels); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+func (r *alertRepo) listAlerts(query string, args ...interface{}) ([]domain.Alert, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (r *alertRepo) GetAlert(ruleID uint, seriesKey string) (*domain.Alert, error) {
+	a, err := scanAlert(r.db.QueryRow(`SELECT `+alertColumns+` FROM alerts al JOIN alert_rules ar ON ar.id = al.rule_id
+		WHERE al.rule_id=$1 AND al.series_key=$2`, ruleID, seriesKey))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *alertRepo) SaveAlert(a *domain.Alert) error {
+	labels, err := labelsJSON(a.Labels)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO alerts (rule_id, series_key, labels, state, value, prev_value, analysis_id, active_since,
+				fired_at, resolved_at, last_eval_at, notified_state, last_notified_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			  ON CONFLICT (rule_id, series_key) DO UPDATE SET
+				labels=EXCLUDED.labels, state=EXCLUDED.state, value=EXCLUDED.value, prev_value=EXCLUDED.prev_value,
+				analysis_id=EXCLUDED.analysis_id, active_since=EXCLUDED.active_since, fired_at=EXCLUDED.fired_at,
+				resolved_at=EXCLUDED.resolved_at, last_eval_at=EXCLUDED.last_eval_at,
+				notified_state=EXCLUDED.notified_state, last_notified_at=EXCLUDED.last_notified_at
+			  RETURNING id`
+	return r.db.QueryRow(query, a.RuleID, a.SeriesKey, labels, a.State, a.Value, a.PrevValue, a.AnalysisID,
+		a.ActiveSince, a.FiredAt, a.ResolvedAt, a.LastEvalAt, a.NotifiedState, a.LastNotifiedAt).Scan(&a.ID)
+}
+
+func (r *alertRepo) ListAlerts(orgID uint, states []string) ([]domain.Alert, error) {
+	if len(states) == 0 {
+		states = []string{domain.AlertStatePending, domain.AlertStateFiring, domain.AlertStateResolved}
+	}
+	return r.listAlerts(`SELECT `+alertColumns+` FROM alerts al JOIN alert_rules ar ON ar.id = al.rule_id
+		WHERE ar.org_id=$1 AND al.state = ANY($2)
+		ORDER BY CASE al.state WHEN 'firing' THEN 0 WHEN 'pending' THEN 1 ELSE 2 END, al.last_eval_at DESC`,
+		orgID, pq.Array(states))
+}
+
+func (r *alertRepo) Stale(before time.Time) ([]domain.Alert, error) {
+	return r.listAlerts(`SELECT `+alertColumns+` FROM alerts al JOIN alert_rules ar ON ar.id = al.rule_id
+		WHERE al.state IN ('pending', 'firing') AND al.last_eval_at < $1`, before)
+}
+
+// ===================== SILENCES =====================
+
+const alertSilenceColumns = `id, user_id, org_id, rule_id, labels, comment, starts_at, ends_at, created_at`
+
+func scanAlertSilence(row rowScanner) (domain.AlertSilence, error) {
+	var s domain.AlertSilence
+	var ruleID, orgID sql.NullInt64
+	var labels []byte
+	if err := row.Scan(&s.ID, &s.UserID, &orgID, &ruleID, &labels, &s.Comment, &s.StartsAt, &s.EndsAt, &s.CreatedAt); err != nil {
+		return s, err
+	}
+	s.RuleID = uint(ruleID.Int64)
+	s.OrgID = uint(orgID.Int64)
+	if err := json.Unmarshal(labels, &s.Labels); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func (r *alertRepo) CreateSilence(s *domain.AlertSilence) error {
+	labels, err := labelsJSON(s.Labels)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO alert_silences (user_id, org_id, rule_id, labels, comment, starts_at, ends_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	return r.db.QueryRow(query, s.UserID, s.OrgID, nullID(s.RuleID), labels, s.Comment, s.StartsAt, s.EndsAt).
+		Scan(&s.ID, &s.CreatedAt)
+}
+
+func (r *alertRepo) GetSilence(id uint) (*domain.AlertSilence, error) {
+	s, err := scanAlertSilence(r.db.QueryRow(`SELECT `+alertSilenceColumns+` FROM alert_silences WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *alertRepo) ListSilences(orgID uint, now time.Time) ([]domain.AlertSilence, error) {
+	rows, err := r.db.Query(`SELECT `+alertSilenceColumns+` FROM alert_silences
+		WHERE org_id=$1 AND ends_at > $2 ORDER BY starts_at`, orgID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.AlertSilence
+	for rows.Next() {
+		s, err := scanAlertSilence(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *alertRepo) DeleteSilence(id uint) error {
+	_, err := r.db.Exec(`DELETE FROM alert_silences WHERE id=$1`, id)
+	return err
+}
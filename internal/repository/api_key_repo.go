@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository interface {
+	Create(k *domain.APIKey) error
+	GetByPrefix(prefix string) (*domain.APIKey, error)
+	ListByUser(userID uint) ([]domain.APIKey, error)
+	// Revoke marks the user's key revoked; false if there is no such
+	// active key.
+	Revoke(userID, id uint) (bool, error)
+	Touch(id uint, at time.Time) error
+}
+
+type apiKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepo{db: db}
+}
+
+const apiKeyColumns = `id, user_id, org_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (domain.APIKey, error) {
+	var k domain.APIKey
+	var lastUsed, revoked sql.NullTime
+	err := row.Scan(&k.ID, &k.UserID, &k.OrgID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&k.Scopes), &lastUsed, &revoked, &k.CreatedAt)
+	if err != nil {
+		return k, err
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return k, nil
+}
+
+func (r *apiKeyRepo) Create(k *domain.APIKey) error {
+	query := `INSERT INTO api_keys (user_id, org_id, name, prefix, key_hash, scopes)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	return r.db.QueryRow(query, k.UserID, k.OrgID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes)).Scan(&k.ID, &k.CreatedAt)
+}
+
+func (r *apiKeyRepo) GetByPrefix(prefix string) (*domain.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix=$1`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepo) ListByUser(userID uint) ([]domain.APIKey, error) {
+	rows, err := r.db.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id=$1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, k)
+	}
+	return list, rows.Err()
+}
+
+func (r *apiKeyRepo) Revoke(userID, id uint) (bool, error) {
+	res, err := r.db.Exec(`UPDATE api_keys SET revoked_at=now() WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *apiKeyRepo) Touch(id uint, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE api_keys SET last_used_at=$2 WHERE id=$1`, id, at)
+	return err
+}
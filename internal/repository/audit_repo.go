@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type AuditRepository interface {
+	Create(e *domain.AuditEntry) error
+	// List returns the newest entries first; targetUserID 0 means all users.
+	List(targetUserID uint, limit int) ([]domain.AuditEntry, error)
+}
+
+type auditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(e *domain.AuditEntry) error {
+	query := `INSERT INTO audit_log (actor_id, action, target_user_id, detail, ip)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	return r.db.QueryRow(query, nullID(e.ActorID), e.Action, nullID(e.TargetUserID), e.Detail, e.IP).
+		Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *auditRepo) List(targetUserID uint, limit int) ([]domain.AuditEntry, error) {
+	rows, err := r.db.Query(`SELECT id, COALESCE(actor_id, 0), action, COALESCE(target_user_id, 0), detail, ip, created_at
+			  FROM audit_log WHERE ($1 = 0 OR target_user_id = $1)
+			  ORDER BY created_at DESC, id DESC LIMIT $2`, targetUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.AuditEntry
+	for rows.Next() {
+		var e domain.AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetUserID, &e.Detail, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
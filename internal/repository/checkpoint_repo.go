@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type CheckpointRepository interface {
+	Get(path string) (*domain.TailCheckpoint, error)
+	Save(cp *domain.TailCheckpoint) error
+}
+
+type checkpointRepo struct {
+	db *sql.DB
+}
+
+func NewCheckpointRepository(db *sql.DB) CheckpointRepository {
+	return &checkpointRepo{db: db}
+}
+
+func (r *checkpointRepo) Get(path string) (*domain.TailCheckpoint, error) {
+	var cp domain.TailCheckpoint
+	var inode int64
+	err := r.db.QueryRow(`SELECT path, inode, "offset", updated_at FROM tail_checkpoints WHERE path=$1`, path).
+		Scan(&cp.Path, &inode, &cp.Offset, &cp.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp.Inode = uint64(inode)
+	return &cp, nil
+}
+
+func (r *checkpointRepo) Save(cp *domain.TailCheckpoint) error {
+	cp.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		INSERT INTO tail_checkpoints (path, inode, "offset", updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (path) DO UPDATE SET inode = EXCLUDED.inode, "offset" = EXCLUDED."offset", updated_at = EXCLUDED.updated_at`,
+		cp.Path, int64(cp.Inode), cp.Offset, cp.UpdatedAt)
+	return err
+}
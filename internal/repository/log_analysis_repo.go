@@ -2,18 +2,32 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/lib/pq"
 )
 
 type LogAnalysisRepository interface {
 	Create(a *domain.LogAnalysis) error
-	GetAll() ([]domain.LogAnalysis, error)
+	GetAll(filter domain.AnalysisFilter) ([]domain.LogAnalysis, error)
 	GetByID(id uint) (*domain.LogAnalysis, error)
 	Update(a *domain.LogAnalysis) error
 	Delete(id uint) error
+	SetLabels(id uint, labels map[string]string) error
+	Report(groupBy []string, filter domain.AnalysisFilter) ([]domain.LabelReportRow, error)
+	FindWindow(streamID uint, start time.Time, key string) (*domain.LogAnalysis, error)
+	UpsertWindow(a *domain.LogAnalysis) error
+
+	Share(s *domain.AnalysisShare) error
+	Unshare(analysisID, userID uint) error
+	Shares(analysisID uint) ([]domain.AnalysisShare, error)
+	IsSharedWith(analysisID, userID uint) (bool, error)
 }
 
 type logAnalysisRepo struct {
@@ -24,25 +38,113 @@ func NewLogAnalysisRepo(db *sql.DB) LogAnalysisRepository {
 	return &logAnalysisRepo{db: db}
 }
 
+const analysisColumns = `a.id, a.user_id, a.org_id, a.filename, a.total_requests, a.unique_ips, a.error_count, a.average_response, a.details,
+	a.stream_id, a.window_start, a.window_end, a.window_key, a.created_at, a.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAnalysis(row rowScanner) (domain.LogAnalysis, error) {
+	var a domain.LogAnalysis
+	var userID, orgID, streamID sql.NullInt64
+	var details []byte
+	var windowStart, windowEnd sql.NullTime
+	var windowKey sql.NullString
+	err := row.Scan(
+		&a.ID,
+		&userID,
+		&orgID,
+		&a.Filename,
+		&a.TotalRequests,
+		&a.UniqueIPs,
+		&a.ErrorCount,
+		&a.AverageResponse,
+		&details,
+		&streamID,
+		&windowStart,
+		&windowEnd,
+		&windowKey,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return a, err
+	}
+	a.UserID = uint(userID.Int64)
+	a.OrgID = uint(orgID.Int64)
+	a.StreamID = uint(streamID.Int64)
+	a.WindowKey = windowKey.String
+	if windowStart.Valid {
+		a.WindowStart = &windowStart.Time
+	}
+	if windowEnd.Valid {
+		a.WindowEnd = &windowEnd.Time
+	}
+	if len(details) > 0 {
+		a.Details = &domain.AnalysisDetails{}
+		if err := json.Unmarshal(details, a.Details); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// detailsValue returns nil (SQL NULL) when there are no details. JSON is
+// passed as string; lib/pq would send []byte as bytea.
+func detailsValue(d *domain.AnalysisDetails) (interface{}, error) {
+	if d == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
 func (r *logAnalysisRepo) Create(a *domain.LogAnalysis) error {
+	details, err := detailsValue(a.Details)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO log_analysis 
-			(filename, total_requests, unique_ips, error_count, average_response, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id`
-	return r.db.QueryRow(query,
+		INSERT INTO log_analysis
+			(user_id, org_id, filename, total_requests, unique_ips, error_count, average_response, details, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(query,
+		a.UserID,
+		nullID(a.OrgID),
 		a.Filename,
 		a.TotalRequests,
 		a.UniqueIPs,
 		a.ErrorCount,
 		a.AverageResponse,
+		details,
 		time.Now(),
 		time.Now(),
-	).Scan(&a.ID)
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceLabels(tx, a.ID, a.Labels); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *logAnalysisRepo) GetAll() ([]domain.LogAnalysis, error) {
-	rows, err := r.db.Query(`SELECT id, filename, total_requests, unique_ips, error_count, average_response, created_at, updated_at FROM log_analysis`)
+func (r *logAnalysisRepo) GetAll(filter domain.AnalysisFilter) ([]domain.LogAnalysis, error) {
+	where, args := analysisFilterSQL(filter, nil)
+	rows, err := r.db.Query(`SELECT `+analysisColumns+` FROM log_analysis a`+where+` ORDER BY a.id`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -50,55 +152,352 @@ func (r *logAnalysisRepo) GetAll() ([]domain.LogAnalysis, error) {
 
 	var list []domain.LogAnalysis
 	for rows.Next() {
-		var a domain.LogAnalysis
-		if err := rows.Scan(&a.ID, &a.Filename, &a.TotalRequests, &a.UniqueIPs, &a.ErrorCount, &a.AverageResponse, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		a, err := scanAnalysis(rows)
+		if err != nil {
 			return nil, err
 		}
 		list = append(list, a)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachLabels(list); err != nil {
+		return nil, err
+	}
 	return list, nil
 }
 
 func (r *logAnalysisRepo) GetByID(id uint) (*domain.LogAnalysis, error) {
-	var a domain.LogAnalysis
-	query := `SELECT id, filename, total_requests, unique_ips, error_count, average_response, created_at, updated_at FROM log_analysis WHERE id = $1`
-	err := r.db.QueryRow(query, id).Scan(
-		&a.ID,
-		&a.Filename,
-		&a.TotalRequests,
-		&a.UniqueIPs,
-		&a.ErrorCount,
-		&a.AverageResponse,
-		&a.CreatedAt,
-		&a.UpdatedAt,
-	)
+	query := `SELECT ` + analysisColumns + ` FROM log_analysis a WHERE a.id = $1`
+	a, err := scanAnalysis(r.db.QueryRow(query, id))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &a, nil
+
+	list := []domain.LogAnalysis{a}
+	if err := r.attachLabels(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
 }
 
 func (r *logAnalysisRepo) Update(a *domain.LogAnalysis) error {
+	details, err := detailsValue(a.Details)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		UPDATE log_analysis 
-		SET filename=$1, total_requests=$2, unique_ips=$3, error_count=$4, average_response=$5, updated_at=$6
-		WHERE id=$7`
-	_, err := r.db.Exec(query,
+		UPDATE log_analysis
+		SET filename=$1, total_requests=$2, unique_ips=$3, error_count=$4, average_response=$5,
+			details=COALESCE($6, details), updated_at=$7
+		WHERE id=$8`
+	_, err = tx.Exec(query,
 		a.Filename,
 		a.TotalRequests,
 		a.UniqueIPs,
 		a.ErrorCount,
 		a.AverageResponse,
+		details,
 		time.Now(),
 		a.ID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// labels nil = tidak diubah
+	if a.Labels != nil {
+		if err := replaceLabels(tx, a.ID, a.Labels); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *logAnalysisRepo) Delete(id uint) error {
 	_, err := r.db.Exec(`DELETE FROM log_analysis WHERE id=$1`, id)
 	return err
 }
+
+func (r *logAnalysisRepo) SetLabels(id uint, labels map[string]string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceLabels(tx, id, labels); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE log_analysis SET updated_at=$1 WHERE id=$2`, time.Now(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Share is a no-op when the analysis is already shared with the user.
+func (r *logAnalysisRepo) Share(s *domain.AnalysisShare) error {
+	query := `INSERT INTO analysis_shares (analysis_id, user_id, shared_by) VALUES ($1, $2, $3)
+			  ON CONFLICT (analysis_id, user_id) DO NOTHING`
+	_, err := r.db.Exec(query, s.AnalysisID, s.UserID, nullID(s.SharedBy))
+	return err
+}
+
+func (r *logAnalysisRepo) Unshare(analysisID, userID uint) error {
+	_, err := r.db.Exec(`DELETE FROM analysis_shares WHERE analysis_id=$1 AND user_id=$2`, analysisID, userID)
+	return err
+}
+
+func (r *logAnalysisRepo) Shares(analysisID uint) ([]domain.AnalysisShare, error) {
+	rows, err := r.db.Query(`SELECT s.analysis_id, s.user_id, u.email, s.shared_by, s.created_at
+		FROM analysis_shares s JOIN users u ON u.id = s.user_id
+		WHERE s.analysis_id=$1 ORDER BY u.email`, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.AnalysisShare
+	for rows.Next() {
+		var s domain.AnalysisShare
+		var sharedBy sql.NullInt64
+		if err := rows.Scan(&s.AnalysisID, &s.UserID, &s.Email, &sharedBy, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.SharedBy = uint(sharedBy.Int64)
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *logAnalysisRepo) IsSharedWith(analysisID, userID uint) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM analysis_shares WHERE analysis_id=$1 AND user_id=$2)`,
+		analysisID, userID).Scan(&ok)
+	return ok, err
+}
+
+// FindWindow returns the saved analysis of one stream window, or nil.
+func (r *logAnalysisRepo) FindWindow(streamID uint, start time.Time, key string) (*domain.LogAnalysis, error) {
+	query := `SELECT ` + analysisColumns + ` FROM log_analysis a WHERE a.stream_id = $1 AND a.window_start = $2 AND a.window_key = $3`
+	a, err := scanAnalysis(r.db.QueryRow(query, streamID, start, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []domain.LogAnalysis{a}
+	if err := r.attachLabels(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// UpsertWindow inserts or overwrites the analysis of a stream window,
+// identified by (stream_id, window_start, window_key).
+func (r *logAnalysisRepo) UpsertWindow(a *domain.LogAnalysis) error {
+	details, err := detailsValue(a.Details)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// labels of a window never change, so only write them the first time
+	isNew := a.ID == 0
+
+	query := `
+		INSERT INTO log_analysis
+			(user_id, org_id, filename, total_requests, unique_ips, error_count, average_response, details,
+			 stream_id, window_start, window_end, window_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
+		ON CONFLICT (stream_id, window_start, window_key) DO UPDATE SET
+			total_requests = EXCLUDED.total_requests,
+			unique_ips = EXCLUDED.unique_ips,
+			error_count = EXCLUDED.error_count,
+			average_response = EXCLUDED.average_response,
+			details = EXCLUDED.details,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(query,
+		a.UserID,
+		nullID(a.OrgID),
+		a.Filename,
+		a.TotalRequests,
+		a.UniqueIPs,
+		a.ErrorCount,
+		a.AverageResponse,
+		details,
+		a.StreamID,
+		a.WindowStart,
+		a.WindowEnd,
+		a.WindowKey,
+		time.Now(),
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if isNew {
+		if err := replaceLabels(tx, a.ID, a.Labels); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Report aggregates analyses grouped by the values of the given label keys.
+// Analyses without a label fall into the "" group for that key.
+func (r *logAnalysisRepo) Report(groupBy []string, filter domain.AnalysisFilter) ([]domain.LabelReportRow, error) {
+	var args []interface{}
+	var cols, joins, groups []string
+	for i, key := range groupBy {
+		args = append(args, key)
+		alias := fmt.Sprintf("g%d", i)
+		cols = append(cols, fmt.Sprintf("COALESCE(%s.value, '')", alias))
+		joins = append(joins, fmt.Sprintf(" LEFT JOIN analysis_labels %s ON %s.analysis_id = a.id AND %s.key = $%d", alias, alias, alias, len(args)))
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+	where, args := analysisFilterSQL(filter, args)
+
+	query := `SELECT ` + strings.Join(append(cols,
+		`COUNT(*)`,
+		`COALESCE(SUM(a.total_requests), 0)`,
+		`COALESCE(SUM(a.error_count), 0)`,
+		`COALESCE(SUM(a.average_response * a.total_requests) / NULLIF(SUM(a.total_requests), 0), 0)`,
+	), ", ") + ` FROM log_analysis a` + strings.Join(joins, "") + where
+	if len(groups) > 0 {
+		query += ` GROUP BY ` + strings.Join(groups, ", ") + ` ORDER BY ` + strings.Join(groups, ", ")
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []domain.LabelReportRow
+	for rows.Next() {
+		values := make([]string, len(groupBy))
+		row := domain.LabelReportRow{Group: make(map[string]string, len(groupBy))}
+		dest := make([]interface{}, 0, len(groupBy)+4)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &row.Analyses, &row.TotalRequests, &row.ErrorCount, &row.AverageResponse)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, key := range groupBy {
+			row.Group[key] = values[i]
+		}
+		report = append(report, row)
+	}
+	return report, rows.Err()
+}
+
+// attachLabels isi field Labels untuk semua analysis di list (satu query)
+func (r *logAnalysisRepo) attachLabels(list []domain.LogAnalysis) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(list))
+	index := make(map[uint]int, len(list))
+	for i := range list {
+		ids[i] = int64(list[i].ID)
+		index[list[i].ID] = i
+	}
+
+	rows, err := r.db.Query(`SELECT analysis_id, key, value FROM analysis_labels WHERE analysis_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint
+		var key, value string
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			return err
+		}
+		a := &list[index[id]]
+		if a.Labels == nil {
+			a.Labels = make(map[string]string)
+		}
+		a.Labels[key] = value
+	}
+	return rows.Err()
+}
+
+func replaceLabels(tx *sql.Tx, id uint, labels map[string]string) error {
+	if _, err := tx.Exec(`DELETE FROM analysis_labels WHERE analysis_id=$1`, id); err != nil {
+		return err
+	}
+	for key, value := range labels {
+		if _, err := tx.Exec(`INSERT INTO analysis_labels (analysis_id, key, value) VALUES ($1, $2, $3)`, id, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// analysisFilterSQL builds a WHERE clause for the owner, org, time range and every
+// label key=value pair, appending its placeholders to args. Keys are sorted
+// so the query text is stable.
+func analysisFilterSQL(filter domain.AnalysisFilter, args []interface{}) (string, []interface{}) {
+	var conds []string
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conds = append(conds, fmt.Sprintf("a.user_id = $%d", len(args)))
+	}
+	switch {
+	case filter.OrgID != 0 && filter.SharedWith != 0:
+		args = append(args, filter.OrgID, filter.SharedWith)
+		conds = append(conds, fmt.Sprintf(
+			"(a.org_id = $%d OR EXISTS (SELECT 1 FROM analysis_shares s WHERE s.analysis_id = a.id AND s.user_id = $%d))",
+			len(args)-1, len(args)))
+	case filter.OrgID != 0:
+		args = append(args, filter.OrgID)
+		conds = append(conds, fmt.Sprintf("a.org_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conds = append(conds, fmt.Sprintf("COALESCE(a.window_start, a.created_at) >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conds = append(conds, fmt.Sprintf("COALESCE(a.window_start, a.created_at) < $%d", len(args)))
+	}
+
+	keys := make([]string, 0, len(filter.Labels))
+	for k := range filter.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, k, filter.Labels[k])
+		conds = append(conds, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM analysis_labels f WHERE f.analysis_id = a.id AND f.key = $%d AND f.value = $%d)",
+			len(args)-1, len(args)))
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/redis"
+)
+
+const redisLoginPrefix = "log-analyzer:login:"
+
+// redisLoginAttempts keeps each key as a hash (failures, last_failure,
+// locked_until in unix ms) that expires when the window passes without
+// failures.
+type redisLoginAttempts struct {
+	client *redis.Client
+}
+
+func NewRedisLoginAttemptStore(client *redis.Client) LoginAttemptStore {
+	return &redisLoginAttempts{client: client}
+}
+
+func (r *redisLoginAttempts) Get(key string) (*domain.LoginAttempts, error) {
+	reply, err := r.client.Do("HGETALL", redisLoginPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	return parseRedisAttempts(key, reply), nil
+}
+
+func (r *redisLoginAttempts) Fail(key string, now time.Time, window time.Duration) (*domain.LoginAttempts, error) {
+	k := redisLoginPrefix + key
+	replies, err := r.client.Pipeline([][]string{
+		{"MULTI"},
+		{"HINCRBY", k, "failures", "1"},
+		{"HSET", k, "last_failure", strconv.FormatInt(now.UnixMilli(), 10)},
+		{"PEXPIRE", k, strconv.FormatInt(window.Milliseconds(), 10)},
+		{"HGETALL", k},
+		{"EXEC"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	exec := replies[len(replies)-1]
+	if e, ok := exec.(redis.Error); ok {
+		return nil, e
+	}
+	results, _ := exec.([]interface{})
+	if len(results) != 4 {
+		return nil, redis.Error("unexpected EXEC reply")
+	}
+	return parseRedisAttempts(key, results[3]), nil
+}
+
+func (r *redisLoginAttempts) Lock(key string, until time.Time, window time.Duration) error {
+	k := redisLoginPrefix + key
+	// keep the counter for a window after the lock ends
+	ttl := time.Until(until) + window
+	replies, err := r.client.Pipeline([][]string{
+		{"HSET", k, "locked_until", strconv.FormatInt(until.UnixMilli(), 10)},
+		{"PEXPIRE", k, strconv.FormatInt(ttl.Milliseconds(), 10)},
+	})
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if e, ok := reply.(redis.Error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func (r *redisLoginAttempts) Reset(key string) error {
+	_, err := r.client.Do("DEL", redisLoginPrefix+key)
+	return err
+}
+
+func (r *redisLoginAttempts) DeleteExpired(before time.Time) error {
+	return nil // keys expire by themselves
+}
+
+func parseRedisAttempts(key string, reply interface{}) *domain.LoginAttempts {
+	fields, _ := reply.([]interface{})
+	if len(fields) == 0 {
+		return nil
+	}
+	a := &domain.LoginAttempts{Key: key}
+	for i := 0; i+1 < len(fields); i += 2 {
+		name, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch name {
+		case "failures":
+			a.Failures = int(n)
+		case "last_failure":
+			a.LastFailure = time.UnixMilli(n)
+		case "locked_until":
+			t := time.UnixMilli(n)
+			a.LockedUntil = &t
+		}
+	}
+	return a
+}
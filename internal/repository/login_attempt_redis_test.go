@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/pkg/redis"
+)
+
+// hashServer is an in-memory RESP server with the hash commands the store
+// uses, MULTI/EXEC included. TTLs are recorded, not enforced.
+type hashServer struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+	order  map[string][]string // field order, as Redis keeps it for small hashes
+	ttls   map[string]int64    // PEXPIRE in ms
+}
+
+func startHashServer(t *testing.T) (*hashServer, *redis.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &hashServer{hashes: map[string]map[string]string{}, order: map[string][]string{}, ttls: map[string]int64{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	client := redis.New(redis.Config{Addr: ln.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return s, client
+}
+
+func (s *hashServer) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	var queued [][]string
+	inMulti := false
+	for {
+		cmd, err := readRESPCommand(rd)
+		if err != nil {
+			return
+		}
+		switch {
+		case cmd[0] == "MULTI":
+			inMulti, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case cmd[0] == "EXEC":
+			s.mu.Lock()
+			out := fmt.Sprintf("*%d\r\n", len(queued))
+			for _, q := range queued {
+				out += s.apply(q)
+			}
+			s.mu.Unlock()
+			inMulti = false
+			io.WriteString(conn, out)
+		case inMulti:
+			queued = append(queued, cmd)
+			io.WriteString(conn, "+QUEUED\r\n")
+		default:
+			s.mu.Lock()
+			out := s.apply(cmd)
+			s.mu.Unlock()
+			io.WriteString(conn, out)
+		}
+	}
+}
+
+func (s *hashServer) apply(cmd []string) string {
+	key := cmd[1]
+	set := func(field, value string) {
+		if s.hashes[key] == nil {
+			s.hashes[key] = map[string]string{}
+		}
+		if _, ok := s.hashes[key][field]; !ok {
+			s.order[key] = append(s.order[key], field)
+		}
+		s.hashes[key][field] = value
+	}
+	switch cmd[0] {
+	case "HINCRBY":
+		n, _ := strconv.ParseInt(s.hashes[key][cmd[2]], 10, 64)
+		by, _ := strconv.ParseInt(cmd[3], 10, 64)
+		set(cmd[2], strconv.FormatInt(n+by, 10))
+		return fmt.Sprintf(":%d\r\n", n+by)
+	case "HSET":
+		set(cmd[2], cmd[3])
+		return ":1\r\n"
+	case "PEXPIRE":
+		ms, _ := strconv.ParseInt(cmd[2], 10, 64)
+		s.ttls[key] = ms
+		return ":1\r\n"
+	case "HGETALL":
+		out := fmt.Sprintf("*%d\r\n", 2*len(s.order[key]))
+		for _, f := range s.order[key] {
+			v := s.hashes[key][f]
+			out += fmt.Sprintf("$%d\r\n%s\r\n$%d\r\n%s\r\n", len(f), f, len(v), v)
+		}
+		return out
+	case "DEL":
+		delete(s.hashes, key)
+		delete(s.order, key)
+		delete(s.ttls, key)
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + cmd[0] + "'\r\n"
+}
+
+func (s *hashServer) ttl(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Duration(s.ttls[redisLoginPrefix+key]) * time.Millisecond
+}
+
+func readRESPCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, n)
+	for i := range cmd {
+		head, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(head, "$")))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(buf[:size])
+	}
+	return cmd, nil
+}
+
+func TestRedisLoginAttemptsFail(t *testing.T) {
+	srv, client := startHashServer(t)
+	store := NewRedisLoginAttemptStore(client)
+	now := time.UnixMilli(time.Now().UnixMilli())
+	window := 15 * time.Minute
+
+	if st, err := store.Get("account:a@example.com"); err != nil || st != nil {
+		t.Fatalf("Get before any failure = %+v, %v", st, err)
+	}
+	for i := 1; i <= 3; i++ {
+		st, err := store.Fail("account:a@example.com", now.Add(time.Duration(i)*time.Second), window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Key != "account:a@example.com" || st.Failures != i || !st.LastFailure.Equal(now.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("after %d failures: %+v", i, st)
+		}
+		if st.LockedUntil != nil {
+			t.Fatalf("locked without Lock: %+v", st)
+		}
+	}
+	if got := srv.ttl("account:a@example.com"); got != window {
+		t.Errorf("TTL after Fail = %s, want the window %s", got, window)
+	}
+
+	st, err := store.Get("account:a@example.com")
+	if err != nil || st.Failures != 3 {
+		t.Fatalf("Get = %+v, %v", st, err)
+	}
+}
+
+func TestRedisLoginAttemptsLock(t *testing.T) {
+	srv, client := startHashServer(t)
+	store := NewRedisLoginAttemptStore(client)
+	now := time.Now()
+	window := 15 * time.Minute
+	until := time.UnixMilli(now.Add(30 * time.Minute).UnixMilli())
+
+	if _, err := store.Fail("ip:10.0.0.1", now, window); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Lock("ip:10.0.0.1", until, window); err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.Get("ip:10.0.0.1")
+	if err != nil || st.LockedUntil == nil || !st.LockedUntil.Equal(until) {
+		t.Fatalf("Get after Lock = %+v, %v", st, err)
+	}
+	// the counter outlives the lock by a window
+	ttl := srv.ttl("ip:10.0.0.1")
+	if want := 45 * time.Minute; ttl > want || ttl < want-time.Second {
+		t.Errorf("TTL after Lock = %s, want about %s", ttl, want)
+	}
+
+	if err := store.Reset("ip:10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := store.Get("ip:10.0.0.1"); err != nil || st != nil {
+		t.Errorf("Get after Reset = %+v, %v", st, err)
+	}
+}
+
+func TestRedisLoginAttemptsExecError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			cmd, err := readRESPCommand(rd)
+			if err != nil {
+				return
+			}
+			switch cmd[0] {
+			case "MULTI":
+				io.WriteString(conn, "+OK\r\n")
+			case "EXEC":
+				io.WriteString(conn, "-EXECABORT Transaction discarded because of previous errors.\r\n")
+			default:
+				io.WriteString(conn, "+QUEUED\r\n")
+			}
+		}
+	}()
+	client := redis.New(redis.Config{Addr: ln.Addr().String()})
+	defer client.Close()
+
+	_, err = NewRedisLoginAttemptStore(client).Fail("account:x", time.Now(), time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "EXECABORT") {
+		t.Errorf("err = %v, want the EXEC error", err)
+	}
+}
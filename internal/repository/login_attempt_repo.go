@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// LoginAttemptStore keeps failed login counters. Postgres is the default;
+// a Redis-compatible server can be used instead (see login_attempt_redis.go).
+type LoginAttemptStore interface {
+	// Get returns nil when the key has no failures.
+	Get(key string) (*domain.LoginAttempts, error)
+	// Fail counts a failure at now; counting starts over when the last
+	// failure is older than window.
+	Fail(key string, now time.Time, window time.Duration) (*domain.LoginAttempts, error)
+	// Lock refuses the key until then; stores with expiry keep the counter
+	// for another window after that.
+	Lock(key string, until time.Time, window time.Duration) error
+	Reset(key string) error
+	// DeleteExpired drops counters idle since before; stores that expire
+	// keys by themselves do nothing.
+	DeleteExpired(before time.Time) error
+}
+
+type loginAttemptRepo struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptStore {
+	return &loginAttemptRepo{db: db}
+}
+
+func (r *loginAttemptRepo) Get(key string) (*domain.LoginAttempts, error) {
+	a, err := scanLoginAttempts(key, r.db.QueryRow(`SELECT failures, last_failure, locked_until FROM login_attempts WHERE key=$1`, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (r *loginAttemptRepo) Fail(key string, now time.Time, window time.Duration) (*domain.LoginAttempts, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure) VALUES ($1, 1, $2)
+			  ON CONFLICT (key) DO UPDATE SET
+			    failures = CASE WHEN login_attempts.last_failure < $2 - make_interval(secs => $3)
+			                    THEN 1 ELSE login_attempts.failures + 1 END,
+			    locked_until = CASE WHEN login_attempts.last_failure < $2 - make_interval(secs => $3)
+			                    THEN NULL ELSE login_attempts.locked_until END,
+			    last_failure = $2
+			  RETURNING failures, last_failure, locked_until`
+	return scanLoginAttempts(key, r.db.QueryRow(query, key, now, window.Seconds()))
+}
+
+func (r *loginAttemptRepo) Lock(key string, until time.Time, window time.Duration) error {
+	_, err := r.db.Exec(`UPDATE login_attempts SET locked_until=$2 WHERE key=$1`, key, until)
+	return err
+}
+
+func (r *loginAttemptRepo) Reset(key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE key=$1`, key)
+	return err
+}
+
+func (r *loginAttemptRepo) DeleteExpired(before time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts
+			  WHERE last_failure < $1 AND (locked_until IS NULL OR locked_until < now())`, before)
+	return err
+}
+
+func scanLoginAttempts(key string, row rowScanner) (*domain.LoginAttempts, error) {
+	a := domain.LoginAttempts{Key: key}
+	var locked sql.NullTime
+	if err := row.Scan(&a.Failures, &a.LastFailure, &locked); err != nil {
+		return nil, err
+	}
+	if locked.Valid {
+		a.LockedUntil = &locked.Time
+	}
+	return &a, nil
+}
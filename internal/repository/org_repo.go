@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type OrgRepository interface {
+	// Create stores the org with its creator as admin member.
+	Create(org *domain.Organization) error
+	GetByID(id uint) (*domain.Organization, error)
+	// ListForUser returns the orgs the user is a member of, with their role.
+	ListForUser(userID uint) ([]domain.Organization, error)
+
+	GetMember(orgID, userID uint) (*domain.OrgMember, error)
+	Members(orgID uint) ([]domain.OrgMember, error)
+	SetMemberRole(orgID, userID uint, role string) error
+	RemoveMember(orgID, userID uint) error
+	CountAdmins(orgID uint) (int, error)
+
+	CreateInvitation(inv *domain.OrgInvitation) error
+	GetInvitationByHash(hash string) (*domain.OrgInvitation, error)
+	// ListInvitations returns the pending invitations of the org.
+	ListInvitations(orgID uint) ([]domain.OrgInvitation, error)
+	// AcceptInvitation marks the invitation accepted and adds the user with
+	// its role; false if it was accepted already.
+	AcceptInvitation(inv *domain.OrgInvitation, userID uint) (bool, error)
+	DeleteInvitation(orgID, id uint) error
+	DeleteExpiredInvitations(now time.Time) error
+}
+
+type orgRepo struct {
+	db *sql.DB
+}
+
+func NewOrgRepository(db *sql.DB) OrgRepository {
+	return &orgRepo{db: db}
+}
+
+// ===================== ORGS =====================
+
+func (r *orgRepo) Create(org *domain.Organization) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO organizations (name, created_by) VALUES ($1, $2) RETURNING id, created_at`,
+		org.Name, nullID(org.CreatedBy)).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if org.CreatedBy != 0 {
+		_, err = tx.Exec(`INSERT INTO org_members (org_id, user_id, role) VALUES ($1, $2, $3)`,
+			org.ID, org.CreatedBy, domain.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		org.Role = domain.RoleAdmin
+	}
+	return tx.Commit()
+}
+
+func scanOrg(row rowScanner, extra ...interface{}) (domain.Organization, error) {
+	var o domain.Organization
+	var createdBy sql.NullInt64
+	dest := append([]interface{}{&o.ID, &o.Name, &createdBy, &o.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return o, err
+	}
+	o.CreatedBy = uint(createdBy.Int64)
+	return o, nil
+}
+
+func (r *orgRepo) GetByID(id uint) (*domain.Organization, error) {
+	o, err := scanOrg(r.db.QueryRow(`SELECT id, name, created_by, created_at FROM organizations WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *orgRepo) ListForUser(userID uint) ([]domain.Organization, error) {
+	rows, err := r.db.Query(`SELECT o.id, o.name, o.created_by, o.created_at, m.role
+		FROM organizations o JOIN org_members m ON m.org_id = o.id
+		WHERE m.user_id=$1 ORDER BY o.name, o.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.Organization
+	for rows.Next() {
+		var role string
+		o, err := scanOrg(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		o.Role = role
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// ===================== MEMBERS =====================
+
+const orgMemberQuery = `SELECT m.org_id, m.user_id, u.email, COALESCE(u.name, ''), m.role, m.created_at
+	FROM org_members m JOIN users u ON u.id = m.user_id`
+
+func scanOrgMember(row rowScanner) (domain.OrgMember, error) {
+	var m domain.OrgMember
+	err := row.Scan(&m.OrgID, &m.UserID, &m.Email, &m.Name, &m.Role, &m.CreatedAt)
+	return m, err
+}
+
+func (r *orgRepo) GetMember(orgID, userID uint) (*domain.OrgMember, error) {
+	m, err := scanOrgMember(r.db.QueryRow(orgMemberQuery+` WHERE m.org_id=$1 AND m.user_id=$2`, orgID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *orgRepo) Members(orgID uint) ([]domain.OrgMember, error) {
+	rows, err := r.db.Query(orgMemberQuery+` WHERE m.org_id=$1 ORDER BY u.email`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.OrgMember
+	for rows.Next() {
+		m, err := scanOrgMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (r *orgRepo) SetMemberRole(orgID, userID uint, role string) error {
+	res, err := r.db.Exec(`UPDATE org_members SET role=$3 WHERE org_id=$1 AND user_id=$2`, orgID, userID, role)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("record not found")
+	}
+	return nil
+}
+
+func (r *orgRepo) RemoveMember(orgID, userID uint) error {
+	_, err := r.db.Exec(`DELETE FROM org_members WHERE org_id=$1 AND user_id=$2`, orgID, userID)
+	return err
+}
+
+func (r *orgRepo) CountAdmins(orgID uint) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM org_members WHERE org_id=$1 AND role=$2`, orgID, domain.RoleAdmin).Scan(&n)
+	return n, err
+}
+
+// ===================== INVITATIONS =====================
+
+const orgInvitationColumns = `id, org_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at`
+
+func scanOrgInvitation(row rowScanner) (domain.OrgInvitation, error) {
+	var inv domain.OrgInvitation
+	var invitedBy sql.NullInt64
+	var acceptedAt sql.NullTime
+	err := row.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.TokenHash, &invitedBy, &inv.ExpiresAt, &acceptedAt, &inv.CreatedAt)
+	if err != nil {
+		return inv, err
+	}
+	inv.InvitedBy = uint(invitedBy.Int64)
+	if acceptedAt.Valid {
+		inv.AcceptedAt = &acceptedAt.Time
+	}
+	return inv, nil
+}
+
+func (r *orgRepo) CreateInvitation(inv *domain.OrgInvitation) error {
+	query := `INSERT INTO org_invitations (org_id, email, role, token_hash, invited_by, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	return r.db.QueryRow(query, inv.OrgID, inv.Email, inv.Role, inv.TokenHash, nullID(inv.InvitedBy), inv.ExpiresAt).
+		Scan(&inv.ID, &inv.CreatedAt)
+}
+
+func (r *orgRepo) GetInvitationByHash(hash string) (*domain.OrgInvitation, error) {
+	inv, err := scanOrgInvitation(r.db.QueryRow(`SELECT `+orgInvitationColumns+` FROM org_invitations WHERE token_hash=$1`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *orgRepo) ListInvitations(orgID uint) ([]domain.OrgInvitation, error) {
+	rows, err := r.db.Query(`SELECT `+orgInvitationColumns+` FROM org_invitations
+		WHERE org_id=$1 AND accepted_at IS NULL AND expires_at > now() ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.OrgInvitation
+	for rows.Next() {
+		inv, err := scanOrgInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+func (r *orgRepo) AcceptInvitation(inv *domain.OrgInvitation, userID uint) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE org_invitations SET accepted_at=now() WHERE id=$1 AND accepted_at IS NULL`, inv.ID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	_, err = tx.Exec(`INSERT INTO org_members (org_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role=EXCLUDED.role`, inv.OrgID, userID, inv.Role)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *orgRepo) DeleteInvitation(orgID, id uint) error {
+	_, err := r.db.Exec(`DELETE FROM org_invitations WHERE org_id=$1 AND id=$2`, orgID, id)
+	return err
+}
+
+func (r *orgRepo) DeleteExpiredInvitations(now time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM org_invitations WHERE expires_at < $1`, now)
+	return err
+}
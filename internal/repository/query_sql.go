@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/query"
+	"github.com/lib/pq"
+)
+
+// queryColumns maps query fields to log_records columns
+var queryColumns = map[string]string{
+	"time": "time", "method": "method", "path": "path", "status": "status", "latency": "latency_ms",
+	"ip": "ip", "level": "level", "message": "message", "raw": "raw",
+}
+
+// numeric fields are compared as float8, so 499.5 works against status
+var numericQueryFields = map[string]bool{"status": true, "latency": true}
+
+// sqlQuery builds one statement; values go to args as $n placeholders
+type sqlQuery struct {
+	args []interface{}
+}
+
+func (b *sqlQuery) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (r *recordRepo) Aggregate(scope domain.QueryScope, q *query.Query) (*domain.QueryResult, error) {
+	stmt, args, err := compileQuery(scope, q)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), userQueryTimeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, userQueryError(ctx, err)
+	}
+	defer rows.Close()
+
+	res := &domain.QueryResult{Rows: [][]interface{}{}}
+	for _, c := range q.Select {
+		res.Columns = append(res.Columns, c.Name())
+	}
+	for rows.Next() {
+		row := make([]interface{}, len(q.Select))
+		ptrs := make([]interface{}, len(row))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, userQueryError(ctx, err)
+		}
+		for i, v := range row {
+			if raw, ok := v.([]byte); ok {
+				row[i] = string(raw)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, userQueryError(ctx, rows.Err())
+}
+
+// compileQuery turns a query into one SELECT over log_records
+func compileQuery(scope domain.QueryScope, q *query.Query) (string, []interface{}, error) {
+	b := &sqlQuery{}
+	where := []string{"org_id = " + b.arg(scope.OrgID)}
+	if scope.AnalysisID != 0 {
+		where = append(where, "analysis_id = "+b.arg(scope.AnalysisID))
+	}
+	if scope.From != nil {
+		where = append(where, "time >= "+b.arg(*scope.From))
+	}
+	if scope.To != nil {
+		where = append(where, "time < "+b.arg(*scope.To))
+	}
+	if q.Where != nil {
+		cond, err := b.expr(q.Where)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+	}
+
+	cols := make([]string, len(q.Select))
+	for i, c := range q.Select {
+		expr, err := sqlColumn(c)
+		if err != nil {
+			return "", nil, err
+		}
+		cols[i] = expr
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SELECT %s FROM log_records WHERE %s", strings.Join(cols, ", "), strings.Join(where, " AND "))
+
+	var order []string
+	for _, o := range q.OrderBy {
+		dir := "ASC NULLS FIRST"
+		if o.Desc {
+			dir = "DESC NULLS LAST"
+		}
+		expr, err := orderColumn(q, o.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		order = append(order, expr+" "+dir)
+	}
+	if q.Aggregated() {
+		if len(q.GroupBy) > 0 {
+			groups := make([]string, len(q.GroupBy))
+			for i, t := range q.GroupBy {
+				expr, err := sqlTerm(t)
+				if err != nil {
+					return "", nil, err
+				}
+				groups[i] = expr
+			}
+			fmt.Fprintf(&sb, " GROUP BY %s", strings.Join(groups, ", "))
+		}
+		// default: by the selected group columns, like the in-memory evaluator
+		if len(order) == 0 {
+			for _, t := range q.GroupBy {
+				for i, c := range q.Select {
+					if c.Agg == "" && c.Term.String() == t.String() {
+						expr, err := orderColumn(q, i)
+						if err != nil {
+							return "", nil, err
+						}
+						order = append(order, expr+" ASC NULLS FIRST")
+						break
+					}
+				}
+			}
+		}
+	} else if len(order) == 0 {
+		order = []string{"time NULLS FIRST", "analysis_id", "line_no"}
+	}
+	if len(order) > 0 {
+		fmt.Fprintf(&sb, " ORDER BY %s", strings.Join(order, ", "))
+	}
+	fmt.Fprintf(&sb, " LIMIT %d", q.Limit)
+	return sb.String(), b.args, nil
+}
+
+// textTerm reports whether a term is a text column, whose order and ranges
+// depend on the collation
+func textTerm(t query.Term) bool {
+	return t.Bucket == 0 && t.Field != "time" && !numericQueryFields[t.Field]
+}
+
+// orderColumn refers to select column i; text columns sort by byte value
+// (COLLATE "C"), like the in-memory evaluator, not by the locale.
+func orderColumn(q *query.Query, i int) (string, error) {
+	c := q.Select[i]
+	if c.Agg != "" || !textTerm(c.Term) {
+		return strconv.Itoa(i + 1), nil
+	}
+	expr, err := sqlTerm(c.Term)
+	if err != nil {
+		return "", err
+	}
+	return "(" + expr + `) COLLATE "C"`, nil
+}
+
+func sqlField(field string) (string, error) {
+	if col, ok := queryColumns[field]; ok {
+		return col, nil
+	}
+	if key, ok := query.AttrKey(field); ok {
+		return "attributes->>" + pq.QuoteLiteral(key), nil
+	}
+	return "", fmt.Errorf("unknown field %q", field)
+}
+
+func sqlTerm(t query.Term) (string, error) {
+	col, err := sqlField(t.Field)
+	if err != nil {
+		return "", err
+	}
+	if t.Bucket > 0 {
+		if t.Bucket%time.Second != 0 {
+			return "", fmt.Errorf("bucket %s is not a whole number of seconds", t.Bucket)
+		}
+		secs := int64(t.Bucket / time.Second)
+		return fmt.Sprintf("to_timestamp(floor(extract(epoch FROM %s) / %d) * %d)", col, secs, secs), nil
+	}
+	return col, nil
+}
+
+func sqlColumn(c query.Column) (string, error) {
+	if c.Agg == "count" && c.Term.Field == "*" {
+		return "count(*)", nil
+	}
+	expr, err := sqlTerm(c.Term)
+	if err != nil {
+		return "", err
+	}
+	switch c.Agg {
+	case "":
+		return expr, nil
+	case "count":
+		return "count(" + expr + ")", nil
+	case "count_distinct":
+		return "count(DISTINCT " + expr + ")", nil
+	case "sum", "avg":
+		return c.Agg + "(" + expr + ")::float8", nil
+	case "min", "max":
+		return c.Agg + "(" + expr + ")", nil
+	case "percentile":
+		return fmt.Sprintf("percentile_cont(%s) WITHIN GROUP (ORDER BY %s)",
+			strconv.FormatFloat(c.Percentile, 'f', -1, 64), expr), nil
+	}
+	return "", fmt.Errorf("unknown aggregate %s", c.Agg)
+}
+
+func (b *sqlQuery) expr(e query.Expr) (string, error) {
+	switch e := e.(type) {
+	case *query.Logical:
+		left, err := b.expr(e.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := b.expr(e.Right)
+		if err != nil {
+			return "", err
+		}
+		return "(" + left + " " + strings.ToUpper(e.Op) + " " + right + ")", nil
+	case *query.Not:
+		x, err := b.expr(e.X)
+		if err != nil {
+			return "", err
+		}
+		// a comparison with NULL is unknown; count it as false before negating
+		return "NOT coalesce(" + x + ", false)", nil
+	case *query.Compare:
+		return b.compare(e)
+	}
+	return "", fmt.Errorf("unsupported expression %s", e)
+}
+
+func (b *sqlQuery) compare(e *query.Compare) (string, error) {
+	col, err := sqlField(e.Field)
+	if err != nil {
+		return "", err
+	}
+	value := func(l query.Literal) string {
+		switch {
+		case e.Field == "time":
+			t, _ := time.Parse(time.RFC3339, l.Str)
+			return b.arg(t)
+		case numericQueryFields[e.Field]:
+			return b.arg(l.Num) + "::float8"
+		}
+		return b.arg(l.Str)
+	}
+
+	switch e.Op {
+	case "in", "not in":
+		vals := make([]string, len(e.Values))
+		for i, l := range e.Values {
+			vals[i] = value(l)
+		}
+		return fmt.Sprintf("%s %s (%s)", col, strings.ToUpper(e.Op), strings.Join(vals, ", ")), nil
+	case "like", "not like":
+		return fmt.Sprintf("%s %s %s", col, strings.ToUpper(e.Op), b.arg(e.Values[0].Str)), nil
+	case "~", "!~":
+		return fmt.Sprintf("%s %s %s", col, e.Op, b.arg(e.Values[0].Str)), nil
+	case "!=":
+		return fmt.Sprintf("%s <> %s", col, value(e.Values[0])), nil
+	case "=":
+		return fmt.Sprintf("%s = %s", col, value(e.Values[0])), nil
+	case "<", "<=", ">", ">=":
+		if textTerm(query.Term{Field: e.Field}) {
+			col = "(" + col + `) COLLATE "C"`
+		}
+		return fmt.Sprintf("%s %s %s", col, e.Op, value(e.Values[0])), nil
+	}
+	return "", fmt.Errorf("unsupported operator %s", e.Op)
+}
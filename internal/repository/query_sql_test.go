@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/query"
+)
+
+func TestCompileQuery(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		query string
+		scope domain.QueryScope
+		sql   string
+		args  []interface{}
+	}{
+		{
+			"SELECT path, status LIMIT 3",
+			domain.QueryScope{OrgID: 4},
+			`SELECT path, status FROM log_records WHERE org_id = $1 ORDER BY time NULLS FIRST, analysis_id, line_no LIMIT 3`,
+			[]interface{}{uint(4)},
+		},
+		{
+			"SELECT path, count(*), p95(latency) WHERE status >= 500 AND method IN ('GET', 'POST') GROUP BY path ORDER BY 2 DESC LIMIT 10",
+			domain.QueryScope{OrgID: 4, AnalysisID: 12, From: &from},
+			`SELECT path, count(*), percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms) FROM log_records ` +
+				`WHERE org_id = $1 AND analysis_id = $2 AND time >= $3 AND (status >= $4::float8 AND method IN ($5, $6)) ` +
+				`GROUP BY path ORDER BY 2 DESC NULLS LAST LIMIT 10`,
+			[]interface{}{uint(4), uint(12), from, 500.0, "GET", "POST"},
+		},
+		{
+			// text columns sort and compare by byte value
+			"SELECT path, count(*) WHERE path < '/b' GROUP BY path",
+			domain.QueryScope{OrgID: 4},
+			`SELECT path, count(*) FROM log_records WHERE org_id = $1 AND (path) COLLATE "C" < $2 ` +
+				`GROUP BY path ORDER BY (path) COLLATE "C" ASC NULLS FIRST LIMIT 100`,
+			[]interface{}{uint(4), "/b"},
+		},
+		{
+			"SELECT bucket(time, 90s), avg(latency), attr.user GROUP BY bucket(time, 90s), attr.user",
+			domain.QueryScope{OrgID: 4},
+			`SELECT to_timestamp(floor(extract(epoch FROM time) / 90) * 90), avg(latency_ms)::float8, attributes->>'user' ` +
+				`FROM log_records WHERE org_id = $1 ` +
+				`GROUP BY to_timestamp(floor(extract(epoch FROM time) / 90) * 90), attributes->>'user' ` +
+				`ORDER BY 1 ASC NULLS FIRST, (attributes->>'user') COLLATE "C" ASC NULLS FIRST LIMIT 100`,
+			[]interface{}{uint(4)},
+		},
+		{
+			"SELECT count(*) WHERE NOT (path LIKE '/api/%' OR message ~ 'x') AND status != 200",
+			domain.QueryScope{OrgID: 4},
+			`SELECT count(*) FROM log_records WHERE org_id = $1 AND ` +
+				`(NOT coalesce((path LIKE $2 OR message ~ $3), false) AND status <> $4::float8) LIMIT 100`,
+			[]interface{}{uint(4), "/api/%", "x", 200.0},
+		},
+	}
+	for _, c := range cases {
+		q, err := query.Parse(c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if q.Limit == 0 {
+			q.Limit = 100
+		}
+		sql, args, err := compileQuery(c.scope, q)
+		if err != nil {
+			t.Errorf("%s: %v", c.query, err)
+			continue
+		}
+		if sql != c.sql {
+			t.Errorf("%s:\n got %s\nwant %s", c.query, sql, c.sql)
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: args %#v, want %#v", c.query, args, c.args)
+		}
+	}
+}
+
+func TestCompileQueryBucketSeconds(t *testing.T) {
+	q := &query.Query{Select: []query.Column{{Term: query.Term{Field: "time", Bucket: 1500 * time.Millisecond}}}, Limit: 10}
+	_, _, err := compileQuery(domain.QueryScope{OrgID: 1}, q)
+	if err == nil || !strings.Contains(err.Error(), "whole number of seconds") {
+		t.Errorf("err = %v, want a whole seconds error", err)
+	}
+}
@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/query"
+	"github.com/lib/pq"
+)
+
+const (
+	recordSourceUpload = "upload"
+	recordSourceStream = "stream"
+
+	uploadPartitionPrefix = "log_records_a"
+	streamPartitionPrefix = "log_records_d"
+	partitionDayLayout    = "20060102"
+
+	// userQueryTimeout bounds statements built from user input, searches
+	// and queries, whose wildcards and regexes may scan a lot of records
+	userQueryTimeout = 30 * time.Second
+)
+
+type RecordRepository interface {
+	// Insert writes records of one analysis with COPY, creating the
+	// partitions it needs first.
+	Insert(a *domain.LogAnalysis, records []domain.StoredRecord) error
+	Find(filter domain.RecordFilter) ([]domain.StoredRecord, error)
+	Search(filter domain.SearchFilter) ([]domain.StoredRecord, error)
+	// Aggregate runs a query checked by the usecase over the records in scope.
+	Aggregate(scope domain.QueryScope, q *query.Query) (*domain.QueryResult, error)
+	DeleteByAnalysis(analysisID uint) error
+	// DropBefore drops stream day partitions older than cutoff and upload
+	// partitions whose analysis is older than cutoff or gone.
+	DropBefore(cutoff time.Time) (int, error)
+}
+
+type recordRepo struct {
+	db            *sql.DB
+	retentionDays int // stream records are kept in partitions of this many days back
+}
+
+func NewRecordRepository(db *sql.DB, retentionDays int) RecordRepository {
+	return &recordRepo{db: db, retentionDays: retentionDays}
+}
+
+var recordCopyColumns = []string{
+	"analysis_id", "user_id", "org_id", "source", "day", "line_no", "time", "method", "path",
+	"status", "latency_ms", "ip", "level", "message", "attributes", "raw",
+}
+
+func (r *recordRepo) Insert(a *domain.LogAnalysis, records []domain.StoredRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	source := recordSourceUpload
+	if a.StreamID != 0 {
+		source = recordSourceStream
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	// partitions first: CREATE ... PARTITION OF cannot run inside the COPY
+	if source == recordSourceUpload {
+		if err := r.ensurePartition(uploadPartition(a.ID)); err != nil {
+			return err
+		}
+	} else {
+		days := make(map[time.Time]bool)
+		for _, rec := range records {
+			days[recordDay(rec.Time, today, r.retentionDays)] = true
+		}
+		for day := range days {
+			if err := r.ensurePartition(streamPartition(day)); err != nil {
+				return err
+			}
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pq.CopyIn("log_records", recordCopyColumns...))
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		var attrs interface{}
+		if len(rec.Attributes) > 0 {
+			b, err := json.Marshal(rec.Attributes)
+			if err != nil {
+				return err
+			}
+			attrs = string(b)
+		}
+		var t interface{}
+		if !rec.Time.IsZero() {
+			t = rec.Time
+		}
+		var line interface{}
+		if rec.Line > 0 {
+			line = rec.Line
+		}
+		if _, err := stmt.Exec(a.ID, a.UserID, a.OrgID, source, recordDay(rec.Time, today, r.retentionDays), line, t,
+			rec.Method, rec.Path, rec.Status, rec.Latency, rec.IP, rec.Level, rec.Message, attrs, rec.Raw); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// partition is the DDL for one partition; name is also its identifier
+type partition struct {
+	name string
+	ddl  string
+}
+
+func uploadPartition(analysisID uint) partition {
+	name := fmt.Sprintf("%s%d", uploadPartitionPrefix, analysisID)
+	return partition{name, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF log_records_upload FOR VALUES IN (%d)`, name, analysisID)}
+}
+
+func streamPartition(day time.Time) partition {
+	name := streamPartitionPrefix + day.Format(partitionDayLayout)
+	return partition{name, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF log_records_stream FOR VALUES FROM ('%s') TO ('%s')`,
+		name, day.Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02"))}
+}
+
+func (r *recordRepo) ensurePartition(p partition) error {
+	_, err := r.db.Exec(p.ddl)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return nil // created by a concurrent writer
+	}
+	return err
+}
+
+// recordDay is the stream partition of a record; records without a time
+// go to today's partition. Times come from the sender, so the day is clamped
+// between the retention period and tomorrow: otherwise every odd timestamp
+// would create a partition of its own.
+func recordDay(t, today time.Time, retentionDays int) time.Time {
+	if t.IsZero() {
+		return today
+	}
+	day := t.UTC().Truncate(24 * time.Hour)
+	if oldest := today.AddDate(0, 0, -retentionDays); day.Before(oldest) {
+		return oldest
+	}
+	if newest := today.AddDate(0, 0, 1); day.After(newest) {
+		return newest
+	}
+	return day
+}
+
+func (r *recordRepo) Find(f domain.RecordFilter) ([]domain.StoredRecord, error) {
+	table := "log_records_upload"
+	if f.Stream {
+		table = "log_records_stream"
+	}
+	where := []string{"analysis_id = $1"}
+	args := []interface{}{f.AnalysisID}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Status != 0 {
+		add("status = $%d", f.Status)
+	}
+	if f.StatusMin != 0 {
+		add("status >= $%d", f.StatusMin)
+	}
+	if f.StatusMax != 0 {
+		add("status <= $%d", f.StatusMax)
+	}
+	if f.Method != "" {
+		add("method = $%d", strings.ToUpper(f.Method))
+	}
+	if f.Path != "" {
+		if prefix, ok := strings.CutSuffix(f.Path, "*"); ok {
+			add(`path LIKE $%d ESCAPE '\'`, likeEscape(prefix)+"%")
+		} else {
+			add("path = $%d", f.Path)
+		}
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if f.LatencyMin != nil {
+		add("latency_ms >= $%d", *f.LatencyMin)
+	}
+	if f.LatencyMax != nil {
+		add("latency_ms <= $%d", *f.LatencyMax)
+	}
+	if f.From != nil {
+		add("time >= $%d", *f.From)
+		if f.Stream {
+			add("day >= $%d", f.From.UTC().Truncate(24*time.Hour))
+		}
+	}
+	if f.To != nil {
+		add("time < $%d", *f.To)
+		if f.Stream {
+			add("day <= $%d", f.To.UTC().Truncate(24*time.Hour))
+		}
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query := fmt.Sprintf(`
+		SELECT analysis_id, line_no, time, method, path, status, latency_ms, ip, level, message, attributes, raw
+		FROM %s WHERE %s
+		ORDER BY time NULLS FIRST, line_no
+		LIMIT $%d OFFSET $%d`, table, strings.Join(where, " AND "), len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []domain.StoredRecord{}
+	for rows.Next() {
+		rec, err := scanStoredRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+var (
+	searchWordChars  = regexp.MustCompile(`[\pL\pN]`)
+	searchPrefixWord = regexp.MustCompile(`^[\pL\pN_]+\*$`)
+)
+
+func (r *recordRepo) Search(f domain.SearchFilter) ([]domain.StoredRecord, error) {
+	where := []string{"org_id = $1"}
+	args := []interface{}{f.OrgID}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.AnalysisID != 0 {
+		add("analysis_id = $%d", f.AnalysisID)
+	}
+	if f.From != nil {
+		add("time >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("time < $%d", *f.To)
+	}
+	for _, t := range f.Terms {
+		cond, v := searchCondition(t)
+		if t.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		add(cond, v)
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query := fmt.Sprintf(`
+		SELECT analysis_id, line_no, time, method, path, status, latency_ms, ip, level, message, attributes, raw
+		FROM log_records WHERE %s
+		ORDER BY time DESC NULLS LAST, analysis_id, line_no
+		LIMIT $%d OFFSET $%d`, strings.Join(where, " AND "), len(args)-1, len(args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), userQueryTimeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, userQueryError(ctx, err)
+	}
+	defer rows.Close()
+
+	records := []domain.StoredRecord{}
+	for rows.Next() {
+		rec, err := scanStoredRecord(rows)
+		if err != nil {
+			return nil, userQueryError(ctx, err)
+		}
+		records = append(records, rec)
+	}
+	return records, userQueryError(ctx, rows.Err())
+}
+
+// userQueryError explains a statement cancelled by userQueryTimeout.
+func userQueryError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query took longer than %s, narrow it down", userQueryTimeout)
+	}
+	return err
+}
+
+// searchCondition maps a term to the tsvector index where it can (words,
+// phrases, word prefixes) and to a trigram ILIKE otherwise. The condition
+// has one %d placeholder for its argument.
+func searchCondition(t domain.SearchTerm) (string, interface{}) {
+	wildcard := !t.Phrase && strings.ContainsAny(t.Text, "*?")
+	switch {
+	case !wildcard && searchWordChars.MatchString(t.Text):
+		return "search @@ phraseto_tsquery('simple', $%d)", t.Text
+	case wildcard && searchPrefixWord.MatchString(t.Text):
+		return "search @@ to_tsquery('simple', $%d)", strings.TrimSuffix(t.Text, "*") + ":*"
+	}
+	pattern := likeEscape(t.Text)
+	if wildcard {
+		pattern = strings.NewReplacer("*", "%", "?", "_").Replace(pattern)
+	}
+	return `raw ILIKE $%d ESCAPE '\'`, "%" + pattern + "%"
+}
+
+func scanStoredRecord(row rowScanner) (domain.StoredRecord, error) {
+	var rec domain.StoredRecord
+	var line sql.NullInt64
+	var t sql.NullTime
+	var method, path, ip, level, message, raw sql.NullString
+	var status sql.NullInt64
+	var latency sql.NullFloat64
+	var attrs []byte
+	if err := row.Scan(&rec.AnalysisID, &line, &t, &method, &path, &status, &latency, &ip, &level, &message, &attrs, &raw); err != nil {
+		return rec, err
+	}
+	rec.Line = line.Int64
+	rec.Time = t.Time
+	rec.Method, rec.Path, rec.IP, rec.Level = method.String, path.String, ip.String, level.String
+	rec.Message, rec.Raw = message.String, raw.String
+	rec.Status = int(status.Int64)
+	rec.Latency = latency.Float64
+	if len(attrs) > 0 {
+		if err := json.Unmarshal(attrs, &rec.Attributes); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
+
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (r *recordRepo) DeleteByAnalysis(analysisID uint) error {
+	if _, err := r.db.Exec(`DROP TABLE IF EXISTS ` + uploadPartition(analysisID).name); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`DELETE FROM log_records_stream WHERE analysis_id = $1`, analysisID)
+	return err
+}
+
+func (r *recordRepo) DropBefore(cutoff time.Time) (int, error) {
+	rows, err := r.db.Query(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname IN ('log_records_upload', 'log_records_stream')`)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// uploads that are still within the retention period
+	keep := make(map[uint64]bool)
+	idRows, err := r.db.Query(`SELECT id FROM log_analysis WHERE stream_id IS NULL AND created_at >= $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	for idRows.Next() {
+		var id uint64
+		if err := idRows.Scan(&id); err != nil {
+			idRows.Close()
+			return 0, err
+		}
+		keep[id] = true
+	}
+	idRows.Close()
+
+	cutoffDay := cutoff.UTC().Truncate(24 * time.Hour)
+	dropped := 0
+	for _, name := range names {
+		drop := false
+		if rest, ok := strings.CutPrefix(name, uploadPartitionPrefix); ok {
+			id, err := strconv.ParseUint(rest, 10, 64)
+			drop = err == nil && !keep[id]
+		} else if rest, ok := strings.CutPrefix(name, streamPartitionPrefix); ok {
+			day, err := time.Parse(partitionDayLayout, rest)
+			drop = err == nil && day.Before(cutoffDay)
+		}
+		if !drop {
+			continue
+		}
+		if _, err := r.db.Exec(`DROP TABLE IF EXISTS ` + pq.QuoteIdentifier(name)); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
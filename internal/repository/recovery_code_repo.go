@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type RecoveryCodeRepository interface {
+	// Replace drops the user's codes and stores the new hashes.
+	Replace(userID uint, hashes []string) error
+	ListUnused(userID uint) ([]domain.RecoveryCode, error)
+	CountUnused(userID uint) (int, error)
+	// Use marks a code used; false if it already was.
+	Use(id uint) (bool, error)
+	DeleteAll(userID uint) error
+}
+
+type recoveryCodeRepo struct {
+	db *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepo{db: db}
+}
+
+func (r *recoveryCodeRepo) Replace(userID uint, hashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *recoveryCodeRepo) ListUnused(userID uint) ([]domain.RecoveryCode, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, code_hash FROM recovery_codes
+			  WHERE user_id=$1 AND used_at IS NULL ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.RecoveryCode
+	for rows.Next() {
+		var c domain.RecoveryCode
+		if err := rows.Scan(&c.ID, &c.UserID, &c.CodeHash); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (r *recoveryCodeRepo) CountUnused(userID uint) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id=$1 AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *recoveryCodeRepo) Use(id uint) (bool, error) {
+	res, err := r.db.Exec(`UPDATE recovery_codes SET used_at=now() WHERE id=$1 AND used_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *recoveryCodeRepo) DeleteAll(userID uint) error {
+	_, err := r.db.Exec(`DELETE FROM recovery_codes WHERE user_id=$1`, userID)
+	return err
+}
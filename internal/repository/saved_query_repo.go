@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// runsKept is how many runs (with output) are kept per saved query
+const runsKept = 50
+
+type SavedQueryRepository interface {
+	Create(q *domain.SavedQuery) error
+	Update(q *domain.SavedQuery) error
+	GetByID(id uint) (*domain.SavedQuery, error)
+	ListByOrg(orgID uint) ([]domain.SavedQuery, error)
+	Delete(id uint) error
+	// Due returns enabled scheduled queries whose next run is not after now.
+	Due(now time.Time) ([]domain.SavedQuery, error)
+	// Claim moves next_run_at from prev to next; false means another
+	// instance already claimed this run.
+	Claim(id uint, prev time.Time, next *time.Time) (bool, error)
+	CreateRun(run *domain.ReportRun) error
+	ListRuns(savedQueryID uint, limit int) ([]domain.ReportRun, error)
+	GetRun(savedQueryID, runID uint) (*domain.ReportRun, error)
+}
+
+type savedQueryRepo struct {
+	db *sql.DB
+}
+
+func NewSavedQueryRepository(db *sql.DB) SavedQueryRepository {
+	return &savedQueryRepo{db: db}
+}
+
+const savedQueryColumns = `id, user_id, org_id, name, kind, query, analysis_id, range_seconds, labels, group_by,
+	format, schedule, notify, enabled, next_run_at, last_run_at, created_at, updated_at`
+
+func scanSavedQuery(row rowScanner) (domain.SavedQuery, error) {
+	var q domain.SavedQuery
+	var analysisID, orgID sql.NullInt64
+	var labels, groupBy, notify []byte
+	err := row.Scan(&q.ID, &q.UserID, &orgID, &q.Name, &q.Kind, &q.Query, &analysisID, &q.RangeSeconds, &labels, &groupBy,
+		&q.Format, &q.Schedule, &notify, &q.Enabled, &q.NextRunAt, &q.LastRunAt, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return q, err
+	}
+	q.AnalysisID = uint(analysisID.Int64)
+	q.OrgID = uint(orgID.Int64)
+	if err := json.Unmarshal(labels, &q.Labels); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(groupBy, &q.GroupBy); err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(notify, &q.Notify); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// savedQueryJSON encodes the JSONB columns
+func savedQueryJSON(q *domain.SavedQuery) (labels, groupBy, notify string, err error) {
+	if labels, err = labelsJSON(q.Labels); err != nil {
+		return
+	}
+	list := q.GroupBy
+	if list == nil {
+		list = []string{}
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return
+	}
+	groupBy = string(b)
+	notify, err = notifyJSON(q.Notify)
+	return
+}
+
+func notifyJSON(targets []domain.NotifyTarget) (string, error) {
+	if targets == nil {
+		targets = []domain.NotifyTarget{}
+	}
+	b, err := json.Marshal(targets)
+	return string(b), err
+}
+
+func nullID(id uint) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+func (r *savedQueryRepo) Create(q *domain.SavedQuery) error {
+	labels, groupBy, notify, err := savedQueryJSON(q)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO saved_queries (user_id, org_id, name, kind, query, analysis_id, range_seconds, labels, group_by,
+				format, schedule, notify, enabled, next_run_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, created_at, updated_at`
+	return r.db.QueryRow(query, q.UserID, q.OrgID, q.Name, q.Kind, q.Query, nullID(q.AnalysisID), q.RangeSeconds, labels, groupBy,
+		q.Format, q.Schedule, notify, q.Enabled, q.NextRunAt).
+		Scan(&q.ID, &q.CreatedAt, &q.UpdatedAt)
+}
+
+func (r *savedQueryRepo) Update(q *domain.SavedQuery) error {
+	labels, groupBy, notify, err := savedQueryJSON(q)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE saved_queries SET name=$1, kind=$2, query=$3, analysis_id=$4, range_seconds=$5, labels=$6,
+				group_by=$7, format=$8, schedule=$9, notify=$10, enabled=$11, next_run_at=$12
+			  WHERE id=$13 RETURNING updated_at`
+	err = r.db.QueryRow(query, q.Name, q.Kind, q.Query, nullID(q.AnalysisID), q.RangeSeconds, labels,
+		groupBy, q.Format, q.Schedule, notify, q.Enabled, q.NextRunAt, q.ID).Scan(&q.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("record not found")
+	}
+	return err
+}
+
+func (r *savedQueryRepo) GetByID(id uint) (*domain.SavedQuery, error) {
+	q, err := scanSavedQuery(r.db.QueryRow(`SELECT `+savedQueryColumns+` FROM saved_queries WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (r *savedQueryRepo) list(query string, args ...interface{}) ([]domain.SavedQuery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.SavedQuery
+	for rows.Next() {
+		q, err := scanSavedQuery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+func (r *savedQueryRepo) ListByOrg(orgID uint) ([]domain.SavedQuery, error) {
+	return r.list(`SELECT `+savedQueryColumns+` FROM saved_queries WHERE org_id=$1 ORDER BY name`, orgID)
+}
+
+func (r *savedQueryRepo) Delete(id uint) error {
+	_, err := r.db.Exec(`DELETE FROM saved_queries WHERE id=$1`, id)
+	return err
+}
+
+func (r *savedQueryRepo) Due(now time.Time) ([]domain.SavedQuery, error) {
+	return r.list(`SELECT `+savedQueryColumns+` FROM saved_queries
+		WHERE enabled AND next_run_at IS NOT NULL AND next_run_at <= $1 ORDER BY next_run_at`, now)
+}
+
+func (r *savedQueryRepo) Claim(id uint, prev time.Time, next *time.Time) (bool, error) {
+	res, err := r.db.Exec(`UPDATE saved_queries SET next_run_at=$1, last_run_at=now() WHERE id=$2 AND next_run_at=$3`,
+		next, id, prev)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *savedQueryRepo) CreateRun(run *domain.ReportRun) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO report_runs (saved_query_id, trigger, status, error, delivery_error, filename,
+				content_type, output, started_at, finished_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	err = tx.QueryRow(query, run.SavedQueryID, run.Trigger, run.Status, run.Error, run.DeliveryError, run.Filename,
+		run.ContentType, run.Output, run.StartedAt, run.FinishedAt).Scan(&run.ID)
+	if err != nil {
+		return err
+	}
+	if run.Trigger == domain.RunTriggerManual {
+		if _, err := tx.Exec(`UPDATE saved_queries SET last_run_at=$1 WHERE id=$2`, run.StartedAt, run.SavedQueryID); err != nil {
+			return err
+		}
+	}
+	// buang output lama, cukup simpan runsKept run terakhir
+	_, err = tx.Exec(`DELETE FROM report_runs WHERE saved_query_id=$1 AND id NOT IN
+		(SELECT id FROM report_runs WHERE saved_query_id=$1 ORDER BY id DESC LIMIT $2)`, run.SavedQueryID, runsKept)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const reportRunColumns = `id, saved_query_id, trigger, status, error, delivery_error, filename, content_type,
+	coalesce(octet_length(output), 0), started_at, finished_at`
+
+func scanReportRun(row rowScanner, extra ...interface{}) (domain.ReportRun, error) {
+	var run domain.ReportRun
+	dest := []interface{}{&run.ID, &run.SavedQueryID, &run.Trigger, &run.Status, &run.Error, &run.DeliveryError,
+		&run.Filename, &run.ContentType, &run.Size, &run.StartedAt, &run.FinishedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return run, err
+}
+
+func (r *savedQueryRepo) ListRuns(savedQueryID uint, limit int) ([]domain.ReportRun, error) {
+	rows, err := r.db.Query(`SELECT `+reportRunColumns+` FROM report_runs
+		WHERE saved_query_id=$1 ORDER BY id DESC LIMIT $2`, savedQueryID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.ReportRun
+	for rows.Next() {
+		run, err := scanReportRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, run)
+	}
+	return list, rows.Err()
+}
+
+func (r *savedQueryRepo) GetRun(savedQueryID, runID uint) (*domain.ReportRun, error) {
+	var output []byte
+	run, err := scanReportRun(r.db.QueryRow(`SELECT `+reportRunColumns+`, output FROM report_runs
+		WHERE saved_query_id=$1 AND id=$2`, savedQueryID, runID), &output)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.Output = output
+	return &run, nil
+}
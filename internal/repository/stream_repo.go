@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type StreamRepository interface {
+	Create(s *domain.Stream) error
+	Update(s *domain.Stream) error
+	FindByName(orgID uint, name string) (*domain.Stream, error)
+	ListByOrg(orgID uint) ([]domain.Stream, error)
+	Delete(id uint) error
+}
+
+type streamRepo struct {
+	db *sql.DB
+}
+
+func NewStreamRepository(db *sql.DB) StreamRepository {
+	return &streamRepo{db: db}
+}
+
+const streamColumns = `id, user_id, org_id, name, format, window_seconds, labels, created_at, updated_at`
+
+func scanStream(row rowScanner) (domain.Stream, error) {
+	var s domain.Stream
+	var labels []byte
+	var orgID sql.NullInt64
+	if err := row.Scan(&s.ID, &s.UserID, &orgID, &s.Name, &s.Format, &s.WindowSeconds, &labels, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return s, err
+	}
+	s.OrgID = uint(orgID.Int64)
+	if err := json.Unmarshal(labels, &s.Labels); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+func labelsJSON(labels map[string]string) (string, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	b, err := json.Marshal(labels)
+	return string(b), err
+}
+
+func (r *streamRepo) Create(s *domain.Stream) error {
+	labels, err := labelsJSON(s.Labels)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO streams (user_id, org_id, name, format, window_seconds, labels)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`
+	return r.db.QueryRow(query, s.UserID, s.OrgID, s.Name, s.Format, s.WindowSeconds, labels).
+		Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+func (r *streamRepo) Update(s *domain.Stream) error {
+	labels, err := labelsJSON(s.Labels)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE streams SET format=$1, window_seconds=$2, labels=$3 WHERE id=$4 RETURNING updated_at`
+	err = r.db.QueryRow(query, s.Format, s.WindowSeconds, labels, s.ID).Scan(&s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("stream not found")
+	}
+	return err
+}
+
+func (r *streamRepo) FindByName(orgID uint, name string) (*domain.Stream, error) {
+	s, err := scanStream(r.db.QueryRow(`SELECT `+streamColumns+` FROM streams WHERE org_id=$1 AND name=$2`, orgID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *streamRepo) ListByOrg(orgID uint) ([]domain.Stream, error) {
+	rows, err := r.db.Query(`SELECT `+streamColumns+` FROM streams WHERE org_id=$1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.Stream
+	for rows.Next() {
+		s, err := scanStream(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *streamRepo) Delete(id uint) error {
+	_, err := r.db.Exec(`DELETE FROM streams WHERE id=$1`, id)
+	return err
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type TokenRepository interface {
+	CreateRefresh(t *domain.RefreshToken) error
+	GetRefreshByHash(hash string) (*domain.RefreshToken, error)
+	// UseRefresh marks the token used; false if it was already used or revoked.
+	UseRefresh(id uint) (bool, error)
+	// RevokeFamily revokes the family's refresh tokens and denylists its
+	// access tokens that have not expired.
+	RevokeFamily(familyID string) error
+	// RevokeUser does the same for every family of the user.
+	RevokeUser(userID uint) error
+	RevokeAccess(jti string, userID uint, expiresAt time.Time) error
+	// RevokedSince lists unexpired denylist entries added after since.
+	RevokedSince(since time.Time) ([]domain.RevokedToken, error)
+	DeleteExpired(now time.Time) error
+}
+
+type tokenRepo struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) TokenRepository {
+	return &tokenRepo{db: db}
+}
+
+func (r *tokenRepo) CreateRefresh(t *domain.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_jti, access_expires_at, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	return r.db.QueryRow(query, t.UserID, t.FamilyID, t.TokenHash, t.AccessJTI, t.AccessExpiresAt, t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+}
+
+func (r *tokenRepo) GetRefreshByHash(hash string) (*domain.RefreshToken, error) {
+	query := `SELECT id, user_id, family_id, token_hash, access_jti, access_expires_at, expires_at, used_at, revoked_at, created_at
+			  FROM refresh_tokens WHERE token_hash=$1`
+	var t domain.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.AccessJTI,
+		&t.AccessExpiresAt, &t.ExpiresAt, &usedAt, &revokedAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+func (r *tokenRepo) UseRefresh(id uint) (bool, error) {
+	// the WHERE makes this a compare-and-set: two concurrent refreshes with
+	// the same token cannot both succeed
+	res, err := r.db.Exec(`UPDATE refresh_tokens SET used_at=now()
+			  WHERE id=$1 AND used_at IS NULL AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *tokenRepo) RevokeFamily(familyID string) error {
+	return r.revoke(`family_id=$1`, familyID)
+}
+
+func (r *tokenRepo) RevokeUser(userID uint) error {
+	return r.revoke(`user_id=$1`, userID)
+}
+
+// revoke denylists the live access tokens of the matching refresh tokens,
+// then revokes the refresh tokens themselves
+func (r *tokenRepo) revoke(where string, arg interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO revoked_tokens (jti, user_id, expires_at)
+			  SELECT access_jti, user_id, access_expires_at FROM refresh_tokens
+			  WHERE `+where+` AND access_expires_at > now()
+			  ON CONFLICT (jti) DO NOTHING`, arg)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at=now() WHERE `+where+` AND revoked_at IS NULL`, arg)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *tokenRepo) RevokeAccess(jti string, userID uint, expiresAt time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (jti) DO NOTHING`, jti, userID, expiresAt)
+	return err
+}
+
+func (r *tokenRepo) RevokedSince(since time.Time) ([]domain.RevokedToken, error) {
+	rows, err := r.db.Query(`SELECT jti, expires_at, created_at FROM revoked_tokens
+			  WHERE created_at > $1 AND expires_at > now() ORDER BY created_at`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.RevokedToken
+	for rows.Next() {
+		var t domain.RevokedToken
+		if err := rows.Scan(&t.JTI, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (r *tokenRepo) DeleteExpired(now time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now); err != nil {
+		return err
+	}
+	// refresh tokens are kept past use for reuse detection, until they expire
+	_, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, now)
+	return err
+}
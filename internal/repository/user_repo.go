@@ -12,6 +12,11 @@ type UserRepository interface {
 	FindByEmail(email string) (*domain.User, error)
 	FindByID(id uint) (*domain.User, error)
 	Update(user *domain.User) error
+	// List returns all users ordered by id.
+	List() ([]domain.User, error)
+	// AcceptTOTPStep records step as the last used TOTP step; false if it
+	// is not later than the one recorded (the code was used already).
+	AcceptTOTPStep(id uint, step int64) (bool, error)
 }
 
 type userRepo struct {
@@ -22,46 +27,61 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
+const userColumns = `id, email, password_hash, name, role, email_verified, disabled_at, active_org_id, totp_secret, totp_pending_secret, totp_enabled, created_at, updated_at`
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	var u domain.User
+	var disabledAt sql.NullTime
+	var activeOrgID sql.NullInt64
+	err := row.Scan(
+		&u.ID, &u.Email, &u.PasswordHash, &u.Name, &u.Role, &u.EmailVerified, &disabledAt, &activeOrgID,
+		&u.TOTPSecret, &u.TOTPPending, &u.TOTPEnabled, &u.CreatedAt, &u.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		u.DisabledAt = &disabledAt.Time
+	}
+	u.ActiveOrgID = uint(activeOrgID.Int64)
+	return &u, nil
+}
+
 func (r *userRepo) Create(user *domain.User) error {
-	query := `INSERT INTO users (email, password_hash, name, totp_secret, totp_enabled) 
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err := r.db.QueryRow(query, user.Email, user.PasswordHash, user.Name, user.TOTPSecret, user.TOTPEnabled).Scan(&user.ID)
+	query := `INSERT INTO users (email, password_hash, name, role, email_verified, totp_secret, totp_enabled) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
+	err := r.db.QueryRow(query, user.Email, user.PasswordHash, user.Name, user.Role, user.EmailVerified, user.TOTPSecret, user.TOTPEnabled).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	return err
 }
 
 func (r *userRepo) FindByEmail(email string) (*domain.User, error) {
-	query := `SELECT id, email, password_hash, name, totp_secret, totp_enabled FROM users WHERE email=$1`
-	var u domain.User
-	err := r.db.QueryRow(query, email).Scan(
-		&u.ID, &u.Email, &u.PasswordHash, &u.Name, &u.TOTPSecret, &u.TOTPEnabled,
-	)
+	u, err := scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email=$1`, email))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &u, nil
+	return u, nil
 }
 
 func (r *userRepo) FindByID(id uint) (*domain.User, error) {
-	query := `SELECT id, email, password_hash, name, totp_secret, totp_enabled FROM users WHERE id=$1`
-	var u domain.User
-	err := r.db.QueryRow(query, id).Scan(
-		&u.ID, &u.Email, &u.PasswordHash, &u.Name, &u.TOTPSecret, &u.TOTPEnabled,
-	)
+	u, err := scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id=$1`, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &u, nil
+	return u, nil
 }
 
 func (r *userRepo) Update(user *domain.User) error {
-	query := `UPDATE users SET email=$1, password_hash=$2, name=$3, totp_secret=$4, totp_enabled=$5 WHERE id=$6`
-	res, err := r.db.Exec(query, user.Email, user.PasswordHash, user.Name, user.TOTPSecret, user.TOTPEnabled, user.ID)
+	query := `UPDATE users SET email=$1, password_hash=$2, name=$3, role=$4, email_verified=$5, disabled_at=$6,
+			  active_org_id=$7, totp_secret=$8, totp_pending_secret=$9, totp_enabled=$10, updated_at=now() WHERE id=$11`
+	res, err := r.db.Exec(query, user.Email, user.PasswordHash, user.Name, user.Role, user.EmailVerified, user.DisabledAt,
+		nullID(user.ActiveOrgID), user.TOTPSecret, user.TOTPPending, user.TOTPEnabled, user.ID)
 	if err != nil {
 		return err
 	}
@@ -71,3 +91,30 @@ func (r *userRepo) Update(user *domain.User) error {
 	}
 	return nil
 }
+
+func (r *userRepo) List() ([]domain.User, error) {
+	rows, err := r.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []domain.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *u)
+	}
+	return list, rows.Err()
+}
+
+func (r *userRepo) AcceptTOTPStep(id uint, step int64) (bool, error) {
+	res, err := r.db.Exec(`UPDATE users SET totp_last_step=$2 WHERE id=$1 AND totp_last_step < $2`, id, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type UserTokenRepository interface {
+	// Create stores the token and drops the user's older tokens of the
+	// same purpose, so only the newest email works.
+	Create(t *domain.UserToken) error
+	GetByHash(hash string) (*domain.UserToken, error)
+	// Use marks the token used; false if it already was.
+	Use(id uint) (bool, error)
+	DeleteExpired(now time.Time) error
+}
+
+type userTokenRepo struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) UserTokenRepository {
+	return &userTokenRepo{db: db}
+}
+
+func (r *userTokenRepo) Create(t *domain.UserToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_tokens WHERE user_id=$1 AND purpose=$2`, t.UserID, t.Purpose); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+			  VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	if err := tx.QueryRow(query, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *userTokenRepo) GetByHash(hash string) (*domain.UserToken, error) {
+	query := `SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at FROM user_tokens WHERE token_hash=$1`
+	var t domain.UserToken
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &usedAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	return &t, nil
+}
+
+func (r *userTokenRepo) Use(id uint) (bool, error) {
+	res, err := r.db.Exec(`UPDATE user_tokens SET used_at=now() WHERE id=$1 AND used_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *userTokenRepo) DeleteExpired(now time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM user_tokens WHERE expires_at < $1`, now)
+	return err
+}
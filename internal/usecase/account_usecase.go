@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+	minPasswordLen   = 6
+
+	// emails per address: 3, then one per minute doubling up to one per hour
+	mailFreeSends   = 3
+	mailBackoffBase = time.Minute
+	mailBackoffMax  = time.Hour
+	mailForgetAfter = time.Hour
+)
+
+// ErrEmailNotVerified is returned by Login when verification is required.
+var ErrEmailNotVerified = errors.New("email address is not verified, check your inbox or request a new link")
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// issueUserToken stores a new single-use token for the user and returns it.
+func (a *AuthUsecase) issueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = a.userTokens.Create(&domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, err
+}
+
+// useUserToken checks and uses up a token of the given purpose.
+func (a *AuthUsecase) useUserToken(token, purpose string) (*domain.UserToken, error) {
+	t, err := a.userTokens.GetByHash(hashToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.Purpose != purpose || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+	ok, err := a.userTokens.Use(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidUserToken
+	}
+	return t, nil
+}
+
+// sendMail delivers in the background, so a slow SMTP server neither holds
+// the request nor tells by its timing whether an address exists; too many
+// emails to one address within an hour are dropped.
+func (a *AuthUsecase) sendMail(m mailer.Message) {
+	key := "mail:" + strings.ToLower(m.To[0])
+	now := time.Now()
+	if err := a.mailsSent.check(now, key); err != nil {
+		log.Printf("[Auth] not emailing %s: %v", m.To[0], err)
+		return
+	}
+	a.mailsSent.fail(now, key)
+	go func() {
+		if err := a.mail.Send(m); err != nil {
+			log.Printf("[Auth] ❌ email %q to %s: %v", m.Subject, m.To[0], err)
+		}
+	}()
+}
+
+// ===================== EMAIL VERIFICATION =====================
+
+func (a *AuthUsecase) sendVerification(u *domain.User) error {
+	token, err := a.issueUserToken(u.ID, domain.TokenPurposeEmailVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	link := strings.TrimRight(a.cfg.AppURL, "/") + "/api/email/verify?token=" + url.QueryEscape(token)
+	a.sendMail(mailer.Message{
+		To:      []string{u.Email},
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hi %s,\n\nopen this link to verify your email address:\n\n%s\n\n"+
+			"The link works once and expires in %d hours. If you did not sign up, ignore this email.\n",
+			displayName(u), link, int(emailVerifyTTL.Hours())),
+	})
+	return nil
+}
+
+// VerifyEmail marks the address of the token's user as verified.
+func (a *AuthUsecase) VerifyEmail(token string) error {
+	t, err := a.useUserToken(token, domain.TokenPurposeEmailVerify)
+	if err != nil {
+		return err
+	}
+	u, err := a.repo.FindByID(t.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errInvalidUserToken
+	}
+	u.EmailVerified = true
+	return a.repo.Update(u)
+}
+
+// ResendVerification sends a new link; unknown or verified addresses are
+// ignored without telling the caller.
+func (a *AuthUsecase) ResendVerification(email string) error {
+	u, err := a.repo.FindByEmail(email)
+	if err != nil || u == nil || u.EmailVerified {
+		return err
+	}
+	return a.sendVerification(u)
+}
+
+// ===================== PASSWORD RESET =====================
+
+// ForgotPassword emails a reset token if the account exists. The result is
+// the same either way, so the endpoint cannot be used to probe for emails.
+func (a *AuthUsecase) ForgotPassword(email string) error {
+	u, err := a.repo.FindByEmail(email)
+	if err != nil || u == nil {
+		return err
+	}
+	token, err := a.issueUserToken(u.ID, domain.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	how := "Reset token: " + token + "\n\nSend it with your new password to POST /api/password/reset " +
+		`({"token": "...", "password": "..."}).`
+	if a.cfg.PasswordResetURL != "" {
+		how = "Open this link to choose a new password:\n\n" + a.cfg.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	}
+	a.sendMail(mailer.Message{
+		To:      []string{u.Email},
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account.\n\n%s\n\n"+
+			"This works once and expires in %d minutes. If it was not you, ignore this email; your password stays the same.\n",
+			displayName(u), how, int(passwordResetTTL.Minutes())),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and logs the user
+// out everywhere.
+func (a *AuthUsecase) ResetPassword(token, password, ip string) error {
+	if len(password) < minPasswordLen {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	t, err := a.useUserToken(token, domain.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	u, err := a.repo.FindByID(t.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errInvalidUserToken
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	u.EmailVerified = true // the reset link proved the mailbox
+	if err := a.repo.Update(u); err != nil {
+		return err
+	}
+	if err := a.tokens.RevokeUser(u.ID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	if err := a.loginAttempts.Reset(accountKey(u.Email)); err != nil {
+		log.Printf("[Auth] ❌ failed to clear login failures of userID %d: %v", u.ID, err)
+	}
+	a.record(&domain.AuditEntry{ActorID: u.ID, Action: domain.AuditPasswordReset, TargetUserID: u.ID, IP: ip})
+	return nil
+}
+
+func displayName(u *domain.User) string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Email
+}
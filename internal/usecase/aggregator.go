@@ -0,0 +1,289 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const (
+	topEndpointsLimit = 10
+	maxTimeBuckets    = 120
+
+	// latency histogram: bucket 0 is < 1ms, bucket i ends at 1.05^i ms
+	latencyGrowth     = 1.05
+	maxLatencyBuckets = 400 // up to ~3.3 days, longer latencies share the last bucket
+)
+
+// bucket sizes tried (smallest first) so the time series stays under maxTimeBuckets
+var bucketSteps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour,
+	6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+type counter struct {
+	requests   int
+	errors     int
+	latencySum float64
+}
+
+func (c *counter) add(rec domain.LogRecord) {
+	c.requests++
+	if rec.IsError() {
+		c.errors++
+	}
+	c.latencySum += rec.Latency
+}
+
+func (c *counter) average() float64 {
+	if c.requests == 0 {
+		return 0
+	}
+	return c.latencySum / float64(c.requests)
+}
+
+// analysisAggregator accumulates parsed records into a LogAnalysis.
+// It is not safe for concurrent use; callers hold their own lock.
+type analysisAggregator struct {
+	total      counter
+	ips        map[string]struct{}
+	seededIPs  int // unique IPs of a seeded result; the addresses themselves are not stored
+	statuses   map[int]int
+	endpoints  map[[2]string]*counter
+	minutes    map[int64]*counter // key: unix minute
+	latencies  []int              // histogram, see latencyBucket
+	maxLatency float64
+}
+
+func newAnalysisAggregator() *analysisAggregator {
+	return &analysisAggregator{
+		ips:       make(map[string]struct{}),
+		statuses:  make(map[int]int),
+		endpoints: make(map[[2]string]*counter),
+		minutes:   make(map[int64]*counter),
+	}
+}
+
+func (g *analysisAggregator) Add(rec domain.LogRecord) {
+	g.total.add(rec)
+	if rec.IP != "" {
+		g.ips[rec.IP] = struct{}{}
+	}
+	if rec.Status > 0 {
+		g.statuses[rec.Status]++
+	}
+	if rec.Path != "" {
+		key := [2]string{rec.Method, rec.Path}
+		c := g.endpoints[key]
+		if c == nil {
+			c = &counter{}
+			g.endpoints[key] = c
+		}
+		c.add(rec)
+	}
+	if !rec.Time.IsZero() {
+		minute := rec.Time.Unix() / 60
+		c := g.minutes[minute]
+		if c == nil {
+			c = &counter{}
+			g.minutes[minute] = c
+		}
+		c.add(rec)
+	}
+	if rec.Latency > 0 {
+		i := latencyBucket(rec.Latency)
+		for len(g.latencies) <= i {
+			g.latencies = append(g.latencies, 0)
+		}
+		g.latencies[i]++
+		g.maxLatency = math.Max(g.maxLatency, rec.Latency)
+	}
+}
+
+func latencyBucket(ms float64) int {
+	if ms < 1 {
+		return 0
+	}
+	i := 1 + int(math.Log(ms)/math.Log(latencyGrowth))
+	if i >= maxLatencyBuckets {
+		i = maxLatencyBuckets - 1
+	}
+	return i
+}
+
+// seed loads a previously saved result so a stream window can keep
+// accumulating after a restart. Endpoints outside the saved top list and the
+// IP set are lost, so those numbers become approximate.
+func (g *analysisAggregator) seed(a *domain.LogAnalysis) {
+	g.total = counter{
+		requests:   a.TotalRequests,
+		errors:     a.ErrorCount,
+		latencySum: a.AverageResponse * float64(a.TotalRequests),
+	}
+	g.seededIPs = a.UniqueIPs
+	if a.Details == nil {
+		return
+	}
+	for code, n := range a.Details.StatusCounts {
+		if status, err := strconv.Atoi(code); err == nil {
+			g.statuses[status] += n
+		}
+	}
+	for _, e := range a.Details.TopEndpoints {
+		g.endpoints[[2]string{e.Method, e.Path}] = &counter{
+			requests:   e.Requests,
+			errors:     e.Errors,
+			latencySum: e.AverageResponse * float64(e.Requests),
+		}
+	}
+	for _, b := range a.Details.TimeSeries {
+		g.minutes[b.Start.Unix()/60] = &counter{
+			requests:   b.Requests,
+			errors:     b.Errors,
+			latencySum: b.AverageResponse * float64(b.Requests),
+		}
+	}
+	if l := a.Details.Latency; l != nil {
+		g.latencies = append([]int(nil), l.Histogram...)
+		g.maxLatency = l.Max
+	}
+}
+
+// Result builds the summary plus details. Filename and owner are left to the caller.
+func (g *analysisAggregator) Result() *domain.LogAnalysis {
+	return &domain.LogAnalysis{
+		TotalRequests:   g.total.requests,
+		ErrorCount:      g.total.errors,
+		UniqueIPs:       len(g.ips) + g.seededIPs,
+		AverageResponse: g.total.average(),
+		Details: &domain.AnalysisDetails{
+			StatusCounts: g.statusCounts(),
+			TopEndpoints: g.topEndpoints(),
+			TimeSeries:   g.timeSeries(),
+			Latency:      g.latencyStats(),
+		},
+	}
+}
+
+func (g *analysisAggregator) latencyStats() *domain.LatencyStats {
+	if histogramTotal(g.latencies) == 0 {
+		return nil
+	}
+	return &domain.LatencyStats{
+		P50:       histogramPercentile(g.latencies, g.maxLatency, 0.50),
+		P90:       histogramPercentile(g.latencies, g.maxLatency, 0.90),
+		P95:       histogramPercentile(g.latencies, g.maxLatency, 0.95),
+		P99:       histogramPercentile(g.latencies, g.maxLatency, 0.99),
+		Max:       g.maxLatency,
+		Histogram: append([]int(nil), g.latencies...),
+	}
+}
+
+func histogramTotal(hist []int) int {
+	total := 0
+	for _, n := range hist {
+		total += n
+	}
+	return total
+}
+
+// histogramPercentile reads a percentile off a latency histogram: the upper
+// end of its bucket, so within 5% above the exact value.
+func histogramPercentile(hist []int, max, p float64) float64 {
+	rank := int(math.Ceil(p * float64(histogramTotal(hist))))
+	seen := 0
+	for i, n := range hist {
+		seen += n
+		if seen >= rank {
+			return math.Min(math.Pow(latencyGrowth, float64(i)), max)
+		}
+	}
+	return max
+}
+
+func (g *analysisAggregator) statusCounts() map[string]int {
+	out := make(map[string]int, len(g.statuses))
+	for status, n := range g.statuses {
+		out[strconv.Itoa(status)] = n
+	}
+	return out
+}
+
+func (g *analysisAggregator) topEndpoints() []domain.EndpointStat {
+	list := make([]domain.EndpointStat, 0, len(g.endpoints))
+	for key, c := range g.endpoints {
+		list = append(list, domain.EndpointStat{
+			Method:          key[0],
+			Path:            key[1],
+			Requests:        c.requests,
+			Errors:          c.errors,
+			AverageResponse: c.average(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	if len(list) > topEndpointsLimit {
+		list = list[:topEndpointsLimit]
+	}
+	return list
+}
+
+func (g *analysisAggregator) timeSeries() []domain.TimeBucket {
+	if len(g.minutes) == 0 {
+		return []domain.TimeBucket{}
+	}
+
+	var first, last int64
+	for m := range g.minutes {
+		if first == 0 || m < first {
+			first = m
+		}
+		if m > last {
+			last = m
+		}
+	}
+
+	step := bucketSteps[len(bucketSteps)-1]
+	for _, s := range bucketSteps {
+		if (last-first)/int64(s/time.Minute) < maxTimeBuckets {
+			step = s
+			break
+		}
+	}
+	stepMinutes := int64(step / time.Minute)
+
+	buckets := make(map[int64]*counter)
+	for m, c := range g.minutes {
+		start := m - m%stepMinutes
+		b := buckets[start]
+		if b == nil {
+			b = &counter{}
+			buckets[start] = b
+		}
+		b.requests += c.requests
+		b.errors += c.errors
+		b.latencySum += c.latencySum
+	}
+
+	series := make([]domain.TimeBucket, 0, len(buckets))
+	for start, b := range buckets {
+		series = append(series, domain.TimeBucket{
+			Start:           time.Unix(start*60, 0).UTC(),
+			Requests:        b.requests,
+			Errors:          b.errors,
+			AverageResponse: b.average(),
+		})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start.Before(series[j].Start) })
+	return series
+}
@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const maxAlertFor = 24 * time.Hour
+
+// alertCondition is a parsed rule expression:
+//
+//	error_rate > 5% for 10m
+//	p99 > 2s
+//	change(requests) < -50% for 15m
+type alertCondition struct {
+	Metric    string
+	Change    bool // compare with the previous analysis of the series, as a fraction
+	Op        string
+	Threshold float64 // fraction for error_rate and change(), ms for latencies
+	For       time.Duration
+}
+
+var alertExprPattern = regexp.MustCompile(`(?i)^\s*(?:change\(\s*([a-z0-9_]+)\s*\)|([a-z0-9_]+))\s*(>=|<=|>|<)\s*(-?[0-9]+(?:\.[0-9]+)?)\s*(%|ms|s|m|h)?\s*(?:\s+for\s+([0-9a-z.]+))?\s*$`)
+
+// alertMetrics: metric name -> value unit ("" count, "ratio", "ms")
+var alertMetrics = map[string]string{
+	"requests":    "",
+	"errors":      "",
+	"unique_ips":  "",
+	"error_rate":  "ratio",
+	"avg_latency": "ms",
+	"p50":         "ms",
+	"p90":         "ms",
+	"p95":         "ms",
+	"p99":         "ms",
+	"max_latency": "ms",
+}
+
+// parseAlertExpr validates a rule expression; see alertCondition.
+func parseAlertExpr(s string) (*alertCondition, error) {
+	m := alertExprPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid expression %q, expected e.g. \"error_rate > 5%% for 10m\" or \"change(requests) < -50%%\"", s)
+	}
+	c := &alertCondition{Metric: strings.ToLower(m[2]), Op: m[3]}
+	if m[1] != "" {
+		c.Metric, c.Change = strings.ToLower(m[1]), true
+	}
+	unit, ok := alertMetrics[c.Metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q (use requests, errors, unique_ips, error_rate, avg_latency, p50, p90, p95, p99 or max_latency)", c.Metric)
+	}
+	v, err := strconv.ParseFloat(m[4], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := strings.ToLower(m[5])
+	switch {
+	case suffix == "%":
+		if !c.Change && unit != "ratio" {
+			return nil, fmt.Errorf("%% only applies to error_rate and change()")
+		}
+		v /= 100
+	case suffix != "":
+		if c.Change || unit != "ms" {
+			return nil, fmt.Errorf("durations only apply to latency metrics")
+		}
+		d, err := time.ParseDuration(m[4] + suffix)
+		if err != nil {
+			return nil, err
+		}
+		v = float64(d) / float64(time.Millisecond)
+	}
+	c.Threshold = v
+
+	if m[6] != "" {
+		d, err := time.ParseDuration(strings.ToLower(m[6]))
+		if err != nil || d < 0 || d > maxAlertFor {
+			return nil, fmt.Errorf("invalid for duration %q (e.g. 10m, max %s)", m[6], maxAlertFor)
+		}
+		c.For = d
+	}
+	return c, nil
+}
+
+// value reads the metric off an analysis; false when the analysis has no
+// such value (no latencies recorded).
+func (c *alertCondition) value(a *domain.LogAnalysis) (float64, bool) {
+	switch c.Metric {
+	case "requests":
+		return float64(a.TotalRequests), true
+	case "errors":
+		return float64(a.ErrorCount), true
+	case "unique_ips":
+		return float64(a.UniqueIPs), true
+	case "error_rate":
+		return a.ErrorRate(), true
+	case "avg_latency":
+		return a.AverageResponse, true
+	}
+	if a.Details == nil || a.Details.Latency == nil {
+		return 0, false
+	}
+	l := a.Details.Latency
+	switch c.Metric {
+	case "p50":
+		return l.P50, true
+	case "p90":
+		return l.P90, true
+	case "p95":
+		return l.P95, true
+	case "p99":
+		return l.P99, true
+	default:
+		return l.Max, true
+	}
+}
+
+// breached compares the value, or its change from prev, with the threshold.
+// change() without a non-zero baseline never breaches.
+func (c *alertCondition) breached(value float64, prev *float64) bool {
+	if c.Change {
+		if prev == nil || *prev == 0 {
+			return false
+		}
+		value = (value - *prev) / *prev
+	}
+	switch c.Op {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	default:
+		return value <= c.Threshold
+	}
+}
+
+// format renders a value in the metric's unit, e.g. "7.3%" or "2.15s".
+func (c *alertCondition) format(value float64) string {
+	switch alertMetrics[c.Metric] {
+	case "ratio":
+		return strconv.FormatFloat(value*100, 'f', 1, 64) + "%"
+	case "ms":
+		if value >= 1000 {
+			return strconv.FormatFloat(value/1000, 'f', 2, 64) + "s"
+		}
+		return strconv.FormatFloat(value, 'f', 1, 64) + "ms"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
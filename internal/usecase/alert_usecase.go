@@ -0,0 +1,471 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/notify"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+)
+
+const (
+	alertEvalInterval   = 10 * time.Second
+	alertSweepInterval  = time.Minute
+	alertStaleAfter     = time.Hour // pending/firing alerts without new data resolve after this
+	alertRepeatInterval = 4 * time.Hour
+	alertNotifyTimeout  = 30 * time.Second
+	maxAlertRuleName    = 100
+	maxSilenceDuration  = 30 * 24 * time.Hour
+)
+
+// AlertUsecase evaluates alert rules on completed uploads and live stream
+// windows. Observe only queues a snapshot; a worker evaluates the latest
+// snapshot of each analysis every 10 seconds, so a busy window is not
+// evaluated on every flush.
+type AlertUsecase struct {
+	repo     repository.AlertRepository
+	notifier *notify.Dispatcher
+
+	mu      sync.Mutex
+	pending map[uint]domain.LogAnalysis
+}
+
+func NewAlertUsecase(repo repository.AlertRepository, notifier *notify.Dispatcher) *AlertUsecase {
+	return &AlertUsecase{repo: repo, notifier: notifier, pending: make(map[uint]domain.LogAnalysis)}
+}
+
+// Observe queues a saved analysis (its ID must be set) for evaluation.
+func (u *AlertUsecase) Observe(a *domain.LogAnalysis) {
+	if u == nil || a.ID == 0 {
+		return
+	}
+	u.mu.Lock()
+	u.pending[a.ID] = *a
+	u.mu.Unlock()
+}
+
+// PendingEvaluations is the number of analyses waiting to be evaluated.
+func (u *AlertUsecase) PendingEvaluations() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.pending)
+}
+
+// Start runs the evaluation worker and the sweep that resolves alerts
+// whose series stopped sending data.
+func (u *AlertUsecase) Start() {
+	go func() {
+		eval := time.NewTicker(alertEvalInterval)
+		sweep := time.NewTicker(alertSweepInterval)
+		defer eval.Stop()
+		defer sweep.Stop()
+		for {
+			select {
+			case <-eval.C:
+				u.evaluatePending(time.Now())
+			case <-sweep.C:
+				u.sweep(time.Now())
+			}
+		}
+	}()
+}
+
+// ===================== RULES =====================
+
+func (u *AlertUsecase) CreateRule(rule *domain.AlertRule) error {
+	if err := u.validateRule(rule); err != nil {
+		return err
+	}
+	return u.repo.CreateRule(rule)
+}
+
+// UpdateRule replaces a rule; its alerts start over.
+func (u *AlertUsecase) UpdateRule(rule *domain.AlertRule) error {
+	if _, err := u.GetRule(rule.OrgID, rule.ID); err != nil {
+		return err
+	}
+	if err := u.validateRule(rule); err != nil {
+		return err
+	}
+	return u.repo.UpdateRule(rule)
+}
+
+// GetRule returns a rule of the org.
+func (u *AlertUsecase) GetRule(orgID, id uint) (*domain.AlertRule, error) {
+	rule, err := u.repo.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil || rule.OrgID != orgID {
+		return nil, errors.New("record not found")
+	}
+	return rule, nil
+}
+
+func (u *AlertUsecase) ListRules(orgID uint) ([]domain.AlertRule, error) {
+	return u.repo.ListRules(orgID, false)
+}
+
+func (u *AlertUsecase) DeleteRule(orgID, id uint) error {
+	if _, err := u.GetRule(orgID, id); err != nil {
+		return err
+	}
+	return u.repo.DeleteRule(id)
+}
+
+// TestRule sends a test message to every notify target of the rule and
+// returns the error of each target that failed, keyed "type target".
+func (u *AlertUsecase) TestRule(orgID, id uint) (map[string]string, error) {
+	rule, err := u.GetRule(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rule.Notify) == 0 {
+		return nil, errors.New("rule has no notify targets")
+	}
+	m := notify.Message{
+		Subject: "[TEST] " + rule.Name,
+		Text:    fmt.Sprintf("Test notification for alert rule %q (%s). No action needed.\n", rule.Name, rule.Expr),
+		Fields:  map[string]interface{}{"status": "test", "rule_id": rule.ID, "rule": rule.Name, "expr": rule.Expr},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+	defer cancel()
+	failed := make(map[string]string)
+	for _, t := range rule.Notify {
+		if err := u.notifier.Send(ctx, notify.Target(t), m); err != nil {
+			failed[t.Type+" "+t.Target] = err.Error()
+		}
+	}
+	return failed, nil
+}
+
+func (u *AlertUsecase) validateRule(rule *domain.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" || len(rule.Name) > maxAlertRuleName {
+		return fmt.Errorf("name is required (max %d characters)", maxAlertRuleName)
+	}
+	if _, err := parseAlertExpr(rule.Expr); err != nil {
+		return err
+	}
+	rule.Expr = strings.TrimSpace(rule.Expr)
+	if rule.MinRequests < 0 {
+		return errors.New("min_requests cannot be negative")
+	}
+	if err := ValidateLabels(rule.Labels); err != nil {
+		return err
+	}
+	if len(rule.Notify) > maxNotifyTargets {
+		return fmt.Errorf("too many notify targets (max %d)", maxNotifyTargets)
+	}
+	for _, t := range rule.Notify {
+		if err := u.notifier.Validate(notify.Target(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===================== ALERTS & SILENCES =====================
+
+// Alerts lists the org's alerts, optionally only in the given states.
+// Silenced is set for alerts an active silence covers.
+func (u *AlertUsecase) Alerts(orgID uint, states []string) ([]domain.Alert, error) {
+	for _, s := range states {
+		switch s {
+		case domain.AlertStatePending, domain.AlertStateFiring, domain.AlertStateResolved:
+		default:
+			return nil, fmt.Errorf("invalid state %q (use pending, firing or resolved)", s)
+		}
+	}
+	alerts, err := u.repo.ListAlerts(orgID, states)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	silences, err := u.repo.ListSilences(orgID, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range alerts {
+		alerts[i].Silenced = silenced(silences, &alerts[i], now)
+	}
+	return alerts, nil
+}
+
+func (u *AlertUsecase) CreateSilence(s *domain.AlertSilence) error {
+	if s.StartsAt.IsZero() {
+		s.StartsAt = time.Now()
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if s.EndsAt.Sub(s.StartsAt) > maxSilenceDuration {
+		return fmt.Errorf("silence cannot be longer than %d days", int(maxSilenceDuration.Hours()/24))
+	}
+	if s.RuleID == 0 && len(s.Labels) == 0 {
+		return errors.New("silence needs a rule_id or labels to match")
+	}
+	if s.RuleID != 0 {
+		if _, err := u.GetRule(s.OrgID, s.RuleID); err != nil {
+			return err
+		}
+	}
+	if err := ValidateLabels(s.Labels); err != nil {
+		return err
+	}
+	return u.repo.CreateSilence(s)
+}
+
+// ListSilences returns the silences that have not ended.
+func (u *AlertUsecase) ListSilences(orgID uint) ([]domain.AlertSilence, error) {
+	return u.repo.ListSilences(orgID, time.Now())
+}
+
+func (u *AlertUsecase) DeleteSilence(orgID, id uint) error {
+	s, err := u.repo.GetSilence(id)
+	if err != nil {
+		return err
+	}
+	if s == nil || s.OrgID != orgID {
+		return errors.New("record not found")
+	}
+	return u.repo.DeleteSilence(id)
+}
+
+func silenced(silences []domain.AlertSilence, a *domain.Alert, now time.Time) bool {
+	for i := range silences {
+		if silences[i].Matches(a.RuleID, a.Labels, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// ===================== EVALUATION =====================
+
+func (u *AlertUsecase) evaluatePending(now time.Time) {
+	u.mu.Lock()
+	batch := u.pending
+	u.pending = make(map[uint]domain.LogAnalysis)
+	u.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	// windows of one series in analysis order, so change() sees them in turn
+	list := make([]domain.LogAnalysis, 0, len(batch))
+	for _, a := range batch {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	rules := make(map[uint][]domain.AlertRule)
+	silences := make(map[uint][]domain.AlertSilence)
+	for i := range list {
+		a := &list[i]
+		if _, ok := rules[a.OrgID]; !ok {
+			r, err := u.repo.ListRules(a.OrgID, true)
+			if err != nil {
+				log.Printf("[Alerts] ❌ loading rules of org %d: %v", a.OrgID, err)
+				continue
+			}
+			s, err := u.repo.ListSilences(a.OrgID, now)
+			if err != nil {
+				log.Printf("[Alerts] ❌ loading silences of org %d: %v", a.OrgID, err)
+				continue
+			}
+			rules[a.OrgID], silences[a.OrgID] = r, s
+		}
+		for j := range rules[a.OrgID] {
+			rule := &rules[a.OrgID][j]
+			if !labelsMatch(rule.Labels, a.Labels) {
+				continue
+			}
+			if err := u.evaluate(rule, a, silences[a.OrgID], now); err != nil {
+				log.Printf("[Alerts] ❌ rule %q on analysis %d: %v", rule.Name, a.ID, err)
+			}
+		}
+	}
+}
+
+// labelsMatch reports whether labels include every selector pair
+func labelsMatch(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *AlertUsecase) evaluate(rule *domain.AlertRule, a *domain.LogAnalysis, silences []domain.AlertSilence, now time.Time) error {
+	cond, err := parseAlertExpr(rule.Expr)
+	if err != nil {
+		return err
+	}
+	value, ok := cond.value(a)
+	if !ok {
+		return nil
+	}
+
+	key := labelsKey(a.Labels)
+	al, err := u.repo.GetAlert(rule.ID, key)
+	if err != nil {
+		return err
+	}
+	if al == nil {
+		al = &domain.Alert{RuleID: rule.ID, RuleName: rule.Name, SeriesKey: key, State: domain.AlertStateOK}
+	}
+	// the previous analysis of the series is the baseline for change()
+	if al.AnalysisID != 0 && al.AnalysisID != a.ID {
+		prev := al.Value
+		al.PrevValue = &prev
+	}
+	al.Labels, al.AnalysisID, al.Value, al.LastEvalAt = a.Labels, a.ID, value, now
+
+	breach := a.TotalRequests >= rule.MinRequests && cond.breached(value, al.PrevValue)
+	stepAlert(al, breach, cond.For, now)
+	u.deliver(rule, al, cond, silenced(silences, al, now), now)
+	return u.repo.SaveAlert(al)
+}
+
+// stepAlert moves the alert through ok -> pending -> firing -> resolved.
+// A breach must last For before the alert fires.
+func stepAlert(al *domain.Alert, breach bool, hold time.Duration, now time.Time) {
+	if breach {
+		if al.State != domain.AlertStatePending && al.State != domain.AlertStateFiring {
+			al.State, al.ActiveSince = domain.AlertStatePending, &now
+			al.FiredAt, al.ResolvedAt = nil, nil
+		}
+		if al.State == domain.AlertStatePending && now.Sub(*al.ActiveSince) >= hold {
+			al.State, al.FiredAt = domain.AlertStateFiring, &now
+		}
+		return
+	}
+	switch al.State {
+	case domain.AlertStateFiring:
+		al.State, al.ResolvedAt = domain.AlertStateResolved, &now
+	case domain.AlertStatePending:
+		al.State, al.ActiveSince = domain.AlertStateOK, nil
+	}
+}
+
+// deliver notifies on firing (again every alertRepeatInterval while it
+// keeps firing) and once on resolve. Silenced alerts are not sent, but a
+// firing alert is sent when its silence ends.
+func (u *AlertUsecase) deliver(rule *domain.AlertRule, al *domain.Alert, cond *alertCondition, muted bool, now time.Time) {
+	switch {
+	case al.State == domain.AlertStateFiring:
+		repeat := al.NotifiedState == domain.AlertStateFiring && al.LastNotifiedAt != nil &&
+			now.Sub(*al.LastNotifiedAt) < alertRepeatInterval
+		if muted || repeat {
+			return
+		}
+	case al.State == domain.AlertStateResolved && al.NotifiedState == domain.AlertStateFiring:
+		if muted {
+			al.NotifiedState = domain.AlertStateResolved
+			return
+		}
+	default:
+		return
+	}
+
+	al.NotifiedState, al.LastNotifiedAt = al.State, &now
+	if len(rule.Notify) == 0 {
+		return
+	}
+	m := alertMessage(rule, al, cond)
+	ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+	defer cancel()
+	for _, t := range rule.Notify {
+		if err := u.notifier.Send(ctx, notify.Target(t), m); err != nil {
+			log.Printf("[Alerts] ❌ notify %s for %q: %v", t.Type, rule.Name, err)
+		}
+	}
+	log.Printf("[Alerts] %s %q %s", strings.ToUpper(al.State), rule.Name, labelString(al.Labels))
+}
+
+// sweep resolves alerts whose series has had no new analysis for
+// alertStaleAfter, e.g. a stream that stopped sending.
+func (u *AlertUsecase) sweep(now time.Time) {
+	stale, err := u.repo.Stale(now.Add(-alertStaleAfter))
+	if err != nil {
+		log.Printf("[Alerts] ❌ listing stale alerts: %v", err)
+		return
+	}
+	for i := range stale {
+		al := &stale[i]
+		rule, err := u.repo.GetRule(al.RuleID)
+		if err != nil || rule == nil {
+			continue
+		}
+		cond, err := parseAlertExpr(rule.Expr)
+		if err != nil {
+			continue
+		}
+		silences, err := u.repo.ListSilences(rule.OrgID, now)
+		if err != nil {
+			continue
+		}
+		stepAlert(al, false, 0, now)
+		al.LastEvalAt = now
+		u.deliver(rule, al, cond, silenced(silences, al, now), now)
+		if err := u.repo.SaveAlert(al); err != nil {
+			log.Printf("[Alerts] ❌ resolving stale alert %d: %v", al.ID, err)
+		}
+	}
+}
+
+func alertMessage(rule *domain.AlertRule, al *domain.Alert, cond *alertCondition) notify.Message {
+	labels := labelString(al.Labels)
+	m := notify.Message{
+		Subject: fmt.Sprintf("[%s] %s %s", strings.ToUpper(al.State), rule.Name, labels),
+		Fields: map[string]interface{}{
+			"status":       al.State,
+			"rule_id":      rule.ID,
+			"rule":         rule.Name,
+			"expr":         rule.Expr,
+			"labels":       al.Labels,
+			"value":        al.Value,
+			"analysis_id":  al.AnalysisID,
+			"active_since": al.ActiveSince,
+			"fired_at":     al.FiredAt,
+			"resolved_at":  al.ResolvedAt,
+		},
+	}
+
+	var b strings.Builder
+	value := cond.format(al.Value)
+	if cond.Change && al.PrevValue != nil {
+		value += " (was " + cond.format(*al.PrevValue) + ")"
+	}
+	if al.State == domain.AlertStateFiring {
+		fmt.Fprintf(&b, "%s is %s, rule: %s\n", cond.Metric, value, rule.Expr)
+	} else {
+		fmt.Fprintf(&b, "Resolved, %s is now %s, rule: %s\n", cond.Metric, value, rule.Expr)
+	}
+	fmt.Fprintf(&b, "Labels: %s\n", labels)
+	fmt.Fprintf(&b, "Analysis: #%d\n", al.AnalysisID)
+	if al.ActiveSince != nil {
+		fmt.Fprintf(&b, "Active since: %s\n", al.ActiveSince.UTC().Format(time.RFC3339))
+	}
+	m.Text = b.String()
+	return m
+}
+
+// labelString -> "{env=prod, service=checkout}"
+func labelString(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
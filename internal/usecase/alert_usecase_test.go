@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/notify"
+)
+
+// recorder is a notifier that keeps what it was sent.
+type recorder struct {
+	sent []notify.Message
+}
+
+func (r *recorder) Validate(string) error { return nil }
+
+func (r *recorder) Notify(_ context.Context, _ string, m notify.Message) error {
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func TestStepAlert(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	hold := 10 * time.Minute
+	al := &domain.Alert{State: domain.AlertStateOK}
+
+	steps := []struct {
+		at     time.Duration
+		breach bool
+		want   string
+	}{
+		{0, true, domain.AlertStatePending},
+		{5 * time.Minute, true, domain.AlertStatePending},
+		{10 * time.Minute, true, domain.AlertStateFiring},
+		{15 * time.Minute, true, domain.AlertStateFiring},
+		{20 * time.Minute, false, domain.AlertStateResolved},
+		{25 * time.Minute, false, domain.AlertStateResolved},
+	}
+	for _, s := range steps {
+		stepAlert(al, s.breach, hold, t0.Add(s.at))
+		if al.State != s.want {
+			t.Fatalf("at +%s breach=%v: state %q, want %q", s.at, s.breach, al.State, s.want)
+		}
+	}
+	if !al.ActiveSince.Equal(t0) || !al.FiredAt.Equal(t0.Add(10*time.Minute)) || !al.ResolvedAt.Equal(t0.Add(20*time.Minute)) {
+		t.Errorf("times: active %v fired %v resolved %v", al.ActiveSince, al.FiredAt, al.ResolvedAt)
+	}
+
+	// a new breach starts over as pending
+	stepAlert(al, true, hold, t0.Add(time.Hour))
+	if al.State != domain.AlertStatePending || al.FiredAt != nil || al.ResolvedAt != nil || !al.ActiveSince.Equal(t0.Add(time.Hour)) {
+		t.Errorf("after resolve: %+v", al)
+	}
+	// and goes back to ok without firing when it clears early
+	stepAlert(al, false, hold, t0.Add(time.Hour+time.Minute))
+	if al.State != domain.AlertStateOK || al.ActiveSince != nil {
+		t.Errorf("pending cleared: %+v", al)
+	}
+
+	// without a hold a breach fires at once
+	stepAlert(al, true, 0, t0.Add(2*time.Hour))
+	if al.State != domain.AlertStateFiring {
+		t.Errorf("hold 0: state %q", al.State)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	rec := &recorder{}
+	d := notify.NewDispatcher()
+	d.Register("test", rec)
+	u := NewAlertUsecase(nil, d)
+
+	rule := &domain.AlertRule{ID: 3, Name: "errors", Expr: "error_rate > 5%", Notify: []domain.NotifyTarget{{Type: "test", Target: "x"}}}
+	cond, err := parseAlertExpr(rule.Expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	al := &domain.Alert{RuleID: rule.ID, Labels: map[string]string{"service": "checkout"}, State: domain.AlertStateFiring, Value: 0.12}
+
+	expect := func(step string, n int, prefix string) {
+		t.Helper()
+		if len(rec.sent) != n {
+			t.Fatalf("%s: %d notifications, want %d", step, len(rec.sent), n)
+		}
+		if prefix != "" && !strings.HasPrefix(rec.sent[n-1].Subject, prefix) {
+			t.Fatalf("%s: subject %q, want prefix %q", step, rec.sent[n-1].Subject, prefix)
+		}
+	}
+
+	u.deliver(rule, al, cond, false, t0)
+	expect("first firing", 1, "[FIRING] errors {service=checkout}")
+
+	u.deliver(rule, al, cond, false, t0.Add(time.Hour))
+	expect("still firing within the repeat interval", 1, "")
+
+	u.deliver(rule, al, cond, false, t0.Add(alertRepeatInterval))
+	expect("repeat interval passed", 2, "[FIRING]")
+
+	al.State = domain.AlertStateResolved
+	u.deliver(rule, al, cond, false, t0.Add(5*time.Hour))
+	expect("resolved", 3, "[RESOLVED]")
+
+	u.deliver(rule, al, cond, false, t0.Add(6*time.Hour))
+	expect("resolved once only", 3, "")
+}
+
+func TestDeliverSilenced(t *testing.T) {
+	rec := &recorder{}
+	d := notify.NewDispatcher()
+	d.Register("test", rec)
+	u := NewAlertUsecase(nil, d)
+
+	rule := &domain.AlertRule{ID: 3, Name: "errors", Expr: "error_rate > 5%", Notify: []domain.NotifyTarget{{Type: "test", Target: "x"}}}
+	cond, _ := parseAlertExpr(rule.Expr)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	al := &domain.Alert{RuleID: rule.ID, Labels: map[string]string{"service": "checkout", "env": "prod"}, State: domain.AlertStateFiring}
+	silences := []domain.AlertSilence{{Labels: map[string]string{"service": "checkout"}, StartsAt: t0, EndsAt: t0.Add(2 * time.Hour)}}
+
+	u.deliver(rule, al, cond, silenced(silences, al, t0), t0)
+	if len(rec.sent) != 0 || al.NotifiedState != "" {
+		t.Fatalf("silenced firing was sent: %d, notified %q", len(rec.sent), al.NotifiedState)
+	}
+
+	// sent once the silence ends
+	end := t0.Add(2 * time.Hour)
+	u.deliver(rule, al, cond, silenced(silences, al, end), end)
+	if len(rec.sent) != 1 {
+		t.Fatalf("firing after the silence: %d notifications", len(rec.sent))
+	}
+
+	// a resolve during a new silence is recorded but not sent
+	silences[0].StartsAt, silences[0].EndsAt = end, end.Add(time.Hour)
+	al.State = domain.AlertStateResolved
+	at := end.Add(30 * time.Minute)
+	u.deliver(rule, al, cond, silenced(silences, al, at), at)
+	if len(rec.sent) != 1 || al.NotifiedState != domain.AlertStateResolved {
+		t.Errorf("silenced resolve: %d notifications, notified %q", len(rec.sent), al.NotifiedState)
+	}
+
+	// silences of another rule or other labels do not mute
+	other := []domain.AlertSilence{
+		{RuleID: 4, StartsAt: t0, EndsAt: end},
+		{Labels: map[string]string{"env": "staging"}, StartsAt: t0, EndsAt: end},
+	}
+	if silenced(other, al, t0) {
+		t.Error("unrelated silence matched")
+	}
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/metrics"
+)
+
+// otherService collects the services beyond the cardinality limit
+const otherService = "_other"
+
+// AnalysisMetrics exports per-service gauges (request rate, error rate,
+// p95 latency) over the analyses saved in the last window. A stream window
+// is counted once with its latest flush. Only the busiest maxServices
+// services get their own label value, so a noisy label cannot blow up the
+// number of series.
+type AnalysisMetrics struct {
+	label       string
+	window      time.Duration
+	maxServices int
+
+	mu      sync.Mutex
+	entries map[uint]serviceSample // by analysis ID
+}
+
+type serviceSample struct {
+	service   string
+	requests  int
+	errors    int
+	latencies []int
+	maxLat    float64
+	seen      time.Time
+}
+
+func NewAnalysisMetrics(label string, window time.Duration, maxServices int) *AnalysisMetrics {
+	return &AnalysisMetrics{label: label, window: window, maxServices: maxServices, entries: make(map[uint]serviceSample)}
+}
+
+// Observe records the analysis if it has the service label.
+func (m *AnalysisMetrics) Observe(a *domain.LogAnalysis) {
+	service := a.Labels[m.label]
+	if service == "" || a.ID == 0 {
+		return
+	}
+	s := serviceSample{service: service, requests: a.TotalRequests, errors: a.ErrorCount, seen: time.Now()}
+	if a.Details != nil && a.Details.Latency != nil {
+		s.latencies = append([]int(nil), a.Details.Latency.Histogram...)
+		s.maxLat = a.Details.Latency.Max
+	}
+	m.mu.Lock()
+	m.entries[a.ID] = s
+	m.mu.Unlock()
+}
+
+type serviceTotals struct {
+	name      string
+	analyses  int
+	requests  int
+	errors    int
+	latencies []int
+	maxLat    float64
+}
+
+func (t *serviceTotals) merge(o *serviceTotals) {
+	t.analyses += o.analyses
+	t.requests += o.requests
+	t.errors += o.errors
+	for len(t.latencies) < len(o.latencies) {
+		t.latencies = append(t.latencies, 0)
+	}
+	for i, n := range o.latencies {
+		t.latencies[i] += n
+	}
+	if o.maxLat > t.maxLat {
+		t.maxLat = o.maxLat
+	}
+}
+
+// services prunes old entries and totals the rest per service, busiest first.
+func (m *AnalysisMetrics) services(now time.Time) []*serviceTotals {
+	byName := make(map[string]*serviceTotals)
+	m.mu.Lock()
+	for id, s := range m.entries {
+		if now.Sub(s.seen) > m.window {
+			delete(m.entries, id)
+			continue
+		}
+		t := byName[s.service]
+		if t == nil {
+			t = &serviceTotals{name: s.service}
+			byName[s.service] = t
+		}
+		t.merge(&serviceTotals{analyses: 1, requests: s.requests, errors: s.errors, latencies: s.latencies, maxLat: s.maxLat})
+	}
+	m.mu.Unlock()
+
+	list := make([]*serviceTotals, 0, len(byName))
+	for _, t := range byName {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].requests != list[j].requests {
+			return list[i].requests > list[j].requests
+		}
+		return list[i].name < list[j].name
+	})
+	if len(list) <= m.maxServices {
+		return list
+	}
+
+	other := &serviceTotals{name: otherService}
+	for _, t := range list[m.maxServices:] {
+		other.merge(t)
+	}
+	return append(list[:m.maxServices], other)
+}
+
+func (m *AnalysisMetrics) Collect(w *metrics.Writer) {
+	list := m.services(time.Now())
+	seconds := m.window.Seconds()
+
+	w.Family("log_analyzer_service_requests_per_second",
+		"Requests per second of each service over the metrics window.", "gauge")
+	for _, t := range list {
+		w.Sample("log_analyzer_service_requests_per_second", float64(t.requests)/seconds, "service", t.name)
+	}
+	w.Family("log_analyzer_service_error_rate",
+		"Share of requests that failed (0-1) per service over the metrics window.", "gauge")
+	for _, t := range list {
+		rate := 0.0
+		if t.requests > 0 {
+			rate = float64(t.errors) / float64(t.requests)
+		}
+		w.Sample("log_analyzer_service_error_rate", rate, "service", t.name)
+	}
+	w.Family("log_analyzer_service_latency_p95_seconds",
+		"95th percentile response time per service over the metrics window.", "gauge")
+	for _, t := range list {
+		if histogramTotal(t.latencies) == 0 {
+			continue
+		}
+		p95 := histogramPercentile(t.latencies, t.maxLat, 0.95)
+		w.Sample("log_analyzer_service_latency_p95_seconds", p95/1000, "service", t.name)
+	}
+	w.Family("log_analyzer_service_analyses",
+		"Analyses (uploads and stream windows) per service in the metrics window.", "gauge")
+	for _, t := range list {
+		w.Sample("log_analyzer_service_analyses", float64(t.analyses), "service", t.name)
+	}
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+const (
+	// keys look like la_<12 hex>_<secret>; the first part is the prefix
+	apiKeyMarker     = "la_"
+	apiKeyPrefixLen  = len(apiKeyMarker) + 12
+	apiKeyTouchEvery = time.Minute
+)
+
+var errInvalidAPIKey = errors.New("invalid or revoked API key")
+
+// CreateAPIKey makes a key acting as the user in orgID with the given
+// scopes. The key is returned only here; it is stored hashed.
+func (a *AuthUsecase) CreateAPIKey(userID, orgID uint, name string, scopes []string, ip string) (*domain.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	scopes, err := validScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	// a real membership: VerifyAPIKey reads the org role from it, and site
+	// admins have none in orgs they only reach as admins
+	m, err := a.orgs.GetMember(orgID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if m == nil {
+		return nil, "", ErrNotOrgMember
+	}
+
+	b := make([]byte, (apiKeyPrefixLen-len(apiKeyMarker))/2)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := apiKeyMarker + hex.EncodeToString(b)
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key := prefix + "_" + secret
+
+	k := &domain.APIKey{
+		UserID:  userID,
+		OrgID:   orgID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hashToken(key),
+		Scopes:  scopes,
+	}
+	if err := a.apiKeys.Create(k); err != nil {
+		return nil, "", err
+	}
+	log.Printf("[Auth] API key %s created by userID %d for org %d (%s)", prefix, userID, orgID, strings.Join(scopes, ","))
+	a.record(&domain.AuditEntry{ActorID: userID, Action: domain.AuditAPIKeyCreated, TargetUserID: userID,
+		Detail: fmt.Sprintf("%s %q org %d scopes %s", prefix, name, orgID, strings.Join(scopes, ",")), IP: ip})
+	return k, key, nil
+}
+
+func validScopes(scopes []string) ([]string, error) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, s := range scopes {
+		switch s {
+		case jwt.ScopeRead, jwt.ScopeUpload, jwt.ScopeAdmin:
+		default:
+			return nil, fmt.Errorf("invalid scope %q (use read, upload or admin)", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			list = append(list, s)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return list, nil
+}
+
+// ListAPIKeys returns the user's keys, revoked ones included, without secrets.
+func (a *AuthUsecase) ListAPIKeys(userID uint) ([]domain.APIKey, error) {
+	return a.apiKeys.ListByUser(userID)
+}
+
+func (a *AuthUsecase) RevokeAPIKey(userID, id uint, ip string) error {
+	ok, err := a.apiKeys.Revoke(userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("record not found")
+	}
+	log.Printf("[Auth] API key %d revoked by userID %d", id, userID)
+	a.record(&domain.AuditEntry{ActorID: userID, Action: domain.AuditAPIKeyRevoked, TargetUserID: userID, Detail: fmt.Sprintf("key %d", id), IP: ip})
+	return nil
+}
+
+// VerifyAPIKey implements jwt.APIKeys. The user's role and org role are
+// read on every request, so disabling the user or removing them from the
+// org stops their keys at once. Like Login it refuses unverified emails
+// when RequireVerifiedEmail is set.
+func (a *AuthUsecase) VerifyAPIKey(key string) (*jwt.Identity, error) {
+	if !strings.HasPrefix(key, apiKeyMarker) || len(key) <= apiKeyPrefixLen || key[apiKeyPrefixLen] != '_' {
+		return nil, errInvalidAPIKey
+	}
+	k, err := a.apiKeys.GetByPrefix(key[:apiKeyPrefixLen])
+	if err != nil {
+		return nil, err
+	}
+	if k == nil || k.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(k.KeyHash)) != 1 {
+		return nil, errInvalidAPIKey
+	}
+	u, err := a.repo.FindByID(k.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.DisabledAt != nil {
+		return nil, errInvalidAPIKey
+	}
+	if a.cfg.RequireVerifiedEmail && !u.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	m, err := a.orgs.GetMember(k.OrgID, k.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNotOrgMember
+	}
+
+	now := time.Now()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > apiKeyTouchEvery {
+		if err := a.apiKeys.Touch(k.ID, now); err != nil {
+			log.Printf("[Auth] ❌ last use of API key %d: %v", k.ID, err)
+		}
+	}
+	return &jwt.Identity{
+		KeyID:  k.ID,
+		UserID: u.ID,
+		Role:   u.Role,
+		Org:    jwt.Org{ID: k.OrgID, Role: m.Role},
+		Scopes: k.Scopes,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TooManyAttemptsError is returned while a key is backing off.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+	Locked     bool // temporarily locked out, not just slowed down
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// backoff is the wait after failures: none for the first free ones, then
+// base doubling up to max.
+func backoff(failures, free int, base, max time.Duration) time.Duration {
+	n := failures - free
+	if n <= 0 {
+		return 0
+	}
+	if n <= 30 && base<<(n-1) < max {
+		return base << (n - 1)
+	}
+	return max
+}
+
+// attemptLimiter counts failed attempts per key (a user or an IP). After
+// free failures every further failure doubles the wait, from base up to
+// max. A key is forgotten after forget without failures.
+type attemptLimiter struct {
+	free      int
+	base, max time.Duration
+	forget    time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*attemptState
+	lastPrune time.Time
+}
+
+type attemptState struct {
+	failures int
+	until    time.Time // blocked until
+	last     time.Time // last failure
+}
+
+func newAttemptLimiter(free int, base, max, forget time.Duration) *attemptLimiter {
+	return &attemptLimiter{free: free, base: base, max: max, forget: forget, keys: make(map[string]*attemptState)}
+}
+
+// check returns an error if any of the keys is still backing off.
+func (l *attemptLimiter) check(now time.Time, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for _, k := range keys {
+		if s := l.keys[k]; s != nil && s.until.After(now) && s.until.Sub(now) > wait {
+			wait = s.until.Sub(now)
+		}
+	}
+	if wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (l *attemptLimiter) fail(now time.Time, keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	for _, k := range keys {
+		s := l.keys[k]
+		if s == nil || now.Sub(s.last) > l.forget {
+			s = &attemptState{}
+			l.keys[k] = s
+		}
+		s.failures++
+		s.last = now
+		if wait := backoff(s.failures, l.free, l.base, l.max); wait > 0 {
+			s.until = now.Add(wait)
+		}
+	}
+}
+
+func (l *attemptLimiter) reset(keys ...string) {
+	l.mu.Lock()
+	for _, k := range keys {
+		delete(l.keys, k)
+	}
+	l.mu.Unlock()
+}
+
+// prune drops forgotten keys, at most once per forget period
+func (l *attemptLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.forget {
+		return
+	}
+	l.lastPrune = now
+	for k, s := range l.keys {
+		if now.Sub(s.last) > l.forget && now.After(s.until) {
+			delete(l.keys, k)
+		}
+	}
+}
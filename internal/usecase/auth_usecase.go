@@ -2,11 +2,13 @@ package usecase
 
 import (
 	"errors"
-	"os"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
-	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/mailer"
 	"github.com/ifs21014-itdel/log-analyzer/pkg/totp"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,16 +18,89 @@ type UserRepo interface {
 	FindByEmail(email string) (*domain.User, error)
 	FindByID(id uint) (*domain.User, error)
 	Update(user *domain.User) error
+	List() ([]domain.User, error)
+	AcceptTOTPStep(id uint, step int64) (bool, error)
+}
+
+// AuthConfig holds the settings of AuthUsecase.
+type AuthConfig struct {
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	AdminEmails []string // promoted to admin at startup and when they register
+	DefaultRole string   // role of newly registered users
+	TOTPSkew    uint     // time steps accepted before and after the current one
+	Login       LoginPolicy
+
+	// AppURL is where the server is reachable, for links in emails
+	AppURL string
+	// PasswordResetURL is a frontend page that takes ?token=; without it
+	// the email carries the token and the API endpoint
+	PasswordResetURL string
+	// RequireVerifiedEmail refuses login until the email is verified
+	RequireVerifiedEmail bool
+}
+
+const (
+	// TOTP guesses: 3 free failures, then 1s, 2s, 4s ... up to 15 minutes
+	totpFreeAttempts = 3
+	totpBackoffBase  = time.Second
+	totpBackoffMax   = 15 * time.Minute
+	totpForgetAfter  = time.Hour
+)
+
+// AuthStores are the repositories AuthUsecase works with.
+type AuthStores struct {
+	Users         UserRepo
+	Tokens        repository.TokenRepository
+	RecoveryCodes repository.RecoveryCodeRepository
+	Audit         repository.AuditRepository
+	LoginAttempts repository.LoginAttemptStore // failed passwords per account and IP
+	UserTokens    repository.UserTokenRepository
+	Orgs          repository.OrgRepository
+	APIKeys       repository.APIKeyRepository
 }
 
 type AuthUsecase struct {
-	repo UserRepo
+	repo       UserRepo
+	tokens     repository.TokenRepository
+	codes      repository.RecoveryCodeRepository
+	audit      repository.AuditRepository
+	userTokens repository.UserTokenRepository
+	orgs       repository.OrgRepository
+	apiKeys    repository.APIKeyRepository
+	mail       mailer.Mailer
+	cfg        AuthConfig
+
+	denylist      *tokenDenylist
+	loginAttempts repository.LoginAttemptStore
+	loginLocks    loginLocks
+	attempts      *attemptLimiter // failed TOTP codes per user and per IP
+	mailsSent     *attemptLimiter // reset / verification emails per address
 }
 
-func NewAuthUsecase(r UserRepo) *AuthUsecase {
-	return &AuthUsecase{repo: r}
+func NewAuthUsecase(stores AuthStores, mail mailer.Mailer, cfg AuthConfig) *AuthUsecase {
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = domain.RoleAnalyst
+	}
+	return &AuthUsecase{
+		repo:          stores.Users,
+		tokens:        stores.Tokens,
+		codes:         stores.RecoveryCodes,
+		audit:         stores.Audit,
+		userTokens:    stores.UserTokens,
+		orgs:          stores.Orgs,
+		apiKeys:       stores.APIKeys,
+		mail:          mail,
+		cfg:           cfg,
+		denylist:      newTokenDenylist(),
+		loginAttempts: stores.LoginAttempts,
+		attempts:      newAttemptLimiter(totpFreeAttempts, totpBackoffBase, totpBackoffMax, totpForgetAfter),
+		mailsSent:     newAttemptLimiter(mailFreeSends, mailBackoffBase, mailBackoffMax, mailForgetAfter),
+	}
 }
 
+// Register creates the user, with a personal org as the active one, and
+// emails a link to verify the address.
 func (a *AuthUsecase) Register(email, password, name string) (*domain.User, error) {
 	existing, _ := a.repo.FindByEmail(email)
 	if existing != nil {
@@ -36,68 +111,195 @@ func (a *AuthUsecase) Register(email, password, name string) (*domain.User, erro
 		Email:        email,
 		PasswordHash: string(hash),
 		Name:         name,
+		Role:         a.cfg.DefaultRole,
+	}
+	if a.isAdminEmail(email) {
+		u.Role = domain.RoleAdmin
 	}
 	if err := a.repo.Create(u); err != nil {
 		return nil, err
 	}
+	if _, err := a.ActorFor(u); err != nil {
+		return nil, err
+	}
+	if err := a.sendVerification(u); err != nil {
+		// the account exists either way; the link can be sent again
+		log.Printf("[Auth] ❌ verification email for userID %d: %v", u.ID, err)
+	}
 	return u, nil
 }
 
-func (a *AuthUsecase) Login(email, password, totpCode string) (string, *domain.User, error) {
-	u, err := a.repo.FindByEmail(email)
-	if err != nil || u == nil {
-		return "", nil, errors.New("invalid credentials")
-	}
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
-		return "", nil, errors.New("invalid credentials")
+// Login starts a new session: a short-lived access token plus a refresh token.
+// With 2FA on, totpCode may also be an unused recovery code.
+func (a *AuthUsecase) Login(email, password, totpCode, ip string) (*domain.TokenPair, *domain.User, error) {
+	// one attempt per account and IP at a time, from the check to the count
+	unlock := a.loginLocks.lock(accountKey(email), ipKey(ip))
+	u, err := a.checkPassword(email, password, ip)
+	unlock()
+	if err != nil {
+		return nil, nil, err
 	}
 	// If user enabled TOTP, validate code
 	if u.TOTPEnabled {
-		ok, err := totp.ValidateCode(totpCode, u.TOTPSecret)
-		if err != nil || !ok {
-			return "", nil, errors.New("invalid TOTP code")
+		ok, err := a.limitTOTP(u.ID, ip, func() (bool, error) { return a.checkSecondFactor(u, totpCode, ip) })
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, errors.New("invalid TOTP code")
 		}
 	}
-	// generate JWT token
-	secret := os.Getenv("JWT_SECRET")
-	hours := 24
-	token, err := jwt.GenerateToken(u.ID, secret, time.Duration(hours)*time.Hour)
+	// generate JWT token, in a new refresh token family
+	sessionID, err := randomToken(16)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
-	return token, u, nil
+	tokens, err := a.issueTokens(u, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, u, nil
 }
 
-// Generate TOTP secret & provisioning URI
-func (a *AuthUsecase) GenerateTOTPForUser(userID uint, issuer string) (string, string, error) {
+// checkPassword is the first factor of Login, counted by the login guard.
+func (a *AuthUsecase) checkPassword(email, password, ip string) (*domain.User, error) {
+	now := time.Now()
+	if err := a.guardLogin(email, ip, now); err != nil {
+		return nil, err
+	}
+	u, err := a.repo.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		a.loginFailed(nil, email, ip, now)
+		return nil, errors.New("invalid credentials")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		a.loginFailed(u, email, ip, now)
+		return nil, errors.New("invalid credentials")
+	}
+	if u.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	if a.cfg.RequireVerifiedEmail && !u.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	// the password was right: the account starts over, the IP does not
+	if err := a.loginAttempts.Reset(accountKey(email)); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ErrInvalidPassword is returned when an action needs the current password.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// GenerateTOTPForUser creates a TOTP secret & provisioning URI for the user.
+// The current password is required, also to replace an enabled secret; the
+// new secret only takes effect once VerifyAndEnableTOTP accepts a code.
+func (a *AuthUsecase) GenerateTOTPForUser(userID uint, password, issuer string) (string, string, error) {
 	u, err := a.repo.FindByID(userID)
 	if err != nil || u == nil {
 		return "", "", errors.New("user not found")
 	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return "", "", ErrInvalidPassword
+	}
 	key, uri, err := totp.GenerateKeyString(issuer, u.Email)
 	if err != nil {
 		return "", "", err
 	}
-	u.TOTPSecret = key
-	// don't enable yet; require verification step
+	u.TOTPPending = key
+	// don't use it yet; require verification step
 	if err := a.repo.Update(u); err != nil {
 		return "", "", err
 	}
 	return key, uri, nil
 }
 
-func (a *AuthUsecase) VerifyAndEnableTOTP(userID uint, code string) (bool, error) {
+// VerifyAndEnableTOTP checks a code from the pending secret and makes it
+// the user's TOTP secret. It returns a fresh set of recovery codes, the only
+// time they are shown.
+func (a *AuthUsecase) VerifyAndEnableTOTP(userID uint, code, ip string) ([]string, bool, error) {
+	u, err := a.repo.FindByID(userID)
+	if err != nil || u == nil {
+		return nil, false, errors.New("user not found")
+	}
+	if u.TOTPPending == "" {
+		return nil, false, errors.New("no TOTP setup in progress, call /totp/setup first")
+	}
+	ok, err := a.limitTOTP(u.ID, ip, func() (bool, error) { return a.acceptCode(u.ID, u.TOTPPending, code) })
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	u.TOTPSecret = u.TOTPPending
+	u.TOTPPending = ""
+	u.TOTPEnabled = true
+	if err := a.repo.Update(u); err != nil {
+		return nil, false, err
+	}
+	codes, err := a.newRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// DisableTOTP turns 2FA off; code must be valid for the current secret.
+func (a *AuthUsecase) DisableTOTP(userID uint, code, ip string) (bool, error) {
 	u, err := a.repo.FindByID(userID)
 	if err != nil || u == nil {
 		return false, errors.New("user not found")
 	}
-	ok, err := totp.ValidateCode(code, u.TOTPSecret)
+	if !u.TOTPEnabled {
+		return false, errors.New("TOTP is not enabled")
+	}
+	ok, err := a.limitTOTP(u.ID, ip, func() (bool, error) { return a.acceptCode(u.ID, u.TOTPSecret, code) })
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := a.clearTOTP(u); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (a *AuthUsecase) clearTOTP(u *domain.User) error {
+	u.TOTPSecret = ""
+	u.TOTPPending = ""
+	u.TOTPEnabled = false
+	if err := a.repo.Update(u); err != nil {
+		return err
+	}
+	return a.codes.DeleteAll(u.ID)
+}
+
+// acceptCode checks a TOTP code and uses up its time step.
+func (a *AuthUsecase) acceptCode(userID uint, secret, code string) (bool, error) {
+	step, ok, err := totp.Validate(code, secret, time.Now(), a.cfg.TOTPSkew)
+	if err != nil || !ok {
+		return false, err
+	}
+	return a.repo.AcceptTOTPStep(userID, step)
+}
+
+// limitTOTP runs check unless the user or the IP is backing off after
+// failed codes; a failure counts against both, a success clears the user.
+func (a *AuthUsecase) limitTOTP(userID uint, ip string, check func() (bool, error)) (bool, error) {
+	userKey, ipKey := fmt.Sprintf("user:%d", userID), "ip:"+ip
+	now := time.Now()
+	if err := a.attempts.check(now, userKey, ipKey); err != nil {
+		return false, err
+	}
+	ok, err := check()
 	if err != nil {
 		return false, err
 	}
-	if ok {
-		u.TOTPEnabled = true
-		_ = a.repo.Update(u)
+	if !ok {
+		a.attempts.fail(now, userKey, ipKey)
+		return false, nil
 	}
-	return ok, nil
+	a.attempts.reset(userKey)
+	return true, nil
 }
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+)
+
+const (
+	collectorFlushInterval = time.Second
+	collectorMaxBatch      = 500
+	collectorMaxPending    = 20 * collectorMaxBatch
+	// label values come from the network and each one opens its own window,
+	// so a collector takes this many distinct values per key, then drops it
+	collectorMaxLabelValues = 500
+	collectorMaxLabelKeys   = 32
+)
+
+// Collector buffers records coming from network listeners (syslog, file
+// tail, ...) and pushes them to the ingest queue in batches, so a single UDP
+// packet does not become a batch of its own. Streams are created by one
+// user, in their org.
+type Collector struct {
+	ingest *IngestUsecase
+	orgID  uint
+	owner  OwnerFunc
+	source string
+	format string // format of streams this collector creates
+
+	mu      sync.Mutex
+	pending map[string]*IngestBatch
+	values  map[string]map[string]bool // label values seen, by key
+	capped  map[string]bool            // keys that hit the value limit
+}
+
+// OwnerFunc resolves the user that creates a collector's streams. It runs
+// each time a stream is created, so an owner who was removed from the org
+// or lost write access stops creating streams without a restart.
+type OwnerFunc func() (domain.Actor, error)
+
+// NewCollector starts a collector for streams of orgID, created by owner.
+// Streams it creates get format; source is only used in log messages.
+func (u *IngestUsecase) NewCollector(orgID uint, owner OwnerFunc, source, format string) *Collector {
+	c := &Collector{
+		ingest:  u,
+		orgID:   orgID,
+		owner:   owner,
+		source:  source,
+		format:  format,
+		pending: make(map[string]*IngestBatch),
+		values:  make(map[string]map[string]bool),
+		capped:  make(map[string]bool),
+	}
+	go func() {
+		for range time.Tick(collectorFlushInterval) {
+			c.Flush()
+		}
+	}()
+	return c
+}
+
+// Stream returns the stream (created on first use) and the parser for its format.
+func (c *Collector) Stream(name string) (*domain.Stream, parser.Func, error) {
+	s, err := c.ingest.getOrCreateStream(c.orgID, name, c.format, c.owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	parse, err := parser.Get(s.Format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, countParse(strings.ToLower(c.source), parse), nil
+}
+
+// Add buffers rec with labels, which are filtered like pushed labels and
+// capped in distinct values.
+func (c *Collector) Add(stream *domain.Stream, labels map[string]string, rec domain.LogRecord) {
+	c.mu.Lock()
+	labels = c.limitLabels(FilterLabels(labels))
+	key := stream.Name + "|" + labelsKey(labels)
+	batch, ok := c.pending[key]
+	if !ok {
+		batch = &IngestBatch{Stream: stream, Labels: labels}
+		c.pending[key] = batch
+	}
+	batch.Records = append(batch.Records, rec)
+	full := len(batch.Records) >= collectorMaxBatch
+	c.mu.Unlock()
+
+	if full {
+		c.Flush()
+	}
+}
+
+// PushNow queues records right away, skipping the buffer, for senders that
+// wait for an acknowledgement. ErrIngestBusy means nothing was queued.
+func (c *Collector) PushNow(stream *domain.Stream, records []domain.LogRecord, labels []map[string]string) error {
+	c.mu.Lock()
+	batches := make(map[string]*IngestBatch)
+	var order []string
+	for i, rec := range records {
+		l := c.limitLabels(FilterLabels(labels[i]))
+		key := labelsKey(l)
+		batch, ok := batches[key]
+		if !ok {
+			batch = &IngestBatch{Stream: stream, Labels: l}
+			batches[key] = batch
+			order = append(order, key)
+		}
+		batch.Records = append(batch.Records, rec)
+	}
+	c.mu.Unlock()
+
+	queued := make([]IngestBatch, len(order))
+	for i, key := range order {
+		queued[i] = *batches[key]
+	}
+	return c.ingest.Push(queued...)
+}
+
+// limitLabels drops labels whose key already has collectorMaxLabelValues
+// other values, or that would be a key past collectorMaxLabelKeys.
+func (c *Collector) limitLabels(labels map[string]string) map[string]string {
+	for k, v := range labels {
+		seen, ok := c.values[k]
+		if !ok {
+			if len(c.values) >= collectorMaxLabelKeys {
+				delete(labels, k)
+				continue
+			}
+			seen = make(map[string]bool)
+			c.values[k] = seen
+		}
+		if seen[v] {
+			continue
+		}
+		if len(seen) >= collectorMaxLabelValues {
+			if !c.capped[k] {
+				c.capped[k] = true
+				log.Printf("[%s] ⚠️ label %q has %d values, dropping new ones", c.source, k, collectorMaxLabelValues)
+			}
+			delete(labels, k)
+			continue
+		}
+		seen[v] = true
+	}
+	return labels
+}
+
+// Flush pushes everything buffered. When the queue is full the records stay
+// pending for the next tick, up to collectorMaxPending per stream.
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, batch := range c.pending {
+		err := c.ingest.Push(*batch)
+		if err == nil {
+			delete(c.pending, key)
+			continue
+		}
+		if !errors.Is(err, ErrIngestBusy) || len(batch.Records) > collectorMaxPending {
+			log.Printf("[%s] ❌ dropped %d records for stream %q: %v", c.source, len(batch.Records), batch.Stream.Name, err)
+			delete(c.pending, key)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+type memStreams struct {
+	streams []domain.Stream
+}
+
+func (m *memStreams) Create(s *domain.Stream) error {
+	s.ID = uint(len(m.streams) + 1)
+	m.streams = append(m.streams, *s)
+	return nil
+}
+
+func (m *memStreams) Update(s *domain.Stream) error { return nil }
+
+func (m *memStreams) FindByName(orgID uint, name string) (*domain.Stream, error) {
+	for i := range m.streams {
+		if m.streams[i].OrgID == orgID && m.streams[i].Name == name {
+			s := m.streams[i]
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memStreams) ListByOrg(orgID uint) ([]domain.Stream, error) { return nil, nil }
+
+func (m *memStreams) Delete(id uint) error { return nil }
+
+func TestCollectorOwnerCheckedOnCreate(t *testing.T) {
+	repo := &memStreams{}
+	ingest := NewIngestUsecase(repo, nil, nil, nil)
+	owner := domain.Actor{UserID: 7, Role: domain.RoleAnalyst, OrgID: 3, OrgRole: domain.RoleAnalyst}
+	var ownerErr error
+	calls := 0
+	c := &Collector{ingest: ingest, orgID: 3, format: "raw", source: "Test", owner: func() (domain.Actor, error) {
+		calls++
+		return owner, ownerErr
+	}}
+
+	s, _, err := c.Stream("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.OrgID != 3 || s.UserID != 7 || calls != 1 {
+		t.Errorf("stream %+v after %d owner checks", s, calls)
+	}
+	// an existing stream needs no check
+	if _, _, err := c.Stream("web"); err != nil || calls != 1 {
+		t.Errorf("existing stream: %v after %d owner checks", err, calls)
+	}
+
+	ownerErr = ErrNotOrgMember
+	if _, _, err := c.Stream("api"); !errors.Is(err, ErrNotOrgMember) {
+		t.Errorf("owner left the org: err = %v", err)
+	}
+	ownerErr = nil
+	owner.OrgRole = domain.RoleViewer
+	if _, _, err := c.Stream("api"); err == nil {
+		t.Error("viewer created a stream")
+	}
+	owner.OrgRole, owner.OrgID = domain.RoleAdmin, 4
+	if _, _, err := c.Stream("api"); err == nil {
+		t.Error("owner of another org created a stream")
+	}
+	if len(repo.streams) != 1 {
+		t.Errorf("%d streams created, want 1", len(repo.streams))
+	}
+}
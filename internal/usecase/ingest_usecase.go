@@ -0,0 +1,475 @@
+package usecase
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+)
+
+const (
+	defaultWindowSeconds = 300
+	minWindowSeconds     = 60
+	maxWindowSeconds     = 24 * 60 * 60
+	ingestQueueSize      = 1024
+	maxIngestLineBytes   = 1 << 20
+	windowSweepInterval  = 30 * time.Second
+	streamCacheTTL       = time.Minute
+)
+
+var (
+	ErrIngestBusy = errors.New("ingest queue is full, retry later")
+
+	streamNamePattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]{0,63}$`)
+	invalidStreamChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]+`)
+)
+
+// IngestBatch is a group of records for one stream. Labels are added on top
+// of the stream's own labels, so one stream can feed several label sets
+// (e.g. one per host) and each gets its own window analysis.
+type IngestBatch struct {
+	Stream  *domain.Stream
+	Labels  map[string]string
+	Records []domain.LogRecord
+}
+
+type IngestResult struct {
+	Stream   string `json:"stream"`
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+}
+
+type windowID struct {
+	streamID uint
+	start    int64
+	key      string
+}
+
+type openWindow struct {
+	analysis *domain.LogAnalysis
+	agg      *analysisAggregator
+	dirty    bool
+	pending  []domain.LogRecord // records to retain once the window is saved
+}
+
+// IngestUsecase receives pushed records and rolls them up into time-windowed
+// analyses per stream. Batches go through a queue to a single worker, which
+// owns the open windows, so no locking is needed around them.
+type IngestUsecase struct {
+	streams  repository.StreamRepository
+	analyses repository.LogAnalysisRepository
+	queue    chan []IngestBatch // the batches of one push, applied together
+	windows  map[windowID]*openWindow
+	// deleted streams: their windows are dropped and later batches ignored
+	deleted    chan uint
+	deletedIDs map[uint]bool
+	recent     *recentBuffer
+	records    *RecordUsecase
+	observer   AnalysisObserver // alerts and metrics, may be nil
+
+	// listeners look a stream up for every message, so keep them for a while
+	cacheMu     sync.Mutex
+	streamCache map[streamCacheKey]cachedStream
+}
+
+type streamCacheKey struct {
+	orgID uint
+	name  string
+}
+
+type cachedStream struct {
+	stream  *domain.Stream
+	expires time.Time
+}
+
+func NewIngestUsecase(streams repository.StreamRepository, analyses repository.LogAnalysisRepository, records *RecordUsecase, observer AnalysisObserver) *IngestUsecase {
+	return &IngestUsecase{
+		streams:     streams,
+		analyses:    analyses,
+		records:     records,
+		observer:    observer,
+		queue:       make(chan []IngestBatch, ingestQueueSize),
+		windows:     make(map[windowID]*openWindow),
+		deleted:     make(chan uint, 16),
+		deletedIDs:  make(map[uint]bool),
+		recent:      newRecentBuffer(),
+		streamCache: make(map[streamCacheKey]cachedStream),
+	}
+}
+
+// QueueDepth is the number of pushes waiting for the worker.
+func (u *IngestUsecase) QueueDepth() int {
+	return len(u.queue)
+}
+
+// Start runs the worker that applies queued batches.
+func (u *IngestUsecase) Start() {
+	go u.run()
+}
+
+// ===================== STREAMS =====================
+
+func (u *IngestUsecase) ListStreams(orgID uint) ([]domain.Stream, error) {
+	return u.streams.ListByOrg(orgID)
+}
+
+func (u *IngestUsecase) GetStream(orgID uint, name string) (*domain.Stream, error) {
+	s, err := u.streams.FindByName(orgID, name)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, errors.New("stream not found")
+	}
+	return s, nil
+}
+
+// SaveStream creates the stream or updates its format, window and labels.
+func (u *IngestUsecase) SaveStream(s *domain.Stream) error {
+	if err := validateStream(s); err != nil {
+		return err
+	}
+	existing, err := u.streams.FindByName(s.OrgID, s.Name)
+	if err != nil {
+		return err
+	}
+	u.forgetStream(s.OrgID, s.Name)
+	if existing == nil {
+		return u.streams.Create(s)
+	}
+	s.ID = existing.ID
+	s.UserID = existing.UserID
+	s.CreatedAt = existing.CreatedAt
+	return u.streams.Update(s)
+}
+
+func (u *IngestUsecase) DeleteStream(orgID uint, name string) error {
+	s, err := u.GetStream(orgID, name)
+	if err != nil {
+		return err
+	}
+	u.forgetStream(orgID, name)
+	if err := u.streams.Delete(s.ID); err != nil {
+		return err
+	}
+	// its windows could never be saved again; the worker drops them
+	u.deleted <- s.ID
+	return nil
+}
+
+// GetOrCreateStream finds a stream of the owner's org by name, creating it
+// with the given format (or the default one) the first time something is
+// pushed to it.
+func (u *IngestUsecase) GetOrCreateStream(owner domain.Actor, name, format string) (*domain.Stream, error) {
+	return u.getOrCreateStream(owner.OrgID, name, format, func() (domain.Actor, error) { return owner, nil })
+}
+
+// getOrCreateStream only resolves the owner when the stream has to be
+// created.
+func (u *IngestUsecase) getOrCreateStream(orgID uint, name, format string, ownerFn OwnerFunc) (*domain.Stream, error) {
+	key := streamCacheKey{orgID, name}
+	u.cacheMu.Lock()
+	cached, ok := u.streamCache[key]
+	u.cacheMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.stream, nil
+	}
+
+	s, err := u.streams.FindByName(orgID, name)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		owner, err := ownerFn()
+		if err != nil {
+			return nil, err
+		}
+		if owner.OrgID != orgID || !owner.CanWrite() {
+			return nil, errors.New("stream not found")
+		}
+		s = &domain.Stream{UserID: owner.UserID, OrgID: orgID, Name: name, Format: format}
+		if err := u.SaveStream(s); err != nil {
+			return nil, err
+		}
+		log.Printf("[Ingest] created stream %q (format %s) for org %d", s.Name, s.Format, orgID)
+	}
+
+	u.cacheMu.Lock()
+	u.streamCache[key] = cachedStream{stream: s, expires: time.Now().Add(streamCacheTTL)}
+	u.cacheMu.Unlock()
+	return s, nil
+}
+
+func (u *IngestUsecase) forgetStream(orgID uint, name string) {
+	u.cacheMu.Lock()
+	delete(u.streamCache, streamCacheKey{orgID, name})
+	u.cacheMu.Unlock()
+}
+
+// SanitizeStreamName turns a file name, job or index name into a valid
+// stream name, or "" when nothing usable is left.
+func SanitizeStreamName(name string) string {
+	name = strings.Trim(invalidStreamChars.ReplaceAllString(name, "-"), "-._")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func validateStream(s *domain.Stream) error {
+	if !streamNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid stream name %q", s.Name)
+	}
+	if s.Format == "" {
+		s.Format = parser.DefaultFormat
+	}
+	if _, err := parser.Get(s.Format); err != nil {
+		return err
+	}
+	if s.WindowSeconds == 0 {
+		s.WindowSeconds = defaultWindowSeconds
+	}
+	if s.WindowSeconds < minWindowSeconds || s.WindowSeconds > maxWindowSeconds {
+		return fmt.Errorf("window_seconds must be between %d and %d", minWindowSeconds, maxWindowSeconds)
+	}
+	return ValidateLabels(s.Labels)
+}
+
+// ===================== INGEST =====================
+
+// IngestLines reads newline-delimited lines, parses them with the stream's
+// format and queues the records.
+func (u *IngestUsecase) IngestLines(stream *domain.Stream, labels map[string]string, body io.Reader) (*IngestResult, error) {
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	parse, err := parser.Get(stream.Format)
+	if err != nil {
+		return nil, err
+	}
+	parse = countParse("push", parse)
+
+	result := &IngestResult{Stream: stream.Name}
+	var records []domain.LogRecord
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxIngestLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rec, ok := parse(line)
+		if !ok {
+			result.Rejected++
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := u.Push(IngestBatch{Stream: stream, Labels: labels, Records: records}); err != nil {
+		return nil, err
+	}
+	result.Accepted = len(records)
+	return result, nil
+}
+
+// Push queues batches without blocking, all or none of them, so a client
+// retrying after ErrIngestBusy (queue full) does not send anything twice.
+func (u *IngestUsecase) Push(batches ...IngestBatch) error {
+	now := time.Now().UTC()
+	queued := make([]IngestBatch, 0, len(batches))
+	for _, batch := range batches {
+		if len(batch.Records) == 0 {
+			continue
+		}
+		for i := range batch.Records {
+			if batch.Records[i].Time.IsZero() {
+				batch.Records[i].Time = now
+			}
+		}
+		queued = append(queued, batch)
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+	select {
+	case u.queue <- queued:
+		return nil
+	default:
+		return ErrIngestBusy
+	}
+}
+
+// ===================== WORKER =====================
+
+func (u *IngestUsecase) run() {
+	ticker := time.NewTicker(windowSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case batches := <-u.queue:
+			for _, batch := range batches {
+				u.apply(batch)
+			}
+			u.flush()
+		case id := <-u.deleted:
+			u.dropStream(id)
+		case <-ticker.C:
+			u.flush()
+			u.evict(time.Now())
+		}
+	}
+}
+
+func (u *IngestUsecase) apply(batch IngestBatch) {
+	stream := batch.Stream
+	if u.deletedIDs[stream.ID] {
+		return
+	}
+	labels := make(map[string]string, len(stream.Labels)+len(batch.Labels)+1)
+	for k, v := range stream.Labels {
+		labels[k] = v
+	}
+	for k, v := range batch.Labels {
+		labels[k] = v
+	}
+	labels["stream"] = stream.Name
+	key := labelsKey(labels)
+	u.recent.add(stream.OrgID, labels, key, batch.Records)
+
+	recordsIngested.With().Add(float64(len(batch.Records)))
+	window := stream.Window()
+	retain := u.records.Enabled()
+	for _, rec := range batch.Records {
+		start := rec.Time.Truncate(window)
+		id := windowID{streamID: stream.ID, start: start.Unix(), key: key}
+		w, ok := u.windows[id]
+		if !ok {
+			var err error
+			w, err = u.openWindow(stream, labels, key, start)
+			if err != nil {
+				log.Printf("[Ingest] ❌ open window %s@%s: %v", stream.Name, start.Format(time.RFC3339), err)
+				continue
+			}
+			u.windows[id] = w
+		}
+		w.agg.Add(rec)
+		w.dirty = true
+		if retain {
+			w.pending = append(w.pending, rec)
+		}
+	}
+}
+
+// openWindow starts a window, continuing from the saved row if there is one.
+func (u *IngestUsecase) openWindow(stream *domain.Stream, labels map[string]string, key string, start time.Time) (*openWindow, error) {
+	w := &openWindow{agg: newAnalysisAggregator()}
+	saved, err := u.analyses.FindWindow(stream.ID, start, key)
+	if err != nil {
+		return nil, err
+	}
+	if saved != nil {
+		w.agg.seed(saved)
+		w.analysis = saved
+		return w, nil
+	}
+
+	end := start.Add(stream.Window())
+	w.analysis = &domain.LogAnalysis{
+		UserID:      stream.UserID,
+		OrgID:       stream.OrgID,
+		Filename:    fmt.Sprintf("%s@%s", stream.Name, start.UTC().Format(time.RFC3339)),
+		Labels:      labels,
+		StreamID:    stream.ID,
+		WindowStart: &start,
+		WindowEnd:   &end,
+		WindowKey:   key,
+	}
+	return w, nil
+}
+
+func (u *IngestUsecase) flush() {
+	for _, w := range u.windows {
+		if !w.dirty {
+			continue
+		}
+		res := w.agg.Result()
+		a := w.analysis
+		a.TotalRequests = res.TotalRequests
+		a.ErrorCount = res.ErrorCount
+		a.UniqueIPs = res.UniqueIPs
+		a.AverageResponse = res.AverageResponse
+		a.Details = res.Details
+		if err := u.analyses.UpsertWindow(a); err != nil {
+			log.Printf("[Ingest] ❌ save window %s: %v", a.Filename, err)
+			continue
+		}
+		w.dirty = false
+		analysesSaved.With("window").Inc()
+		if u.observer != nil {
+			u.observer.Observe(a)
+		}
+		// the window has its ID now, so its records can be written
+		if len(w.pending) > 0 {
+			if err := u.records.Save(a, storedRecords(w.pending)); err != nil {
+				log.Printf("[Ingest] ❌ save records %s: %v", a.Filename, err)
+			}
+			w.pending = nil
+		}
+	}
+}
+
+// dropStream forgets the open windows of a deleted stream.
+func (u *IngestUsecase) dropStream(streamID uint) {
+	u.deletedIDs[streamID] = true
+	for id := range u.windows {
+		if id.streamID == streamID {
+			delete(u.windows, id)
+		}
+	}
+}
+
+// evict drops windows that ended more than one window length ago. A late
+// record for an evicted window reopens it from the database.
+func (u *IngestUsecase) evict(now time.Time) {
+	for id, w := range u.windows {
+		if w.dirty || w.analysis.WindowEnd == nil {
+			continue
+		}
+		length := w.analysis.WindowEnd.Sub(*w.analysis.WindowStart)
+		if now.After(w.analysis.WindowEnd.Add(length)) {
+			delete(u.windows, id)
+		}
+	}
+}
+
+// labelsKey is a stable fingerprint of a label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(labels[k]))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxLabels          = 32
+	maxLabelValueBytes = 256
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]{0,62}$`)
+
+// ParseLabels parses "key=value" pairs. Each item may itself hold several
+// comma separated pairs, so both `labels=service=checkout,env=prod` and
+// repeated `label=service=checkout` form/query values are accepted.
+func ParseLabels(items ...string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, item := range items {
+		for _, pair := range strings.Split(item, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid label %q, use key=value", pair)
+			}
+			labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ValidateLabels cek format key, panjang value dan jumlah label
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels (max %d)", maxLabels)
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if value == "" {
+			return fmt.Errorf("label %q has an empty value", key)
+		}
+		if len(value) > maxLabelValueBytes {
+			return fmt.Errorf("label %q value too long (max %d bytes)", key, maxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// FilterLabels keeps the attributes that are valid labels, for sources that
+// send arbitrary attributes (OTLP resources, ...). Keys are taken in sorted
+// order up to the label limit.
+func FilterLabels(attrs map[string]string) map[string]string {
+	keys := make([]string, 0, len(attrs))
+	for key, value := range attrs {
+		if labelKeyPattern.MatchString(key) && value != "" && len(value) <= maxLabelValueBytes {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > maxLabels {
+		keys = keys[:maxLabels]
+	}
+	labels := make(map[string]string, len(keys))
+	for _, key := range keys {
+		labels[key] = attrs[key]
+	}
+	return labels
+}
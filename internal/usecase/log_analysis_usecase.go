@@ -3,59 +3,245 @@ package usecase
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
 	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
 )
 
 type LogAnalysisUsecase struct {
-	repo repository.LogAnalysisRepository
+	repo     repository.LogAnalysisRepository
+	users    UserRepo
+	records  *RecordUsecase
+	observer AnalysisObserver // alerts and metrics, may be nil
 }
 
-func NewLogAnalysisUsecase(r repository.LogAnalysisRepository) *LogAnalysisUsecase {
-	return &LogAnalysisUsecase{repo: r}
+func NewLogAnalysisUsecase(r repository.LogAnalysisRepository, users UserRepo, records *RecordUsecase, observer AnalysisObserver) *LogAnalysisUsecase {
+	return &LogAnalysisUsecase{repo: r, users: users, records: records, observer: observer}
 }
 
+// ErrNotOwner is returned when a user who may read an analysis tries to
+// change it.
+var ErrNotOwner = errors.New("only the uploader or an org admin can change this analysis")
+
 // CRUD
 func (u *LogAnalysisUsecase) Create(a *domain.LogAnalysis) error {
+	if err := ValidateLabels(a.Labels); err != nil {
+		return err
+	}
 	return u.repo.Create(a)
 }
 
-func (u *LogAnalysisUsecase) GetAll() ([]domain.LogAnalysis, error) {
-	return u.repo.GetAll()
+// GetAll lists the analyses of the actor's active org plus those shared
+// with them; admins may ask for every org with filter.AllOrgs.
+func (u *LogAnalysisUsecase) GetAll(actor domain.Actor, filter domain.AnalysisFilter) ([]domain.LogAnalysis, error) {
+	return u.repo.GetAll(visibleFilter(actor, filter))
+}
+
+func visibleFilter(actor domain.Actor, filter domain.AnalysisFilter) domain.AnalysisFilter {
+	if filter.AllOrgs && actor.IsAdmin() {
+		filter.OrgID, filter.SharedWith = 0, 0
+		return filter
+	}
+	filter.OrgID, filter.SharedWith = actor.OrgID, actor.UserID
+	return filter
 }
 
-func (u *LogAnalysisUsecase) GetByID(id uint) (*domain.LogAnalysis, error) {
+// GetByID returns the analysis if the actor may read it; others get
+// "record not found", not a hint that it exists.
+func (u *LogAnalysisUsecase) GetByID(actor domain.Actor, id uint) (*domain.LogAnalysis, error) {
 	a, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
 	if a == nil {
 		return nil, errors.New("record not found")
 	}
-	return a, err
+	ok, err := canRead(u.repo, actor, a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return a, nil
 }
 
-func (u *LogAnalysisUsecase) Update(a *domain.LogAnalysis) error {
+// canRead: site admins, members of the analysis' org and users it is
+// shared with may read an analysis and its records.
+func canRead(repo repository.LogAnalysisRepository, actor domain.Actor, a *domain.LogAnalysis) (bool, error) {
+	if actor.IsAdmin() || a.OrgID == actor.OrgID {
+		return true, nil
+	}
+	if actor.UserID == 0 {
+		return false, nil
+	}
+	return repo.IsSharedWith(a.ID, actor.UserID)
+}
+
+// editable returns the analysis if the actor may change it: admins, admins
+// of its org, and analysts of its org who uploaded it.
+func (u *LogAnalysisUsecase) editable(actor domain.Actor, id uint) (*domain.LogAnalysis, error) {
+	a, err := u.GetByID(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanWrite() {
+		return nil, ErrNotOwner
+	}
+	if actor.IsAdmin() || a.OrgID == actor.OrgID && (actor.IsOrgAdmin() || a.UserID == actor.UserID) {
+		return a, nil
+	}
+	return nil, ErrNotOwner
+}
+
+func (u *LogAnalysisUsecase) Update(actor domain.Actor, a *domain.LogAnalysis) error {
+	existing, err := u.editable(actor, a.ID)
+	if err != nil {
+		return err
+	}
+	if err := ValidateLabels(a.Labels); err != nil {
+		return err
+	}
+	a.UserID, a.OrgID = existing.UserID, existing.OrgID
 	return u.repo.Update(a)
 }
 
-func (u *LogAnalysisUsecase) Delete(id uint) error {
-	return u.repo.Delete(id)
+func (u *LogAnalysisUsecase) Delete(actor domain.Actor, id uint) error {
+	if _, err := u.editable(actor, id); err != nil {
+		return err
+	}
+	if err := u.repo.Delete(id); err != nil {
+		return err
+	}
+	return u.records.DeleteAnalysis(id)
+}
+
+// SetLabels replaces all labels of an analysis.
+func (u *LogAnalysisUsecase) SetLabels(actor domain.Actor, id uint, labels map[string]string) (*domain.LogAnalysis, error) {
+	if _, err := u.editable(actor, id); err != nil {
+		return nil, err
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	if err := u.repo.SetLabels(id, labels); err != nil {
+		return nil, err
+	}
+	return u.GetByID(actor, id)
+}
+
+// MergeLabels updates only the given keys; an empty value removes the label.
+func (u *LogAnalysisUsecase) MergeLabels(actor domain.Actor, id uint, changes map[string]string) (*domain.LogAnalysis, error) {
+	a, err := u.editable(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(a.Labels)+len(changes))
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(labels, k)
+			continue
+		}
+		labels[k] = v
+	}
+	return u.SetLabels(actor, id, labels)
+}
+
+// Share gives the user with the given email read access to the analysis.
+func (u *LogAnalysisUsecase) Share(actor domain.Actor, id uint, email string) (*domain.AnalysisShare, error) {
+	a, err := u.editable(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := u.users.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("no user with that email")
+	}
+	if user.ID == a.UserID {
+		return nil, errors.New("the owner can already read this analysis")
+	}
+	s := &domain.AnalysisShare{AnalysisID: id, UserID: user.ID, Email: user.Email, SharedBy: actor.UserID}
+	if err := u.repo.Share(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unshare takes back the read access of a user.
+func (u *LogAnalysisUsecase) Unshare(actor domain.Actor, id, userID uint) error {
+	if _, err := u.editable(actor, id); err != nil {
+		return err
+	}
+	return u.repo.Unshare(id, userID)
+}
+
+// Shares lists who the analysis is shared with.
+func (u *LogAnalysisUsecase) Shares(actor domain.Actor, id uint) ([]domain.AnalysisShare, error) {
+	if _, err := u.editable(actor, id); err != nil {
+		return nil, err
+	}
+	return u.repo.Shares(id)
+}
+
+// Report groups analyses by label keys, e.g. service and env.
+func (u *LogAnalysisUsecase) Report(actor domain.Actor, groupBy []string, filter domain.AnalysisFilter) ([]domain.LabelReportRow, error) {
+	return u.report(groupBy, visibleFilter(actor, filter))
+}
+
+// report groups the analyses matching filter as is, without the actor's
+// visibility rules.
+func (u *LogAnalysisUsecase) report(groupBy []string, filter domain.AnalysisFilter) ([]domain.LabelReportRow, error) {
+	if len(groupBy) == 0 {
+		return nil, errors.New("group_by is required")
+	}
+	for _, key := range groupBy {
+		if !labelKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid label key %q", key)
+		}
+	}
+	return u.repo.Report(groupBy, filter)
 }
 
 // 🧠 ProcessLogs — concurrent log analyzer with progress logs
-func (u *LogAnalysisUsecase) ProcessLogs(lines []string) (*domain.LogAnalysis, error) {
-	totalRequests := 0
-	errorCount := 0
-	ipSet := make(map[string]struct{})
+func (u *LogAnalysisUsecase) ProcessLogs(lines []string, parse parser.Func) (*domain.LogAnalysis, error) {
+	analysis, _ := u.processLines(lines, parse, false)
+	return analysis, nil
+}
+
+// lineJob is one line and its 1-based line number
+type lineJob struct {
+	no   int
+	line string
+}
+
+// processLines runs the worker pool; with keep it also returns the parsed
+// records in file order.
+func (u *LogAnalysisUsecase) processLines(lines []string, parse parser.Func, keep bool) (*domain.LogAnalysis, []domain.StoredRecord) {
+	agg := newAnalysisAggregator()
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	jobs := make(chan string, len(lines))
+	// indexed by line, so workers never write the same slot
+	var parsed []domain.StoredRecord
+	if keep {
+		parsed = make([]domain.StoredRecord, len(lines))
+	}
+
+	jobs := make(chan lineJob, len(lines))
 
 	// Producer: kirim semua baris log ke channel
 	go func() {
-		for _, line := range lines {
-			jobs <- line
+		for i, line := range lines {
+			jobs <- lineJob{no: i + 1, line: line}
 		}
 		close(jobs)
 	}()
@@ -69,13 +255,16 @@ func (u *LogAnalysisUsecase) ProcessLogs(lines []string) (*domain.LogAnalysis, e
 		go func(workerID int) {
 			defer wg.Done()
 			processed := 0
-			for line := range jobs {
-				log := parseLineToLogAnalysis(line)
-				mu.Lock()
-				totalRequests += log.TotalRequests
-				errorCount += log.ErrorCount
-				ipSet[log.Filename] = struct{}{}
-				mu.Unlock()
+			for job := range jobs {
+				rec, ok := parse(job.line)
+				if ok {
+					mu.Lock()
+					agg.Add(rec)
+					mu.Unlock()
+					if keep {
+						parsed[job.no-1] = domain.StoredRecord{Line: int64(job.no), LogRecord: rec}
+					}
+				}
 				processed++
 				if processed%10 == 0 {
 					progressChan <- processed
@@ -95,23 +284,42 @@ func (u *LogAnalysisUsecase) ProcessLogs(lines []string) (*domain.LogAnalysis, e
 	wg.Wait()
 	close(progressChan)
 
-	analysis := &domain.LogAnalysis{
-		Filename:        "uploaded_file.log",
-		TotalRequests:   totalRequests,
-		ErrorCount:      errorCount,
-		UniqueIPs:       len(ipSet),
-		AverageResponse: 0,
-	}
+	analysis := agg.Result()
+	analysis.Filename = "uploaded_file.log"
 
 	fmt.Println("[Analysis] ✅ Completed log analysis successfully")
 	fmt.Printf("[Analysis] Total Requests: %d | Errors: %d | Unique IPs: %d\n",
-		totalRequests, errorCount, len(ipSet))
+		analysis.TotalRequests, analysis.ErrorCount, analysis.UniqueIPs)
 
-	return analysis, nil
+	// buang baris yang gagal di-parse
+	records := parsed[:0]
+	for _, rec := range parsed {
+		if rec.Line != 0 {
+			records = append(records, rec)
+		}
+	}
+	return analysis, records
+}
+
+// UploadOptions are the optional settings sent along with an uploaded file.
+type UploadOptions struct {
+	Labels map[string]string
+	Format string // parser format name, "" = default
+	// RetainRecords overrides RECORDS_RETAIN for this upload, nil = default
+	RetainRecords *bool
 }
 
-// 🧩 ParseAndSaveLog — baca file log & panggil ProcessLogs
-func (u *LogAnalysisUsecase) ParseAndSaveLog(path string, userID uint) error {
+// 🧩 ParseAndSaveLog — baca file log & jalankan worker pool; the analysis
+// goes into the owner's active org.
+func (u *LogAnalysisUsecase) ParseAndSaveLog(path string, owner domain.Actor, opts UploadOptions) error {
+	if err := ValidateLabels(opts.Labels); err != nil {
+		return err
+	}
+	parse, err := parser.Get(opts.Format)
+	if err != nil {
+		return err
+	}
+
 	lines, err := readFileLines(path)
 	if err != nil {
 		return err
@@ -119,13 +327,18 @@ func (u *LogAnalysisUsecase) ParseAndSaveLog(path string, userID uint) error {
 
 	fmt.Printf("[Log Parser] Starting log processing for %d lines...\n", len(lines))
 
-	// jalankan concurrent log analysis
-	analysis, err := u.ProcessLogs(lines)
-	if err != nil {
-		return err
+	retain := u.records.Enabled()
+	if opts.RetainRecords != nil {
+		retain = *opts.RetainRecords && u.records != nil
 	}
 
-	analysis.UserID = userID
+	// jalankan concurrent log analysis
+	analysis, records := u.processLines(lines, countParse("upload", parse), retain)
+
+	analysis.UserID = owner.UserID
+	analysis.OrgID = owner.OrgID
+	analysis.Filename = filepath.Base(path)
+	analysis.Labels = opts.Labels
 
 	// simpan ke database
 	err = u.repo.Create(analysis)
@@ -134,5 +347,14 @@ func (u *LogAnalysisUsecase) ParseAndSaveLog(path string, userID uint) error {
 	}
 
 	fmt.Println("[Database] Log analysis result saved successfully!")
+	analysesSaved.With("upload").Inc()
+	if u.observer != nil {
+		u.observer.Observe(analysis)
+	}
+
+	// records are a drill-down extra: the analysis stays even if this fails
+	if err := u.records.Save(analysis, records); err != nil {
+		fmt.Println("[Records] ❌ failed to store records:", err)
+	}
 	return nil
 }
@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// LoginPolicy limits password guessing. Failures are counted per account
+// and per client IP. After FreeAttempts failures the next attempt has to
+// wait DelayBase, doubling with every failure up to DelayMax; at
+// AccountLockout (or IPLockout) failures the account (or IP) is locked for
+// LockoutDuration. Counters start over after Window without failures.
+type LoginPolicy struct {
+	FreeAttempts    int
+	DelayBase       time.Duration
+	DelayMax        time.Duration
+	AccountLockout  int // 0 = never lock accounts
+	IPLockout       int // 0 = never lock IPs
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+func accountKey(email string) string { return "account:" + strings.ToLower(strings.TrimSpace(email)) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// loginLocks serializes login attempts per account and per IP: otherwise
+// parallel requests all pass guardLogin before the first failure is
+// counted. Several server instances still check one attempt each at a time.
+type loginLocks struct {
+	mu    sync.Mutex
+	locks map[string]*loginLock
+}
+
+type loginLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock takes the locks of keys in order and returns the unlock func.
+func (l *loginLocks) lock(keys ...string) func() {
+	held := make([]func(), 0, len(keys))
+	for _, key := range keys {
+		held = append(held, l.lockOne(key))
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i]()
+		}
+	}
+}
+
+func (l *loginLocks) lockOne(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*loginLock)
+	}
+	k := l.locks[key]
+	if k == nil {
+		k = &loginLock{}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		l.mu.Lock()
+		if k.refs--; k.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// guardLogin refuses the attempt while the account or the IP is locked or
+// still waiting out its delay.
+func (a *AuthUsecase) guardLogin(email, ip string, now time.Time) error {
+	p := a.cfg.Login
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		st, err := a.loginAttempts.Get(key)
+		if err != nil {
+			return err
+		}
+		if st == nil {
+			continue
+		}
+		if st.LockedUntil != nil && st.LockedUntil.After(now) {
+			return &TooManyAttemptsError{RetryAfter: st.LockedUntil.Sub(now), Locked: true}
+		}
+		if now.Sub(st.LastFailure) > p.Window {
+			continue
+		}
+		if next := st.LastFailure.Add(backoff(st.Failures, p.FreeAttempts, p.DelayBase, p.DelayMax)); next.After(now) {
+			return &TooManyAttemptsError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// loginFailed counts a wrong password; u is nil for an unknown email, which
+// is counted all the same so lockouts do not reveal which emails exist.
+func (a *AuthUsecase) loginFailed(u *domain.User, email, ip string, now time.Time) {
+	p := a.cfg.Login
+	for _, l := range []struct {
+		key       string
+		threshold int
+	}{{accountKey(email), p.AccountLockout}, {ipKey(ip), p.IPLockout}} {
+		st, err := a.loginAttempts.Fail(l.key, now, p.Window)
+		if err != nil {
+			log.Printf("[Auth] ❌ failed to count login failure for %s: %v", l.key, err)
+			continue
+		}
+		if l.threshold == 0 || st.Failures < l.threshold {
+			continue
+		}
+		if err := a.loginAttempts.Lock(l.key, now.Add(p.LockoutDuration), p.Window); err != nil {
+			log.Printf("[Auth] ❌ failed to lock %s: %v", l.key, err)
+			continue
+		}
+		log.Printf("[Auth] 🔒 %s locked for %s after %d failed logins", l.key, p.LockoutDuration, st.Failures)
+		entry := &domain.AuditEntry{Action: domain.AuditLoginLocked, IP: ip,
+			Detail: fmt.Sprintf("%s locked after %d failed logins", l.key, st.Failures)}
+		if u != nil {
+			entry.TargetUserID = u.ID
+		}
+		a.record(entry)
+	}
+}
+
+// Unlock clears the failed logins of a user and/or an IP.
+func (a *AuthUsecase) Unlock(adminID, userID uint, lockedIP, ip string) error {
+	if !a.IsAdmin(adminID) {
+		return ErrForbidden
+	}
+	if userID == 0 && lockedIP == "" {
+		return errors.New("user_id or ip is required")
+	}
+	var keys []string
+	if userID != 0 {
+		u, err := a.repo.FindByID(userID)
+		if err != nil {
+			return err
+		}
+		if u == nil {
+			return errors.New("record not found")
+		}
+		keys = append(keys, accountKey(u.Email))
+	}
+	if lockedIP != "" {
+		keys = append(keys, ipKey(lockedIP))
+	}
+	for _, key := range keys {
+		if err := a.loginAttempts.Reset(key); err != nil {
+			return err
+		}
+	}
+	return a.audit.Create(&domain.AuditEntry{ActorID: adminID, Action: domain.AuditLoginUnlocked, TargetUserID: userID,
+		Detail: strings.Join(keys, ", "), IP: ip})
+}
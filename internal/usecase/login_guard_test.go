@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginLocks(t *testing.T) {
+	var l loginLocks
+	unlock := l.lock(accountKey("a@example.com"), ipKey("10.0.0.1"))
+
+	// another attempt from the same IP waits for the first one
+	got := make(chan struct{})
+	go func() {
+		defer close(got)
+		l.lock(accountKey("b@example.com"), ipKey("10.0.0.1"))()
+	}()
+	select {
+	case <-got:
+		t.Fatal("second attempt from the same IP did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// other accounts and IPs are not held up
+	l.lock(accountKey("c@example.com"), ipKey("10.0.0.2"))()
+
+	unlock()
+	<-got
+
+	// parallel attempts on one key run one at a time
+	var wg sync.WaitGroup
+	inside := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer l.lock(accountKey("a@example.com"))()
+			if inside++; inside != 1 {
+				t.Error("two attempts inside the lock")
+			}
+			time.Sleep(time.Millisecond)
+			inside--
+		}()
+	}
+	wg.Wait()
+
+	if len(l.locks) != 0 {
+		t.Errorf("%d locks left after all attempts", len(l.locks))
+	}
+}
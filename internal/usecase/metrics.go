@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/metrics"
+)
+
+var (
+	linesParsed = metrics.NewCounterVec("log_analyzer_lines_parsed_total",
+		"Log lines parsed, by source (upload, push, syslog, tail, ...).", "source")
+	parseFailures = metrics.NewCounterVec("log_analyzer_parse_failures_total",
+		"Log lines that did not match their format, by source.", "source")
+	recordsIngested = metrics.NewCounterVec("log_analyzer_ingested_records_total",
+		"Records applied to stream windows, parsed or pushed structured.")
+	analysesSaved = metrics.NewCounterVec("log_analyzer_analyses_saved_total",
+		"Analyses saved, by kind (upload or window).", "kind")
+)
+
+func init() {
+	metrics.Register(linesParsed, parseFailures, recordsIngested, analysesSaved)
+}
+
+// countParse wraps a parser so every line it sees is counted under source.
+func countParse(source string, parse parser.Func) parser.Func {
+	parsed, failed := linesParsed.With(source), parseFailures.With(source)
+	return func(line string) (domain.LogRecord, bool) {
+		rec, ok := parse(line)
+		parsed.Inc()
+		if !ok {
+			failed.Inc()
+		}
+		return rec, ok
+	}
+}
+
+// StreamParser is the parser of format, counted under source, for push
+// endpoints that parse lines themselves.
+func StreamParser(source, format string) (parser.Func, error) {
+	parse, err := parser.Get(format)
+	if err != nil {
+		return nil, err
+	}
+	return countParse(source, parse), nil
+}
+
+// AnalysisObserver is told about every saved analysis: uploads once,
+// stream windows each time they are flushed.
+type AnalysisObserver interface {
+	Observe(a *domain.LogAnalysis)
+}
+
+// Observers fans an analysis out to several observers.
+type Observers []AnalysisObserver
+
+func (o Observers) Observe(a *domain.LogAnalysis) {
+	for _, obs := range o {
+		obs.Observe(a)
+	}
+}
@@ -0,0 +1,405 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/mailer"
+)
+
+const orgInvitationTTL = 7 * 24 * time.Hour
+
+// ErrNotOrgMember is returned when the user does not belong to the org.
+var ErrNotOrgMember = errors.New("you are not a member of this organization")
+
+// ErrNotOrgAdmin is returned when an org change needs its admin role.
+var ErrNotOrgAdmin = errors.New("only an org admin can do this")
+
+var errInvalidInvitation = errors.New("invalid or expired invitation")
+
+// ActorFor resolves the user in their active org. If they left it (or never
+// had one) the first org they belong to becomes active, or a personal org
+// is created for them.
+func (a *AuthUsecase) ActorFor(u *domain.User) (domain.Actor, error) {
+	actor := domain.Actor{UserID: u.ID, Role: u.Role}
+	if u.ActiveOrgID != 0 {
+		m, err := a.orgs.GetMember(u.ActiveOrgID, u.ID)
+		if err != nil {
+			return actor, err
+		}
+		if m != nil {
+			actor.OrgID, actor.OrgRole = m.OrgID, m.Role
+			return actor, nil
+		}
+	}
+	orgs, err := a.orgs.ListForUser(u.ID)
+	if err != nil {
+		return actor, err
+	}
+	var org *domain.Organization
+	if len(orgs) > 0 {
+		org = &orgs[0]
+	} else if org, err = a.personalOrg(u); err != nil {
+		return actor, err
+	}
+	u.ActiveOrgID = org.ID
+	if err := a.repo.Update(u); err != nil {
+		return actor, err
+	}
+	actor.OrgID, actor.OrgRole = org.ID, org.Role
+	return actor, nil
+}
+
+// OwnerActor is ActorFor by user ID, for data collected without a request
+// (syslog, forward, GELF, tail).
+func (a *AuthUsecase) OwnerActor(userID uint) (domain.Actor, error) {
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+	if u == nil {
+		return domain.Actor{}, fmt.Errorf("user %d not found", userID)
+	}
+	return a.ActorFor(u)
+}
+
+// IngestOwner re-reads the collector owner in orgID, the org OwnerActor
+// picked at startup. Like VerifyAPIKey it fails once the user is disabled,
+// unverified (with RequireVerifiedEmail) or no longer a member, and the org
+// role is the current one.
+func (a *AuthUsecase) IngestOwner(userID, orgID uint) (domain.Actor, error) {
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+	if u == nil || u.DisabledAt != nil {
+		return domain.Actor{}, fmt.Errorf("ingest user %d not found or disabled", userID)
+	}
+	if a.cfg.RequireVerifiedEmail && !u.EmailVerified {
+		return domain.Actor{}, fmt.Errorf("ingest user %d: %w", userID, ErrEmailNotVerified)
+	}
+	m, err := a.orgs.GetMember(orgID, userID)
+	if err != nil {
+		return domain.Actor{}, err
+	}
+	if m == nil {
+		return domain.Actor{}, fmt.Errorf("ingest user %d, org %d: %w", userID, orgID, ErrNotOrgMember)
+	}
+	return domain.Actor{UserID: u.ID, Role: u.Role, OrgID: orgID, OrgRole: m.Role}, nil
+}
+
+func (a *AuthUsecase) personalOrg(u *domain.User) (*domain.Organization, error) {
+	org := &domain.Organization{Name: displayName(u), CreatedBy: u.ID}
+	if err := a.orgs.Create(org); err != nil {
+		return nil, err
+	}
+	log.Printf("[Orgs] personal org %d created for userID %d", org.ID, u.ID)
+	return org, nil
+}
+
+// CreateOrg creates an org with the user as its admin.
+func (a *AuthUsecase) CreateOrg(userID uint, name string) (*domain.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	org := &domain.Organization{Name: name, CreatedBy: userID}
+	if err := a.orgs.Create(org); err != nil {
+		return nil, err
+	}
+	log.Printf("[Orgs] org %d %q created by userID %d", org.ID, org.Name, userID)
+	return org, nil
+}
+
+// ListOrgs returns the orgs of the user, each with their role in it.
+func (a *AuthUsecase) ListOrgs(userID uint) ([]domain.Organization, error) {
+	return a.orgs.ListForUser(userID)
+}
+
+// SwitchOrg makes orgID the active org of the user. Tokens carry the org, so
+// the calling session is ended and a new one started in the new org.
+func (a *AuthUsecase) SwitchOrg(userID, orgID uint, jti, sessionID string, expiresAt time.Time) (*domain.TokenPair, *domain.Organization, error) {
+	m, err := a.orgs.GetMember(orgID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil {
+		return nil, nil, ErrNotOrgMember
+	}
+	org, err := a.orgs.GetByID(orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org == nil {
+		return nil, nil, errors.New("record not found")
+	}
+	org.Role = m.Role
+
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, errors.New("record not found")
+	}
+	if u.DisabledAt != nil {
+		return nil, nil, ErrAccountDisabled
+	}
+	u.ActiveOrgID = orgID
+	if err := a.repo.Update(u); err != nil {
+		return nil, nil, err
+	}
+	if err := a.Logout(userID, jti, sessionID, expiresAt); err != nil {
+		return nil, nil, err
+	}
+	newSession, err := randomToken(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := a.issueTokens(u, newSession)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, org, nil
+}
+
+// member returns the caller's membership of orgID; site admins may look at
+// every org.
+func (a *AuthUsecase) member(userID, orgID uint) (*domain.OrgMember, error) {
+	m, err := a.orgs.GetMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return m, nil
+	}
+	if a.IsAdmin(userID) {
+		org, err := a.orgs.GetByID(orgID)
+		if err != nil {
+			return nil, err
+		}
+		if org == nil {
+			return nil, errors.New("record not found")
+		}
+		return &domain.OrgMember{OrgID: orgID, UserID: userID, Role: domain.RoleAdmin}, nil
+	}
+	return nil, ErrNotOrgMember
+}
+
+// orgAdmin checks that the caller manages orgID. The role is read from the
+// database, not the token.
+func (a *AuthUsecase) orgAdmin(userID, orgID uint) error {
+	m, err := a.member(userID, orgID)
+	if err != nil {
+		return err
+	}
+	if m.Role != domain.RoleAdmin {
+		return ErrNotOrgAdmin
+	}
+	return nil
+}
+
+// ===================== MEMBERS =====================
+
+func (a *AuthUsecase) Members(userID, orgID uint) ([]domain.OrgMember, error) {
+	if _, err := a.member(userID, orgID); err != nil {
+		return nil, err
+	}
+	return a.orgs.Members(orgID)
+}
+
+// SetMemberRole changes the role of a member. Their sessions are revoked so
+// the new role applies right away; the last admin cannot be demoted.
+func (a *AuthUsecase) SetMemberRole(userID, orgID, memberID uint, role, ip string) (*domain.OrgMember, error) {
+	if !domain.ValidRole(role) {
+		return nil, errors.New("role must be admin, analyst or viewer")
+	}
+	if err := a.orgAdmin(userID, orgID); err != nil {
+		return nil, err
+	}
+	m, err := a.orgs.GetMember(orgID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("record not found")
+	}
+	if m.Role == role {
+		return m, nil
+	}
+	if m.Role == domain.RoleAdmin {
+		if err := a.keepOrgAdmin(orgID); err != nil {
+			return nil, err
+		}
+	}
+	detail := fmt.Sprintf("org %d: %s -> %s", orgID, m.Role, role)
+	if err := a.orgs.SetMemberRole(orgID, memberID, role); err != nil {
+		return nil, err
+	}
+	m.Role = role
+	if err := a.revokeUser(memberID); err != nil {
+		return nil, err
+	}
+	log.Printf("[Orgs] role of userID %d changed by userID %d: %s", memberID, userID, detail)
+	a.record(&domain.AuditEntry{ActorID: userID, Action: domain.AuditOrgMemberRole, TargetUserID: memberID, Detail: detail, IP: ip})
+	return m, nil
+}
+
+// RemoveMember takes a member out of the org; members may also leave on
+// their own. The last admin has to hand over first.
+func (a *AuthUsecase) RemoveMember(userID, orgID, memberID uint, ip string) error {
+	if userID != memberID {
+		if err := a.orgAdmin(userID, orgID); err != nil {
+			return err
+		}
+	}
+	m, err := a.orgs.GetMember(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New("record not found")
+	}
+	if m.Role == domain.RoleAdmin {
+		if err := a.keepOrgAdmin(orgID); err != nil {
+			return err
+		}
+	}
+	if err := a.orgs.RemoveMember(orgID, memberID); err != nil {
+		return err
+	}
+	if err := a.revokeUser(memberID); err != nil {
+		return err
+	}
+	log.Printf("[Orgs] userID %d removed from org %d by userID %d", memberID, orgID, userID)
+	a.record(&domain.AuditEntry{ActorID: userID, Action: domain.AuditOrgMemberRemoved, TargetUserID: memberID, Detail: fmt.Sprintf("org %d", orgID), IP: ip})
+	return nil
+}
+
+func (a *AuthUsecase) keepOrgAdmin(orgID uint) error {
+	n, err := a.orgs.CountAdmins(orgID)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return errors.New("an organization needs at least one admin")
+	}
+	return nil
+}
+
+// ===================== INVITATIONS =====================
+
+// Invite emails a token to join orgID with role. The token is also returned,
+// once, to hand over another way.
+func (a *AuthUsecase) Invite(userID, orgID uint, email, role string) (*domain.OrgInvitation, string, error) {
+	email = strings.TrimSpace(email)
+	if !domain.ValidRole(role) {
+		return nil, "", errors.New("role must be admin, analyst or viewer")
+	}
+	if err := a.orgAdmin(userID, orgID); err != nil {
+		return nil, "", err
+	}
+	org, err := a.orgs.GetByID(orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	if org == nil {
+		return nil, "", errors.New("record not found")
+	}
+	if u, err := a.repo.FindByEmail(email); err != nil {
+		return nil, "", err
+	} else if u != nil {
+		if m, err := a.orgs.GetMember(orgID, u.ID); err != nil {
+			return nil, "", err
+		} else if m != nil {
+			return nil, "", errors.New("already a member of this organization")
+		}
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	inv := &domain.OrgInvitation{
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		TokenHash: hashToken(token),
+		InvitedBy: userID,
+		ExpiresAt: time.Now().Add(orgInvitationTTL),
+	}
+	if err := a.orgs.CreateInvitation(inv); err != nil {
+		return nil, "", err
+	}
+	a.sendMail(mailer.Message{
+		To:      []string{email},
+		Subject: fmt.Sprintf("You are invited to %s", org.Name),
+		Text: fmt.Sprintf("Hi,\n\nyou are invited to join %q as %s.\n\nInvitation token: %s\n\n"+
+			"Log in (or register with this address) and send it to POST %s/api/invitations/accept "+
+			`({"token": "..."}).`+"\n\nThe invitation expires in %d days.\n",
+			org.Name, role, token, strings.TrimRight(a.cfg.AppURL, "/"), int(orgInvitationTTL.Hours()/24)),
+	})
+	log.Printf("[Orgs] userID %d invited %s to org %d as %s", userID, email, orgID, role)
+	return inv, token, nil
+}
+
+func (a *AuthUsecase) ListInvitations(userID, orgID uint) ([]domain.OrgInvitation, error) {
+	if err := a.orgAdmin(userID, orgID); err != nil {
+		return nil, err
+	}
+	return a.orgs.ListInvitations(orgID)
+}
+
+func (a *AuthUsecase) RevokeInvitation(userID, orgID, id uint) error {
+	if err := a.orgAdmin(userID, orgID); err != nil {
+		return err
+	}
+	return a.orgs.DeleteInvitation(orgID, id)
+}
+
+// AcceptInvitation adds the user to the org of the invitation. It must be
+// addressed to the user's email and works once.
+func (a *AuthUsecase) AcceptInvitation(userID uint, token, ip string) (*domain.Organization, error) {
+	inv, err := a.orgs.GetInvitationByHash(hashToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.AcceptedAt != nil || time.Now().After(inv.ExpiresAt) {
+		return nil, errInvalidInvitation
+	}
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || !strings.EqualFold(u.Email, inv.Email) {
+		return nil, errors.New("this invitation is for another email address")
+	}
+	if m, err := a.orgs.GetMember(inv.OrgID, userID); err != nil {
+		return nil, err
+	} else if m != nil {
+		return nil, errors.New("already a member of this organization")
+	}
+	ok, err := a.orgs.AcceptInvitation(inv, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidInvitation
+	}
+	org, err := a.orgs.GetByID(inv.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, errors.New("record not found")
+	}
+	org.Role = inv.Role
+	log.Printf("[Orgs] userID %d joined org %d as %s", userID, inv.OrgID, inv.Role)
+	a.record(&domain.AuditEntry{ActorID: userID, Action: domain.AuditOrgMemberAdded, TargetUserID: userID,
+		Detail: fmt.Sprintf("org %d as %s (invited by userID %d)", inv.OrgID, inv.Role, inv.InvitedBy), IP: ip})
+	return org, nil
+}
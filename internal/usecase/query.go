@@ -0,0 +1,714 @@
+package usecase
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/parser"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/query"
+)
+
+const (
+	defaultAggregateLimit = 100
+	maxAggregateLimit     = 1000
+	maxQueryLength        = 4096
+	maxQueryColumns       = 32
+	maxQueryGroups        = 100000 // in-memory evaluation only
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindTime
+)
+
+// queryFields are the record fields a query can use; attributes are
+// attr.<key> and always strings.
+var queryFields = map[string]fieldKind{
+	"time":    kindTime,
+	"method":  kindString,
+	"path":    kindString,
+	"status":  kindNumber,
+	"latency": kindNumber,
+	"ip":      kindString,
+	"level":   kindString,
+	"message": kindString,
+	"raw":     kindString,
+}
+
+func queryFieldKind(field string) (fieldKind, error) {
+	if kind, ok := queryFields[field]; ok {
+		return kind, nil
+	}
+	if _, ok := query.AttrKey(field); ok {
+		return kindString, nil
+	}
+	return 0, fmt.Errorf("unknown field %q (use time, method, path, status, latency, ip, level, message, raw or attr.<key>)", field)
+}
+
+// ParseQuery parses a query and checks it against the record fields. The
+// limit is set to the default when the query has none.
+func ParseQuery(s string) (*query.Query, error) {
+	if len(s) > maxQueryLength {
+		return nil, fmt.Errorf("query is longer than %d bytes", maxQueryLength)
+	}
+	q, err := query.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Select) > maxQueryColumns {
+		return nil, fmt.Errorf("query selects more than %d columns", maxQueryColumns)
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultAggregateLimit
+	}
+	if q.Limit > maxAggregateLimit {
+		return nil, fmt.Errorf("LIMIT must be at most %d", maxAggregateLimit)
+	}
+
+	grouped := make(map[string]bool, len(q.GroupBy))
+	for _, t := range q.GroupBy {
+		if err := checkTerm(t); err != nil {
+			return nil, err
+		}
+		grouped[t.String()] = true
+	}
+	aggregated := q.Aggregated()
+	for _, c := range q.Select {
+		if c.Agg == "" {
+			if err := checkTerm(c.Term); err != nil {
+				return nil, err
+			}
+			if aggregated && !grouped[c.Term.String()] {
+				return nil, fmt.Errorf("%s must be in GROUP BY or inside an aggregate", c.Term)
+			}
+			continue
+		}
+		if c.Term.Field == "*" {
+			continue
+		}
+		if err := checkTerm(c.Term); err != nil {
+			return nil, err
+		}
+		kind, _ := queryFieldKind(c.Term.Field)
+		if c.Term.Bucket > 0 {
+			kind = kindTime
+		}
+		switch c.Agg {
+		case "sum", "avg", "percentile":
+			if kind != kindNumber {
+				return nil, fmt.Errorf("%s needs a numeric field (status, latency)", c.Name())
+			}
+		case "min", "max":
+			if kind == kindString {
+				return nil, fmt.Errorf("%s needs a numeric field or time", c.Name())
+			}
+		}
+	}
+	if q.Where != nil {
+		if err := checkExpr(q.Where); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func checkTerm(t query.Term) error {
+	kind, err := queryFieldKind(t.Field)
+	if err != nil {
+		return err
+	}
+	if t.Bucket > 0 {
+		if kind != kindTime {
+			return fmt.Errorf("bucket needs the time field, not %s", t.Field)
+		}
+		if t.Bucket < time.Second {
+			return errors.New("bucket must be at least 1s")
+		}
+		// SQL buckets by whole epoch seconds
+		if t.Bucket%time.Second != 0 {
+			return errors.New("bucket must be a whole number of seconds")
+		}
+	}
+	return nil
+}
+
+func checkExpr(e query.Expr) error {
+	switch e := e.(type) {
+	case *query.Logical:
+		if err := checkExpr(e.Left); err != nil {
+			return err
+		}
+		return checkExpr(e.Right)
+	case *query.Not:
+		return checkExpr(e.X)
+	case *query.Compare:
+		kind, err := queryFieldKind(e.Field)
+		if err != nil {
+			return err
+		}
+		switch e.Op {
+		case "like", "not like", "~", "!~":
+			if kind != kindString {
+				return fmt.Errorf("%s: %s only works on text fields", e, strings.ToUpper(e.Op))
+			}
+			if e.Values[0].IsNum {
+				return fmt.Errorf("%s: pattern must be a 'string'", e)
+			}
+			if e.Op == "~" || e.Op == "!~" {
+				if _, err := regexp.Compile(e.Values[0].Str); err != nil {
+					return fmt.Errorf("%s: %w", e, err)
+				}
+			}
+			return nil
+		}
+		for _, v := range e.Values {
+			switch {
+			case kind == kindNumber && !v.IsNum:
+				return fmt.Errorf("%s: %s is a number", e, e.Field)
+			case kind == kindString && v.IsNum:
+				return fmt.Errorf("%s: %s is text, quote the value", e, e.Field)
+			case kind == kindTime:
+				if v.IsNum {
+					return fmt.Errorf("%s: time must be an RFC3339 'string'", e)
+				}
+				if _, err := time.Parse(time.RFC3339, v.Str); err != nil {
+					return fmt.Errorf("%s: time must be RFC3339", e)
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported expression %s", e)
+}
+
+// LikePattern turns a LIKE pattern (% and _, \ escapes) into an anchored
+// regexp.
+func LikePattern(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// ===================== IN-MEMORY EVALUATOR =====================
+
+// QueryReader runs a query over newline-delimited lines parsed with the
+// given format, e.g. a file that was not retained.
+func QueryReader(r io.Reader, format string, q *query.Query) (*domain.QueryResult, error) {
+	parse, err := parser.Get(format)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := newQueryEvaluator(q)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxIngestLineBytes)
+	for scanner.Scan() {
+		rec, ok := parse(scanner.Text())
+		if !ok {
+			continue
+		}
+		if err := ev.Add(rec); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ev.Result(), nil
+}
+
+// queryEvaluator runs a checked query over records one at a time, with the
+// same semantics as the SQL it compiles to: missing values are NULL, never
+// match a comparison and are skipped by aggregates.
+type queryEvaluator struct {
+	q      *query.Query
+	where  func(domain.LogRecord) bool
+	groups map[string]*queryGroup
+	order  []*queryGroup // first-seen order
+	rows   [][]interface{}
+	times  []time.Time // time of each row, kept without ORDER BY
+}
+
+type queryGroup struct {
+	terms []interface{}
+	aggs  []*aggState
+}
+
+type aggState struct {
+	count    int64
+	sum      float64
+	min, max interface{}
+	values   []float64
+	distinct map[string]struct{}
+}
+
+func newQueryEvaluator(q *query.Query) (*queryEvaluator, error) {
+	ev := &queryEvaluator{q: q, where: func(domain.LogRecord) bool { return true }, groups: make(map[string]*queryGroup)}
+	if q.Where != nil {
+		where, err := compileWhere(q.Where)
+		if err != nil {
+			return nil, err
+		}
+		ev.where = where
+	}
+	return ev, nil
+}
+
+func (ev *queryEvaluator) Add(rec domain.LogRecord) error {
+	if !ev.where(rec) {
+		return nil
+	}
+	if !ev.q.Aggregated() {
+		if len(ev.q.OrderBy) > 0 && len(ev.rows) >= maxQueryGroups {
+			return fmt.Errorf("more than %d matching records to sort, narrow the WHERE clause", maxQueryGroups)
+		}
+		row := make([]interface{}, len(ev.q.Select))
+		for i, c := range ev.q.Select {
+			row[i] = termValue(rec, c.Term)
+		}
+		if len(ev.q.OrderBy) == 0 {
+			ev.keepEarliest(rec.Time, row)
+			return nil
+		}
+		ev.rows = append(ev.rows, row)
+		return nil
+	}
+
+	terms := make([]interface{}, len(ev.q.GroupBy))
+	var key strings.Builder
+	for i, t := range ev.q.GroupBy {
+		terms[i] = termValue(rec, t)
+		key.WriteString(valueKey(terms[i]))
+		key.WriteByte(0)
+	}
+	g, ok := ev.groups[key.String()]
+	if !ok {
+		if len(ev.groups) >= maxQueryGroups {
+			return fmt.Errorf("more than %d groups", maxQueryGroups)
+		}
+		g = &queryGroup{terms: terms, aggs: make([]*aggState, len(ev.q.Select))}
+		for i := range g.aggs {
+			g.aggs[i] = &aggState{}
+		}
+		ev.groups[key.String()] = g
+		ev.order = append(ev.order, g)
+	}
+	for i, c := range ev.q.Select {
+		if c.Agg == "" {
+			continue
+		}
+		var v interface{} = true // count(*)
+		if c.Term.Field != "*" {
+			v = termValue(rec, c.Term)
+		}
+		g.aggs[i].add(c.Agg, v)
+	}
+	return nil
+}
+
+// keepEarliest keeps the first Limit rows by time, rows without a time
+// first and ties in input order, like the SQL default ORDER BY time NULLS
+// FIRST, line_no. Files are mostly in time order, so most rows past the
+// limit are dropped at once.
+func (ev *queryEvaluator) keepEarliest(t time.Time, row []interface{}) {
+	// the zero time sorts before any real one
+	i := sort.Search(len(ev.times), func(i int) bool { return ev.times[i].After(t) })
+	if i >= ev.q.Limit {
+		return
+	}
+	ev.rows = slices.Insert(ev.rows, i, row)
+	ev.times = slices.Insert(ev.times, i, t)
+	if len(ev.rows) > ev.q.Limit {
+		ev.rows, ev.times = ev.rows[:ev.q.Limit], ev.times[:ev.q.Limit]
+	}
+}
+
+func (a *aggState) add(agg string, v interface{}) {
+	if v == nil {
+		return
+	}
+	a.count++
+	switch agg {
+	case "count_distinct":
+		if a.distinct == nil {
+			a.distinct = make(map[string]struct{})
+		}
+		a.distinct[valueKey(v)] = struct{}{}
+	case "sum", "avg":
+		a.sum += v.(float64)
+	case "percentile":
+		a.values = append(a.values, v.(float64))
+	case "min":
+		if a.min == nil || compareValues(v, a.min) < 0 {
+			a.min = v
+		}
+	case "max":
+		if a.max == nil || compareValues(v, a.max) > 0 {
+			a.max = v
+		}
+	}
+}
+
+func (a *aggState) result(c query.Column) interface{} {
+	switch c.Agg {
+	case "count":
+		return a.count
+	case "count_distinct":
+		return int64(len(a.distinct))
+	case "sum":
+		if a.count == 0 {
+			return nil
+		}
+		return a.sum
+	case "avg":
+		if a.count == 0 {
+			return nil
+		}
+		return a.sum / float64(a.count)
+	case "min":
+		return a.min
+	case "max":
+		return a.max
+	case "percentile":
+		return percentileCont(a.values, c.Percentile)
+	}
+	return nil
+}
+
+// percentileCont interpolates like Postgres percentile_cont
+func percentileCont(values []float64, p float64) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	pos := p * float64(len(values)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(values) {
+		return values[lo]
+	}
+	return values[lo] + (values[lo+1]-values[lo])*(pos-float64(lo))
+}
+
+func (ev *queryEvaluator) Result() *domain.QueryResult {
+	res := &domain.QueryResult{Rows: [][]interface{}{}}
+	for _, c := range ev.q.Select {
+		res.Columns = append(res.Columns, c.Name())
+	}
+
+	rows := ev.rows
+	if ev.q.Aggregated() {
+		// an aggregate without GROUP BY still returns one row
+		if len(ev.order) == 0 && len(ev.q.GroupBy) == 0 {
+			g := &queryGroup{aggs: make([]*aggState, len(ev.q.Select))}
+			for i := range g.aggs {
+				g.aggs[i] = &aggState{}
+			}
+			ev.order = append(ev.order, g)
+		}
+		position := make(map[string]int, len(ev.q.GroupBy))
+		for i, t := range ev.q.GroupBy {
+			position[t.String()] = i
+		}
+		rows = make([][]interface{}, 0, len(ev.order))
+		for _, g := range ev.order {
+			row := make([]interface{}, len(ev.q.Select))
+			for i, c := range ev.q.Select {
+				if c.Agg == "" {
+					row[i] = g.terms[position[c.Term.String()]]
+				} else {
+					row[i] = g.aggs[i].result(c)
+				}
+			}
+			rows = append(rows, row)
+		}
+		if len(ev.q.OrderBy) == 0 {
+			sortGroups(rows, ev.q)
+		}
+	}
+
+	if len(ev.q.OrderBy) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, o := range ev.q.OrderBy {
+				c := compareValues(rows[i][o.Column], rows[j][o.Column])
+				if c == 0 {
+					continue
+				}
+				return (c < 0) != o.Desc
+			}
+			return false
+		})
+	}
+	if len(rows) > ev.q.Limit {
+		rows = rows[:ev.q.Limit]
+	}
+	res.Rows = append(res.Rows, rows...)
+	return res
+}
+
+// sortGroups orders groups by their selected GROUP BY columns, like the
+// default ORDER BY of the SQL form
+func sortGroups(rows [][]interface{}, q *query.Query) {
+	var cols []int
+	for _, t := range q.GroupBy {
+		for i, c := range q.Select {
+			if c.Agg == "" && c.Term.String() == t.String() {
+				cols = append(cols, i)
+				break
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, col := range cols {
+			if c := compareValues(rows[i][col], rows[j][col]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// termValue is a record value: string, float64, time.Time or nil
+func termValue(rec domain.LogRecord, t query.Term) interface{} {
+	if t.Bucket > 0 {
+		if rec.Time.IsZero() {
+			return nil
+		}
+		return bucketStart(rec.Time, t.Bucket)
+	}
+	switch t.Field {
+	case "time":
+		if rec.Time.IsZero() {
+			return nil
+		}
+		return rec.Time.UTC()
+	case "method":
+		return rec.Method
+	case "path":
+		return rec.Path
+	case "status":
+		return float64(rec.Status)
+	case "latency":
+		return rec.Latency
+	case "ip":
+		return rec.IP
+	case "level":
+		return rec.Level
+	case "message":
+		return rec.Message
+	case "raw":
+		return rec.Raw
+	}
+	if key, ok := query.AttrKey(t.Field); ok {
+		if v, ok := rec.Attributes[key]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// bucketStart floors t to a multiple of d counted from the Unix epoch, as
+// the SQL form does; time.Truncate counts from year 1, which differs for
+// buckets that do not divide a day.
+func bucketStart(t time.Time, d time.Duration) time.Time {
+	secs := int64(d / time.Second)
+	unix := t.Unix()
+	start := unix - unix%secs
+	if unix%secs < 0 {
+		start -= secs
+	}
+	return time.Unix(start, 0).UTC()
+}
+
+func valueKey(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "\x01"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano(), 10)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// compareValues orders nil first, then by value; both sides have the same
+// type otherwise
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch a := a.(type) {
+	case float64:
+		return cmpFloat(a, toFloat(b))
+	case int64:
+		return cmpFloat(float64(a), toFloat(b))
+	case time.Time:
+		if bt, ok := b.(time.Time); ok {
+			return a.Compare(bt)
+		}
+	case string:
+		if bs, ok := b.(string); ok {
+			return strings.Compare(a, bs)
+		}
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compileWhere turns a checked expression into a predicate
+func compileWhere(e query.Expr) (func(domain.LogRecord) bool, error) {
+	switch e := e.(type) {
+	case *query.Logical:
+		left, err := compileWhere(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileWhere(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == "and" {
+			return func(r domain.LogRecord) bool { return left(r) && right(r) }, nil
+		}
+		return func(r domain.LogRecord) bool { return left(r) || right(r) }, nil
+	case *query.Not:
+		x, err := compileWhere(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return func(r domain.LogRecord) bool { return !x(r) }, nil
+	case *query.Compare:
+		return compileCompare(e)
+	}
+	return nil, fmt.Errorf("unsupported expression %s", e)
+}
+
+func compileCompare(e *query.Compare) (func(domain.LogRecord) bool, error) {
+	term := query.Term{Field: e.Field}
+	kind, err := queryFieldKind(e.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Op {
+	case "like", "not like", "~", "!~":
+		pattern := e.Values[0].Str
+		if strings.HasSuffix(e.Op, "like") {
+			pattern = LikePattern(pattern)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		want := e.Op == "like" || e.Op == "~"
+		return func(r domain.LogRecord) bool {
+			s, ok := termValue(r, term).(string)
+			return ok && re.MatchString(s) == want
+		}, nil
+	}
+
+	values := make([]interface{}, len(e.Values))
+	for i, lit := range e.Values {
+		switch {
+		case kind == kindTime:
+			t, _ := time.Parse(time.RFC3339, lit.Str)
+			values[i] = t
+		case lit.IsNum:
+			values[i] = lit.Num
+		default:
+			values[i] = lit.Str
+		}
+	}
+
+	op := e.Op
+	return func(r domain.LogRecord) bool {
+		v := termValue(r, term)
+		if v == nil {
+			return false
+		}
+		switch op {
+		case "in", "not in":
+			found := false
+			for _, want := range values {
+				if compareValues(v, want) == 0 {
+					found = true
+					break
+				}
+			}
+			return found == (op == "in")
+		}
+		c := compareValues(v, values[0])
+		switch op {
+		case "=":
+			return c == 0
+		case "!=":
+			return c != 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+)
+
+// TestQuerySQLAgrees runs queryCases through the SQL form and expects the
+// rows of the in-memory evaluator. It needs Postgres in TEST_DATABASE_URL,
+// e.g. postgres://postgres@localhost/test?sslmode=disable, and works in a
+// schema of its own that is dropped afterwards.
+func TestQuerySQLAgrees(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// search_path is per connection
+	db.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("query_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema + ", public"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		db.Close()
+	})
+
+	migrations, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("no migrations: %v", err)
+	}
+	sort.Strings(migrations)
+	for _, m := range migrations {
+		ddl, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(ddl)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(m), err)
+		}
+	}
+
+	repo := repository.NewRecordRepository(db, 7)
+	stored := make([]domain.StoredRecord, len(queryTestRecords))
+	for i, rec := range queryTestRecords {
+		stored[i] = domain.StoredRecord{AnalysisID: 1, Line: int64(i + 1), LogRecord: rec}
+	}
+	if err := repo.Insert(&domain.LogAnalysis{ID: 1, UserID: 1, OrgID: 1}, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range queryCases {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		res, err := repo.Aggregate(domain.QueryScope{OrgID: 1}, q)
+		if err != nil {
+			t.Errorf("%s: %v", c.query, err)
+			continue
+		}
+		if got, want := sqlComparable(res.Rows), sqlComparable(c.rows); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s\n SQL %v\nwant %v", c.query, got, want)
+		}
+	}
+}
+
+// sqlComparable maps values to what both forms share: Postgres returns
+// integers for int columns and times in the session time zone.
+func sqlComparable(rows [][]interface{}) [][]interface{} {
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		out[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			switch v := v.(type) {
+			case int64:
+				out[i][j] = float64(v)
+			case time.Time:
+				out[i][j] = v.UTC()
+			default:
+				out[i][j] = v
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/query"
+)
+
+var queryT0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// queryTestRecords are out of time order, with one record without a time,
+// mixed-case paths and a missing attribute.
+var queryTestRecords = []domain.LogRecord{
+	{Time: queryT0.Add(10 * time.Second), Method: "GET", Path: "/api/orders", Status: 200, Latency: 120, IP: "10.0.0.1", Level: "info", Attributes: map[string]string{"user": "ann"}},
+	{Time: queryT0.Add(5 * time.Second), Method: "POST", Path: "/api/orders", Status: 500, Latency: 800, IP: "10.0.0.2", Level: "error", Attributes: map[string]string{"user": "bob"}},
+	{Time: queryT0.Add(70 * time.Second), Method: "GET", Path: "/Api/users", Status: 404, Latency: 30, IP: "10.0.0.1", Level: "warning"},
+	{Method: "GET", Path: "/health", Status: 200, Latency: 1, IP: "10.0.0.3", Level: "info"},
+	{Time: queryT0.Add(130 * time.Second), Method: "GET", Path: "/api/orders", Status: 503, Latency: 1500, IP: "10.0.0.2", Level: "error", Attributes: map[string]string{"user": "ann"}},
+	{Time: queryT0.Add(65 * time.Second), Method: "DELETE", Path: "/api/orders/7", Status: 204, Latency: 45, IP: "10.0.0.4", Level: "info"},
+	{Time: queryT0.Add(200 * time.Second), Method: "GET", Path: "/api/users", Status: 200, Latency: 60, IP: "10.0.0.1", Level: "info"},
+}
+
+// queryCases hold the expected rows over queryTestRecords. The SQL form
+// must return the same (see TestQuerySQLAgrees).
+var queryCases = []struct {
+	query string
+	rows  [][]interface{}
+}{
+	{
+		// without ORDER BY: the earliest records, the one without a time first
+		"SELECT path, status LIMIT 3",
+		[][]interface{}{{"/health", 200.0}, {"/api/orders", 500.0}, {"/api/orders", 200.0}},
+	},
+	{
+		"SELECT path, count(*), avg(latency) WHERE status >= 500 GROUP BY path",
+		[][]interface{}{{"/api/orders", int64(2), 1150.0}},
+	},
+	{
+		"SELECT method, count(*) AS n GROUP BY method ORDER BY n DESC, method",
+		[][]interface{}{{"GET", int64(5)}, {"DELETE", int64(1)}, {"POST", int64(1)}},
+	},
+	{
+		// byte order, not the locale's
+		"SELECT path GROUP BY path",
+		[][]interface{}{{"/Api/users"}, {"/api/orders"}, {"/api/orders/7"}, {"/api/users"}, {"/health"}},
+	},
+	{
+		"SELECT bucket(time, 1m), count(*) GROUP BY bucket(time, 1m)",
+		[][]interface{}{
+			{nil, int64(1)},
+			{queryT0, int64(2)},
+			{queryT0.Add(time.Minute), int64(2)},
+			{queryT0.Add(2 * time.Minute), int64(1)},
+			{queryT0.Add(3 * time.Minute), int64(1)},
+		},
+	},
+	{
+		// a missing attribute is NULL and matches no comparison
+		"SELECT attr.user, count(*), max(latency) WHERE attr.user != 'bob' GROUP BY attr.user",
+		[][]interface{}{{"ann", int64(2), 1500.0}},
+	},
+	{
+		"SELECT count(*), count_distinct(ip), min(status), max(time), p50(latency), p75(latency)",
+		[][]interface{}{{int64(7), int64(4), 200.0, queryT0.Add(200 * time.Second), 60.0, 460.0}},
+	},
+	{
+		"SELECT path, latency WHERE NOT (status = 200 OR latency > 1000) ORDER BY latency DESC",
+		[][]interface{}{{"/api/orders", 800.0}, {"/api/orders/7", 45.0}, {"/Api/users", 30.0}},
+	},
+	{
+		"SELECT path WHERE path LIKE '/api/%' AND path < '/api/p' ORDER BY 1",
+		[][]interface{}{{"/api/orders"}, {"/api/orders"}, {"/api/orders"}, {"/api/orders/7"}},
+	},
+	{
+		`SELECT ip, count(*) WHERE method IN ('GET', 'DELETE') AND ip ~ '^10\.0\.0\.[12]$' GROUP BY ip`,
+		[][]interface{}{{"10.0.0.1", int64(3)}, {"10.0.0.2", int64(1)}},
+	},
+	{
+		"SELECT path WHERE method NOT IN ('GET', 'POST') OR path NOT LIKE '/api/%' ORDER BY path DESC",
+		[][]interface{}{{"/health"}, {"/api/orders/7"}, {"/Api/users"}},
+	},
+	{
+		"SELECT level, min(time) WHERE time >= '2026-03-01T12:01:00Z' GROUP BY level",
+		[][]interface{}{{"error", queryT0.Add(130 * time.Second)}, {"info", queryT0.Add(65 * time.Second)}, {"warning", queryT0.Add(70 * time.Second)}},
+	},
+	{
+		// an aggregate over no records still returns a row
+		"SELECT count(*), sum(status) WHERE level = 'nothing'",
+		[][]interface{}{{int64(0), nil}},
+	},
+}
+
+func runInMemory(t *testing.T, s string, records []domain.LogRecord) [][]interface{} {
+	t.Helper()
+	q, err := ParseQuery(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	ev, err := newQueryEvaluator(q)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	for _, rec := range records {
+		if err := ev.Add(rec); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return ev.Result().Rows
+}
+
+func TestQueryEvaluator(t *testing.T) {
+	for _, c := range queryCases {
+		if got := runInMemory(t, c.query, queryTestRecords); !reflect.DeepEqual(got, c.rows) {
+			t.Errorf("%s\n got %v\nwant %v", c.query, got, c.rows)
+		}
+	}
+}
+
+func TestQueryEvaluatorLimit(t *testing.T) {
+	var records []domain.LogRecord
+	for i := 0; i < 50; i++ {
+		// newest first, so every record replaces the kept ones
+		records = append(records, domain.LogRecord{Time: queryT0.Add(time.Duration(50-i) * time.Second), Status: i})
+	}
+	got := runInMemory(t, "SELECT status LIMIT 3", records)
+	if want := [][]interface{}{{49.0}, {48.0}, {47.0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseQueryChecks(t *testing.T) {
+	ok := []string{
+		"SELECT path, count(*) GROUP BY path",
+		"SELECT min(time), max(latency), sum(status), p99(latency)",
+		"SELECT attr.user_id WHERE attr.user_id = '7'",
+		"SELECT bucket(time, 90s), count(*) GROUP BY bucket(time, 90s)",
+	}
+	for _, s := range ok {
+		if _, err := ParseQuery(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+
+	bad := []struct{ query, err string }{
+		{"SELECT foo", `unknown field "foo"`},
+		{"SELECT path, count(*)", "path must be in GROUP BY or inside an aggregate"},
+		{"SELECT sum(path)", "sum(path) needs a numeric field"},
+		{"SELECT max(method)", "max(method) needs a numeric field or time"},
+		{"SELECT p95(time)", "p95(time) needs a numeric field"},
+		{"SELECT bucket(path, 5m) GROUP BY bucket(path, 5m)", "bucket needs the time field, not path"},
+		{"SELECT path WHERE status = '200'", "status is a number"},
+		{"SELECT path WHERE path = 200", "path is text, quote the value"},
+		{"SELECT path WHERE status LIKE '5%'", "LIKE only works on text fields"},
+		{"SELECT path WHERE path ~ '('", "missing closing )"},
+		{"SELECT path WHERE time > 'yesterday'", "time must be RFC3339"},
+		{"SELECT path LIMIT 1001", "LIMIT must be at most 1000"},
+		{"SELECT path WHERE path = '" + strings.Repeat("x", maxQueryLength) + "'", "longer than 4096 bytes"},
+	}
+	for _, c := range bad {
+		_, err := ParseQuery(c.query)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%.60s: err = %v, want %q", c.query, err, c.err)
+		}
+	}
+}
+
+func TestBucketWholeSeconds(t *testing.T) {
+	// the lexer only reads whole units; a built query is checked as well
+	if err := checkTerm(query.Term{Field: "time", Bucket: 1500 * time.Millisecond}); err == nil {
+		t.Error("1.5s bucket accepted")
+	}
+	if err := checkTerm(query.Term{Field: "time", Bucket: 500 * time.Millisecond}); err == nil {
+		t.Error("500ms bucket accepted")
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	cases := []struct {
+		at   time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{queryT0.Add(59 * time.Second), time.Minute, queryT0},
+		// from the Unix epoch: 7s does not divide a day
+		{time.Unix(20, 0), 7 * time.Second, time.Unix(14, 0)},
+		{time.Unix(1772366405, 900), 7 * time.Second, time.Unix(1772366405-1772366405%7, 0)},
+		{time.Unix(-1, 0), 7 * time.Second, time.Unix(-7, 0)},
+		{time.Unix(-7, 0), 7 * time.Second, time.Unix(-7, 0)},
+	}
+	for _, c := range cases {
+		if got := bucketStart(c.at, c.d); !got.Equal(c.want) {
+			t.Errorf("bucketStart(%v, %s) = %v, want %v", c.at, c.d, got, c.want)
+		}
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"/api/%", "/api/orders", true},
+		{"/api/%", "/API/orders", false},
+		{"/api/_", "/api/x", true},
+		{"/api/_", "/api/xy", false},
+		{`100\%`, "100%", true},
+		{`100\%`, "1000", false},
+		{"a.c", "abc", false},
+		{"%\n%", "line1\nline2", true},
+	}
+	for _, c := range cases {
+		q, err := ParseQuery("SELECT path WHERE path LIKE '" + strings.ReplaceAll(c.pattern, "'", "''") + "'")
+		if err != nil {
+			t.Fatal(err)
+		}
+		where, _ := compileWhere(q.Where)
+		if got := where(domain.LogRecord{Path: c.s}); got != c.want {
+			t.Errorf("%q LIKE %q = %v, want %v", c.s, c.pattern, got, c.want)
+		}
+	}
+}
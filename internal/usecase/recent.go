@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/loki"
+)
+
+const (
+	recentLinesPerSeries = 1000
+	maxRecentSeries      = 5000
+	defaultQueryLimit    = 100
+	maxQueryLimit        = 5000
+)
+
+// RecentStream is one label set with the lines that matched a query.
+type RecentStream struct {
+	Labels  map[string]string
+	Records []domain.LogRecord
+}
+
+// LogQuery selects recently ingested lines, newest first unless Forward.
+type LogQuery struct {
+	Query   *loki.Query
+	Start   time.Time
+	End     time.Time
+	Limit   int
+	Forward bool
+}
+
+type seriesKey struct {
+	orgID uint
+	key   string
+}
+
+// recentSeries is a ring of the last lines of one label set
+type recentSeries struct {
+	labels  map[string]string
+	records []domain.LogRecord
+	next    int
+	updated time.Time
+}
+
+// recentBuffer keeps the last lines of every label set in memory, so
+// pushed logs can be browsed (Loki query API) without storing every line.
+type recentBuffer struct {
+	mu     sync.RWMutex
+	series map[seriesKey]*recentSeries
+}
+
+func newRecentBuffer() *recentBuffer {
+	return &recentBuffer{series: make(map[seriesKey]*recentSeries)}
+}
+
+func (b *recentBuffer) add(orgID uint, labels map[string]string, key string, records []domain.LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := seriesKey{orgID, key}
+	s, ok := b.series[id]
+	if !ok {
+		if len(b.series) >= maxRecentSeries {
+			b.dropOldest()
+		}
+		s = &recentSeries{labels: labels}
+		b.series[id] = s
+	}
+	for _, rec := range records {
+		if len(s.records) < recentLinesPerSeries {
+			s.records = append(s.records, rec)
+			continue
+		}
+		s.records[s.next] = rec
+		s.next = (s.next + 1) % recentLinesPerSeries
+	}
+	s.updated = time.Now()
+}
+
+func (b *recentBuffer) dropOldest() {
+	var oldest seriesKey
+	var at time.Time
+	for id, s := range b.series {
+		if at.IsZero() || s.updated.Before(at) {
+			oldest, at = id, s.updated
+		}
+	}
+	delete(b.series, oldest)
+}
+
+type recentHit struct {
+	series int
+	rec    domain.LogRecord
+}
+
+func (b *recentBuffer) query(orgID uint, q LogQuery) []RecentStream {
+	b.mu.RLock()
+	var streams []RecentStream
+	var hits []recentHit
+	for id, s := range b.series {
+		if id.orgID != orgID || !q.Query.MatchLabels(s.labels) {
+			continue
+		}
+		idx := -1
+		for _, rec := range s.records {
+			if rec.Time.Before(q.Start) || !rec.Time.Before(q.End) || !q.Query.MatchLine(RecordLine(rec)) {
+				continue
+			}
+			if idx == -1 {
+				idx = len(streams)
+				streams = append(streams, RecentStream{Labels: s.labels})
+			}
+			hits = append(hits, recentHit{series: idx, rec: rec})
+		}
+	}
+	b.mu.RUnlock()
+
+	// the limit applies across streams, like in Loki
+	sort.SliceStable(hits, func(i, j int) bool {
+		if q.Forward {
+			return hits[i].rec.Time.Before(hits[j].rec.Time)
+		}
+		return hits[i].rec.Time.After(hits[j].rec.Time)
+	})
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	for _, h := range hits {
+		streams[h.series].Records = append(streams[h.series].Records, h.rec)
+	}
+
+	out := streams[:0]
+	for _, s := range streams {
+		if len(s.Records) > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// labelValues returns label names (name == "") or the values of one label
+// for series written since the given time.
+func (b *recentBuffer) labelValues(orgID uint, name string, since time.Time) []string {
+	b.mu.RLock()
+	seen := make(map[string]bool)
+	for id, s := range b.series {
+		if id.orgID != orgID || s.updated.Before(since) {
+			continue
+		}
+		if name == "" {
+			for k := range s.labels {
+				seen[k] = true
+			}
+		} else if v, ok := s.labels[name]; ok {
+			seen[v] = true
+		}
+	}
+	b.mu.RUnlock()
+
+	out := make([]string, 0, len(seen))
+	for v := range seen {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RecordLine is the text shown for a record: the original line when there
+// is one, otherwise the message.
+func RecordLine(rec domain.LogRecord) string {
+	if rec.Raw != "" {
+		return rec.Raw
+	}
+	return rec.Message
+}
+
+// ===================== QUERY =====================
+
+// QueryRecent searches the lines kept in memory. Only lines ingested since
+// the server started are available, up to the last 1000 per label set.
+func (u *IngestUsecase) QueryRecent(orgID uint, q LogQuery) []RecentStream {
+	if q.Limit <= 0 {
+		q.Limit = defaultQueryLimit
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	return u.recent.query(orgID, q)
+}
+
+func (u *IngestUsecase) LabelNames(orgID uint, since time.Time) []string {
+	return u.recent.labelValues(orgID, "", since)
+}
+
+func (u *IngestUsecase) LabelValues(orgID uint, name string, since time.Time) []string {
+	if name == "" {
+		return []string{}
+	}
+	return u.recent.labelValues(orgID, name, since)
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+)
+
+const (
+	defaultRecordLimit    = 100
+	maxRecordLimit        = 1000
+	recordRetentionPeriod = time.Hour
+)
+
+// RecordUsecase keeps parsed records of uploads and stream windows in
+// log_records so an analysis can be drilled down to the lines behind it.
+type RecordUsecase struct {
+	records       repository.RecordRepository
+	analyses      repository.LogAnalysisRepository
+	enabled       bool
+	retentionDays int
+}
+
+func NewRecordUsecase(records repository.RecordRepository, analyses repository.LogAnalysisRepository, enabled bool, retentionDays int) *RecordUsecase {
+	return &RecordUsecase{records: records, analyses: analyses, enabled: enabled, retentionDays: retentionDays}
+}
+
+// Enabled is the default retain mode; uploads may override it per file.
+func (u *RecordUsecase) Enabled() bool {
+	return u != nil && u.enabled
+}
+
+// Save writes the records of a saved analysis (its ID must be set).
+func (u *RecordUsecase) Save(a *domain.LogAnalysis, records []domain.StoredRecord) error {
+	if u == nil || len(records) == 0 {
+		return nil
+	}
+	start := time.Now()
+	if err := u.records.Insert(a, records); err != nil {
+		return err
+	}
+	log.Printf("[Records] stored %d records for analysis %d in %s", len(records), a.ID, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// readable returns the analysis if the actor may read it, by the same rule
+// as LogAnalysisUsecase.GetByID.
+func (u *RecordUsecase) readable(actor domain.Actor, id uint) (*domain.LogAnalysis, error) {
+	a, err := u.analyses.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, errors.New("record not found")
+	}
+	ok, err := canRead(u.analyses, actor, a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return a, nil
+}
+
+// Find returns records of one analysis the actor may read.
+func (u *RecordUsecase) Find(actor domain.Actor, filter domain.RecordFilter) ([]domain.StoredRecord, error) {
+	a, err := u.readable(actor, filter.AnalysisID)
+	if err != nil {
+		return nil, err
+	}
+	filter.Stream = a.StreamID != 0
+	filter.Limit, filter.Offset = recordPage(filter.Limit, filter.Offset)
+	// a stream window only holds records inside the window
+	if filter.Stream && a.WindowStart != nil && a.WindowEnd != nil {
+		if filter.From == nil || filter.From.Before(*a.WindowStart) {
+			filter.From = a.WindowStart
+		}
+		if filter.To == nil || filter.To.After(*a.WindowEnd) {
+			filter.To = a.WindowEnd
+		}
+	}
+	return u.records.Find(filter)
+}
+
+// Search finds lines matching filter.Terms (see ParseSearchQuery) in one
+// analysis the actor may read or, without AnalysisID, across all of the
+// active org's analyses.
+func (u *RecordUsecase) Search(actor domain.Actor, filter domain.SearchFilter) ([]domain.SearchHit, error) {
+	if len(filter.Terms) == 0 {
+		return nil, errors.New("query needs at least one term to match")
+	}
+	filter.OrgID = actor.OrgID
+	if filter.AnalysisID != 0 {
+		a, err := u.readable(actor, filter.AnalysisID)
+		if err != nil {
+			return nil, err
+		}
+		// records are stored under the analysis' org, which differs for
+		// analyses shared from another org
+		filter.OrgID = a.OrgID
+	}
+	filter.Limit, filter.Offset = recordPage(filter.Limit, filter.Offset)
+
+	records, err := u.records.Search(filter)
+	if err != nil {
+		return nil, err
+	}
+	re := highlighter(filter.Terms)
+	hits := make([]domain.SearchHit, len(records))
+	for i, rec := range records {
+		hits[i] = domain.SearchHit{StoredRecord: rec, Snippet: snippet(RecordLine(rec.LogRecord), re)}
+	}
+	return hits, nil
+}
+
+// Query runs a query (see ParseQuery) over the retained records of one
+// analysis the actor may read or, without scope.AnalysisID, of the active
+// org.
+func (u *RecordUsecase) Query(actor domain.Actor, scope domain.QueryScope, q string) (*domain.QueryResult, error) {
+	parsed, err := ParseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	scope.OrgID = actor.OrgID
+	if scope.AnalysisID != 0 {
+		a, err := u.readable(actor, scope.AnalysisID)
+		if err != nil {
+			return nil, err
+		}
+		scope.OrgID = a.OrgID
+	}
+	return u.records.Aggregate(scope, parsed)
+}
+
+// DeleteAnalysis drops the records of a deleted analysis.
+func (u *RecordUsecase) DeleteAnalysis(id uint) error {
+	if u == nil {
+		return nil
+	}
+	return u.records.DeleteByAnalysis(id)
+}
+
+// StartRetention drops partitions older than the retention period, once
+// now and then every hour.
+func (u *RecordUsecase) StartRetention() {
+	go func() {
+		ticker := time.NewTicker(recordRetentionPeriod)
+		defer ticker.Stop()
+		for {
+			u.sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func (u *RecordUsecase) sweep() {
+	cutoff := time.Now().AddDate(0, 0, -u.retentionDays)
+	dropped, err := u.records.DropBefore(cutoff)
+	if err != nil {
+		log.Println("[Records] ❌ retention:", err)
+		return
+	}
+	if dropped > 0 {
+		log.Printf("[Records] retention dropped %d partitions older than %d days", dropped, u.retentionDays)
+	}
+}
+
+// recordPage applies the default and maximum page size
+func recordPage(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultRecordLimit
+	}
+	return min(limit, maxRecordLimit), max(offset, 0)
+}
+
+// storedRecords wraps window records for Save
+func storedRecords(records []domain.LogRecord) []domain.StoredRecord {
+	out := make([]domain.StoredRecord, len(records))
+	for i, rec := range records {
+		out[i] = domain.StoredRecord{LogRecord: rec}
+	}
+	return out
+}
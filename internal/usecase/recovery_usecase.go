@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeLen   = 10 // shown as two groups of 5
+	// no 0/o, 1/l/i: codes get typed from paper
+	recoveryAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// ErrForbidden is returned when the caller is not an admin.
+var ErrForbidden = errors.New("admin only")
+
+// checkSecondFactor accepts a TOTP code or, failing that, an unused
+// recovery code, which is then used up.
+func (a *AuthUsecase) checkSecondFactor(u *domain.User, code, ip string) (bool, error) {
+	if ok, err := a.acceptCode(u.ID, u.TOTPSecret, code); err != nil || ok {
+		return ok, err
+	}
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLen {
+		return false, nil
+	}
+	codes, err := a.codes.ListUnused(u.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(c.CodeHash), []byte(code)) != nil {
+			continue
+		}
+		// two logins racing with the same code: only one gets it
+		ok, err := a.codes.Use(c.ID)
+		if err != nil || !ok {
+			return false, err
+		}
+		a.record(&domain.AuditEntry{ActorID: u.ID, Action: domain.AuditRecoveryCodeUsed, TargetUserID: u.ID,
+			Detail: fmt.Sprintf("%d codes left", len(codes)-1), IP: ip})
+		return true, nil
+	}
+	return false, nil
+}
+
+// newRecoveryCodes replaces the user's recovery codes; only hashes are kept.
+func (a *AuthUsecase) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomCode(recoveryCodeLen)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:recoveryCodeLen/2] + "-" + code[recoveryCodeLen/2:]
+		hashes[i] = string(hash)
+	}
+	if err := a.codes.Replace(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes invalidates the old codes and returns new ones.
+func (a *AuthUsecase) RegenerateRecoveryCodes(userID uint, password, ip string) ([]string, error) {
+	u, err := a.repo.FindByID(userID)
+	if err != nil || u == nil {
+		return nil, errors.New("user not found")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidPassword
+	}
+	if !u.TOTPEnabled {
+		return nil, errors.New("TOTP is not enabled")
+	}
+	codes, err := a.newRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	a.record(&domain.AuditEntry{ActorID: u.ID, Action: domain.AuditRecoveryCodesRegen, TargetUserID: u.ID, IP: ip})
+	return codes, nil
+}
+
+// RecoveryCodesLeft counts the user's unused recovery codes.
+func (a *AuthUsecase) RecoveryCodesLeft(userID uint) (int, error) {
+	return a.codes.CountUnused(userID)
+}
+
+// ===================== ADMIN =====================
+
+// IsAdmin reports whether the user may act on other users. The role is read
+// from the database, so a demotion counts before the token expires.
+func (a *AuthUsecase) IsAdmin(userID uint) bool {
+	u, err := a.repo.FindByID(userID)
+	if err != nil || u == nil {
+		return false
+	}
+	return u.Role == domain.RoleAdmin && u.DisabledAt == nil
+}
+
+// ResetTOTP turns off 2FA of a user who lost their device and logs them
+// out everywhere. The reset is written to the audit log.
+func (a *AuthUsecase) ResetTOTP(adminID, userID uint, reason, ip string) error {
+	if !a.IsAdmin(adminID) {
+		return ErrForbidden
+	}
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("reason is required")
+	}
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return errors.New("record not found")
+	}
+	if !u.TOTPEnabled && u.TOTPPending == "" {
+		return errors.New("user has no 2FA to reset")
+	}
+	if err := a.clearTOTP(u); err != nil {
+		return err
+	}
+	if err := a.tokens.RevokeUser(u.ID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	log.Printf("[Auth] 2FA of userID %d reset by admin %d", u.ID, adminID)
+	return a.audit.Create(&domain.AuditEntry{ActorID: adminID, Action: domain.AuditTOTPReset, TargetUserID: u.ID, Detail: reason, IP: ip})
+}
+
+// AuditLog lists audit entries, newest first; userID 0 means all users.
+func (a *AuthUsecase) AuditLog(adminID, userID uint, limit int) ([]domain.AuditEntry, error) {
+	if !a.IsAdmin(adminID) {
+		return nil, ErrForbidden
+	}
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	return a.audit.List(userID, limit)
+}
+
+// record writes an audit entry; a failure is logged, not returned, since
+// the action itself already happened
+func (a *AuthUsecase) record(e *domain.AuditEntry) {
+	if err := a.audit.Create(e); err != nil {
+		log.Printf("[Audit] ❌ failed to record %s: %v", e.Action, err)
+	}
+}
+
+// normalizeRecoveryCode drops dashes and spaces and lowercases.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == ' ':
+			return -1
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return r
+	}, code)
+}
+
+func randomCode(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	for i := range b {
+		k, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = recoveryAlphabet[k.Int64()]
+	}
+	return string(b), nil
+}
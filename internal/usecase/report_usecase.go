@@ -0,0 +1,460 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/internal/export"
+	"github.com/ifs21014-itdel/log-analyzer/internal/notify"
+	"github.com/ifs21014-itdel/log-analyzer/internal/repository"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/cron"
+)
+
+const (
+	schedulerInterval = 30 * time.Second
+	reportRunTimeout  = 5 * time.Minute
+	maxSavedQueryName = 100
+	maxNotifyTargets  = 10
+	defaultRunsListed = 20
+)
+
+// ReportUsecase manages saved queries and reports, runs them on their cron
+// schedule and delivers the output through the notifiers.
+type ReportUsecase struct {
+	repo     repository.SavedQueryRepository
+	orgs     repository.OrgRepository
+	records  *RecordUsecase
+	analyses *LogAnalysisUsecase
+	notifier *notify.Dispatcher
+}
+
+// errOwnerLeft: the creator of a saved query left its org, so it no longer
+// runs on their behalf.
+var errOwnerLeft = errors.New("the creator of this saved query is no longer a member of the org")
+
+func NewReportUsecase(repo repository.SavedQueryRepository, orgs repository.OrgRepository, records *RecordUsecase, analyses *LogAnalysisUsecase, notifier *notify.Dispatcher) *ReportUsecase {
+	return &ReportUsecase{repo: repo, orgs: orgs, records: records, analyses: analyses, notifier: notifier}
+}
+
+func (u *ReportUsecase) Create(q *domain.SavedQuery) error {
+	if err := u.validate(q); err != nil {
+		return err
+	}
+	q.NextRunAt = nextRun(q, time.Now())
+	return u.repo.Create(q)
+}
+
+// Update replaces a saved query; the schedule restarts from now.
+func (u *ReportUsecase) Update(q *domain.SavedQuery) error {
+	existing, err := u.Get(q.OrgID, q.ID)
+	if err != nil {
+		return err
+	}
+	q.UserID = existing.UserID
+	if err := u.validate(q); err != nil {
+		return err
+	}
+	q.NextRunAt = nextRun(q, time.Now())
+	return u.repo.Update(q)
+}
+
+// Get returns a saved query of the org.
+func (u *ReportUsecase) Get(orgID, id uint) (*domain.SavedQuery, error) {
+	q, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil || q.OrgID != orgID {
+		return nil, errors.New("record not found")
+	}
+	return q, nil
+}
+
+func (u *ReportUsecase) List(orgID uint) ([]domain.SavedQuery, error) {
+	return u.repo.ListByOrg(orgID)
+}
+
+func (u *ReportUsecase) Delete(orgID, id uint) error {
+	if _, err := u.Get(orgID, id); err != nil {
+		return err
+	}
+	return u.repo.Delete(id)
+}
+
+func (u *ReportUsecase) Runs(orgID, id uint, limit int) ([]domain.ReportRun, error) {
+	if _, err := u.Get(orgID, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > defaultRunsListed*5 {
+		limit = defaultRunsListed
+	}
+	return u.repo.ListRuns(id, limit)
+}
+
+// Run returns one run including its output.
+func (u *ReportUsecase) Run(orgID, id, runID uint) (*domain.ReportRun, error) {
+	if _, err := u.Get(orgID, id); err != nil {
+		return nil, err
+	}
+	run, err := u.repo.GetRun(id, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, errors.New("record not found")
+	}
+	return run, nil
+}
+
+// RunNow runs a saved query outside its schedule.
+func (u *ReportUsecase) RunNow(orgID, id uint) (*domain.ReportRun, error) {
+	q, err := u.Get(orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reportRunTimeout)
+	defer cancel()
+	return u.execute(ctx, q, domain.RunTriggerManual)
+}
+
+func (u *ReportUsecase) validate(q *domain.SavedQuery) error {
+	q.Name = strings.TrimSpace(q.Name)
+	if q.Name == "" || len(q.Name) > maxSavedQueryName {
+		return fmt.Errorf("name is required (max %d characters)", maxSavedQueryName)
+	}
+	if q.RangeSeconds < 0 {
+		return errors.New("range_seconds cannot be negative")
+	}
+	if q.Format == "" {
+		q.Format = "json"
+	}
+
+	switch q.Kind {
+	case domain.SavedQueryKindQuery:
+		if len(q.Labels) > 0 || len(q.GroupBy) > 0 {
+			return errors.New("labels and group_by apply to reports only")
+		}
+		if _, err := ParseQuery(q.Query); err != nil {
+			return err
+		}
+		if q.Format != "json" && q.Format != "csv" {
+			return errors.New("query output format must be json or csv")
+		}
+		if q.AnalysisID != 0 {
+			a, err := u.analyses.repo.GetByID(q.AnalysisID)
+			if err != nil {
+				return err
+			}
+			if a == nil || a.OrgID != q.OrgID {
+				return errors.New("record not found")
+			}
+		}
+	case domain.SavedQueryKindReport:
+		if q.Query != "" || q.AnalysisID != 0 {
+			return errors.New("query and analysis_id apply to queries only")
+		}
+		if err := ValidateLabels(q.Labels); err != nil {
+			return err
+		}
+		for _, key := range q.GroupBy {
+			if !labelKeyPattern.MatchString(key) {
+				return fmt.Errorf("invalid label key %q", key)
+			}
+		}
+		if len(q.GroupBy) > 0 {
+			if q.Format != "json" && q.Format != "csv" {
+				return errors.New("grouped report format must be json or csv")
+			}
+		} else if !export.Supported(q.Format) {
+			return fmt.Errorf("unsupported export format %q (use csv, json, xlsx or html)", q.Format)
+		}
+	default:
+		return fmt.Errorf("kind must be %q or %q", domain.SavedQueryKindQuery, domain.SavedQueryKindReport)
+	}
+
+	q.Schedule = strings.TrimSpace(q.Schedule)
+	if q.Schedule != "" {
+		s, err := cron.Parse(q.Schedule)
+		if err != nil {
+			return err
+		}
+		if s.Next(time.Now()).IsZero() {
+			return fmt.Errorf("schedule %q never fires", q.Schedule)
+		}
+	}
+	if len(q.Notify) > maxNotifyTargets {
+		return fmt.Errorf("too many notify targets (max %d)", maxNotifyTargets)
+	}
+	for _, t := range q.Notify {
+		if err := u.notifier.Validate(notify.Target(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextRun is the next scheduled time after now, nil for manual-only or
+// disabled queries.
+func nextRun(q *domain.SavedQuery, now time.Time) *time.Time {
+	if !q.Enabled || q.Schedule == "" {
+		return nil
+	}
+	s, err := cron.Parse(q.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := s.Next(now)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// StartScheduler checks for due saved queries every 30 seconds. Claim
+// makes sure each run happens once even with several instances.
+func (u *ReportUsecase) StartScheduler() {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			u.runDue(time.Now())
+		}
+	}()
+}
+
+func (u *ReportUsecase) runDue(now time.Time) {
+	due, err := u.repo.Due(now)
+	if err != nil {
+		log.Printf("[Reports] listing due queries failed: %v", err)
+		return
+	}
+	for i := range due {
+		q := &due[i]
+		if err := u.checkOwner(q); errors.Is(err, errOwnerLeft) {
+			q.Enabled, q.NextRunAt = false, nil
+			if err := u.repo.Update(q); err != nil {
+				log.Printf("[Reports] disabling %q failed: %v", q.Name, err)
+			} else {
+				log.Printf("[Reports] ⏸️ disabled %q: creator userID %d left org %d", q.Name, q.UserID, q.OrgID)
+			}
+			continue
+		}
+		// runs missed while the server was down are skipped, not replayed
+		ok, err := u.repo.Claim(q.ID, *q.NextRunAt, nextRun(q, now))
+		if err != nil {
+			log.Printf("[Reports] claiming %q failed: %v", q.Name, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), reportRunTimeout)
+		run, err := u.execute(ctx, q, domain.RunTriggerSchedule)
+		cancel()
+		if err != nil {
+			log.Printf("[Reports] storing run of %q failed: %v", q.Name, err)
+			continue
+		}
+		log.Printf("[Reports] ran %q: %s (%d bytes)", q.Name, run.Status, run.Size)
+	}
+}
+
+// checkOwner makes sure the creator of q is still a member of its org.
+func (u *ReportUsecase) checkOwner(q *domain.SavedQuery) error {
+	m, err := u.orgs.GetMember(q.OrgID, q.UserID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errOwnerLeft
+	}
+	return nil
+}
+
+// execute renders the output, delivers it and stores the run. Render and
+// delivery failures are recorded on the run; only storing it returns an
+// error. Nothing is rendered or sent once the creator left the org.
+func (u *ReportUsecase) execute(ctx context.Context, q *domain.SavedQuery, trigger string) (*domain.ReportRun, error) {
+	run := &domain.ReportRun{SavedQueryID: q.ID, Trigger: trigger, StartedAt: time.Now().UTC()}
+	if err := u.checkOwner(q); err != nil {
+		run.Status, run.Error = domain.RunStatusFailed, err.Error()
+		run.FinishedAt = time.Now().UTC()
+		if err := u.repo.CreateRun(run); err != nil {
+			return nil, err
+		}
+		return run, nil
+	}
+	output, err := u.render(q, run.StartedAt)
+	if err != nil {
+		run.Status, run.Error = domain.RunStatusFailed, err.Error()
+	} else {
+		run.Status, run.Output, run.Size = domain.RunStatusOK, output, len(output)
+		run.Filename = reportFilename(q, run.StartedAt)
+		run.ContentType = outputContentType(q)
+	}
+
+	if len(q.Notify) > 0 {
+		msg := reportMessage(q, run)
+		var failed []string
+		for _, t := range q.Notify {
+			if err := u.notifier.Send(ctx, notify.Target(t), msg); err != nil {
+				failed = append(failed, t.Type+": "+err.Error())
+			}
+		}
+		run.DeliveryError = strings.Join(failed, "; ")
+	}
+
+	run.FinishedAt = time.Now().UTC()
+	if err := u.repo.CreateRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (u *ReportUsecase) render(q *domain.SavedQuery, now time.Time) ([]byte, error) {
+	var from *time.Time
+	if q.RangeSeconds > 0 {
+		t := now.Add(-time.Duration(q.RangeSeconds) * time.Second)
+		from = &t
+	}
+	var buf bytes.Buffer
+
+	if q.Kind == domain.SavedQueryKindQuery {
+		// the org itself, without a user, reads no shared analyses
+		org := domain.Actor{OrgID: q.OrgID}
+		res, err := u.records.Query(org, domain.QueryScope{AnalysisID: q.AnalysisID, From: from}, q.Query)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeResult(&buf, q.Format, res); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// reports cover the analyses of the org only, not those shared with
+	// the creator: every member reads the runs
+	filter := domain.AnalysisFilter{OrgID: q.OrgID, Labels: q.Labels, From: from}
+	if len(q.GroupBy) > 0 {
+		rows, err := u.analyses.report(q.GroupBy, filter)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeResult(&buf, q.Format, labelReportResult(q.GroupBy, rows)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	analyses, err := u.analyses.repo.GetAll(filter)
+	if err != nil {
+		return nil, err
+	}
+	err = export.Write(&buf, q.Format, export.Report{Title: q.Name, Analyses: analyses, GeneratedAt: now})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// labelReportResult turns report rows into a table: one column per label
+// key, then the totals.
+func labelReportResult(groupBy []string, rows []domain.LabelReportRow) *domain.QueryResult {
+	res := &domain.QueryResult{Columns: append(append([]string{}, groupBy...), "analyses", "total_requests", "error_count", "average_response")}
+	for _, r := range rows {
+		row := make([]interface{}, 0, len(res.Columns))
+		for _, key := range groupBy {
+			row = append(row, r.Group[key])
+		}
+		row = append(row, int64(r.Analyses), int64(r.TotalRequests), int64(r.ErrorCount), r.AverageResponse)
+		res.Rows = append(res.Rows, row)
+	}
+	return res
+}
+
+// writeResult writes a table as JSON ({columns, rows}) or CSV with a header
+func writeResult(w io.Writer, format string, res *domain.QueryResult) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(res)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(res.Columns)
+	for _, row := range res.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				record[i] = v
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case int64:
+				record[i] = strconv.FormatInt(v, 10)
+			case time.Time:
+				record[i] = v.UTC().Format(time.RFC3339Nano)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func outputContentType(q *domain.SavedQuery) string {
+	if q.Kind == domain.SavedQueryKindReport && len(q.GroupBy) == 0 {
+		return export.ContentType(q.Format)
+	}
+	if q.Format == "csv" {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// reportFilename -> "daily-errors-20251019-0700.csv"
+func reportFilename(q *domain.SavedQuery, at time.Time) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, q.Name)
+	return slug + "-" + at.Format("20060102-1504") + "." + q.Format
+}
+
+func reportMessage(q *domain.SavedQuery, run *domain.ReportRun) notify.Message {
+	m := notify.Message{
+		Subject: "[log-analyzer] " + q.Name,
+		Fields: map[string]interface{}{
+			"saved_query_id": q.ID,
+			"name":           q.Name,
+			"kind":           q.Kind,
+			"trigger":        run.Trigger,
+			"status":         run.Status,
+			"started_at":     run.StartedAt,
+		},
+	}
+	if run.Status == domain.RunStatusFailed {
+		m.Subject += " failed"
+		m.Text = fmt.Sprintf("%s %q failed at %s:\n\n%s\n", q.Kind, q.Name, run.StartedAt.Format(time.RFC1123), run.Error)
+		m.Fields["error"] = run.Error
+		return m
+	}
+	m.Text = fmt.Sprintf("%s %q ran at %s. The output (%s, %d bytes) is attached.\n",
+		q.Kind, q.Name, run.StartedAt.Format(time.RFC1123), run.Filename, run.Size)
+	m.Attachments = []notify.Attachment{{Filename: run.Filename, ContentType: run.ContentType, Data: run.Output}}
+	return m
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+const (
+	maxSearchTerms     = 16
+	snippetMaxBytes    = 240
+	snippetLeadBytes   = 80
+	snippetMarkOpen    = "<mark>"
+	snippetMarkClose   = "</mark>"
+	snippetEllipsis    = "…"
+	searchWordCharsRgx = `[\pL\pN_]`
+)
+
+var nonWordRun = regexp.MustCompile(`[^\pL\pN]+`)
+
+// ParseSearchQuery splits a query into terms: bare words (AND-ed),
+// "quoted phrases", wildcards (* any run, ? one character) and -excluded
+// terms, e.g. `"GET /api/orders" req-7f3a* -healthcheck`.
+func ParseSearchQuery(q string) ([]domain.SearchTerm, error) {
+	var terms []domain.SearchTerm
+	for i := 0; i < len(q); {
+		if q[i] == ' ' || q[i] == '\t' || q[i] == '\n' {
+			i++
+			continue
+		}
+		var t domain.SearchTerm
+		if q[i] == '-' && i+1 < len(q) && q[i+1] != ' ' {
+			t.Negate = true
+			i++
+		}
+		if q[i] == '"' {
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated phrase in query")
+			}
+			t.Text, t.Phrase = strings.TrimSpace(q[i+1:i+1+end]), true
+			i += end + 2
+		} else {
+			end := strings.IndexAny(q[i:], " \t\n")
+			if end < 0 {
+				end = len(q) - i
+			}
+			t.Text = q[i : i+end]
+			i += end
+		}
+		if strings.Trim(t.Text, "*?") == "" {
+			continue // nothing to match on
+		}
+		terms = append(terms, t)
+	}
+
+	if len(terms) > maxSearchTerms {
+		return nil, fmt.Errorf("query has more than %d terms", maxSearchTerms)
+	}
+	for _, t := range terms {
+		if !t.Negate {
+			return terms, nil
+		}
+	}
+	return nil, errors.New("query needs at least one term to match")
+}
+
+// termPattern is the regexp a term highlights with, close to how the
+// database matched it
+func termPattern(t domain.SearchTerm) string {
+	if t.Phrase {
+		words := nonWordRun.Split(strings.Trim(nonWordRun.ReplaceAllString(t.Text, " "), " "), -1)
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		if len(words) == 1 && words[0] == "" {
+			return regexp.QuoteMeta(t.Text)
+		}
+		return strings.Join(words, `[^\pL\pN]+`)
+	}
+	if prefix, ok := strings.CutSuffix(t.Text, "*"); ok && !strings.ContainsAny(prefix, "*?") {
+		return regexp.QuoteMeta(prefix) + searchWordCharsRgx + "*"
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(t.Text, "*") {
+		if i > 0 {
+			b.WriteString(".*?")
+		}
+		b.WriteString(strings.ReplaceAll(regexp.QuoteMeta(part), `\?`, "."))
+	}
+	return b.String()
+}
+
+// highlighter marks every positive term of a query
+func highlighter(terms []domain.SearchTerm) *regexp.Regexp {
+	var parts []string
+	for _, t := range terms {
+		if !t.Negate {
+			parts = append(parts, termPattern(t))
+		}
+	}
+	re, err := regexp.Compile(`(?i)` + strings.Join(parts, "|"))
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// snippet cuts the line around the first match and wraps matches in
+// <mark>; the rest of the text is HTML-escaped.
+func snippet(line string, re *regexp.Regexp) string {
+	var matches [][]int
+	if re != nil {
+		matches = re.FindAllStringIndex(line, -1)
+	}
+
+	start, end := 0, len(line)
+	if len(line) > snippetMaxBytes {
+		if len(matches) > 0 && matches[0][0] > snippetLeadBytes {
+			start = matches[0][0] - snippetLeadBytes
+		}
+		end = min(start+snippetMaxBytes, len(line))
+		for start > 0 && !utf8.RuneStart(line[start]) {
+			start--
+		}
+		for end < len(line) && !utf8.RuneStart(line[end]) {
+			end++
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(snippetEllipsis)
+	}
+	pos := start
+	for _, m := range matches {
+		if m[1] <= pos || m[0] == m[1] {
+			continue
+		}
+		if m[0] >= end {
+			break
+		}
+		b.WriteString(html.EscapeString(line[pos:max(m[0], pos)]))
+		b.WriteString(snippetMarkOpen)
+		b.WriteString(html.EscapeString(line[max(m[0], pos):min(m[1], end)]))
+		b.WriteString(snippetMarkClose)
+		pos = min(m[1], end)
+	}
+	b.WriteString(html.EscapeString(line[pos:end]))
+	if end < len(line) {
+		b.WriteString(snippetEllipsis)
+	}
+	return b.String()
+}
@@ -0,0 +1,248 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/jwt"
+)
+
+const (
+	revocationSyncInterval = 30 * time.Second
+	tokenCleanupInterval   = time.Hour
+	// revocations committed out of order are still picked up by the next sync
+	revocationSyncOverlap = 5 * time.Second
+)
+
+var errInvalidRefresh = errors.New("invalid or expired refresh token")
+
+// issueTokens creates an access token, carrying the user's current role and
+// active org, and the next refresh token of the family (session).
+func (a *AuthUsecase) issueTokens(u *domain.User, familyID string) (*domain.TokenPair, error) {
+	userID := u.ID
+	actor, err := a.ActorFor(u)
+	if err != nil {
+		return nil, err
+	}
+	access, jti, err := jwt.GenerateToken(userID, u.Role, jwt.Org{ID: actor.OrgID, Role: actor.OrgRole}, familyID, os.Getenv("JWT_SECRET"), a.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = a.tokens.CreateRefresh(&domain.RefreshToken{
+		UserID:          userID,
+		FamilyID:        familyID,
+		TokenHash:       hashToken(refresh),
+		AccessJTI:       jti,
+		AccessExpiresAt: now.Add(a.cfg.AccessTTL),
+		ExpiresAt:       now.Add(a.cfg.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int(a.cfg.AccessTTL.Seconds())}, nil
+}
+
+// Refresh trades a refresh token for a new pair. Each refresh token works
+// once; presenting one again means it was copied, so the whole session is
+// revoked, including the access tokens issued to it.
+func (a *AuthUsecase) Refresh(refreshToken string) (*domain.TokenPair, error) {
+	t, err := a.tokens.GetRefreshByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.RevokedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, errInvalidRefresh
+	}
+	if t.UsedAt != nil {
+		return nil, a.refreshReused(t)
+	}
+	ok, err := a.tokens.UseRefresh(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, a.refreshReused(t)
+	}
+	u, err := a.repo.FindByID(t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errInvalidRefresh
+	}
+	if u.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	return a.issueTokens(u, t.FamilyID)
+}
+
+func (a *AuthUsecase) refreshReused(t *domain.RefreshToken) error {
+	log.Printf("[Auth] ⚠️ refresh token reuse for userID %d, revoking session %s", t.UserID, t.FamilyID)
+	if err := a.tokens.RevokeFamily(t.FamilyID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	return errors.New("refresh token was already used, session revoked; please login again")
+}
+
+// Logout revokes the calling access token and its session.
+func (a *AuthUsecase) Logout(userID uint, jti, sessionID string, expiresAt time.Time) error {
+	if err := a.revokeAccess(userID, jti, expiresAt); err != nil {
+		return err
+	}
+	if sessionID == "" {
+		return nil
+	}
+	if err := a.tokens.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	return nil
+}
+
+// LogoutAll revokes every session of the user.
+func (a *AuthUsecase) LogoutAll(userID uint, jti string, expiresAt time.Time) error {
+	if err := a.revokeAccess(userID, jti, expiresAt); err != nil {
+		return err
+	}
+	if err := a.tokens.RevokeUser(userID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	return nil
+}
+
+func (a *AuthUsecase) revokeAccess(userID uint, jti string, expiresAt time.Time) error {
+	if err := a.tokens.RevokeAccess(jti, userID, expiresAt); err != nil {
+		return err
+	}
+	a.denylist.add(jti, expiresAt)
+	return nil
+}
+
+// Revoked implements jwt.Denylist from the in-memory copy of revoked_tokens.
+func (a *AuthUsecase) Revoked(jti string) bool {
+	return a.denylist.has(jti, time.Now())
+}
+
+// Start loads the denylist and keeps it in step with the database, so
+// tokens revoked by another instance are refused within 30 seconds.
+// Expired tokens, invitations and idle login counters are cleaned up hourly.
+func (a *AuthUsecase) Start() {
+	a.syncRevocations()
+	go func() {
+		resync := time.NewTicker(revocationSyncInterval)
+		cleanup := time.NewTicker(tokenCleanupInterval)
+		defer resync.Stop()
+		defer cleanup.Stop()
+		for {
+			select {
+			case <-resync.C:
+				a.syncRevocations()
+			case now := <-cleanup.C:
+				a.denylist.prune(now)
+				if err := a.tokens.DeleteExpired(now); err != nil {
+					log.Println("[Auth] ❌ token cleanup:", err)
+				}
+				if err := a.loginAttempts.DeleteExpired(now.Add(-a.cfg.Login.Window)); err != nil {
+					log.Println("[Auth] ❌ login attempts cleanup:", err)
+				}
+				if err := a.userTokens.DeleteExpired(now); err != nil {
+					log.Println("[Auth] ❌ email token cleanup:", err)
+				}
+				if err := a.orgs.DeleteExpiredInvitations(now); err != nil {
+					log.Println("[Auth] ❌ invitation cleanup:", err)
+				}
+			}
+		}
+	}()
+}
+
+func (a *AuthUsecase) syncRevocations() {
+	list, err := a.tokens.RevokedSince(a.denylist.cursor().Add(-revocationSyncOverlap))
+	if err != nil {
+		log.Println("[Auth] ❌ revocation sync:", err)
+		return
+	}
+	for _, t := range list {
+		a.denylist.add(t.JTI, t.ExpiresAt)
+		a.denylist.advance(t.CreatedAt)
+	}
+}
+
+// tokenDenylist maps revoked jtis to when they expire.
+type tokenDenylist struct {
+	mu     sync.RWMutex
+	jtis   map[string]time.Time
+	synced time.Time // created_at of the newest row loaded
+}
+
+func newTokenDenylist() *tokenDenylist {
+	return &tokenDenylist{jtis: make(map[string]time.Time)}
+}
+
+func (d *tokenDenylist) add(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	d.jtis[jti] = expiresAt
+	d.mu.Unlock()
+}
+
+func (d *tokenDenylist) has(jti string, now time.Time) bool {
+	d.mu.RLock()
+	exp, ok := d.jtis[jti]
+	d.mu.RUnlock()
+	return ok && now.Before(exp)
+}
+
+func (d *tokenDenylist) cursor() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.synced
+}
+
+func (d *tokenDenylist) advance(t time.Time) {
+	d.mu.Lock()
+	if t.After(d.synced) {
+		d.synced = t
+	}
+	d.mu.Unlock()
+}
+
+func (d *tokenDenylist) prune(now time.Time) {
+	d.mu.Lock()
+	for jti, exp := range d.jtis {
+		if now.After(exp) {
+			delete(d.jtis, jti)
+		}
+	}
+	d.mu.Unlock()
+}
+
+// randomToken returns n random bytes, URL-safe base64 encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how secrets handed to clients are stored: they are random
+// and long, so a plain sha256 is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/internal/domain"
+)
+
+// ErrAccountDisabled is returned at login and refresh of a disabled user.
+var ErrAccountDisabled = errors.New("account disabled")
+
+func (a *AuthUsecase) isAdminEmail(email string) bool {
+	for _, e := range a.cfg.AdminEmails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// PromoteAdmins gives the users of ADMIN_EMAILS the admin role, at startup.
+// Addresses without an account yet get it when they register.
+func (a *AuthUsecase) PromoteAdmins() error {
+	for _, email := range a.cfg.AdminEmails {
+		u, err := a.repo.FindByEmail(email)
+		if err != nil {
+			return err
+		}
+		if u == nil || u.Role == domain.RoleAdmin {
+			continue
+		}
+		detail := fmt.Sprintf("%s -> %s (ADMIN_EMAILS)", u.Role, domain.RoleAdmin)
+		u.Role = domain.RoleAdmin
+		if err := a.repo.Update(u); err != nil {
+			return err
+		}
+		log.Printf("[Auth] userID %d promoted to admin from ADMIN_EMAILS", u.ID)
+		a.record(&domain.AuditEntry{ActorID: u.ID, Action: domain.AuditUserRoleChanged, TargetUserID: u.ID, Detail: detail})
+	}
+	return nil
+}
+
+// ListUsers returns every account, for admins.
+func (a *AuthUsecase) ListUsers(adminID uint) ([]domain.User, error) {
+	if !a.IsAdmin(adminID) {
+		return nil, ErrForbidden
+	}
+	return a.repo.List()
+}
+
+// SetRole changes the role of another user. Their sessions are revoked so
+// the new role applies from the next login, not when tokens expire.
+func (a *AuthUsecase) SetRole(adminID, userID uint, role, ip string) (*domain.User, error) {
+	if !domain.ValidRole(role) {
+		return nil, errors.New("role must be admin, analyst or viewer")
+	}
+	u, err := a.otherUser(adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Role == role {
+		return u, nil
+	}
+	detail := fmt.Sprintf("%s -> %s", u.Role, role)
+	u.Role = role
+	if err := a.repo.Update(u); err != nil {
+		return nil, err
+	}
+	if err := a.revokeUser(u.ID); err != nil {
+		return nil, err
+	}
+	log.Printf("[Auth] role of userID %d changed by admin %d: %s", u.ID, adminID, detail)
+	return u, a.audit.Create(&domain.AuditEntry{ActorID: adminID, Action: domain.AuditUserRoleChanged, TargetUserID: u.ID, Detail: detail, IP: ip})
+}
+
+// SetDisabled disables (and logs out everywhere) or re-enables a user.
+func (a *AuthUsecase) SetDisabled(adminID, userID uint, disabled bool, ip string) (*domain.User, error) {
+	u, err := a.otherUser(adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if (u.DisabledAt != nil) == disabled {
+		return u, nil
+	}
+	action := domain.AuditUserEnabled
+	u.DisabledAt = nil
+	if disabled {
+		now := time.Now()
+		action = domain.AuditUserDisabled
+		u.DisabledAt = &now
+	}
+	if err := a.repo.Update(u); err != nil {
+		return nil, err
+	}
+	if disabled {
+		if err := a.revokeUser(u.ID); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("[Auth] %s for userID %d by admin %d", action, u.ID, adminID)
+	return u, a.audit.Create(&domain.AuditEntry{ActorID: adminID, Action: action, TargetUserID: u.ID, IP: ip})
+}
+
+// otherUser loads the user an admin acts on; admins cannot demote or
+// disable themselves, so there is always one left.
+func (a *AuthUsecase) otherUser(adminID, userID uint) (*domain.User, error) {
+	if !a.IsAdmin(adminID) {
+		return nil, ErrForbidden
+	}
+	if adminID == userID {
+		return nil, errors.New("admins cannot change their own role or account")
+	}
+	u, err := a.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.New("record not found")
+	}
+	return u, nil
+}
+
+func (a *AuthUsecase) revokeUser(userID uint) error {
+	if err := a.tokens.RevokeUser(userID); err != nil {
+		return err
+	}
+	a.syncRevocations()
+	return nil
+}
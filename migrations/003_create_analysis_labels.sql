@@ -0,0 +1,12 @@
+-- Key/value labels per analysis (service=checkout, env=prod, host=web-3, ...)
+CREATE TABLE IF NOT EXISTS analysis_labels (
+    analysis_id INT NOT NULL REFERENCES log_analysis(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (analysis_id, key)
+);
+
+-- filter "key=value" dan group by key
+CREATE INDEX IF NOT EXISTS idx_analysis_labels_key_value ON analysis_labels (key, value);
+
+CREATE INDEX IF NOT EXISTS idx_log_analysis_user_id ON log_analysis (user_id);
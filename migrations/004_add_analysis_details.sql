@@ -0,0 +1,2 @@
+-- Status mix, top endpoints dan time series per analysis
+ALTER TABLE log_analysis ADD COLUMN IF NOT EXISTS details JSONB;
@@ -0,0 +1,27 @@
+-- Streams: sumber log yang di-push terus-menerus (POST /api/ingest/:stream)
+CREATE TABLE IF NOT EXISTS streams (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    format TEXT NOT NULL DEFAULT 'default',
+    window_seconds INT NOT NULL DEFAULT 300,
+    labels JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_streams_updated_at
+    BEFORE UPDATE ON streams
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Each time window of a stream (per label set) is one log_analysis row
+ALTER TABLE log_analysis
+    ADD COLUMN IF NOT EXISTS stream_id INT REFERENCES streams(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS window_start TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS window_end TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS window_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_log_analysis_window
+    ON log_analysis (stream_id, window_start, window_key);
@@ -0,0 +1,7 @@
+-- Posisi baca terakhir per file untuk tail mode, supaya restart tidak baca ulang
+CREATE TABLE IF NOT EXISTS tail_checkpoints (
+    path TEXT PRIMARY KEY,
+    inode BIGINT NOT NULL DEFAULT 0,
+    "offset" BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
@@ -0,0 +1,30 @@
+-- Parsed records kept for drill-down (RECORDS_RETAIN=true).
+-- Uploads get one partition per analysis, streams one partition per day,
+-- so retention and deletes drop whole partitions instead of deleting rows.
+-- Partitions are created by the application when records are written.
+CREATE TABLE IF NOT EXISTS log_records (
+    analysis_id INT NOT NULL,
+    user_id INT NOT NULL,
+    source TEXT NOT NULL,            -- 'upload' or 'stream'
+    day DATE NOT NULL,
+    line_no BIGINT,
+    time TIMESTAMP WITH TIME ZONE,
+    method TEXT,
+    path TEXT,
+    status INT,
+    latency_ms DOUBLE PRECISION,
+    ip TEXT,
+    level TEXT,
+    message TEXT,
+    attributes JSONB,
+    raw TEXT
+) PARTITION BY LIST (source);
+
+CREATE TABLE IF NOT EXISTS log_records_upload PARTITION OF log_records
+    FOR VALUES IN ('upload') PARTITION BY LIST (analysis_id);
+
+CREATE TABLE IF NOT EXISTS log_records_stream PARTITION OF log_records
+    FOR VALUES IN ('stream') PARTITION BY RANGE (day);
+
+CREATE INDEX IF NOT EXISTS idx_log_records_analysis_time ON log_records (analysis_id, time);
+CREATE INDEX IF NOT EXISTS idx_log_records_analysis_status ON log_records (analysis_id, status);
@@ -0,0 +1,11 @@
+-- Full-text search over retained lines.
+-- The tsvector ('simple': no stemming, so request IDs and paths stay intact)
+-- serves words, phrases and prefixes; the trigram index serves wildcards.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS search TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(raw, message, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_log_records_search ON log_records USING GIN (search);
+CREATE INDEX IF NOT EXISTS idx_log_records_raw_trgm ON log_records USING GIN (raw gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_log_records_user_time ON log_records (user_id, time);
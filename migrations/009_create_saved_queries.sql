@@ -0,0 +1,45 @@
+-- Saved queries and reports, optionally run on a cron schedule
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,                  -- 'query' (query language) or 'report' (analyses)
+    query TEXT NOT NULL DEFAULT '',
+    analysis_id INT,                     -- query scope, NULL = all analyses of the user
+    range_seconds INT NOT NULL DEFAULT 0, -- look back from the run time, 0 = everything
+    labels JSONB NOT NULL DEFAULT '{}',
+    group_by JSONB NOT NULL DEFAULT '[]',
+    format TEXT NOT NULL,
+    schedule TEXT NOT NULL DEFAULT '',   -- cron expression, '' = manual runs only
+    notify JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_saved_queries_updated_at
+    BEFORE UPDATE ON saved_queries
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX IF NOT EXISTS idx_saved_queries_due ON saved_queries (next_run_at) WHERE enabled;
+
+-- Output of every run; the newest runs per saved query are kept
+CREATE TABLE IF NOT EXISTS report_runs (
+    id SERIAL PRIMARY KEY,
+    saved_query_id INT NOT NULL REFERENCES saved_queries(id) ON DELETE CASCADE,
+    trigger TEXT NOT NULL,               -- 'schedule' or 'manual'
+    status TEXT NOT NULL,                -- 'ok' or 'failed'
+    error TEXT NOT NULL DEFAULT '',
+    delivery_error TEXT NOT NULL DEFAULT '',
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    output BYTEA,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_runs_saved_query ON report_runs (saved_query_id, id DESC);
@@ -0,0 +1,53 @@
+-- Alert rules evaluated on completed analyses and live stream windows
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}', -- analyses must carry these labels
+    expr TEXT NOT NULL,                 -- e.g. 'error_rate > 5% for 10m'
+    min_requests INT NOT NULL DEFAULT 0,
+    notify JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_alert_rules_updated_at
+    BEFORE UPDATE ON alert_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per rule and label set
+CREATE TABLE IF NOT EXISTS alerts (
+    id SERIAL PRIMARY KEY,
+    rule_id INT NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    series_key TEXT NOT NULL,           -- fingerprint of labels
+    labels JSONB NOT NULL DEFAULT '{}',
+    state TEXT NOT NULL,                -- 'ok', 'pending', 'firing', 'resolved'
+    value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    prev_value DOUBLE PRECISION,
+    analysis_id INT NOT NULL DEFAULT 0, -- analysis of the last evaluation
+    active_since TIMESTAMP WITH TIME ZONE,
+    fired_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    last_eval_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    notified_state TEXT NOT NULL DEFAULT '',
+    last_notified_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (rule_id, series_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_active ON alerts (last_eval_at) WHERE state IN ('pending', 'firing');
+
+CREATE TABLE IF NOT EXISTS alert_silences (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rule_id INT REFERENCES alert_rules(id) ON DELETE CASCADE, -- NULL = every rule
+    labels JSONB NOT NULL DEFAULT '{}',
+    comment TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_silences_user ON alert_silences (user_id, ends_at);
@@ -0,0 +1,3 @@
+-- A new TOTP secret waits here until a code from it is verified, so
+-- re-keying never locks the user out with a secret they did not scan.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_pending_secret TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Last accepted TOTP time step (unix time / 30); a code is only accepted
+-- for a later step, so a code cannot be used twice.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
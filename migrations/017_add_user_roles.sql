@@ -0,0 +1,17 @@
+-- Roles: admin manages users and sees everything, analyst uploads and edits
+-- their own analyses, viewer only reads what is shared with them.
+-- Existing accounts keep what they could do so far.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'analyst'
+    CHECK (role IN ('admin', 'analyst', 'viewer'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- Analyses shared read-only with other users
+CREATE TABLE IF NOT EXISTS analysis_shares (
+    analysis_id INT NOT NULL REFERENCES log_analysis(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    PRIMARY KEY (analysis_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_shares_user ON analysis_shares (user_id);
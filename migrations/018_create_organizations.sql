@@ -0,0 +1,73 @@
+-- Organizations (teams): analyses, streams, alert rules and saved queries
+-- belong to an org; user_id stays as who created them
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Per-org roles, same names as the user roles
+CREATE TABLE IF NOT EXISTS org_members (
+    org_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'analyst', 'viewer')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_org_members_user ON org_members (user_id);
+
+CREATE TABLE IF NOT EXISTS org_invitations (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'analyst', 'viewer')),
+    token_hash TEXT UNIQUE NOT NULL,     -- sha256 of the token
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_org_invitations_org ON org_invitations (org_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active_org_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- Every existing user gets a personal org holding what they own so far
+INSERT INTO organizations (name, created_by)
+SELECT COALESCE(NULLIF(u.name, ''), u.email), u.id FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM org_members m WHERE m.user_id = u.id);
+
+INSERT INTO org_members (org_id, user_id, role)
+SELECT o.id, o.created_by, 'admin' FROM organizations o
+WHERE o.created_by IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM org_members m WHERE m.user_id = o.created_by);
+
+UPDATE users u SET active_org_id = m.org_id
+FROM org_members m WHERE m.user_id = u.id AND u.active_org_id IS NULL;
+
+ALTER TABLE log_analysis ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE streams ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE alert_silences ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE saved_queries ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS org_id INT;
+
+UPDATE log_analysis t SET org_id = u.active_org_id FROM users u WHERE t.user_id = u.id AND t.org_id IS NULL;
+UPDATE streams t SET org_id = u.active_org_id FROM users u WHERE t.user_id = u.id AND t.org_id IS NULL;
+UPDATE alert_rules t SET org_id = u.active_org_id FROM users u WHERE t.user_id = u.id AND t.org_id IS NULL;
+UPDATE alert_silences t SET org_id = u.active_org_id FROM users u WHERE t.user_id = u.id AND t.org_id IS NULL;
+UPDATE saved_queries t SET org_id = u.active_org_id FROM users u WHERE t.user_id = u.id AND t.org_id IS NULL;
+UPDATE log_records t SET org_id = a.org_id FROM log_analysis a WHERE t.analysis_id = a.id AND t.org_id IS NULL;
+
+-- names are unique per org now, not per user
+ALTER TABLE streams DROP CONSTRAINT IF EXISTS streams_user_id_name_key;
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rules_user_id_name_key;
+ALTER TABLE saved_queries DROP CONSTRAINT IF EXISTS saved_queries_user_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_streams_org_name ON streams (org_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_rules_org_name ON alert_rules (org_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_queries_org_name ON saved_queries (org_id, name);
+
+CREATE INDEX IF NOT EXISTS idx_log_analysis_org_id ON log_analysis (org_id);
+CREATE INDEX IF NOT EXISTS idx_log_records_org_time ON log_records (org_id, time);
@@ -0,0 +1,137 @@
+// Package elastic reads Elasticsearch bulk requests (NDJSON action and
+// document pairs) and builds the responses bulk clients expect.
+package elastic
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const maxBulkLineBytes = 10 << 20
+
+// Action is one bulk operation. Err is set for operations the analyzer
+// cannot apply (update, delete, invalid documents); they are reported per
+// item instead of failing the request, like Elasticsearch does.
+type Action struct {
+	Op    string // index or create
+	Index string
+	ID    string
+	Doc   map[string]interface{}
+	Err   error
+}
+
+// ParseBulk reads the request body. defaultIndex comes from /{index}/_bulk.
+func ParseBulk(r io.Reader, defaultIndex string) ([]Action, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxBulkLineBytes)
+	var actions []Action
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var meta map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &meta); err != nil || len(meta) != 1 {
+			return nil, fmt.Errorf("malformed action/metadata line [%d]", len(actions)+1)
+		}
+
+		var a Action
+		for op, m := range meta {
+			a = Action{Op: op, Index: m.Index, ID: m.ID}
+		}
+		if a.Index == "" {
+			a.Index = defaultIndex
+		}
+		if a.ID == "" {
+			a.ID = newID()
+		}
+
+		switch a.Op {
+		case "delete":
+			a.Err = fmt.Errorf("delete is not supported")
+			actions = append(actions, a)
+			continue
+		case "index", "create", "update":
+		default:
+			return nil, fmt.Errorf("unknown bulk action [%s]", a.Op)
+		}
+
+		if !sc.Scan() {
+			return nil, fmt.Errorf("the bulk request must be terminated by a newline [\\n]")
+		}
+		switch {
+		case a.Op == "update":
+			a.Err = fmt.Errorf("update is not supported")
+		case a.Index == "":
+			a.Err = fmt.Errorf("index is missing")
+		default:
+			dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+			dec.UseNumber()
+			if err := dec.Decode(&a.Doc); err != nil || a.Doc == nil {
+				a.Err = fmt.Errorf("failed to parse document: not a JSON object")
+			}
+		}
+		actions = append(actions, a)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// Flatten turns nested objects into dotted keys: {"http":{"request":{"method":"GET"}}}
+// becomes {"http.request.method":"GET"}. Arrays are kept as values.
+func Flatten(doc map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, len(doc))
+	flattenInto(flat, "", doc)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, doc map[string]interface{}) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			flattenInto(flat, key, m)
+			continue
+		}
+		flat[key] = v
+	}
+}
+
+// String formats a flattened value for record attributes.
+func String(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// newID makes a 20 character id like Elasticsearch's auto-generated ones
+func newID() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
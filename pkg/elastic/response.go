@@ -0,0 +1,61 @@
+package elastic
+
+import "net/http"
+
+// Version reported to clients. Beats, Logstash and Vector check the major
+// version on startup and pick the matching bulk format (no _type in 8.x).
+const Version = "8.11.0"
+
+// Info is the body of GET /, which clients use as a version check.
+func Info(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         name,
+		"cluster_name": name,
+		"cluster_uuid": "log-analyzer",
+		"version": map[string]interface{}{
+			"number":                              Version,
+			"build_flavor":                        "default",
+			"build_type":                          "docker",
+			"lucene_version":                      "9.8.0",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	}
+}
+
+// Item is one entry of the bulk response "items" array.
+func Item(a Action, status int, err error) map[string]interface{} {
+	op := a.Op
+	if op == "" {
+		op = "index"
+	}
+	item := map[string]interface{}{
+		"_index": a.Index,
+		"_id":    a.ID,
+		"status": status,
+	}
+	if err != nil {
+		errType := "illegal_argument_exception"
+		if status == http.StatusTooManyRequests {
+			errType = "es_rejected_execution_exception"
+		}
+		item["error"] = map[string]interface{}{"type": errType, "reason": err.Error()}
+	} else {
+		item["_version"] = 1
+		item["result"] = "created"
+		item["_shards"] = map[string]int{"total": 1, "successful": 1, "failed": 0}
+		item["_seq_no"] = 0
+		item["_primary_term"] = 1
+	}
+	return map[string]interface{}{op: item}
+}
+
+// Error is a top-level error body, e.g. for a malformed bulk request.
+func Error(status int, errType, reason string) map[string]interface{} {
+	cause := map[string]interface{}{"type": errType, "reason": reason}
+	return map[string]interface{}{
+		"error":  map[string]interface{}{"root_cause": []interface{}{cause}, "type": errType, "reason": reason},
+		"status": status,
+	}
+}
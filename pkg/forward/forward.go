@@ -0,0 +1,180 @@
+// Package forward decodes the Fluentd Forward protocol (v1) used by
+// Fluentd and Fluent Bit: Message, Forward, PackedForward and
+// CompressedPackedForward modes, the ack response and the shared-key
+// handshake.
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// Message is one decoded forward message. Chunk is set when the sender
+// asked for an ack (option "chunk").
+type Message struct {
+	Tag     string
+	Entries []Entry
+	Chunk   string
+}
+
+var ErrInvalidMessage = errors.New("forward: invalid message")
+
+// Read decodes the next message from the stream; io.EOF means the peer
+// closed the connection between messages.
+func Read(d *Decoder) (*Message, error) {
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 2 {
+		return nil, ErrInvalidMessage
+	}
+	tag, ok := arr[0].(string)
+	if !ok {
+		return nil, ErrInvalidMessage
+	}
+	m := &Message{Tag: tag}
+
+	// the option map follows the entries: index 3 in Message mode, else 2
+	optionAt := 2
+	switch arr[1].(type) {
+	case []interface{}, string, []byte:
+	default:
+		optionAt = 3
+	}
+	var option map[string]interface{}
+	if len(arr) > optionAt {
+		option, _ = arr[optionAt].(map[string]interface{})
+	}
+	if chunk, ok := option["chunk"].(string); ok {
+		m.Chunk = chunk
+	}
+
+	switch second := arr[1].(type) {
+	case []interface{}:
+		// Forward: [tag, [[time, record], ...], option]
+		for _, item := range second {
+			e, err := entry(item)
+			if err != nil {
+				return nil, err
+			}
+			m.Entries = append(m.Entries, e)
+		}
+	case string, []byte:
+		// PackedForward: [tag, <msgpack stream of [time, record]>, option]
+		packed := toBytes(second)
+		if option["compressed"] == "gzip" {
+			if packed, err = gunzip(packed); err != nil {
+				return nil, err
+			}
+		}
+		inner := NewDecoder(bytes.NewReader(packed))
+		for {
+			if len(m.Entries) >= maxObjectItems {
+				return nil, ErrTooLarge
+			}
+			item, err := inner.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("forward: invalid packed entries: %w", err)
+			}
+			e, err := entry(item)
+			if err != nil {
+				return nil, err
+			}
+			m.Entries = append(m.Entries, e)
+		}
+	default:
+		// Message: [tag, time, record, option]
+		if len(arr) < 3 {
+			return nil, ErrInvalidMessage
+		}
+		e, err := entry([]interface{}{arr[1], arr[2]})
+		if err != nil {
+			return nil, err
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return m, nil
+}
+
+// gunzip inflates CompressedPackedForward entries up to maxObjectBytes.
+func gunzip(packed []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("forward: invalid gzip entries: %w", err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxObjectBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("forward: invalid gzip entries: %w", err)
+	}
+	if len(data) > maxObjectBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// entry decodes [time, record]
+func entry(v interface{}) (Entry, error) {
+	pair, ok := v.([]interface{})
+	if !ok || len(pair) < 2 {
+		return Entry{}, ErrInvalidMessage
+	}
+	t, err := eventTime(pair[0])
+	if err != nil {
+		return Entry{}, err
+	}
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return Entry{}, errors.New("forward: record must be a map")
+	}
+	return Entry{Time: t, Record: record}, nil
+}
+
+// eventTime accepts unix seconds or the EventTime extension
+// (type 0: big-endian uint32 seconds + uint32 nanoseconds).
+func eventTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case uint64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case float64:
+		return time.Unix(0, int64(t*1e9)).UTC(), nil
+	case Ext:
+		if t.Type == 0 && len(t.Data) == 8 {
+			sec := binary.BigEndian.Uint32(t.Data[:4])
+			nsec := binary.BigEndian.Uint32(t.Data[4:])
+			return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("forward: invalid event time")
+}
+
+func toBytes(v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		return []byte(s)
+	}
+	return v.([]byte)
+}
+
+// Ack encodes the {"ack": chunk} response for a message that asked for one.
+func Ack(chunk string) []byte {
+	b := []byte{0x81} // fixmap with one entry
+	b = appendString(b, "ack")
+	return appendString(b, chunk)
+}
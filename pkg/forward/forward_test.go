@@ -0,0 +1,178 @@
+package forward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	fwdT0  = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fwdRec = map[string]interface{}{"log": "GET /api 200", "host": "web01"}
+)
+
+func eventTimeExt(t time.Time) Ext {
+	b := binary.BigEndian.AppendUint32(nil, uint32(t.Unix()))
+	return Ext{Type: 0, Data: binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))}
+}
+
+func packedEntries(entries ...[]interface{}) []byte {
+	var b []byte
+	for _, e := range entries {
+		b = append(b, pack(e)...)
+	}
+	return b
+}
+
+func gzipped(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func readOne(b []byte) (*Message, error) {
+	return Read(NewDecoder(bytes.NewReader(b)))
+}
+
+func TestRead(t *testing.T) {
+	entry1 := []interface{}{int(fwdT0.Unix()), fwdRec}
+	entry2 := []interface{}{eventTimeExt(fwdT0.Add(1500 * time.Millisecond)), map[string]interface{}{"msg": "b"}}
+	want := []Entry{
+		{Time: fwdT0, Record: fwdRec},
+		{Time: fwdT0.Add(1500 * time.Millisecond), Record: map[string]interface{}{"msg": "b"}},
+	}
+	packed := packedEntries(entry1, entry2)
+
+	cases := []struct {
+		name string
+		in   []interface{}
+		want *Message
+	}{
+		{
+			"Message",
+			[]interface{}{"app.web", int(fwdT0.Unix()), fwdRec},
+			&Message{Tag: "app.web", Entries: want[:1]},
+		},
+		{
+			"Message with ack",
+			[]interface{}{"app.web", 1.772366400e9, fwdRec, map[string]interface{}{"chunk": "c1"}},
+			&Message{Tag: "app.web", Entries: want[:1], Chunk: "c1"},
+		},
+		{
+			"Forward",
+			[]interface{}{"app.web", []interface{}{entry1, entry2}},
+			&Message{Tag: "app.web", Entries: want},
+		},
+		{
+			"Forward with ack",
+			[]interface{}{"app.web", []interface{}{entry1}, map[string]interface{}{"chunk": "c2", "size": 1}},
+			&Message{Tag: "app.web", Entries: want[:1], Chunk: "c2"},
+		},
+		{
+			"Forward without entries",
+			[]interface{}{"app.web", []interface{}{}},
+			&Message{Tag: "app.web"},
+		},
+		{
+			"PackedForward as bin",
+			[]interface{}{"app.web", packed},
+			&Message{Tag: "app.web", Entries: want},
+		},
+		{
+			"PackedForward as str",
+			[]interface{}{"app.web", string(packed), map[string]interface{}{"chunk": "c3"}},
+			&Message{Tag: "app.web", Entries: want, Chunk: "c3"},
+		},
+		{
+			"CompressedPackedForward",
+			[]interface{}{"app.web", gzipped(packed), map[string]interface{}{"compressed": "gzip", "chunk": "c4"}},
+			&Message{Tag: "app.web", Entries: want, Chunk: "c4"},
+		},
+	}
+	for _, c := range cases {
+		got, err := readOne(pack(c.in))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	b := append(pack([]interface{}{"a", 1, fwdRec}), pack([]interface{}{"b", 2, fwdRec})...)
+	d := NewDecoder(bytes.NewReader(b))
+	for _, tag := range []string{"a", "b"} {
+		m, err := Read(d)
+		if err != nil || m.Tag != tag {
+			t.Fatalf("Read = %+v, %v, want tag %s", m, err, tag)
+		}
+	}
+	if _, err := Read(d); err != io.EOF {
+		t.Errorf("after the last message: %v, want io.EOF", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"not an array", pack("app"), ErrInvalidMessage.Error()},
+		{"only a tag", pack([]interface{}{"app"}), ErrInvalidMessage.Error()},
+		{"tag not a string", pack([]interface{}{1, 2, fwdRec}), ErrInvalidMessage.Error()},
+		{"Message without record", pack([]interface{}{"app", 1}), ErrInvalidMessage.Error()},
+		{"record not a map", pack([]interface{}{"app", 1, "line"}), "forward: record must be a map"},
+		{"bad time", pack([]interface{}{"app", true, fwdRec}), "forward: invalid event time"},
+		{"bad EventTime", pack([]interface{}{"app", Ext{Type: 0, Data: []byte{1}}, fwdRec}), "forward: invalid event time"},
+		{"entry not a pair", pack([]interface{}{"app", []interface{}{[]interface{}{1}}}), ErrInvalidMessage.Error()},
+		{"truncated packed entries", pack([]interface{}{"app", packedEntries([]interface{}{1, fwdRec})[:5]}), "forward: invalid packed entries: unexpected EOF"},
+		{"not gzip", pack([]interface{}{"app", []byte("plain"), map[string]interface{}{"compressed": "gzip"}}), "forward: invalid gzip entries: unexpected EOF"},
+		{"truncated gzip", pack([]interface{}{"app", gzipped(packedEntries([]interface{}{1, fwdRec}))[:15], map[string]interface{}{"compressed": "gzip"}}), "forward: invalid gzip entries: unexpected EOF"},
+		{"truncated message", pack([]interface{}{"app", 1, fwdRec})[:12], io.ErrUnexpectedEOF.Error()},
+	}
+	for _, c := range cases {
+		_, err := readOne(c.in)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%s: err = %v, want %s", c.name, err, c.want)
+		}
+	}
+}
+
+func TestReadGzipBomb(t *testing.T) {
+	// inflates past maxObjectBytes while the message itself is small
+	big := gzipped(make([]byte, maxObjectBytes+1))
+	_, err := readOne(pack([]interface{}{"app", big, map[string]interface{}{"compressed": "gzip"}}))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestReadPackedEntryLimit(t *testing.T) {
+	// [1, {}] is three bytes; the entry count is checked before each decode
+	packed := bytes.Repeat([]byte{0x90 | 2, 0x01, 0x80}, maxObjectItems+1)
+	_, err := readOne(pack([]interface{}{"app", packed}))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestAck(t *testing.T) {
+	got, err := decodeAll(Ack("c1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{map[string]interface{}{"ack": "c1"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ack = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package forward
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+)
+
+var ErrInvalidPing = errors.New("forward: invalid PING")
+
+// Helo is the server's greeting when a shared key is configured:
+// ["HELO", {"nonce": nonce, "auth": "", "keepalive": true}]. The empty auth
+// salt tells the client that no username/password is required.
+func Helo(nonce []byte) []byte {
+	b := appendArray(nil, 2)
+	b = appendString(b, "HELO")
+	b = appendMap(b, 3)
+	b = appendString(b, "nonce")
+	b = appendBin(b, nonce)
+	b = appendString(b, "auth")
+	b = appendBin(b, nil)
+	b = appendString(b, "keepalive")
+	return appendBool(b, true)
+}
+
+// Ping is the client's answer to HELO:
+// ["PING", hostname, shared_key_salt, digest, username, password_digest]
+type Ping struct {
+	Hostname      string
+	SharedKeySalt string
+	Digest        string
+}
+
+func ReadPing(d *Decoder) (*Ping, error) {
+	v, err := d.Decode()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 4 {
+		return nil, ErrInvalidPing
+	}
+	fields := make([]string, 4)
+	for i := range fields {
+		if fields[i], ok = text(arr[i]); !ok {
+			return nil, ErrInvalidPing
+		}
+	}
+	if fields[0] != "PING" {
+		return nil, ErrInvalidPing
+	}
+	return &Ping{Hostname: fields[1], SharedKeySalt: fields[2], Digest: fields[3]}, nil
+}
+
+// Verify checks the PING digest against the nonce sent in HELO.
+func (p *Ping) Verify(nonce []byte, sharedKey string) bool {
+	want := Digest(p.SharedKeySalt, p.Hostname, nonce, sharedKey)
+	return subtle.ConstantTimeCompare([]byte(p.Digest), []byte(want)) == 1
+}
+
+// Digest is hex(sha512(salt + hostname + nonce + sharedKey)), used both in
+// PING (client hostname) and PONG (server hostname).
+func Digest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Pong answers a PING: ["PONG", ok, reason, hostname, digest]. A rejected
+// client gets an empty hostname and digest.
+func Pong(ok bool, reason, hostname, digest string) []byte {
+	b := appendArray(nil, 5)
+	b = appendString(b, "PONG")
+	b = appendBool(b, ok)
+	b = appendString(b, reason)
+	b = appendString(b, hostname)
+	return appendString(b, digest)
+}
+
+// text accepts str and bin, since senders differ in which one they use
+func text(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	}
+	return "", false
+}
@@ -0,0 +1,78 @@
+package forward
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	nonce := []byte{0, 1, 2, 0xff}
+	helo, err := decodeAll(Helo(nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHelo := []interface{}{"HELO", map[string]interface{}{"nonce": nonce, "auth": []byte{}, "keepalive": true}}
+	if len(helo) != 1 || !reflect.DeepEqual(helo[0], wantHelo) {
+		t.Fatalf("HELO = %#v, want %#v", helo, wantHelo)
+	}
+
+	// as Fluentd's out_forward builds it
+	digest := Digest("salt", "client01", nonce, "secret")
+	in := pack([]interface{}{"PING", "client01", []byte("salt"), digest, "", ""})
+	ping, err := ReadPing(NewDecoder(bytes.NewReader(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Ping{Hostname: "client01", SharedKeySalt: "salt", Digest: digest}); !reflect.DeepEqual(ping, want) {
+		t.Errorf("PING = %+v, want %+v", ping, want)
+	}
+	if !ping.Verify(nonce, "secret") {
+		t.Error("valid PING rejected")
+	}
+	if ping.Verify(nonce, "other") {
+		t.Error("PING with the wrong key accepted")
+	}
+	if ping.Verify([]byte{9}, "secret") {
+		t.Error("PING for another nonce accepted")
+	}
+
+	pong, err := decodeAll(Pong(true, "", "analyzer", "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"PONG", true, "", "analyzer", "abc"}; len(pong) != 1 || !reflect.DeepEqual(pong[0], want) {
+		t.Errorf("PONG = %#v, want %#v", pong, want)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	// sha512 over salt + hostname + nonce + key, hex encoded
+	const want = "22a7a485ef3619238022842cf7f3e6a29ba010a8857e0801896d467beaa6aa37b00e15bc7261de5643aa2c9b4cd6eadf3e378ad6aedbeb31ce7571852348e656"
+	if got := Digest("salt", "client01", []byte{0, 1, 2, 0xff}, "secret"); got != want {
+		t.Errorf("Digest = %s, want %s", got, want)
+	}
+}
+
+func TestReadPingErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want error
+	}{
+		{"not an array", pack("PING"), ErrInvalidPing},
+		{"too short", pack([]interface{}{"PING", "host", "salt"}), ErrInvalidPing},
+		{"not a PING", pack([]interface{}{"HELO", "host", "salt", "d"}), ErrInvalidPing},
+		{"digest not text", pack([]interface{}{"PING", "host", "salt", 1}), ErrInvalidPing},
+		{"closed", nil, io.ErrUnexpectedEOF},
+		{"truncated", pack([]interface{}{"PING", "host", "salt", "d"})[:8], io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		_, err := ReadPing(NewDecoder(bytes.NewReader(c.in)))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package forward
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Limits per top-level value (one forward message); anything past them is
+// treated as a broken or hostile peer.
+const (
+	maxObjectBytes   = 64 << 20 // all value data together
+	maxObjectItems   = 1 << 20  // array items and map entries together
+	maxObjectNesting = 64
+)
+
+var (
+	ErrTooLarge = errors.New("msgpack: object too large")
+	ErrTooDeep  = errors.New("msgpack: object nested too deeply")
+)
+
+// Ext is a msgpack extension value. Type 0 is Fluentd's EventTime.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Decoder reads msgpack values one at a time from a stream. Maps decode to
+// map[string]interface{}, str to string, bin to []byte, integers to int64
+// or uint64 and floats to float64.
+type Decoder struct {
+	r *bufio.Reader
+
+	// budget of the value being decoded
+	depth int
+	bytes int
+	items int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	if br, ok := r.(*bufio.Reader); ok {
+		return &Decoder{r: br}
+	}
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value; each one gets the full size budget.
+func (d *Decoder) Decode() (interface{}, error) {
+	d.depth, d.bytes, d.items = 0, 0, 0
+	return d.decode()
+}
+
+func (d *Decoder) decode() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.readMap(int(c & 0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return d.readArray(int(c & 0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		return d.readString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLen(c - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLen(c - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		b, err := d.readBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := d.readBytes(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return beUint(b), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		b, err := d.readBytes(1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		// sign-extend from the encoded width
+		shift := 64 - 8*uint(len(b))
+		return int64(beUint(b)<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLen(c - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLen(c - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLen(c - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+}
+
+// readLen reads a 1, 2 or 4 byte length (width 0, 1, 2)
+func (d *Decoder) readLen(width byte) (int, error) {
+	b, err := d.readBytes(1 << width)
+	if err != nil {
+		return 0, err
+	}
+	n := beUint(b)
+	if n > maxObjectBytes {
+		return 0, ErrTooLarge
+	}
+	return int(n), nil
+}
+
+func (d *Decoder) readBytes(n int) ([]byte, error) {
+	if d.bytes += n; d.bytes > maxObjectBytes {
+		return nil, ErrTooLarge
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+func (d *Decoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *Decoder) readExt(n int) (Ext, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return Ext{}, unexpected(err)
+	}
+	b, err := d.readBytes(n)
+	return Ext{Type: int8(t), Data: b}, err
+}
+
+// enter accounts for a container of n items, until the returned func runs
+func (d *Decoder) enter(n int) (func(), error) {
+	if d.items += n; d.items > maxObjectItems {
+		return nil, ErrTooLarge
+	}
+	if d.depth++; d.depth > maxObjectNesting {
+		return nil, ErrTooDeep
+	}
+	return func() { d.depth-- }, nil
+}
+
+func (d *Decoder) readArray(n int) ([]interface{}, error) {
+	leave, err := d.enter(n)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	list := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (d *Decoder) readMap(n int) (map[string]interface{}, error) {
+	leave, err := d.enter(n)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	m := make(map[string]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		m[keyString(k)] = v
+	}
+	return m, nil
+}
+
+func keyString(k interface{}) string {
+	switch t := k.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	}
+	return fmt.Sprint(k)
+}
+
+func beUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// EOF inside a value is a truncated message, not a clean end of stream
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendString encodes a msgpack str
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n < 1<<8:
+		b = append(b, 0xd9, byte(n))
+	case n < 1<<16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, s...)
+}
+
+func appendBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n < 1<<8:
+		b = append(b, 0xc4, byte(n))
+	case n < 1<<16:
+		b = append(b, 0xc5, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xc6, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, data...)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// appendArray and appendMap write a container header for up to 15 items
+func appendArray(b []byte, n int) []byte { return append(b, 0x90|byte(n)) }
+
+func appendMap(b []byte, n int) []byte { return append(b, 0x80|byte(n)) }
@@ -0,0 +1,182 @@
+package forward
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// pack encodes test values; maps are written with sorted keys.
+func pack(v interface{}) []byte {
+	var b []byte
+	switch t := v.(type) {
+	case nil:
+		b = append(b, 0xc0)
+	case bool:
+		b = appendBool(b, t)
+	case int:
+		b = append(b, 0xd3)
+		b = binary.BigEndian.AppendUint64(b, uint64(t))
+	case float64:
+		b = append(b, 0xcb)
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(t))
+	case string:
+		b = appendString(b, t)
+	case []byte:
+		b = appendBin(b, t)
+	case Ext:
+		b = append(b, 0xc7, byte(len(t.Data)), byte(t.Type))
+		b = append(b, t.Data...)
+	case []interface{}:
+		b = append(b, 0xdd)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, item := range t {
+			b = append(b, pack(item)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = append(b, 0xdf)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, k := range keys {
+			b = append(b, pack(k)...)
+			b = append(b, pack(t[k])...)
+		}
+	default:
+		panic("pack: unsupported type")
+	}
+	return b
+}
+
+func decodeAll(b []byte) ([]interface{}, error) {
+	d := NewDecoder(bytes.NewReader(b))
+	var values []interface{}
+	for {
+		v, err := d.Decode()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		in   []byte
+		want interface{}
+	}{
+		{[]byte{0x05}, int64(5)},
+		{[]byte{0xff}, int64(-1)},
+		{[]byte{0xcc, 0xff}, uint64(255)},
+		{[]byte{0xcd, 0x01, 0x00}, uint64(256)},
+		{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+		{[]byte{0xd0, 0x80}, int64(-128)},
+		{[]byte{0xd1, 0xff, 0x00}, int64(-256)},
+		{[]byte{0xd2, 0x80, 0x00, 0x00, 0x00}, int64(math.MinInt32)},
+		{pack(-7), int64(-7)},
+		{[]byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, 1.5},
+		{pack(2.25), 2.25},
+		{[]byte{0xc0}, nil},
+		{[]byte{0xc2}, false},
+		{[]byte{0xc3}, true},
+		{[]byte{0xa3, 'a', 'b', 'c'}, "abc"},
+		{[]byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		{appendString(nil, strings.Repeat("x", 300)), strings.Repeat("x", 300)},
+		{[]byte{0xc4, 0x02, 0x01, 0x02}, []byte{1, 2}},
+		{[]byte{0xd4, 0x05, 0xaa}, Ext{Type: 5, Data: []byte{0xaa}}},
+		{[]byte{0xd7, 0x00, 0, 0, 0, 1, 0, 0, 0, 2}, Ext{Type: 0, Data: []byte{0, 0, 0, 1, 0, 0, 0, 2}}},
+		{[]byte{0x92, 0x01, 0xa1, 'x'}, []interface{}{int64(1), "x"}},
+		// non-string keys are formatted
+		{[]byte{0x82, 0xa1, 'a', 0x01, 0x07, 0xc3}, map[string]interface{}{"a": int64(1), "7": true}},
+		{pack(map[string]interface{}{"log": "line", "n": []interface{}{nil, 1.5}}), map[string]interface{}{"log": "line", "n": []interface{}{nil, 1.5}}},
+	}
+	for _, c := range cases {
+		got, err := decodeAll(c.in)
+		if err != nil {
+			t.Errorf("% x: %v", c.in, err)
+			continue
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], c.want) {
+			t.Errorf("% x = %#v, want %#v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	got, err := decodeAll(append(pack("a"), pack([]interface{}{1})...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"a", []interface{}{int64(1)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+// nested returns n arrays, each holding the next
+func nested(n int) []byte {
+	b := bytes.Repeat([]byte{0x91}, n)
+	return append(b, 0xc0)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	full := pack([]interface{}{"tag", map[string]interface{}{"log": "line"}})
+	cases := []struct {
+		name string
+		in   []byte
+		want error
+	}{
+		{"str length past the limit", []byte{0xdb, 0x04, 0x00, 0x00, 0x01}, ErrTooLarge},
+		{"bin length past the limit", []byte{0xc6, 0xff, 0xff, 0xff, 0xff}, ErrTooLarge},
+		{"array length past the limit", []byte{0xdd, 0x00, 0x10, 0x00, 0x01}, ErrTooLarge},
+		{"map length past the limit", []byte{0xdf, 0x00, 0x10, 0x00, 0x01}, ErrTooLarge},
+		{"nested items past the limit", append([]byte{0xdd, 0x00, 0x08, 0x00, 0x00, 0xdd, 0x00, 0x08, 0x00, 0x01}, make([]byte, 1<<19)...), ErrTooLarge},
+		{"too deep", nested(maxObjectNesting + 1), ErrTooDeep},
+		{"truncated str", []byte{0xa5, 'a', 'b'}, io.ErrUnexpectedEOF},
+		{"truncated length", []byte{0xda, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated float", []byte{0xcb, 0x00, 0x00}, io.ErrUnexpectedEOF},
+		{"truncated ext type", []byte{0xd4}, io.ErrUnexpectedEOF},
+		{"truncated array", []byte{0x93, 0x01}, io.ErrUnexpectedEOF},
+		{"map missing a value", []byte{0x81, 0xa1, 'k'}, io.ErrUnexpectedEOF},
+		{"truncated message", full[:len(full)-2], io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		_, err := decodeAll(c.in)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	if _, err := decodeAll([]byte{0xc1}); err == nil || err.Error() != "msgpack: invalid type byte 0xc1" {
+		t.Errorf("0xc1: err = %v", err)
+	}
+	// the deepest allowed nesting still decodes
+	if _, err := decodeAll(nested(maxObjectNesting)); err != nil {
+		t.Errorf("nesting %d: %v", maxObjectNesting, err)
+	}
+}
+
+func TestDecodeBudgetPerValue(t *testing.T) {
+	// each value gets the full budget: two values that are together past
+	// the item limit both decode
+	half := append([]byte{0xdd}, binary.BigEndian.AppendUint32(nil, maxObjectItems/2+1)...)
+	half = append(half, make([]byte, maxObjectItems/2+1)...)
+	got, err := decodeAll(append(half, half...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("decoded %d values, want 2", len(got))
+	}
+}
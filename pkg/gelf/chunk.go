@@ -0,0 +1,97 @@
+package gelf
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	maxChunks       = 128
+	chunkExpiry     = 5 * time.Second
+	maxPendingChunk = 1024 // messages being reassembled at once
+)
+
+var chunkMagic = [2]byte{0x1e, 0x0f}
+
+// IsChunked reports whether a UDP datagram is a GELF chunk.
+func IsChunked(b []byte) bool {
+	return len(b) >= 2 && b[0] == chunkMagic[0] && b[1] == chunkMagic[1]
+}
+
+type pending struct {
+	chunks   [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// Assembler reassembles chunked UDP messages:
+// magic (2) | message id (8) | sequence number (1) | sequence count (1) | payload.
+// Incomplete messages are dropped after 5 seconds, as the spec asks.
+type Assembler struct {
+	mu      sync.Mutex
+	pending map[[8]byte]*pending
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{pending: make(map[[8]byte]*pending)}
+}
+
+// Add stores one chunk. It returns the full payload once every chunk of
+// the message has arrived, otherwise nil.
+func (a *Assembler) Add(b []byte, now time.Time) ([]byte, error) {
+	if len(b) < 12 || !IsChunked(b) {
+		return nil, errors.New("gelf: invalid chunk header")
+	}
+	var id [8]byte
+	copy(id[:], b[2:10])
+	seq, count := int(b[10]), int(b[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return nil, errors.New("gelf: invalid chunk sequence")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(now)
+
+	p, ok := a.pending[id]
+	if !ok {
+		if len(a.pending) >= maxPendingChunk {
+			return nil, errors.New("gelf: too many incomplete chunked messages")
+		}
+		p = &pending{chunks: make([][]byte, count), started: now}
+		a.pending[id] = p
+	}
+	if len(p.chunks) != count {
+		delete(a.pending, id)
+		return nil, errors.New("gelf: chunk count changed within a message")
+	}
+	if p.chunks[seq] == nil {
+		p.chunks[seq] = append([]byte(nil), b[12:]...)
+		p.received++
+		p.size += len(b) - 12
+	}
+	if p.size > MaxMessageSize {
+		delete(a.pending, id)
+		return nil, errors.New("gelf: message too large")
+	}
+	if p.received < count {
+		return nil, nil
+	}
+
+	delete(a.pending, id)
+	payload := make([]byte, 0, p.size)
+	for _, c := range p.chunks {
+		payload = append(payload, c...)
+	}
+	return payload, nil
+}
+
+func (a *Assembler) expire(now time.Time) {
+	for id, p := range a.pending {
+		if now.Sub(p.started) > chunkExpiry {
+			delete(a.pending, id)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gelf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var chunkT0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func chunk(id byte, seq, count int, payload string) []byte {
+	b := []byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}
+	return append(b, payload...)
+}
+
+func TestAssembler(t *testing.T) {
+	cases := []struct {
+		name   string
+		chunks [][]byte
+		want   []string
+	}{
+		{"single chunk", [][]byte{chunk(1, 0, 1, "abc")}, []string{"abc"}},
+		{"in order", [][]byte{chunk(1, 0, 3, "a"), chunk(1, 1, 3, "b"), chunk(1, 2, 3, "c")}, []string{"abc"}},
+		{"out of order", [][]byte{chunk(1, 2, 3, "c"), chunk(1, 0, 3, "a"), chunk(1, 1, 3, "b")}, []string{"abc"}},
+		{"duplicate keeps the first", [][]byte{chunk(1, 0, 2, "a"), chunk(1, 0, 2, "x"), chunk(1, 1, 2, "b")}, []string{"ab"}},
+		{"interleaved messages", [][]byte{chunk(1, 0, 2, "a"), chunk(2, 0, 2, "x"), chunk(2, 1, 2, "y"), chunk(1, 1, 2, "b")}, []string{"xy", "ab"}},
+		{"empty chunks", [][]byte{chunk(1, 0, 2, ""), chunk(1, 1, 2, "b")}, []string{"b"}},
+	}
+	for _, c := range cases {
+		a := NewAssembler()
+		var got []string
+		for i, b := range c.chunks {
+			out, err := a.Add(b, chunkT0)
+			if err != nil {
+				t.Fatalf("%s: chunk %d: %v", c.name, i, err)
+			}
+			if out != nil {
+				got = append(got, string(out))
+			}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+		if len(a.pending) != 0 {
+			t.Errorf("%s: %d messages left pending", c.name, len(a.pending))
+		}
+	}
+}
+
+func TestAssemblerDuplicateAfterComplete(t *testing.T) {
+	a := NewAssembler()
+	a.Add(chunk(1, 0, 2, "a"), chunkT0)
+	if out, _ := a.Add(chunk(1, 1, 2, "b"), chunkT0); string(out) != "ab" {
+		t.Fatalf("got %q", out)
+	}
+	// a late duplicate starts a new message instead of repeating the old one
+	if out, err := a.Add(chunk(1, 1, 2, "b"), chunkT0); out != nil || err != nil {
+		t.Errorf("late duplicate = %q, %v", out, err)
+	}
+}
+
+func TestAssemblerErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"short header", chunk(1, 0, 1, "")[:11], "gelf: invalid chunk header"},
+		{"no magic", append([]byte{0x1e, 0x00}, chunk(1, 0, 1, "x")[2:]...), "gelf: invalid chunk header"},
+		{"zero count", chunk(1, 0, 0, "x"), "gelf: invalid chunk sequence"},
+		{"seq past count", chunk(1, 2, 2, "x"), "gelf: invalid chunk sequence"},
+		{"too many chunks", chunk(1, 0, maxChunks+1, "x"), "gelf: invalid chunk sequence"},
+	}
+	for _, c := range cases {
+		_, err := NewAssembler().Add(c.in, chunkT0)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%s: err = %v, want %s", c.name, err, c.want)
+		}
+	}
+
+	// the most chunks a message may have
+	a := NewAssembler()
+	for seq := 0; seq < maxChunks; seq++ {
+		out, err := a.Add(chunk(1, seq, maxChunks, "x"), chunkT0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq == maxChunks-1 && len(out) != maxChunks {
+			t.Errorf("%d chunks gave %d bytes", maxChunks, len(out))
+		}
+	}
+}
+
+func TestAssemblerCountChanged(t *testing.T) {
+	a := NewAssembler()
+	a.Add(chunk(1, 0, 3, "a"), chunkT0)
+	if _, err := a.Add(chunk(1, 1, 2, "b"), chunkT0); err == nil || err.Error() != "gelf: chunk count changed within a message" {
+		t.Errorf("err = %v", err)
+	}
+	// the message is dropped
+	if len(a.pending) != 0 {
+		t.Errorf("%d pending", len(a.pending))
+	}
+}
+
+func TestAssemblerTooLarge(t *testing.T) {
+	a := NewAssembler()
+	part := string(bytes.Repeat([]byte("x"), MaxMessageSize/maxChunks))
+	for seq := 0; seq < maxChunks; seq++ {
+		if _, err := a.Add(chunk(1, seq, maxChunks, part), chunkT0); err != nil {
+			t.Fatalf("chunk %d: %v", seq, err)
+		}
+	}
+
+	a = NewAssembler()
+	big := part + "x"
+	var err error
+	for seq := 0; seq < maxChunks && err == nil; seq++ {
+		_, err = a.Add(chunk(1, seq, maxChunks, big), chunkT0)
+	}
+	if err == nil || err.Error() != "gelf: message too large" {
+		t.Errorf("err = %v", err)
+	}
+	if len(a.pending) != 0 {
+		t.Errorf("%d pending after a too large message", len(a.pending))
+	}
+}
+
+func TestAssemblerExpiry(t *testing.T) {
+	a := NewAssembler()
+	a.Add(chunk(1, 0, 2, "a"), chunkT0)
+	a.Add(chunk(2, 0, 2, "x"), chunkT0.Add(3*time.Second))
+
+	// message 1 is past chunkExpiry: its second chunk starts over
+	later := chunkT0.Add(chunkExpiry + time.Second)
+	if out, err := a.Add(chunk(1, 1, 2, "b"), later); out != nil || err != nil {
+		t.Errorf("expired message completed: %q, %v", out, err)
+	}
+	// message 2 is still within the window
+	if out, _ := a.Add(chunk(2, 1, 2, "y"), later); string(out) != "xy" {
+		t.Errorf("got %q, want xy", out)
+	}
+	if out, _ := a.Add(chunk(1, 0, 2, "a"), later); string(out) != "ab" {
+		t.Errorf("restarted message = %q, want ab", out)
+	}
+}
+
+func TestAssemblerPendingLimit(t *testing.T) {
+	a := NewAssembler()
+	for i := 0; i < maxPendingChunk; i++ {
+		b := chunk(0, 0, 2, "a")
+		b[2], b[3] = byte(i), byte(i>>8)
+		if _, err := a.Add(b, chunkT0); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	fresh := chunk(0, 0, 2, "a")
+	fresh[9] = 1
+	if _, err := a.Add(fresh, chunkT0); err == nil || err.Error() != "gelf: too many incomplete chunked messages" {
+		t.Errorf("err = %v", err)
+	}
+	// expired messages make room again
+	if _, err := a.Add(fresh, chunkT0.Add(chunkExpiry+time.Second)); err != nil {
+		t.Errorf("after expiry: %v", err)
+	}
+}
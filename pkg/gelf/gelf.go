@@ -0,0 +1,145 @@
+// Package gelf decodes Graylog Extended Log Format messages: plain, zlib or
+// gzip JSON payloads, chunked UDP datagrams and null-delimited TCP streams.
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// MaxMessageSize bounds a decompressed or TCP-framed message.
+const MaxMessageSize = 8 << 20
+
+type Message struct {
+	Version      string
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    time.Time
+	Level        int // syslog severity, -1 when absent
+	// Fields holds the additional "_" fields without the underscore
+	Fields map[string]interface{}
+}
+
+// Parse decodes one payload, detecting zlib and gzip compression by their
+// magic bytes.
+func Parse(payload []byte) (*Message, error) {
+	data, err := decompress(payload)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("gelf: invalid JSON: %w", err)
+	}
+
+	m := &Message{Level: -1, Fields: make(map[string]interface{})}
+	for k, v := range raw {
+		switch k {
+		case "version":
+			m.Version, _ = v.(string)
+		case "host":
+			m.Host, _ = v.(string)
+		case "short_message":
+			m.ShortMessage, _ = v.(string)
+		case "full_message":
+			m.FullMessage, _ = v.(string)
+		case "timestamp":
+			if n, ok := v.(json.Number); ok {
+				if secs, err := n.Float64(); err == nil {
+					m.Timestamp = unixTime(secs)
+				}
+			}
+		case "level":
+			if n, ok := v.(json.Number); ok {
+				if lvl, err := n.Int64(); err == nil {
+					m.Level = int(lvl)
+				}
+			}
+		default:
+			// "_id" is reserved by the spec
+			if strings.HasPrefix(k, "_") && k != "_id" && len(k) > 1 {
+				m.Fields[k[1:]] = v
+			}
+		}
+	}
+	if m.ShortMessage == "" && m.FullMessage == "" {
+		return nil, errors.New("gelf: short_message is required")
+	}
+	return m, nil
+}
+
+// unixTime converts float seconds, rounded to microseconds: secs*1e9 on
+// its own turns .25 into .249999872
+func unixTime(secs float64) time.Time {
+	sec := math.Floor(secs)
+	usec := math.Round((secs - sec) * 1e6)
+	return time.Unix(int64(sec), int64(usec)*1e3).UTC()
+}
+
+func decompress(payload []byte) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0] == 0x78:
+		r, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gelf: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, MaxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gelf: %w", err)
+	}
+	if len(data) > MaxMessageSize {
+		return nil, errors.New("gelf: message too large")
+	}
+	return data, nil
+}
+
+// NewScanner splits a TCP stream into messages ending with a null byte.
+// A trailing newline before the null byte is tolerated.
+func NewScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4096), MaxMessageSize)
+	sc.Split(splitFrames)
+	return sc
+}
+
+func splitFrames(data []byte, atEOF bool) (int, []byte, error) {
+	// skip empty frames here: a nil token at EOF would end the scan with
+	// frames still buffered
+	start := 0
+	for {
+		i := bytes.IndexByte(data[start:], 0)
+		if i == -1 {
+			break
+		}
+		if frame := bytes.TrimSpace(data[start : start+i]); len(frame) > 0 {
+			return start + i + 1, frame, nil
+		}
+		start += i + 1
+	}
+	if atEOF {
+		if frame := bytes.TrimSpace(data[start:]); len(frame) > 0 {
+			return len(data), frame, nil
+		}
+		return len(data), nil, nil
+	}
+	return start, nil, nil
+}
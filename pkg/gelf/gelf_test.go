@@ -0,0 +1,163 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gelfJSON = `{"version":"1.1","host":"web01","short_message":"GET /api 200","full_message":"trace",` +
+	`"timestamp":1772366400.25,"level":3,"_tag":"nginx","_status":200,"_id":"x","_":"y","extra":"z"}`
+
+var gelfWant = &Message{
+	Version:      "1.1",
+	Host:         "web01",
+	ShortMessage: "GET /api 200",
+	FullMessage:  "trace",
+	Timestamp:    time.Date(2026, 3, 1, 12, 0, 0, 250e6, time.UTC),
+	Level:        3,
+	Fields:       map[string]interface{}{"tag": "nginx", "status": json.Number("200")},
+}
+
+func gzipPayload(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func zlibPayload(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	for name, payload := range map[string][]byte{
+		"plain": []byte(gelfJSON),
+		"gzip":  gzipPayload([]byte(gelfJSON)),
+		"zlib":  zlibPayload([]byte(gelfJSON)),
+	} {
+		m, err := Parse(payload)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, gelfWant) {
+			t.Errorf("%s:\n got %+v\nwant %+v", name, m, gelfWant)
+		}
+	}
+
+	// only full_message, no level
+	m, err := Parse([]byte(`{"full_message":"x","level":"high"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Level != -1 || m.FullMessage != "x" {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestParseChunked(t *testing.T) {
+	// a compressed payload split over chunks sent in reverse
+	payload := gzipPayload([]byte(gelfJSON))
+	const n = 3
+	a := NewAssembler()
+	var out []byte
+	for seq := n - 1; seq >= 0; seq-- {
+		part := payload[seq*len(payload)/n : (seq+1)*len(payload)/n]
+		var err error
+		if out, err = a.Add(chunk(7, seq, n, string(part)), chunkT0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, gelfWant) {
+		t.Errorf("got %+v, want %+v", m, gelfWant)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	for in, want := range map[string]time.Time{
+		"1772366400":       time.Unix(1772366400, 0).UTC(),
+		"1772366400.123":   time.Unix(1772366400, 123e6).UTC(),
+		"1772366400.00001": time.Unix(1772366400, 10e3).UTC(),
+		"1e9":              time.Unix(1e9, 0).UTC(),
+	} {
+		m, err := Parse([]byte(`{"short_message":"x","timestamp":` + in + `}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.Timestamp.Equal(want) {
+			t.Errorf("timestamp %s = %v, want %v", in, m.Timestamp, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bomb := gzipPayload(make([]byte, MaxMessageSize+1))
+	cases := []struct {
+		name    string
+		payload []byte
+		want    string
+	}{
+		{"empty", nil, "gelf: invalid JSON: EOF"},
+		{"not JSON", []byte("hello"), "gelf: invalid JSON"},
+		{"no message", []byte(`{"host":"web01"}`), "gelf: short_message is required"},
+		{"message not a string", []byte(`{"short_message":5}`), "gelf: short_message is required"},
+		{"bad zlib", []byte{0x78, 0x00, 0x01}, "gelf: zlib: invalid header"},
+		{"bad gzip", []byte{0x1f, 0x8b, 0x00}, "gelf: unexpected EOF"},
+		{"truncated gzip", gzipPayload([]byte(gelfJSON))[:20], "gelf: unexpected EOF"},
+		{"truncated zlib", zlibPayload([]byte(gelfJSON))[:10], "gelf: unexpected EOF"},
+		{"too large", bomb, "gelf: message too large"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.payload)
+		if err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %s", c.name, err, c.want)
+		}
+	}
+}
+
+func TestScanner(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"{\"a\":1}\x00{\"b\":2}\x00", []string{`{"a":1}`, `{"b":2}`}},
+		{"{\"a\":1}\n\x00\x00\x00{\"b\":2}", []string{`{"a":1}`, `{"b":2}`}},
+		{"\n\x00  \n", nil},
+	}
+	for _, c := range cases {
+		sc := NewScanner(strings.NewReader(c.in))
+		var got []string
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		if err := sc.Err(); err != nil {
+			t.Errorf("%q: %v", c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	sc := NewScanner(strings.NewReader(strings.Repeat("x", MaxMessageSize+1) + "\x00"))
+	if sc.Scan() || !errors.Is(sc.Err(), bufio.ErrTooLong) {
+		t.Errorf("oversized frame: err = %v", sc.Err())
+	}
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,30 +14,98 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// GenerateToken membuat JWT baru dengan userID sebagai subject
-func GenerateToken(userID uint, secret string, duration time.Duration) (string, error) {
+// Denylist reports access tokens revoked before they expire.
+type Denylist interface {
+	Revoked(jti string) bool
+}
+
+var denylist Denylist
+
+// SetDenylist makes AuthMiddleware reject revoked tokens.
+func SetDenylist(d Denylist) {
+	denylist = d
+}
+
+// API key scopes: read covers GET requests, upload pushing logs, admin
+// everything the user may do.
+const (
+	ScopeRead   = "read"
+	ScopeUpload = "upload"
+	ScopeAdmin  = "admin"
+)
+
+// Identity is who an API key acts as.
+type Identity struct {
+	KeyID  uint
+	UserID uint
+	Role   string
+	Org    Org
+	Scopes []string
+}
+
+// APIKeys checks keys sent as "Authorization: ApiKey <key>".
+type APIKeys interface {
+	VerifyAPIKey(key string) (*Identity, error)
+}
+
+var apiKeys APIKeys
+
+// SetAPIKeys makes AuthMiddleware accept API keys besides JWTs.
+func SetAPIKeys(k APIKeys) {
+	apiKeys = k
+}
+
+// Org is the active organization of the token's user and their role in it.
+type Org struct {
+	ID   uint
+	Role string
+}
+
+// GenerateToken membuat JWT baru dengan userID sebagai subject. role is
+// checked by RequireRole; org scopes the data the token reaches; sessionID
+// ties the token to its refresh token family; jti is its own ID, used to
+// revoke it.
+func GenerateToken(userID uint, role string, org Org, sessionID, secret string, duration time.Duration) (token string, jti string, err error) {
 	log.Printf("[JWT] Generating token for userID: %d, secret length: %d", userID, len(secret))
 
+	jti, err = newID()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(duration).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":      userID,
+		"role":     role,
+		"org":      org.ID,
+		"org_role": org.Role,
+		"sid":      sessionID,
+		"jti":      jti,
+		"exp":      now.Add(duration).Unix(),
+		"iat":      now.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
-
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
 	if err != nil {
 		log.Printf("[JWT] Error generating token: %v", err)
-		return "", err
+		return "", "", err
 	}
 
 	log.Printf("[JWT] Token generated successfully for userID: %d", userID)
-	return tokenString, nil
+	return tokenString, jti, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
-// AuthMiddleware memverifikasi JWT dari header Authorization
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware memverifikasi JWT dari header Authorization, or an API key.
+// API keys with the read or admin scope may send GET and HEAD requests;
+// other methods need the admin scope or one of writeScopes.
+func AuthMiddleware(writeScopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. Cek Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -45,13 +115,17 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if key, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+			authenticateAPIKey(c, key, writeScopes)
+			return
+		}
 
 		// 2. Extract token dari Bearer
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenStr == authHeader {
 			// Tidak ada prefix "Bearer ", format salah
 			log.Println("[JWT] Invalid token format: missing 'Bearer ' prefix")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token format, use: Bearer <token>"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token format, use: Bearer <token> or ApiKey <key>"})
 			c.Abort()
 			return
 		}
@@ -73,7 +147,6 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		log.Printf("[JWT] Verifying token (secret length: %d, token length: %d)", len(secret), len(tokenStr))
-		log.Printf("[JWT] Token received: %s...%s", tokenStr[:20], tokenStr[len(tokenStr)-20:])
 
 		// 4. Parse dan validasi token
 		claims := jwt.MapClaims{}
@@ -127,16 +200,119 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		userID := uint(sub)
+
+		// 8. Cek revocation list; tokens from before jti was added cannot
+		// be revoked, so they are refused too
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			log.Printf("[JWT] Token without jti for userID: %d", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has expired, please login again"})
+			c.Abort()
+			return
+		}
+		if denylist != nil && denylist.Revoked(jti) {
+			log.Printf("[JWT] Revoked token used for userID: %d", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
 		log.Printf("[JWT] ✓ Token validated successfully for userID: %d", userID)
 
-		// 8. Set userID di context
+		// 9. Set userID di context, plus what logout needs
 		c.Set("userID", userID)
+		role, _ := claims["role"].(string)
+		c.Set("role", role)
+		org, _ := claims["org"].(float64)
+		c.Set("orgID", uint(org))
+		orgRole, _ := claims["org_role"].(string)
+		c.Set("orgRole", orgRole)
+		c.Set("tokenID", jti)
+		sid, _ := claims["sid"].(string)
+		c.Set("sessionID", sid)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("tokenExpiresAt", exp.Time)
+		}
+
+		// 10. Continue ke handler berikutnya
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, key string, writeScopes []string) {
+	if apiKeys == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API keys are not enabled"})
+		c.Abort()
+		return
+	}
+	id, err := apiKeys.VerifyAPIKey(strings.TrimSpace(key))
+	if err != nil {
+		log.Printf("[JWT] API key refused: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		c.Abort()
+		return
+	}
+	allowed := []string{ScopeAdmin}
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		allowed = append(allowed, ScopeRead)
+	} else {
+		allowed = append(allowed, writeScopes...)
+	}
+	if !hasScope(id.Scopes, allowed) {
+		log.Printf("[JWT] API key %d lacks scope for %s %s", id.KeyID, c.Request.Method, c.FullPath())
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key scope does not allow this, needs one of: " + strings.Join(allowed, ", ")})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", id.UserID)
+	c.Set("role", id.Role)
+	c.Set("orgID", id.Org.ID)
+	c.Set("orgRole", id.Org.Role)
+	c.Set("apiKeyID", id.KeyID)
+	c.Next()
+}
 
-		// 9. Continue ke handler berikutnya
+func hasScope(scopes, allowed []string) bool {
+	for _, s := range scopes {
+		for _, a := range allowed {
+			if s == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SessionOnly refuses API keys, for routes that manage the account or the
+// keys themselves.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiKeyID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used here, login instead"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// RequireRole lets the request through only if the role of the token,
+// set by AuthMiddleware, is one of roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		log.Printf("[JWT] Role %q not allowed for %s %s", role, c.Request.Method, c.FullPath())
+		c.JSON(http.StatusForbidden, gin.H{"error": "your role does not allow this"})
+		c.Abort()
+	}
+}
+
 // GetUserIDFromContext helper function untuk mengambil userID dari context
 func GetUserIDFromContext(c *gin.Context) (uint, error) {
 	userID, exists := c.Get("userID")
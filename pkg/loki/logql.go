@@ -0,0 +1,247 @@
+package loki
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matcher is one label matcher of a stream selector: =, !=, =~ or !~.
+// Regexes are anchored on both ends, like in Prometheus and Loki.
+type Matcher struct {
+	Name  string
+	Type  string
+	Value string
+	re    *regexp.Regexp
+}
+
+func (m Matcher) Matches(v string) bool {
+	switch m.Type {
+	case "=":
+		return v == m.Value
+	case "!=":
+		return v != m.Value
+	case "=~":
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+// LineFilter is a |=, !=, |~ or !~ filter on the log line.
+type LineFilter struct {
+	Type  string
+	Value string
+	re    *regexp.Regexp
+}
+
+func (f LineFilter) Matches(line string) bool {
+	switch f.Type {
+	case "|=":
+		return strings.Contains(line, f.Value)
+	case "!=":
+		return !strings.Contains(line, f.Value)
+	case "|~":
+		return f.re.MatchString(line)
+	default:
+		return !f.re.MatchString(line)
+	}
+}
+
+// Query is a LogQL log query: a stream selector followed by line filters,
+// e.g. {job="nginx", host=~"web-.*"} |= "POST" != "/health".
+type Query struct {
+	Matchers []Matcher
+	Filters  []LineFilter
+}
+
+func (q *Query) MatchLabels(labels map[string]string) bool {
+	for _, m := range q.Matchers {
+		if !m.Matches(labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *Query) MatchLine(line string) bool {
+	for _, f := range q.Filters {
+		if !f.Matches(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseQuery parses the supported subset. Metric queries and pipeline
+// stages such as | json are rejected with an error.
+func ParseQuery(s string) (*Query, error) {
+	l := &lexer{s: s}
+	l.skipSpace()
+	if !l.accept("{") {
+		return nil, errors.New("logql: only log queries starting with a {stream selector} are supported")
+	}
+	matchers, err := l.selector()
+	if err != nil {
+		return nil, err
+	}
+	if len(matchers) == 0 {
+		return nil, errors.New("logql: the stream selector needs at least one matcher")
+	}
+	q := &Query{Matchers: matchers}
+
+	for {
+		l.skipSpace()
+		if l.done() {
+			return q, nil
+		}
+		op, ok := l.op("|=", "!=", "|~", "!~")
+		if !ok {
+			return nil, fmt.Errorf("logql: unsupported expression at %q", l.rest())
+		}
+		value, err := l.str()
+		if err != nil {
+			return nil, err
+		}
+		f := LineFilter{Type: op, Value: value}
+		if op == "|~" || op == "!~" {
+			if f.re, err = regexp.Compile(value); err != nil {
+				return nil, fmt.Errorf("logql: %w", err)
+			}
+		}
+		q.Filters = append(q.Filters, f)
+	}
+}
+
+// ParseLabelSet parses a label set like {job="nginx", host="web-1"}, the
+// form protobuf push requests use for stream labels.
+func ParseLabelSet(s string) (map[string]string, error) {
+	l := &lexer{s: s}
+	l.skipSpace()
+	if !l.accept("{") {
+		return nil, fmt.Errorf("loki: invalid label set %q", s)
+	}
+	matchers, err := l.selector()
+	if err != nil {
+		return nil, err
+	}
+	if l.skipSpace(); !l.done() {
+		return nil, fmt.Errorf("loki: invalid label set %q", s)
+	}
+	labels := make(map[string]string, len(matchers))
+	for _, m := range matchers {
+		if m.Type != "=" {
+			return nil, fmt.Errorf("loki: invalid label set %q", s)
+		}
+		labels[m.Name] = m.Value
+	}
+	return labels, nil
+}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) rest() string { return l.s[l.pos:] }
+func (l *lexer) done() bool   { return l.pos >= len(l.s) }
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.s) && strings.IndexByte(" \t\r\n", l.s[l.pos]) >= 0 {
+		l.pos++
+	}
+}
+
+func (l *lexer) accept(tok string) bool {
+	if strings.HasPrefix(l.rest(), tok) {
+		l.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (l *lexer) op(ops ...string) (string, bool) {
+	for _, op := range ops {
+		if l.accept(op) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// selector reads `name op "value", ...}` after the opening brace
+func (l *lexer) selector() ([]Matcher, error) {
+	var matchers []Matcher
+	for {
+		l.skipSpace()
+		if l.accept("}") {
+			return matchers, nil
+		}
+		if len(matchers) > 0 && !l.accept(",") {
+			return nil, fmt.Errorf("logql: expected , or } at %q", l.rest())
+		}
+		l.skipSpace()
+		name := l.ident()
+		if name == "" {
+			return nil, fmt.Errorf("logql: expected label name at %q", l.rest())
+		}
+		l.skipSpace()
+		op, ok := l.op("=~", "!~", "!=", "=")
+		if !ok {
+			return nil, fmt.Errorf("logql: expected matcher operator after %s", name)
+		}
+		value, err := l.str()
+		if err != nil {
+			return nil, err
+		}
+		m := Matcher{Name: name, Type: op, Value: value}
+		if op == "=~" || op == "!~" {
+			if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+				return nil, fmt.Errorf("logql: %w", err)
+			}
+		}
+		matchers = append(matchers, m)
+	}
+}
+
+func (l *lexer) ident() string {
+	start := l.pos
+	for l.pos < len(l.s) {
+		c := l.s[l.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || (l.pos > start && c >= '0' && c <= '9') {
+			l.pos++
+			continue
+		}
+		break
+	}
+	return l.s[start:l.pos]
+}
+
+// str reads a "double-quoted" (Go escapes) or `raw` string
+func (l *lexer) str() (string, error) {
+	l.skipSpace()
+	if l.done() {
+		return "", errors.New("logql: expected string")
+	}
+	quote := l.s[l.pos]
+	if quote != '"' && quote != '`' {
+		return "", fmt.Errorf("logql: expected string at %q", l.rest())
+	}
+	for i := l.pos + 1; i < len(l.s); i++ {
+		if quote == '"' && l.s[i] == '\\' {
+			i++
+			continue
+		}
+		if l.s[i] == quote {
+			v, err := strconv.Unquote(l.s[l.pos : i+1])
+			if err != nil {
+				return "", fmt.Errorf("logql: invalid string %s", l.s[l.pos:i+1])
+			}
+			l.pos = i + 1
+			return v, nil
+		}
+	}
+	return "", errors.New("logql: unterminated string")
+}
@@ -0,0 +1,172 @@
+// Package loki decodes Grafana Loki push requests (JSON and snappy-compressed
+// protobuf) and parses the small LogQL subset the query API supports.
+package loki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/ifs21014-itdel/log-analyzer/pkg/pbwire"
+)
+
+// ErrTooLarge: the snappy body would decode to more than the allowed size.
+var ErrTooLarge = errors.New("loki: decoded body too large")
+
+type Entry struct {
+	Time     time.Time
+	Line     string
+	Metadata map[string]string // structured metadata, Loki 3+
+}
+
+type Stream struct {
+	Labels  map[string]string
+	Entries []Entry
+}
+
+// {"streams":[{"stream":{"job":"nginx"},"values":[["<unix ns>","line",{"k":"v"}]]}]}
+type pushJSON struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+func DecodeJSON(body []byte) ([]Stream, error) {
+	var req pushJSON
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("loki: invalid JSON push: %w", err)
+	}
+	streams := make([]Stream, 0, len(req.Streams))
+	for _, s := range req.Streams {
+		out := Stream{Labels: s.Stream, Entries: make([]Entry, 0, len(s.Values))}
+		for _, v := range s.Values {
+			if len(v) < 2 {
+				return nil, errors.New("loki: value must be [timestamp, line]")
+			}
+			var ts, line string
+			if err := json.Unmarshal(v[0], &ts); err != nil {
+				return nil, errors.New("loki: timestamp must be a string of unix nanoseconds")
+			}
+			ns, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("loki: invalid timestamp %q", ts)
+			}
+			if err := json.Unmarshal(v[1], &line); err != nil {
+				return nil, errors.New("loki: line must be a string")
+			}
+			e := Entry{Time: time.Unix(0, ns).UTC(), Line: line}
+			if len(v) > 2 {
+				if err := json.Unmarshal(v[2], &e.Metadata); err != nil {
+					return nil, errors.New("loki: structured metadata must be an object of strings")
+				}
+			}
+			out.Entries = append(out.Entries, e)
+		}
+		streams = append(streams, out)
+	}
+	return streams, nil
+}
+
+// DecodeProto reads a snappy-compressed logproto.PushRequest:
+//
+//	PushRequest   { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter  { Timestamp timestamp = 1; string line = 2; repeated LabelPair structuredMetadata = 3; }
+//
+// Bodies that would decode to more than maxSize bytes fail with ErrTooLarge
+// before anything is allocated.
+func DecodeProto(body []byte, maxSize int64) ([]Stream, error) {
+	n, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("loki: invalid snappy body: %w", err)
+	}
+	if int64(n) > maxSize {
+		return nil, ErrTooLarge
+	}
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("loki: invalid snappy body: %w", err)
+	}
+	var streams []Stream
+	err = pbwire.Walk(raw, func(f pbwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		s, err := decodeStream(f.Bytes)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, s)
+		return nil
+	})
+	return streams, err
+}
+
+func decodeStream(b []byte) (Stream, error) {
+	var s Stream
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			labels, err := ParseLabelSet(f.String())
+			if err != nil {
+				return err
+			}
+			s.Labels = labels
+		case 2:
+			e, err := decodeEntry(f.Bytes)
+			if err != nil {
+				return err
+			}
+			s.Entries = append(s.Entries, e)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func decodeEntry(b []byte) (Entry, error) {
+	var e Entry
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			var sec, nsec uint64
+			if err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+				switch f.Num {
+				case 1:
+					sec = f.Varint
+				case 2:
+					nsec = f.Varint
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			e.Time = time.Unix(int64(sec), int64(int32(nsec))).UTC()
+		case 2:
+			e.Line = f.String()
+		case 3:
+			var name, value string
+			if err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+				switch f.Num {
+				case 1:
+					name = f.String()
+				case 2:
+					value = f.String()
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if e.Metadata == nil {
+				e.Metadata = make(map[string]string)
+			}
+			e.Metadata[name] = value
+		}
+		return nil
+	})
+	return e, err
+}
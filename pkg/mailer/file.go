@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Mailer sends a message; SMTP delivers it, File and Log keep it local for
+// development.
+type Mailer interface {
+	Send(m Message) error
+}
+
+// File writes each message as an .eml file into a directory, which mail
+// clients open directly.
+type File struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(m Message) error {
+	body, err := Build(f.from, m)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), f.seq.Add(1))
+	path := filepath.Join(f.dir, name)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	log.Printf("[Mailer] wrote %q to %s (%s)", m.Subject, strings.Join(m.To, ", "), path)
+	return nil
+}
+
+// Log prints messages to the server log instead of sending them.
+type Log struct{}
+
+func (Log) Send(m Message) error {
+	log.Printf("[Mailer] to: %s | subject: %s\n%s", strings.Join(m.To, ", "), m.Subject, m.Text)
+	return nil
+}
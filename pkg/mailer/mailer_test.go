@@ -0,0 +1,211 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// session is what the test SMTP server received on one connection.
+type session struct {
+	from  string
+	rcpts []string
+	data  string
+}
+
+// startSMTP serves one SMTP connection on 127.0.0.1:0, without STARTTLS,
+// and sends what it received on the channel.
+func startSMTP(t *testing.T) (int, <-chan session) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan session, 1)
+	go func() {
+		var s session
+		defer func() { done <- s }()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c := textproto.NewConn(conn)
+		defer c.Close()
+		c.PrintfLine("220 test ESMTP")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO":
+				c.PrintfLine("250-test")
+				c.PrintfLine("250 8BITMIME")
+			case "MAIL":
+				s.from = line
+				c.PrintfLine("250 ok")
+			case "RCPT":
+				s.rcpts = append(s.rcpts, line)
+				c.PrintfLine("250 ok")
+			case "DATA":
+				c.PrintfLine("354 go ahead")
+				b, err := c.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				s.data = string(b)
+				c.PrintfLine("250 queued")
+			case "QUIT":
+				c.PrintfLine("221 bye")
+				return
+			default:
+				c.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, done
+}
+
+func newTestSMTP(t *testing.T, port int, tlsMode string) *SMTP {
+	t.Helper()
+	s, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: port, From: "Log Analyzer <reports@example.com>", TLS: tlsMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSMTPSend(t *testing.T) {
+	// auto falls back to plain text when STARTTLS is not offered
+	for _, mode := range []string{TLSNone, TLSAuto} {
+		t.Run(mode, func(t *testing.T) {
+			port, done := startSMTP(t)
+			text := "Laporan harian: 5xx naik 12% = perlu dicek ✓\n" + strings.Repeat("x", 100)
+			err := newTestSMTP(t, port, mode).Send(Message{
+				To:      []string{"ops@example.com", "Dev <dev@example.com>"},
+				Subject: "Daily report – api",
+				Text:    text,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := <-done
+
+			if !strings.HasPrefix(s.from, "MAIL FROM:<reports@example.com>") {
+				t.Errorf("MAIL = %q", s.from)
+			}
+			want := []string{"RCPT TO:<ops@example.com>", "RCPT TO:<dev@example.com>"}
+			if strings.Join(s.rcpts, "|") != strings.Join(want, "|") {
+				t.Errorf("RCPT = %q, want %q", s.rcpts, want)
+			}
+
+			msg, err := mail.ReadMessage(strings.NewReader(s.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msg.Header.Get("To"); got != "ops@example.com, Dev <dev@example.com>" {
+				t.Errorf("To = %q", got)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			if err != nil || subject != "Daily report – api" {
+				t.Errorf("Subject = %q (%v)", subject, err)
+			}
+			if got := msg.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+				t.Errorf("Content-Transfer-Encoding = %q", got)
+			}
+			if msg.Header.Get("Message-ID") == "" || !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+				t.Errorf("Message-ID = %q", msg.Header.Get("Message-ID"))
+			}
+
+			raw, _ := io.ReadAll(msg.Body)
+			for _, line := range strings.Split(string(raw), "\n") {
+				if len(line) > 76 {
+					t.Errorf("body line longer than 76 characters: %q", line)
+				}
+			}
+			body, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the SMTP client ends DATA with a line break of its own
+			if got := strings.TrimSuffix(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n"); got != text {
+				t.Errorf("body = %q, want %q", got, text)
+			}
+		})
+	}
+}
+
+func TestSMTPSendAttachment(t *testing.T) {
+	port, done := startSMTP(t)
+	csv := []byte("stream,requests\napi,120\n" + strings.Repeat("web,1\n", 40))
+	err := newTestSMTP(t, port, TLSNone).Send(Message{
+		To:          []string{"ops@example.com"},
+		Subject:     "Report",
+		Text:        "See attached.",
+		Attachments: []Attachment{{Filename: "report.csv", ContentType: "text/csv", Data: csv}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := <-done
+
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	// multipart.Reader undoes quoted-printable itself
+	text, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(text); string(b) != "See attached." {
+		t.Errorf("text part = %q", b)
+	}
+
+	att, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.FileName() != "report.csv" || att.Header.Get("Content-Type") != "text/csv" {
+		t.Errorf("attachment = %q %q", att.FileName(), att.Header.Get("Content-Type"))
+	}
+	if att.Header.Get("Content-Transfer-Encoding") != "base64" {
+		t.Errorf("attachment encoding = %q", att.Header.Get("Content-Transfer-Encoding"))
+	}
+	enc, _ := io.ReadAll(att)
+	data, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(enc)))
+	if err != nil || string(data) != string(csv) {
+		t.Errorf("attachment data = %q (%v)", data, err)
+	}
+
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected two parts, got more (%v)", err)
+	}
+}
+
+func TestSMTPStartTLSRequired(t *testing.T) {
+	port, done := startSMTP(t)
+	err := newTestSMTP(t, port, TLSStartTLS).Send(Message{To: []string{"ops@example.com"}, Subject: "x", Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Fatalf("err = %v, want STARTTLS refusal", err)
+	}
+	if s := <-done; s.from != "" || s.data != "" {
+		t.Errorf("message sent without STARTTLS: %+v", s)
+	}
+}
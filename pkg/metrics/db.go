@@ -0,0 +1,26 @@
+package metrics
+
+import "database/sql"
+
+// DBStats exposes the connection pool statistics of db.
+func DBStats(db *sql.DB) Collector {
+	return CollectorFunc(func(w *Writer) {
+		s := db.Stats()
+		for _, m := range []struct {
+			name, help, typ string
+			value           float64
+		}{
+			{"db_max_open_connections", "Maximum number of open connections to the database.", "gauge", float64(s.MaxOpenConnections)},
+			{"db_open_connections", "Established connections, in use and idle.", "gauge", float64(s.OpenConnections)},
+			{"db_in_use_connections", "Connections currently in use.", "gauge", float64(s.InUse)},
+			{"db_idle_connections", "Idle connections.", "gauge", float64(s.Idle)},
+			{"db_wait_count_total", "Connections waited for.", "counter", float64(s.WaitCount)},
+			{"db_wait_duration_seconds_total", "Time blocked waiting for a connection.", "counter", s.WaitDuration.Seconds()},
+			{"db_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", "counter", float64(s.MaxIdleClosed)},
+			{"db_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", "counter", float64(s.MaxLifetimeClosed)},
+		} {
+			w.Family("log_analyzer_"+m.name, m.help, m.typ)
+			w.Sample("log_analyzer_"+m.name, m.value)
+		}
+	})
+}
@@ -0,0 +1,319 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format (version 0.0.4), so a Prometheus
+// server can scrape /metrics without a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector writes one or more metric families.
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// CollectorFunc adapts a function to Collector, for values read at scrape
+// time (pool stats, derived gauges).
+type CollectorFunc func(w *Writer)
+
+func (f CollectorFunc) Collect(w *Writer) { f(w) }
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry package-level metrics register with.
+var Default = NewRegistry()
+
+func (r *Registry) Register(cs ...Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, cs...)
+	r.mu.Unlock()
+}
+
+// Register adds collectors to Default.
+func Register(cs ...Collector) {
+	Default.Register(cs...)
+}
+
+// WriteTo writes every collector in registration order.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	cs := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	w := &Writer{}
+	for _, c := range cs {
+		c.Collect(w)
+	}
+	n, err := io.WriteString(out, w.b.String())
+	return int64(n), err
+}
+
+// Handler serves the registry; with a token, requests must send
+// "Authorization: Bearer <token>".
+func Handler(r *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" && req.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// Writer builds the exposition text. Call Family before the samples of
+// each metric.
+type Writer struct {
+	b strings.Builder
+}
+
+// Family writes the HELP and TYPE lines; typ is counter, gauge or histogram.
+func (w *Writer) Family(name, help, typ string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one value; labels are name/value pairs.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteByte(',')
+			}
+			w.b.WriteString(labels[i])
+			w.b.WriteString(`="`)
+			w.b.WriteString(escapeLabel(labels[i+1]))
+			w.b.WriteByte('"')
+		}
+		w.b.WriteByte('}')
+	}
+	w.b.WriteByte(' ')
+	w.b.WriteString(formatValue(value))
+	w.b.WriteByte('\n')
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// ===================== VECTORS =====================
+
+// vec holds one child per label value combination.
+type vec[T any] struct {
+	name, help string
+	labels     []string
+	newChild   func() *T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	values []string
+	m      *T
+}
+
+func newVec[T any](name, help string, labels []string, newChild func() *T) vec[T] {
+	return vec[T]{name: name, help: help, labels: labels, newChild: newChild, children: make(map[string]*child[T])}
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.m
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.children[key]; ok {
+		return c.m
+	}
+	c = &child[T]{values: append([]string(nil), values...), m: v.newChild()}
+	v.children[key] = c
+	return c.m
+}
+
+// sorted returns the children ordered by label values, for stable output
+func (v *vec[T]) sorted() []*child[T] {
+	v.mu.RLock()
+	list := make([]*child[T], 0, len(v.children))
+	for _, c := range v.children {
+		list = append(list, c)
+	}
+	v.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].values, "\xff") < strings.Join(list[j].values, "\xff")
+	})
+	return list
+}
+
+func (v *vec[T]) pairs(values []string, extra ...string) []string {
+	out := make([]string, 0, 2*len(values)+len(extra))
+	for i, name := range v.labels {
+		out = append(out, name, values[i])
+	}
+	return append(out, extra...)
+}
+
+// ===================== COUNTER & GAUGE =====================
+
+// Value is a float64 that can be changed concurrently.
+type Value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *Value) Add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *Value) Inc()          { v.Add(1) }
+func (v *Value) Dec()          { v.Add(-1) }
+func (v *Value) Set(x float64) { v.mu.Lock(); v.v = x; v.mu.Unlock() }
+
+func (v *Value) Get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+type valueVec struct {
+	vec[Value]
+	typ string
+}
+
+// With returns the value for the label values, in label name order.
+func (c *valueVec) With(values ...string) *Value {
+	return c.with(values)
+}
+
+func (c *valueVec) Collect(w *Writer) {
+	w.Family(c.name, c.help, c.typ)
+	for _, ch := range c.sorted() {
+		w.Sample(c.name, ch.m.Get(), c.pairs(ch.values)...)
+	}
+}
+
+type CounterVec struct{ valueVec }
+
+type GaugeVec struct{ valueVec }
+
+func newValue() *Value { return &Value{} }
+
+// NewCounterVec creates a counter with the given label names.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{valueVec{newVec(name, help, labels, newValue), "counter"}}
+}
+
+// NewGaugeVec creates a gauge with the given label names.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{valueVec{newVec(name, help, labels, newValue), "gauge"}}
+}
+
+// GaugeFunc reads its value at scrape time.
+func GaugeFunc(name, help string, fn func() float64) Collector {
+	return CollectorFunc(func(w *Writer) {
+		w.Family(name, help, "gauge")
+		w.Sample(name, fn())
+	})
+}
+
+// ===================== HISTOGRAM =====================
+
+// DefBuckets suit request latencies in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Histogram struct {
+	upper  []float64
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+type HistogramVec struct {
+	vec[Histogram]
+}
+
+// NewHistogramVec creates a histogram with ascending bucket upper bounds.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	upper := append([]float64(nil), buckets...)
+	sort.Float64s(upper)
+	return &HistogramVec{vec: newVec(name, help, labels, func() *Histogram {
+		return &Histogram{upper: upper, counts: make([]uint64, len(upper)+1)}
+	})}
+}
+
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) Collect(w *Writer) {
+	w.Family(h.name, h.help, "histogram")
+	for _, ch := range h.sorted() {
+		m := ch.m
+		m.mu.Lock()
+		counts := append([]uint64(nil), m.counts...)
+		sum, count := m.sum, m.count
+		m.mu.Unlock()
+
+		var cum uint64
+		for i, le := range m.upper {
+			cum += counts[i]
+			w.Sample(h.name+"_bucket", float64(cum), h.pairs(ch.values, "le", formatValue(le))...)
+		}
+		w.Sample(h.name+"_bucket", float64(count), h.pairs(ch.values, "le", "+Inf")...)
+		w.Sample(h.name+"_sum", sum, h.pairs(ch.values)...)
+		w.Sample(h.name+"_count", float64(count), h.pairs(ch.values)...)
+	}
+}
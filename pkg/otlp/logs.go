@@ -0,0 +1,394 @@
+// Package otlp decodes OpenTelemetry log export requests (OTLP/HTTP,
+// protobuf and JSON encodings) into flat records.
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ifs21014-itdel/log-analyzer/pkg/pbwire"
+)
+
+// maxAnyDepth bounds arrays and kvlists nested in an AnyValue, so a crafted
+// body cannot recurse the decoder down a deep stack.
+const maxAnyDepth = 32
+
+var errTooDeep = errors.New("otlp: AnyValue nested too deeply")
+
+// Record is one log record with its resource and scope. Attribute values
+// are flattened to strings; arrays and maps become JSON.
+type Record struct {
+	Resource       map[string]string
+	Scope          string
+	Time           time.Time
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	Attributes     map[string]string
+	TraceID        string
+	SpanID         string
+}
+
+// ===================== PROTOBUF =====================
+
+// DecodeProto reads an ExportLogsServiceRequest:
+//
+//	ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	ScopeLogs    { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	LogRecord    { fixed64 time_unix_nano = 1; SeverityNumber severity_number = 2; string severity_text = 3;
+//	               AnyValue body = 5; repeated KeyValue attributes = 6; bytes trace_id = 9; bytes span_id = 10;
+//	               fixed64 observed_time_unix_nano = 11; }
+func DecodeProto(b []byte) ([]Record, error) {
+	var records []Record
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var resource map[string]string
+		var scopes [][]byte
+		if err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+			switch f.Num {
+			case 1:
+				attrs, err := protoAttributes(f.Bytes, 1)
+				resource = attrs
+				return err
+			case 2:
+				scopes = append(scopes, f.Bytes)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, s := range scopes {
+			if err := protoScope(s, resource, &records); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("otlp: %w", err)
+	}
+	return records, nil
+}
+
+func protoScope(b []byte, resource map[string]string, out *[]Record) error {
+	scope := ""
+	var logs [][]byte
+	if err := pbwire.Walk(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			return pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+				if f.Num == 1 {
+					scope = f.String()
+				}
+				return nil
+			})
+		case 2:
+			logs = append(logs, f.Bytes)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, l := range logs {
+		rec := Record{Resource: resource, Scope: scope}
+		var observed uint64
+		if err := pbwire.Walk(l, func(f pbwire.Field) error {
+			var err error
+			switch f.Num {
+			case 1:
+				rec.Time = unixNano(f.Fixed)
+			case 2:
+				rec.SeverityNumber = int(f.Varint)
+			case 3:
+				rec.SeverityText = f.String()
+			case 5:
+				rec.Body, err = protoValue(f.Bytes)
+			case 6:
+				if rec.Attributes == nil {
+					rec.Attributes = make(map[string]string)
+				}
+				err = protoKeyValue(f.Bytes, rec.Attributes)
+			case 9:
+				rec.TraceID = hex.EncodeToString(f.Bytes)
+			case 10:
+				rec.SpanID = hex.EncodeToString(f.Bytes)
+			case 11:
+				observed = f.Fixed
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+		if rec.Time.IsZero() {
+			rec.Time = unixNano(observed)
+		}
+		*out = append(*out, rec)
+	}
+	return nil
+}
+
+// protoAttributes collects the repeated KeyValue field num of a message
+func protoAttributes(b []byte, num int) (map[string]string, error) {
+	attrs := make(map[string]string)
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		if int(f.Num) != num {
+			return nil
+		}
+		return protoKeyValue(f.Bytes, attrs)
+	})
+	return attrs, err
+}
+
+// KeyValue { string key = 1; AnyValue value = 2; }
+func protoKeyValue(b []byte, into map[string]string) error {
+	var key, value string
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		var err error
+		switch f.Num {
+		case 1:
+			key = f.String()
+		case 2:
+			value, err = protoValue(f.Bytes)
+		}
+		return err
+	})
+	if err == nil && key != "" {
+		into[key] = value
+	}
+	return err
+}
+
+func protoValue(b []byte) (string, error) {
+	v, err := protoAny(b, 0)
+	if err != nil {
+		return "", err
+	}
+	return flatten(v), nil
+}
+
+// AnyValue { string_value = 1; bool_value = 2; int_value = 3; double_value = 4;
+// ArrayValue array_value = 5; KeyValueList kvlist_value = 6; bytes bytes_value = 7; }
+func protoAny(b []byte, depth int) (interface{}, error) {
+	if depth > maxAnyDepth {
+		return nil, errTooDeep
+	}
+	var v interface{}
+	err := pbwire.Walk(b, func(f pbwire.Field) error {
+		switch f.Num {
+		case 1:
+			v = f.String()
+		case 2:
+			v = f.Varint != 0
+		case 3:
+			v = int64(f.Varint)
+		case 4:
+			v = math.Float64frombits(f.Fixed)
+		case 5:
+			var list []interface{}
+			err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+				item, err := protoAny(f.Bytes, depth+1)
+				list = append(list, item)
+				return err
+			})
+			v = list
+			return err
+		case 6:
+			m := make(map[string]interface{})
+			err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+				var key string
+				var value interface{}
+				err := pbwire.Walk(f.Bytes, func(f pbwire.Field) error {
+					var err error
+					switch f.Num {
+					case 1:
+						key = f.String()
+					case 2:
+						value, err = protoAny(f.Bytes, depth+1)
+					}
+					return err
+				})
+				m[key] = value
+				return err
+			})
+			v = m
+			return err
+		case 7:
+			v = base64.StdEncoding.EncodeToString(f.Bytes)
+		}
+		return nil
+	})
+	return v, err
+}
+
+// ===================== JSON =====================
+
+// OTLP/JSON uses lowerCamelCase names, 64-bit integers as strings and
+// hex-encoded trace/span ids.
+type jsonRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano         jsonUint64     `json:"timeUnixNano"`
+				ObservedTimeUnixNano jsonUint64     `json:"observedTimeUnixNano"`
+				SeverityNumber       int            `json:"severityNumber"`
+				SeverityText         string         `json:"severityText"`
+				Body                 *jsonAnyValue  `json:"body"`
+				Attributes           []jsonKeyValue `json:"attributes"`
+				TraceID              string         `json:"traceId"`
+				SpanID               string         `json:"spanId"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type jsonKeyValue struct {
+	Key   string       `json:"key"`
+	Value jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    *jsonUint64 `json:"intValue"`
+	DoubleValue *float64    `json:"doubleValue"`
+	BytesValue  *string     `json:"bytesValue"`
+	ArrayValue  *struct {
+		Values []jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+// jsonUint64 accepts both "123" and 123
+type jsonUint64 uint64
+
+func (n *jsonUint64) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if uq, err := strconv.Unquote(s); err == nil {
+		s = uq
+	}
+	if s == "" || s == "null" {
+		return nil
+	}
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		*n = jsonUint64(v)
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("otlp: invalid integer %s", b)
+	}
+	*n = jsonUint64(v)
+	return nil
+}
+
+func (v jsonAnyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		list := make([]interface{}, len(v.ArrayValue.Values))
+		for i, item := range v.ArrayValue.Values {
+			list[i] = item.value()
+		}
+		return list
+	case v.KvlistValue != nil:
+		m := make(map[string]interface{}, len(v.KvlistValue.Values))
+		for _, kv := range v.KvlistValue.Values {
+			m[kv.Key] = kv.Value.value()
+		}
+		return m
+	}
+	return nil
+}
+
+func jsonAttributes(kvs []jsonKeyValue) map[string]string {
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = flatten(kv.Value.value())
+	}
+	return attrs
+}
+
+func DecodeJSON(b []byte) ([]Record, error) {
+	var req jsonRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, fmt.Errorf("otlp: invalid JSON: %w", err)
+	}
+	var records []Record
+	for _, rl := range req.ResourceLogs {
+		resource := jsonAttributes(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			for _, l := range sl.LogRecords {
+				rec := Record{
+					Resource:       resource,
+					Scope:          sl.Scope.Name,
+					Time:           unixNano(uint64(l.TimeUnixNano)),
+					SeverityNumber: l.SeverityNumber,
+					SeverityText:   l.SeverityText,
+					Attributes:     jsonAttributes(l.Attributes),
+					TraceID:        l.TraceID,
+					SpanID:         l.SpanID,
+				}
+				if rec.Time.IsZero() {
+					rec.Time = unixNano(uint64(l.ObservedTimeUnixNano))
+				}
+				if l.Body != nil {
+					rec.Body = flatten(l.Body.value())
+				}
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+// ===================== HELPERS =====================
+
+func unixNano(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns)).UTC()
+}
+
+func flatten(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
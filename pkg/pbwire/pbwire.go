@@ -0,0 +1,58 @@
+// Package pbwire walks protobuf messages field by field. The ingest
+// protocols (Loki, OTLP) only need a few fields each, so they decode the wire
+// format directly instead of depending on generated code.
+package pbwire
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrMalformed = errors.New("pbwire: malformed protobuf")
+
+// Field is one decoded field. Bytes is set for length-delimited fields,
+// Varint for varints and Fixed for fixed32/fixed64 values.
+type Field struct {
+	Num    protowire.Number
+	Type   protowire.Type
+	Bytes  []byte
+	Varint uint64
+	Fixed  uint64
+}
+
+func (f Field) String() string { return string(f.Bytes) }
+
+// Walk calls fn for every field of the message, in wire order.
+func Walk(b []byte, fn func(f Field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		f := Field{Num: num, Type: typ}
+		switch typ {
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			f.Varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.Fixed, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Fixed = uint64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
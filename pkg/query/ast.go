@@ -0,0 +1,153 @@
+// Package query parses a small SQL-like language for ad-hoc aggregations
+// over log records:
+//
+//	SELECT path, count(*), p95(latency) WHERE status >= 500 GROUP BY path ORDER BY count(*) DESC LIMIT 10
+//
+// The AST says nothing about where records come from; callers compile it
+// to SQL or evaluate it in memory.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var attrKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.]*$`)
+
+// AttrKey returns the key of an attr.<key> field, which reads a record
+// attribute.
+func AttrKey(field string) (string, bool) {
+	key, ok := strings.CutPrefix(field, "attr.")
+	return key, ok && attrKeyPattern.MatchString(key)
+}
+
+type Query struct {
+	Select  []Column
+	Where   Expr // nil matches every record
+	GroupBy []Term
+	OrderBy []Order
+	Limit   int // 0 = caller's default
+}
+
+// Aggregated reports whether the query returns groups rather than records.
+func (q *Query) Aggregated() bool {
+	if len(q.GroupBy) > 0 {
+		return true
+	}
+	for _, c := range q.Select {
+		if c.Agg != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Term is a per-record value: a field, or bucket(time, 5m).
+type Term struct {
+	Field  string
+	Bucket time.Duration
+}
+
+func (t Term) String() string {
+	if t.Bucket > 0 {
+		return fmt.Sprintf("bucket(%s, %s)", t.Field, formatDuration(t.Bucket))
+	}
+	return t.Field
+}
+
+// Column is one SELECT item: a term, or an aggregate over a term. count(*)
+// has Term.Field "*".
+type Column struct {
+	Agg        string // count, count_distinct, sum, avg, min, max, percentile; "" = plain term
+	Percentile float64
+	Term       Term
+	Alias      string
+}
+
+// Name is the column title: the alias, or the expression as written.
+func (c Column) Name() string {
+	if c.Alias != "" {
+		return c.Alias
+	}
+	if c.Agg == "" {
+		return c.Term.String()
+	}
+	fn := c.Agg
+	if fn == "percentile" {
+		// 10 digits drop the float noise of 0.999*100
+		fn = "p" + strconv.FormatFloat(c.Percentile*100, 'g', 10, 64)
+	}
+	return fn + "(" + c.Term.String() + ")"
+}
+
+type Order struct {
+	Column int // index into Select
+	Desc   bool
+}
+
+// Expr is a WHERE condition: *Logical, *Not or *Compare.
+type Expr interface {
+	String() string
+}
+
+type Logical struct {
+	Op          string // and, or
+	Left, Right Expr
+}
+
+func (e *Logical) String() string {
+	return "(" + e.Left.String() + " " + strings.ToUpper(e.Op) + " " + e.Right.String() + ")"
+}
+
+type Not struct {
+	X Expr
+}
+
+func (e *Not) String() string { return "NOT " + e.X.String() }
+
+// Compare is `field op value`. Op is one of = != < <= > >= ~ !~ like,
+// not like, in, not in; only in and not in have more than one value.
+type Compare struct {
+	Field  string
+	Op     string
+	Values []Literal
+}
+
+func (e *Compare) String() string {
+	vals := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		vals[i] = v.String()
+	}
+	if e.Op == "in" || e.Op == "not in" {
+		return e.Field + " " + strings.ToUpper(e.Op) + " (" + strings.Join(vals, ", ") + ")"
+	}
+	return e.Field + " " + strings.ToUpper(e.Op) + " " + vals[0]
+}
+
+type Literal struct {
+	Str   string
+	Num   float64
+	IsNum bool
+}
+
+func (l Literal) String() string {
+	if l.IsNum {
+		return strconv.FormatFloat(l.Num, 'f', -1, 64)
+	}
+	return "'" + strings.ReplaceAll(l.Str, "'", "''") + "'"
+}
+
+func formatDuration(d time.Duration) string {
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{{24 * time.Hour, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + u.name
+		}
+	}
+	return d.String()
+}
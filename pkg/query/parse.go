@@ -0,0 +1,504 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokDuration
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string // identifier, punctuation or string value; numbers as written
+	num  float64
+	dur  time.Duration
+	pos  int
+}
+
+var percentileFunc = regexp.MustCompile(`^p([1-9][0-9]?(\.[0-9]+)?)$`)
+
+var aggregates = map[string]bool{
+	"count": true, "count_distinct": true, "sum": true, "avg": true, "min": true, "max": true,
+}
+
+// Parse parses one query. Keywords and function names are case-insensitive;
+// field names are not.
+func Parse(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	return p.query()
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) punct(s string) bool {
+	t := p.peek()
+	if t.kind == tokPunct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// errorf reports an error at the next token
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return p.errorAt(p.peek(), format, args...)
+}
+
+// errorAt reports an error at t, a token already consumed
+func (p *parser) errorAt(t token, format string, args ...interface{}) error {
+	where := "end of query"
+	if t.kind != tokEOF {
+		where = fmt.Sprintf("position %d", t.pos+1)
+	}
+	return fmt.Errorf("query: "+format+" at %s", append(args, where)...)
+}
+
+func (p *parser) query() (*Query, error) {
+	if !p.keyword("select") {
+		return nil, p.errorf("expected SELECT")
+	}
+	q := &Query{}
+	for {
+		c, err := p.column()
+		if err != nil {
+			return nil, err
+		}
+		if p.keyword("as") {
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokString {
+				return nil, p.errorAt(t, "expected alias")
+			}
+			c.Alias = t.text
+		}
+		q.Select = append(q.Select, c)
+		if !p.punct(",") {
+			break
+		}
+	}
+
+	if p.keyword("where") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		q.Where = e
+	}
+
+	if p.keyword("group") {
+		if !p.keyword("by") {
+			return nil, p.errorf("expected BY")
+		}
+		for {
+			t, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			q.GroupBy = append(q.GroupBy, t)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("order") {
+		if !p.keyword("by") {
+			return nil, p.errorf("expected BY")
+		}
+		for {
+			o, err := p.order(q.Select)
+			if err != nil {
+				return nil, err
+			}
+			q.OrderBy = append(q.OrderBy, o)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("limit") {
+		t := p.next()
+		if t.kind != tokNumber || t.num < 1 || t.num != float64(int(t.num)) {
+			return nil, p.errorAt(t, "expected a positive LIMIT")
+		}
+		q.Limit = int(t.num)
+	}
+
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return q, nil
+}
+
+// column reads a term or an aggregate call, without the alias
+func (p *parser) column() (Column, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return Column{}, p.errorf("expected a field or function")
+	}
+	name := strings.ToLower(t.text)
+	isPercentile := percentileFunc.MatchString(name)
+	if !aggregates[name] && !isPercentile {
+		term, err := p.term()
+		return Column{Term: term}, err
+	}
+	if p.toks[p.pos+1].kind != tokPunct || p.toks[p.pos+1].text != "(" {
+		term, err := p.term() // a field that happens to share the name
+		return Column{Term: term}, err
+	}
+	p.pos += 2
+
+	c := Column{Agg: name}
+	if isPercentile {
+		// "99.9e-2" parses to the float nearest 0.999; 99.9/100 does not
+		pct, _ := strconv.ParseFloat(percentileFunc.FindStringSubmatch(name)[1]+"e-2", 64)
+		c.Agg, c.Percentile = "percentile", pct
+	}
+	if t := p.peek(); t.kind == tokPunct && t.text == "*" {
+		if c.Agg != "count" {
+			return c, p.errorf("only count accepts *")
+		}
+		p.pos++
+		c.Term = Term{Field: "*"}
+	} else {
+		term, err := p.term()
+		if err != nil {
+			return c, err
+		}
+		c.Term = term
+	}
+	if !p.punct(")") {
+		return c, p.errorf("expected )")
+	}
+	return c, nil
+}
+
+// term reads a field or bucket(field, duration)
+func (p *parser) term() (Term, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return Term{}, p.errorAt(t, "expected a field")
+	}
+	if !strings.EqualFold(t.text, "bucket") || !p.punct("(") {
+		return Term{Field: t.text}, nil
+	}
+	f := p.next()
+	if f.kind != tokIdent {
+		return Term{}, p.errorAt(f, "expected a field in bucket")
+	}
+	if !p.punct(",") {
+		return Term{}, p.errorf("expected , in bucket")
+	}
+	d := p.next()
+	if d.kind != tokDuration {
+		return Term{}, p.errorAt(d, "expected a duration like 5m in bucket")
+	}
+	if !p.punct(")") {
+		return Term{}, p.errorf("expected )")
+	}
+	return Term{Field: f.text, Bucket: d.dur}, nil
+}
+
+// order reads a column reference: its position, alias or expression
+func (p *parser) order(cols []Column) (Order, error) {
+	var o Order
+	if t := p.peek(); t.kind == tokNumber {
+		p.pos++
+		if t.num < 1 || int(t.num) > len(cols) || t.num != float64(int(t.num)) {
+			return o, p.errorAt(t, "ORDER BY position %v is not a column", t.num)
+		}
+		o.Column = int(t.num) - 1
+	} else {
+		c, err := p.column()
+		if err != nil {
+			return o, err
+		}
+		name := c.Name()
+		o.Column = -1
+		for i, sc := range cols {
+			if sc.Name() == name || sc.Alias == name {
+				o.Column = i
+				break
+			}
+		}
+		if o.Column < 0 {
+			return o, fmt.Errorf("query: ORDER BY %s is not a selected column", name)
+		}
+	}
+	if p.keyword("desc") {
+		o.Desc = true
+	} else {
+		p.keyword("asc")
+	}
+	return o, nil
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	if p.punct("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punct(")") {
+			return nil, p.errorf("expected )")
+		}
+		return e, nil
+	}
+	return p.compare()
+}
+
+var compareOps = []string{"=", "!=", "<>", "<=", ">=", "<", ">", "~", "!~"}
+
+func (p *parser) compare() (Expr, error) {
+	f := p.next()
+	if f.kind != tokIdent {
+		return nil, p.errorAt(f, "expected a field")
+	}
+	c := &Compare{Field: f.text}
+
+	negate := p.keyword("not")
+	switch {
+	case p.keyword("like"):
+		c.Op = "like"
+	case p.keyword("in"):
+		c.Op = "in"
+	case negate:
+		return nil, p.errorf("expected LIKE or IN after NOT")
+	default:
+		for _, op := range compareOps {
+			if p.punct(op) {
+				c.Op = op
+				break
+			}
+		}
+		if c.Op == "" {
+			return nil, p.errorf("expected a comparison after %s", f.text)
+		}
+		if c.Op == "<>" {
+			c.Op = "!="
+		}
+	}
+	if negate {
+		c.Op = "not " + c.Op
+	}
+
+	if c.Op == "in" || c.Op == "not in" {
+		if !p.punct("(") {
+			return nil, p.errorf("expected ( after IN")
+		}
+		for {
+			v, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			c.Values = append(c.Values, v)
+			if !p.punct(",") {
+				break
+			}
+		}
+		if !p.punct(")") {
+			return nil, p.errorf("expected )")
+		}
+		return c, nil
+	}
+
+	v, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	c.Values = []Literal{v}
+	return c, nil
+}
+
+func (p *parser) literal() (Literal, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return Literal{Num: t.num, IsNum: true}, nil
+	case tokString:
+		return Literal{Str: t.text}, nil
+	}
+	return Literal{}, p.errorAt(t, "expected a number or 'string'")
+}
+
+// ===================== LEXER =====================
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour,
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(s) && (s[i] == '_' || s[i] == '.' || isLetter(s[i]) || isDigit(s[i])) {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: s[start:i], pos: start})
+
+		case isDigit(c):
+			start := i
+			for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(s[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("query: invalid number %q at position %d", s[start:i], start+1)
+			}
+			unitStart := i
+			for i < len(s) && isLetter(s[i]) {
+				i++
+			}
+			if unitStart == i {
+				toks = append(toks, token{kind: tokNumber, text: s[start:i], num: num, pos: start})
+				continue
+			}
+			unit, ok := durationUnits[strings.ToLower(s[unitStart:i])]
+			if !ok || num <= 0 || num != float64(int64(num)) {
+				return nil, fmt.Errorf("query: invalid duration %q at position %d", s[start:i], start+1)
+			}
+			toks = append(toks, token{kind: tokDuration, text: s[start:i], dur: time.Duration(num) * unit, pos: start})
+
+		case c == '\'':
+			// SQL style: '' is an escaped quote
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("query: unterminated string at position %d", i+1)
+				}
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			toks = append(toks, token{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("query: unterminated string at position %d", i+1)
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("query: invalid string at position %d", i+1)
+			}
+			toks = append(toks, token{kind: tokString, text: v, pos: i})
+			i = j + 1
+
+		default:
+			op := ""
+			for _, p := range []string{"<=", ">=", "!=", "<>", "!~", "(", ")", ",", "*", "=", "<", ">", "~"} {
+				if strings.HasPrefix(s[i:], p) {
+					op = p
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("query: unexpected %q at position %d", c, i+1)
+			}
+			toks = append(toks, token{kind: tokPunct, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	if len(toks) == 0 {
+		return nil, errors.New("query: empty query")
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
@@ -0,0 +1,169 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want *Query
+	}{
+		{"SELECT path", &Query{Select: []Column{{Term: Term{Field: "path"}}}}},
+		{
+			"select path, count(*) as n, p95(latency), count_distinct(ip) group by path order by n desc, 1 limit 5",
+			&Query{
+				Select: []Column{
+					{Term: Term{Field: "path"}},
+					{Agg: "count", Term: Term{Field: "*"}, Alias: "n"},
+					{Agg: "percentile", Percentile: 0.95, Term: Term{Field: "latency"}},
+					{Agg: "count_distinct", Term: Term{Field: "ip"}},
+				},
+				GroupBy: []Term{{Field: "path"}},
+				OrderBy: []Order{{Column: 1, Desc: true}, {Column: 0}},
+				Limit:   5,
+			},
+		},
+		{
+			"SELECT bucket(time, 5m), count(*) GROUP BY bucket(time, 5m) ORDER BY bucket(time, 5m) ASC",
+			&Query{
+				Select:  []Column{{Term: Term{Field: "time", Bucket: 5 * time.Minute}}, {Agg: "count", Term: Term{Field: "*"}}},
+				GroupBy: []Term{{Field: "time", Bucket: 5 * time.Minute}},
+				OrderBy: []Order{{Column: 0}},
+			},
+		},
+		{
+			// a field named like an aggregate, without a call
+			"SELECT count, attr.user.id AS 'user id'",
+			&Query{Select: []Column{{Term: Term{Field: "count"}}, {Term: Term{Field: "attr.user.id"}, Alias: "user id"}}},
+		},
+		{
+			"SELECT p99.9(latency)",
+			&Query{Select: []Column{{Agg: "percentile", Percentile: 0.999, Term: Term{Field: "latency"}}}},
+		},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseWhere(t *testing.T) {
+	// String shows how the condition was grouped
+	cases := []struct{ in, want string }{
+		{"status >= 500", "status >= 500"},
+		{"status <> 200", "status != 200"},
+		{"a = 1 OR b = 2 AND c = 3", "(a = 1 OR (b = 2 AND c = 3))"},
+		{"(a = 1 OR b = 2) AND c = 3", "((a = 1 OR b = 2) AND c = 3)"},
+		{"NOT a = 1 AND b = 2", "(NOT a = 1 AND b = 2)"},
+		{"NOT (a = 1 AND b = 2)", "NOT (a = 1 AND b = 2)"},
+		{"method IN ('GET', 'POST')", "method IN ('GET', 'POST')"},
+		{"method NOT IN ('GET')", "method NOT IN ('GET')"},
+		{"path LIKE '/api/%'", "path LIKE '/api/%'"},
+		{"path NOT LIKE '/health%'", "path NOT LIKE '/health%'"},
+		{"path ~ '^/v[0-9]+/' and path !~ 'x'", "(path ~ '^/v[0-9]+/' AND path !~ 'x')"},
+		{"message = 'it''s'", "message = 'it''s'"},
+		{`message = "say \"hi\""`, `message = 'say "hi"'`},
+		{"latency < 12.5", "latency < 12.5"},
+	}
+	for _, c := range cases {
+		q, err := Parse("SELECT path WHERE " + c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if got := q.Where.String(); got != c.want {
+			t.Errorf("%q parsed as %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"", "query: empty query"},
+		{"   ", "query: empty query"},
+		{"path", "query: expected SELECT at position 1"},
+		{"SELECT", "query: expected a field or function at end of query"},
+		{"SELECT path,", "query: expected a field or function at end of query"},
+		{"SELECT sum(*)", "query: only count accepts * at position 12"},
+		{"SELECT count(path", "query: expected ) at end of query"},
+		{"SELECT path AS 5", "query: expected alias at position 16"},
+		{"SELECT path WHERE", "query: expected a field at end of query"},
+		{"SELECT path WHERE status", "query: expected a comparison after status at end of query"},
+		{"SELECT path WHERE status 5", "query: expected a comparison after status at position 26"},
+		{"SELECT path WHERE path NOT = 'a'", "query: expected LIKE or IN after NOT at position 28"},
+		{"SELECT path WHERE method IN 'GET'", "query: expected ( after IN at position 29"},
+		{"SELECT path WHERE method IN ('GET'", "query: expected ) at end of query"},
+		{"SELECT path WHERE status = path", "query: expected a number or 'string' at position 28"},
+		{"SELECT path WHERE (status = 1", "query: expected ) at end of query"},
+		{"SELECT path GROUP path", "query: expected BY at position 19"},
+		{"SELECT path ORDER path", "query: expected BY at position 19"},
+		{"SELECT path ORDER BY 2", "query: ORDER BY position 2 is not a column at position 22"},
+		{"SELECT path ORDER BY status", "query: ORDER BY status is not a selected column"},
+		{"SELECT path LIMIT 0", "query: expected a positive LIMIT at position 19"},
+		{"SELECT path LIMIT 2.5", "query: expected a positive LIMIT at position 19"},
+		{"SELECT path LIMIT 5 5", "query: unexpected \"5\" at position 21"},
+		{"SELECT path path", "query: unexpected \"path\" at position 13"},
+		{"SELECT bucket(time 5m)", "query: expected , in bucket at position 20"},
+		{"SELECT bucket(time, 5)", "query: expected a duration like 5m in bucket at position 21"},
+		{"SELECT bucket(time, 5m", "query: expected ) at end of query"},
+		{"SELECT bucket(time, 1.5s)", "query: invalid duration \"1.5s\" at position 21"},
+		{"SELECT bucket(time, 5w)", "query: invalid duration \"5w\" at position 21"},
+		{"SELECT bucket(time, 0s)", "query: invalid duration \"0s\" at position 21"},
+		{"SELECT path WHERE status = 1.2.3", "query: invalid number \"1.2.3\" at position 28"},
+		{"SELECT path WHERE path = 'abc", "query: unterminated string at position 26"},
+		{`SELECT path WHERE path = "abc`, "query: unterminated string at position 26"},
+		{`SELECT path WHERE path = "\q"`, "query: invalid string at position 26"},
+		{"SELECT path WHERE path = ;", "query: unexpected ';' at position 26"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.in)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want %s", c.in, c.want)
+			continue
+		}
+		if err.Error() != c.want {
+			t.Errorf("Parse(%q):\n got %s\nwant %s", c.in, err, c.want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	q, err := Parse("SELECT count(*), p50(latency), p99.9(latency), bucket(time, 90s), bucket(time, 2h), avg(latency) AS mean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range q.Select {
+		names = append(names, c.Name())
+	}
+	want := []string{"count(*)", "p50(latency)", "p99.9(latency)", "bucket(time, 90s)", "bucket(time, 2h)", "mean"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names %q, want %q", names, want)
+	}
+}
+
+func TestAttrKey(t *testing.T) {
+	for field, want := range map[string]bool{
+		"attr.user":       true,
+		"attr.user.id":    true,
+		"attr._x":         true,
+		"attr.":           false,
+		"attr..x":         false,
+		"attr.a-b":        false,
+		"attributes.user": false,
+		"user":            false,
+	} {
+		if _, ok := AttrKey(field); ok != want {
+			t.Errorf("AttrKey(%q) = %v, want %v", field, ok, want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// server is a scripted RESP server on 127.0.0.1:0: reply returns the raw
+// RESP answer to each command.
+type server struct {
+	ln    net.Listener
+	reply func(cmd []string) string
+
+	mu    sync.Mutex
+	cmds  []string // "AUTH pw", "SELECT 2", ...
+	conns int
+}
+
+func newServer(t *testing.T, reply func(cmd []string) string) *server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{ln: ln, reply: reply}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		cmd, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.cmds = append(s.cmds, strings.Join(cmd, " "))
+		s.mu.Unlock()
+		answer := s.reply(cmd)
+		if answer == "" { // hang up
+			return
+		}
+		io.WriteString(conn, answer)
+	}
+}
+
+func (s *server) seen() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.cmds...), s.conns
+}
+
+// readCommand reads one RESP array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("not an array")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, n)
+	for i := range cmd {
+		head, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(head[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(buf[:size])
+	}
+	return cmd, nil
+}
+
+func ok(cmd []string) string {
+	if cmd[0] == "PING" {
+		return "+PONG\r\n"
+	}
+	return "+OK\r\n"
+}
+
+func TestConnectAuthSelect(t *testing.T) {
+	s := newServer(t, ok)
+	c := New(Config{Addr: s.ln.Addr().String(), Password: "pw", DB: 2})
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		reply, err := c.Do("PING")
+		if err != nil || reply != "PONG" {
+			t.Fatalf("PING = %v, %v", reply, err)
+		}
+	}
+	cmds, conns := s.seen()
+	// AUTH and SELECT once, on connect
+	if want := []string{"AUTH pw", "SELECT 2", "PING", "PING"}; !reflect.DeepEqual(cmds, want) || conns != 1 {
+		t.Errorf("commands %q on %d connections, want %q on 1", cmds, conns, want)
+	}
+}
+
+func TestConnectWithoutSetup(t *testing.T) {
+	s := newServer(t, ok)
+	c := New(Config{Addr: s.ln.Addr().String()})
+	defer c.Close()
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatal(err)
+	}
+	if cmds, _ := s.seen(); !reflect.DeepEqual(cmds, []string{"PING"}) {
+		t.Errorf("commands %q, want only PING", cmds)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	s := newServer(t, func(cmd []string) string {
+		if cmd[0] == "AUTH" {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		return "+OK\r\n"
+	})
+	c := New(Config{Addr: s.ln.Addr().String(), Password: "bad", DB: 1})
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Do("PING")
+		var e Error
+		if !errors.As(err, &e) || !strings.HasPrefix(string(e), "WRONGPASS") {
+			t.Fatalf("err = %v, want the WRONGPASS reply", err)
+		}
+	}
+	// the failed connection is dropped and dialed again
+	if _, conns := s.seen(); conns != 2 {
+		t.Errorf("%d connections, want 2", conns)
+	}
+}
+
+func TestReplies(t *testing.T) {
+	s := newServer(t, func(cmd []string) string {
+		switch cmd[0] {
+		case "SET":
+			return "+OK\r\n"
+		case "INCR":
+			return ":-5\r\n"
+		case "GET":
+			return "$5\r\nhe\r\no\r\n" // bulk strings may hold CRLF
+		case "MISSING":
+			return "$-1\r\n"
+		case "HGETALL":
+			return "*2\r\n$1\r\na\r\n*1\r\n:1\r\n"
+		case "NIL":
+			return "*-1\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	c := New(Config{Addr: s.ln.Addr().String()})
+	defer c.Close()
+
+	replies, err := c.Pipeline([][]string{{"SET", "k", "v"}, {"INCR", "k"}, {"GET", "k"}, {"MISSING"}, {"HGETALL", "h"}, {"NIL"}, {"BOGUS"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"OK", int64(-5), "he\r\no", nil, []interface{}{"a", []interface{}{int64(1)}}, nil, Error("ERR unknown command")}
+	if !reflect.DeepEqual(replies, want) {
+		t.Errorf("replies %#v\nwant %#v", replies, want)
+	}
+
+	// Do turns an error reply into an error
+	if _, err := c.Do("BOGUS"); fmt.Sprint(err) != "redis: ERR unknown command" {
+		t.Errorf("Do error = %v", err)
+	}
+}
+
+func TestReconnectAfterNetworkError(t *testing.T) {
+	calls := 0
+	var mu sync.Mutex
+	s := newServer(t, func(cmd []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 1 {
+			return "" // drop the connection without answering
+		}
+		return "+PONG\r\n"
+	})
+	c := New(Config{Addr: s.ln.Addr().String()})
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err == nil {
+		t.Fatal("no error when the server hung up")
+	}
+	if reply, err := c.Do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("after reconnect: %v, %v", reply, err)
+	}
+	if _, conns := s.seen(); conns != 2 {
+		t.Errorf("%d connections, want 2", conns)
+	}
+}
@@ -0,0 +1,63 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// MaxMessageSize bounds a single framed message.
+const MaxMessageSize = 64 * 1024
+
+// NewScanner splits a TCP stream into messages. Each frame is detected on
+// its own: "123 <PRI>..." is octet counting, anything else ends at a newline
+// (non-transparent framing).
+func NewScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4096), MaxMessageSize+16)
+	sc.Split(splitFrames)
+	return sc
+}
+
+func splitFrames(data []byte, atEOF bool) (int, []byte, error) {
+	// skip stray newlines between frames
+	start := 0
+	for start < len(data) && (data[start] == '\n' || data[start] == '\r') {
+		start++
+	}
+	data = data[start:]
+	if len(data) == 0 {
+		return start, nil, nil
+	}
+
+	if data[0] >= '1' && data[0] <= '9' {
+		sp := bytes.IndexByte(data, ' ')
+		if sp == -1 {
+			if atEOF || len(data) > 10 {
+				return 0, nil, errors.New("syslog: invalid octet count")
+			}
+			return 0, nil, nil
+		}
+		n, err := strconv.Atoi(string(data[:sp]))
+		if err != nil || n <= 0 || n > MaxMessageSize {
+			return 0, nil, errors.New("syslog: invalid octet count")
+		}
+		if len(data) < sp+1+n {
+			if atEOF {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, nil
+		}
+		return start + sp + 1 + n, data[sp+1 : sp+1+n], nil
+	}
+
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return start + i + 1, bytes.TrimRight(data[:i], "\r"), nil
+	}
+	if atEOF {
+		return start + len(data), data, nil
+	}
+	return start, nil, nil
+}
@@ -0,0 +1,76 @@
+package syslog
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func scanAll(s string) ([]string, error) {
+	sc := NewScanner(strings.NewReader(s))
+	var frames []string
+	for sc.Scan() {
+		frames = append(frames, sc.Text())
+	}
+	return frames, sc.Err()
+}
+
+func TestScanner(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"<13>a\n<13>b\n", []string{"<13>a", "<13>b"}},
+		{"<13>a\r\n<13>b", []string{"<13>a", "<13>b"}},
+		{"\n\r\n<13>a\n\n\n", []string{"<13>a"}},
+		// octet counting keeps newlines inside the frame
+		{"9 <13>a\nb c5 <13>d", []string{"<13>a\nb c", "<13>d"}},
+		// both framings on one connection
+		{"6 <13>ab<13>c\n4 <1>x\n", []string{"<13>ab", "<13>c", "<1>x"}},
+	}
+	for _, c := range cases {
+		got, err := scanAll(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: frames %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestScannerLargeFrame(t *testing.T) {
+	msg := "<13>" + strings.Repeat("x", MaxMessageSize-4)
+	got, err := scanAll(strconv.Itoa(len(msg)) + " " + msg + "5 <13>y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != msg || got[1] != "<13>y" {
+		t.Errorf("got %d frames", len(got))
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"10 <13>abc", io.ErrUnexpectedEOF.Error()},
+		{"<13>a\n9 <13>", io.ErrUnexpectedEOF.Error()},
+		{"12345", "syslog: invalid octet count"},
+		{"12345678901<13>a", "syslog: invalid octet count"},
+		{"1x <13>a", "syslog: invalid octet count"},
+		{strconv.Itoa(MaxMessageSize+1) + " <13>a", "syslog: invalid octet count"},
+		{"99999999999999999999 <13>a", "syslog: invalid octet count"},
+	}
+	for _, c := range cases {
+		_, err := scanAll(c.in)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%.40q: err = %v, want %s", c.in, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package syslog parses RFC 5424 and RFC 3164 (BSD) syslog messages and
+// splits TCP streams framed with octet counting or newlines (RFC 6587).
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+	Message        string
+	RFC            int // 5424 or 3164
+}
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+func (m *Message) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(facilityNames) {
+		return facilityNames[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+func (m *Message) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(severityNames) {
+		return severityNames[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+var ErrNoPriority = errors.New("syslog: missing <PRI>")
+
+// Parse detects the format from the header: "<PRI>1 " is RFC 5424,
+// anything else after <PRI> is treated as RFC 3164.
+func Parse(b []byte) (*Message, error) {
+	b = bytes.TrimRight(b, "\r\n\x00")
+	pri, rest, err := parsePriority(b)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Facility: pri / 8, Severity: pri % 8}
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		m.RFC = 5424
+		return m, parse5424(m, string(rest[2:]))
+	}
+	m.RFC = 3164
+	parse3164(m, string(rest), time.Now())
+	return m, nil
+}
+
+func parsePriority(b []byte) (int, []byte, error) {
+	if len(b) < 3 || b[0] != '<' {
+		return 0, nil, ErrNoPriority
+	}
+	end := bytes.IndexByte(b, '>')
+	if end < 2 || end > 4 {
+		return 0, nil, ErrNoPriority
+	}
+	// digits only: Atoi would also take "-1" and "+5"
+	pri := 0
+	for _, c := range b[1:end] {
+		if c < '0' || c > '9' {
+			return 0, nil, ErrNoPriority
+		}
+		pri = pri*10 + int(c-'0')
+	}
+	if pri > 191 {
+		return 0, nil, ErrNoPriority
+	}
+	return pri, b[end+1:], nil
+}
+
+// TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func parse5424(m *Message, s string) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		var ok bool
+		fields[i], s, ok = strings.Cut(s, " ")
+		if !ok && i < len(fields)-1 {
+			return errors.New("syslog: truncated RFC 5424 header")
+		}
+	}
+	if fields[0] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return errors.New("syslog: invalid RFC 5424 timestamp")
+		}
+		m.Timestamp = t
+	}
+	m.Hostname = nilValue(fields[1])
+	m.AppName = nilValue(fields[2])
+	m.ProcID = nilValue(fields[3])
+	m.MsgID = nilValue(fields[4])
+
+	sd, msg := splitStructuredData(s)
+	if sd != "-" {
+		m.StructuredData = sd
+	}
+	msg = strings.TrimPrefix(msg, "\ufeff") // BOM before UTF-8 MSG
+	m.Message = msg
+	return nil
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// splitStructuredData returns "-" or the [..][..] elements, and the message.
+// Param values may contain escaped \] and \" characters.
+func splitStructuredData(s string) (string, string) {
+	if strings.HasPrefix(s, "-") {
+		return "-", strings.TrimPrefix(s[1:], " ")
+	}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		inQuote := false
+		for i++; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && inQuote {
+				i++
+				continue
+			}
+			if c == '"' {
+				inQuote = !inQuote
+			}
+			if c == ']' && !inQuote {
+				i++
+				break
+			}
+		}
+	}
+	return s[:i], strings.TrimPrefix(s[i:], " ")
+}
+
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG — hostname is optional in practice
+func parse3164(m *Message, s string, now time.Time) {
+	const stampLen = len("Jan _2 15:04:05")
+	if len(s) > stampLen {
+		if t, err := time.ParseInLocation(time.Stamp, s[:stampLen], time.Local); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// no year in the header: a date far in the future belongs to last year
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			s = strings.TrimPrefix(s[stampLen:], " ")
+		}
+	}
+
+	first, rest, _ := strings.Cut(s, " ")
+	if !isTag(first) {
+		m.Hostname = first
+		s = rest
+	}
+
+	tag, msg, ok := strings.Cut(s, ": ")
+	if ok && isTag(tag+":") {
+		if open := strings.IndexByte(tag, '['); open != -1 && strings.HasSuffix(tag, "]") {
+			m.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		m.AppName = tag
+		s = msg
+	}
+	m.Message = s
+}
+
+// isTag reports whether a token looks like "app:" or "app[123]:"
+func isTag(tok string) bool {
+	if !strings.HasSuffix(tok, ":") || len(tok) < 2 || len(tok) > 64 {
+		return false
+	}
+	return !strings.ContainsAny(tok[:len(tok)-1], " ")
+}
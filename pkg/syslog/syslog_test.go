@@ -0,0 +1,155 @@
+package syslog
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse5424(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Message
+	}{
+		{
+			"<165>1 2026-03-01T12:00:00.123Z web01 nginx 4321 ID47 - GET /api 200",
+			Message{Facility: 20, Severity: 5, Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 123e6, time.UTC),
+				Hostname: "web01", AppName: "nginx", ProcID: "4321", MsgID: "ID47", Message: "GET /api 200", RFC: 5424},
+		},
+		{
+			// every header field nil, no message
+			"<0>1 - - - - - -",
+			Message{RFC: 5424},
+		},
+		{
+			`<14>1 - host app - - [ex@1 a="x\]y" b="q\"]"][ts@2 n="1"] hi`,
+			Message{Facility: 1, Severity: 6, Hostname: "host", AppName: "app",
+				StructuredData: `[ex@1 a="x\]y" b="q\"]"][ts@2 n="1"]`, Message: "hi", RFC: 5424},
+		},
+		{
+			"<14>1 - - - - - - \ufeffutf-8 msg\r\n",
+			Message{Facility: 1, Severity: 6, Message: "utf-8 msg", RFC: 5424},
+		},
+		{
+			// an unterminated element keeps the rest as structured data
+			`<14>1 - - - - - [ex@1 a="x`,
+			Message{Facility: 1, Severity: 6, StructuredData: `[ex@1 a="x`, RFC: 5424},
+		},
+	}
+	for _, c := range cases {
+		m, err := Parse([]byte(c.in))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, c.want) {
+			t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", c.in, *m, c.want)
+		}
+	}
+}
+
+func TestParse3164(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	cases := []struct {
+		in   string
+		want Message
+	}{
+		{
+			"Mar  1 11:59:30 web01 sshd[812]: Accepted publickey for ann",
+			Message{Timestamp: time.Date(2026, 3, 1, 11, 59, 30, 0, time.Local),
+				Hostname: "web01", AppName: "sshd", ProcID: "812", Message: "Accepted publickey for ann"},
+		},
+		{
+			// no hostname
+			"Feb 28 23:00:00 cron: job done",
+			Message{Timestamp: time.Date(2026, 2, 28, 23, 0, 0, 0, time.Local), AppName: "cron", Message: "job done"},
+		},
+		{
+			// more than a day ahead: last year's December
+			"Dec 31 23:59:59 host app: late",
+			Message{Timestamp: time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local), Hostname: "host", AppName: "app", Message: "late"},
+		},
+		{
+			// no timestamp, no tag
+			"host just text",
+			Message{Hostname: "host", Message: "just text"},
+		},
+		{
+			"kernel: oops",
+			Message{AppName: "kernel", Message: "oops"},
+		},
+		{"", Message{}},
+	}
+	for _, c := range cases {
+		var m Message
+		parse3164(&m, c.in, now)
+		if !reflect.DeepEqual(m, c.want) {
+			t.Errorf("parse3164(%q) =\n%+v\nwant\n%+v", c.in, m, c.want)
+		}
+	}
+
+	m, err := Parse([]byte("<13>host app: x"))
+	if err != nil || m.RFC != 3164 || m.Facility != 1 || m.Severity != 5 || m.AppName != "app" {
+		t.Errorf("Parse 3164 = %+v, %v", m, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"", ErrNoPriority.Error()},
+		{"no priority", ErrNoPriority.Error()},
+		{"<>1 - - - - - -", ErrNoPriority.Error()},
+		{"<-1>1 - - - - - -", ErrNoPriority.Error()},
+		{"<+5>host app: x", ErrNoPriority.Error()},
+		{"< 5>host app: x", ErrNoPriority.Error()},
+		{"<192>host app: x", ErrNoPriority.Error()},
+		{"<1000>host app: x", ErrNoPriority.Error()},
+		{"<13", ErrNoPriority.Error()},
+		{"<13 host app: x", ErrNoPriority.Error()},
+		{"<14>1 2026-03-01T12:00:00Z host app", "syslog: truncated RFC 5424 header"},
+		{"<14>1 ", "syslog: truncated RFC 5424 header"},
+		{"<14>1 yesterday host app - - -", "syslog: invalid RFC 5424 timestamp"},
+		{"<14>1 Mar 1 12:00:00 host app - - -", "syslog: invalid RFC 5424 timestamp"},
+	}
+	for _, c := range cases {
+		m, err := Parse([]byte(c.in))
+		if err == nil {
+			t.Errorf("Parse(%q) = %+v, want %s", c.in, m, c.want)
+			continue
+		}
+		if err.Error() != c.want {
+			t.Errorf("Parse(%q): %v, want %s", c.in, err, c.want)
+		}
+	}
+}
+
+func TestPriorityBounds(t *testing.T) {
+	for in, want := range map[string][2]int{"<0>x": {0, 0}, "<191>x": {23, 7}, "<007>x": {0, 7}} {
+		m, err := Parse([]byte(in))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if m.Facility != want[0] || m.Severity != want[1] {
+			t.Errorf("Parse(%q) = %d/%d, want %d/%d", in, m.Facility, m.Severity, want[0], want[1])
+		}
+	}
+	if !errors.Is(func() error { _, err := Parse([]byte("<-1>x")); return err }(), ErrNoPriority) {
+		t.Error("negative priority accepted")
+	}
+}
+
+func TestNames(t *testing.T) {
+	m := Message{Facility: 4, Severity: 3}
+	if m.FacilityName() != "auth" || m.SeverityName() != "err" {
+		t.Errorf("names %s/%s", m.FacilityName(), m.SeverityName())
+	}
+	m = Message{Facility: 30, Severity: 9}
+	if m.FacilityName() != "30" || m.SeverityName() != "9" {
+		t.Errorf("names %s/%s", m.FacilityName(), m.SeverityName())
+	}
+}
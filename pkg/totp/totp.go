@@ -1,6 +1,7 @@
 package totp
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"time"
 
@@ -19,16 +20,41 @@ func GenerateKeyString(issuer, accountName string) (secret string, otpauthURL st
 	return key.Secret(), key.URL(), nil
 }
 
+// Period is the length of a time step in seconds
+const Period = 30
+
+var opts = totp.ValidateOpts{
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+	Period:    Period,
+}
+
 func ValidateCode(code, secret string) (bool, error) {
-	return totp.ValidateCustom(code, secret, time.Now().UTC(), totp.ValidateOpts{
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
-		Period:    30,
-	})
+	_, ok, err := Validate(code, secret, time.Now(), 1)
+	return ok, err
+}
+
+// Validate checks code against the time steps within skew steps of t and
+// returns the step that matched, so callers can refuse a step used before.
+func Validate(code, secret string, t time.Time, skew uint) (step int64, ok bool, err error) {
+	if len(code) != int(opts.Digits) {
+		return 0, false, nil
+	}
+	now := t.Unix() / Period
+	for i := -int64(skew); i <= int64(skew); i++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix((now+i)*Period, 0).UTC(), opts)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return now + i, true, nil
+		}
+	}
+	return 0, false, nil
 }
 
 // Optional helper to get otpauth URI manually
 // otpauth://totp/{issuer}:{account}?secret={secret}&issuer={issuer}
 func KeyUri(issuer, account, secret string) string {
-	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s", issuer, account, secret)
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s", issuer, account, secret, issuer)
 }
@@ -0,0 +1,154 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readBack unzips the workbook and returns its files by name.
+func readBack(t *testing.T, w *Workbook) map[string][]byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := w.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(b))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+		files[f.Name] = b
+	}
+	return files
+}
+
+func TestRoundTrip(t *testing.T) {
+	w := New()
+	s := w.AddSheet("Data")
+	s.AddHeader("name", "value")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.AddRow(`<a & "b">`, 1.5, -3, int64(7), uint(8), at, nil, true)
+	s.AddRow("NaN", math.NaN(), math.Inf(1), math.Inf(-1), " padded ", "=1+1")
+
+	files := readBack(t, w)
+	var got sheetXML
+	if err := xml.Unmarshal(files["xl/worksheets/sheet1.xml"], &got); err != nil {
+		t.Fatal(err)
+	}
+
+	type cell struct{ ref, typ, style, value string }
+	var cells [][]cell
+	for _, r := range got.Rows {
+		var row []cell
+		for _, c := range r.Cells {
+			v := c.Value
+			if c.Type == "inlineStr" {
+				v = c.Inline
+			}
+			row = append(row, cell{c.Ref, c.Type, c.Style, v})
+		}
+		cells = append(cells, row)
+	}
+	want := [][]cell{
+		{{"A1", "inlineStr", "1", "name"}, {"B1", "inlineStr", "1", "value"}},
+		{
+			{"A2", "inlineStr", "", `<a & "b">`}, {"B2", "", "", "1.5"}, {"C2", "", "", "-3"}, {"D2", "", "", "7"},
+			{"E2", "", "", "8"}, {"F2", "inlineStr", "", "2026-03-01T12:00:00Z"}, {"G2", "inlineStr", "", ""}, {"H2", "inlineStr", "", "true"},
+		},
+		{
+			// Excel rejects <v>NaN</v>: not-a-number values are text
+			{"A3", "inlineStr", "", "NaN"}, {"B3", "inlineStr", "", "NaN"}, {"C3", "inlineStr", "", "+Inf"}, {"D3", "inlineStr", "", "-Inf"},
+			{"E3", "inlineStr", "", " padded "}, {"F3", "inlineStr", "", "=1+1"},
+		},
+	}
+	if !reflect.DeepEqual(cells, want) {
+		t.Errorf("cells\n%v\nwant\n%v", cells, want)
+	}
+}
+
+func TestSheets(t *testing.T) {
+	w := New()
+	w.AddSheet("a/b[c]:d*e?f\\g")
+	w.AddSheet("")
+	w.AddSheet("0123456789012345678901234567890123456789")
+	w.AddSheet("R&D <2026>")
+	w.AddSheet("Ringkasan analisis — 2026 — 日本語のシート")
+	files := readBack(t, w)
+
+	var wb struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(files["xl/workbook.xml"], &wb); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+	}
+	want := []string{"a_b_c__d_e_f_g", "Sheet2", "0123456789012345678901234567890", "R&D <2026>", "Ringkasan analisis — 2026 — 日本語"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sheet names %q, want %q", names, want)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, ok := files["xl/worksheets/sheet"+string(rune('0'+i))+".xml"]; !ok {
+			t.Errorf("sheet %d missing", i)
+		}
+	}
+}
+
+func TestEmptyWorkbook(t *testing.T) {
+	files := readBack(t, New())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s missing", name)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}